@@ -28,6 +28,7 @@ import (
 
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/annotations"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/authz"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deployment"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deprecation"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/gateway"
@@ -80,6 +81,29 @@ var testGrid = []testCase{
 			{msg.MisplacedAnnotation, "Namespace staging"},
 		},
 	},
+	{
+		name:           "authorizationPolicies",
+		inputFiles:     []string{"testdata/authorizationpolicies.yaml"},
+		meshConfigFile: "testdata/mesh-with-trustdomain-aliases.yaml",
+		analyzer:       &authz.AuthorizationPoliciesAnalyzer{FederatedTrustDomains: []string{"td-federated"}},
+		expected: []message{
+			{msg.NoMatchingWorkloadsFound, "AuthorizationPolicy no-matching-workloads.httpbin"},
+			{msg.NoMatchingWorkloadsFound, "AuthorizationPolicy meshwide-no-matching-workloads.istio-system"},
+			{msg.AuthorizationPolicyNamespaceNotFound, "AuthorizationPolicy unknown-namespace.httpbin"},
+			{msg.AuthorizationPolicyNamespaceNotFound, "AuthorizationPolicy unknown-namespace.httpbin"},
+			{msg.AuthorizationPolicyNamespaceNotFound, "AuthorizationPolicy unknown-namespace.httpbin"},
+			{msg.AuthorizationPolicyUnknownTrustDomain, "AuthorizationPolicy unknown-trust-domain.httpbin"},
+			{msg.AuthorizationPolicyUnknownTrustDomain, "AuthorizationPolicy unknown-trust-domain.httpbin"},
+			{msg.AuthorizationPolicyPortNotExposed, "AuthorizationPolicy port-not-exposed.httpbin"},
+			{msg.AuthorizationPolicyPortNotExposed, "AuthorizationPolicy port-not-exposed.httpbin"},
+		},
+	},
+	{
+		name:       "authorizationPoliciesWithoutPods",
+		inputFiles: []string{"testdata/authorizationpolicies-no-pods.yaml"},
+		analyzer:   &authz.AuthorizationPoliciesAnalyzer{},
+		expected:   []message{},
+	},
 	{
 		name:       "deprecation",
 		inputFiles: []string{"testdata/deprecation.yaml"},
@@ -282,6 +306,16 @@ var testGrid = []testCase{
 			{msg.InvalidRegexp, "VirtualService lots-of-regexes"},
 		},
 	},
+	{
+		name:       "shadowedRoutes",
+		inputFiles: []string{"testdata/virtualservice_shadowedroutes.yaml"},
+		analyzer:   &virtualservice.ShadowedRouteAnalyzer{},
+		expected: []message{
+			{msg.VirtualServiceShadowedRoute, "VirtualService catch-all-first"},
+			{msg.VirtualServiceShadowedRoute, "VirtualService prefix-covers-exact"},
+			{msg.VirtualServiceShadowedRoute, "VirtualService headers"},
+		},
+	},
 	{
 		name: "unknown service registry in mesh networks",
 		inputFiles: []string{
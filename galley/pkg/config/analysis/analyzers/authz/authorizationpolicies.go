@@ -0,0 +1,231 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/mesh/v1alpha1"
+	"istio.io/api/security/v1beta1"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// AuthorizationPoliciesAnalyzer checks, for every authorization policy, that:
+// * its selector matches at least one pod
+// * the source namespaces it refers to exist
+// * the trust domains of the principals it refers to are known: the mesh one, its aliases or federated ones
+// * the ports it refers to are exposed by the workloads it applies to
+//
+// The negated fields are checked as well: a typo there makes the policy silently match more requests.
+type AuthorizationPoliciesAnalyzer struct {
+	// FederatedTrustDomains are the trust domains federated with the mesh. They aren't part of the mesh config,
+	// so they must be set by the caller, e.g. with the --federated-trust-domains flag of istioctl analyze.
+	FederatedTrustDomains []string
+}
+
+var _ analysis.Analyzer = &AuthorizationPoliciesAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *AuthorizationPoliciesAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "authz.AuthorizationPoliciesAnalyzer",
+		Description: "Checks that authorization policies refer to existing workloads, namespaces, trust domains and ports",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+			collections.K8SCoreV1Namespaces.Name(),
+			collections.K8SCoreV1Pods.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *AuthorizationPoliciesAnalyzer) Analyze(c analysis.Context) {
+	meshConfig := fetchMeshConfig(c)
+	// Federated trust domains are matched like aliases.
+	trustDomains := append([]string{}, meshConfig.GetTrustDomainAliases()...)
+	trustDomains = append(trustDomains, a.FederatedTrustDomains...)
+	bundle := trustdomain.NewBundle(meshConfig.GetTrustDomain(), trustDomains)
+
+	var namespaces []string
+	c.ForEach(collections.K8SCoreV1Namespaces.Name(), func(r *resource.Instance) bool {
+		namespaces = append(namespaces, r.Metadata.FullName.Name.String())
+		return true
+	})
+
+	// Without any pod, e.g. when only analyzing files, there's nothing to check the selectors and ports against.
+	hasPods := false
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		hasPods = true
+		return false
+	})
+
+	c.ForEach(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), func(r *resource.Instance) bool {
+		if hasPods {
+			a.analyzeSelector(r, c, meshConfig)
+		}
+		a.analyzeSources(r, c, meshConfig, bundle, namespaces)
+		return true
+	})
+}
+
+func (a *AuthorizationPoliciesAnalyzer) analyzeSelector(r *resource.Instance, c analysis.Context, meshConfig *v1alpha1.MeshConfig) {
+	ap := r.Message.(*v1beta1.AuthorizationPolicy)
+
+	pods := selectedPods(r, c, meshConfig.GetRootNamespace())
+	matchLabels := ap.GetSelector().GetMatchLabels()
+	if len(matchLabels) > 0 && len(pods) == 0 {
+		m := msg.NewNoMatchingWorkloadsFound(r, labels.SelectorFromSet(matchLabels).String())
+		m.Path = "spec.selector"
+		c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), m)
+		return
+	}
+
+	// Pods don't have to declare the ports they listen on, so only check the ports if all the pods declare them.
+	exposed := make(map[int32]bool)
+	for _, pod := range pods {
+		declared := false
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				exposed[port.ContainerPort] = true
+				declared = true
+			}
+		}
+		if !declared {
+			return
+		}
+	}
+	if len(exposed) == 0 {
+		return
+	}
+
+	for i, rule := range ap.GetRules() {
+		for j, to := range rule.GetTo() {
+			check := func(field string, ports []string) {
+				for k, port := range ports {
+					p, err := strconv.ParseInt(port, 10, 32)
+					if err != nil || exposed[int32(p)] {
+						continue
+					}
+					m := msg.NewAuthorizationPolicyPortNotExposed(r, port)
+					m.Path = fmt.Sprintf("spec.rules[%d].to[%d].operation.%s[%d]", i, j, field, k)
+					c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), m)
+				}
+			}
+			check("ports", to.GetOperation().GetPorts())
+			check("notPorts", to.GetOperation().GetNotPorts())
+		}
+	}
+}
+
+func (a *AuthorizationPoliciesAnalyzer) analyzeSources(r *resource.Instance, c analysis.Context, meshConfig *v1alpha1.MeshConfig,
+	bundle trustdomain.Bundle, namespaces []string) {
+	ap := r.Message.(*v1beta1.AuthorizationPolicy)
+
+	for i, rule := range ap.GetRules() {
+		for j, from := range rule.GetFrom() {
+			checkNamespaces := func(field string, nss []string) {
+				for k, ns := range nss {
+					// Without any namespace, e.g. when only analyzing files, there's nothing to check against.
+					if len(namespaces) == 0 || matchesAnyNamespace(ns, namespaces) {
+						continue
+					}
+					m := msg.NewAuthorizationPolicyNamespaceNotFound(r, ns)
+					m.Path = fmt.Sprintf("spec.rules[%d].from[%d].source.%s[%d]", i, j, field, k)
+					c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), m)
+				}
+			}
+			checkNamespaces("namespaces", from.GetSource().GetNamespaces())
+			checkNamespaces("notNamespaces", from.GetSource().GetNotNamespaces())
+
+			checkPrincipals := func(field string, principals []string) {
+				for k, principal := range principals {
+					if bundle.MatchesTrustDomain(principal) {
+						continue
+					}
+					m := msg.NewAuthorizationPolicyUnknownTrustDomain(r, principal, meshConfig.GetTrustDomain())
+					m.Path = fmt.Sprintf("spec.rules[%d].from[%d].source.%s[%d]", i, j, field, k)
+					c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), m)
+				}
+			}
+			checkPrincipals("principals", from.GetSource().GetPrincipals())
+			checkPrincipals("notPrincipals", from.GetSource().GetNotPrincipals())
+		}
+	}
+}
+
+// selectedPods returns the pods the policy applies to. Policies in the root namespace apply to pods in all namespaces.
+func selectedPods(r *resource.Instance, c analysis.Context, rootNamespace string) []*v1.Pod {
+	ap := r.Message.(*v1beta1.AuthorizationPolicy)
+	ns := r.Metadata.FullName.Namespace
+	sel := labels.SelectorFromSet(ap.GetSelector().GetMatchLabels())
+
+	var pods []*v1.Pod
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(rp *resource.Instance) bool {
+		if ns.String() != rootNamespace && rp.Metadata.FullName.Namespace != ns {
+			return true
+		}
+		pod := rp.Message.(*v1.Pod)
+		if sel.Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			pods = append(pods, pod)
+		}
+		return true
+	})
+	return pods
+}
+
+func fetchMeshConfig(c analysis.Context) *v1alpha1.MeshConfig {
+	var meshConfig *v1alpha1.MeshConfig
+	c.ForEach(collections.IstioMeshV1Alpha1MeshConfig.Name(), func(r *resource.Instance) bool {
+		meshConfig = r.Message.(*v1alpha1.MeshConfig)
+		return false
+	})
+	if meshConfig == nil {
+		defaults := mesh.DefaultMeshConfig()
+		meshConfig = &defaults
+	}
+	return meshConfig
+}
+
+// matchesAnyNamespace returns true if the namespace pattern, which may be "*" or have a "*" prefix or suffix,
+// matches one of the namespaces.
+func matchesAnyNamespace(pattern string, namespaces []string) bool {
+	if pattern == "*" {
+		return true
+	}
+	for _, ns := range namespaces {
+		switch {
+		case pattern == ns:
+			return true
+		case strings.HasPrefix(pattern, "*") && strings.HasSuffix(ns, strings.TrimPrefix(pattern, "*")):
+			return true
+		case strings.HasSuffix(pattern, "*") && strings.HasPrefix(ns, strings.TrimSuffix(pattern, "*")):
+			return true
+		}
+	}
+	return false
+}
@@ -19,6 +19,7 @@ import (
 
 	"istio.io/api/networking/v1alpha3"
 	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
@@ -55,18 +56,40 @@ func (*FieldAnalyzer) analyzeVirtualService(r *resource.Instance, ctx analysis.C
 
 	vs := r.Message.(*v1alpha3.VirtualService)
 
-	for _, httpRoute := range vs.Http {
+	for i, httpRoute := range vs.Http {
 		if httpRoute.Fault != nil {
 			if httpRoute.Fault.Delay != nil {
 				if httpRoute.Fault.Delay.Percent > 0 {
-					ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
-						msg.NewDeprecated(r, replacedMessage("HTTPRoute.fault.delay.percent", "HTTPRoute.fault.delay.percentage")))
+					m := msg.NewDeprecated(r, replacedMessage("HTTPRoute.fault.delay.percent", "HTTPRoute.fault.delay.percentage"))
+					m.Path = fmt.Sprintf("spec.http[%d].fault.delay.percent", i)
+					m.Fix = delayPercentFix(i, httpRoute.Fault.Delay)
+					ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), m)
 				}
 			}
 		}
 	}
 }
 
+// delayPercentFix suggests replacing HTTPRoute.fault.delay.percent with the equivalent percentage, unless the
+// percentage is already set, in which case percent is ignored and can simply be removed.
+func delayPercentFix(i int, delay *v1alpha3.HTTPFaultInjection_Delay) *diag.Fix {
+	fix := &diag.Fix{
+		Description: "Remove HTTPRoute.fault.delay.percent",
+		Patch: []diag.PatchOperation{
+			{Op: "remove", Path: diag.JSONPointer("spec", "http", i, "fault", "delay", "percent")},
+		},
+	}
+	if delay.Percentage == nil {
+		fix.Description = fmt.Sprintf("Replace HTTPRoute.fault.delay.percent with percentage.value %d", delay.Percent)
+		fix.Patch = append(fix.Patch, diag.PatchOperation{
+			Op:    "add",
+			Path:  diag.JSONPointer("spec", "http", i, "fault", "delay", "percentage"),
+			Value: map[string]interface{}{"value": delay.Percent},
+		})
+	}
+	return fix
+}
+
 func replacedMessage(deprecated, replacement string) string {
 	return fmt.Sprintf("%s is deprecated; use %s", deprecated, replacement)
 }
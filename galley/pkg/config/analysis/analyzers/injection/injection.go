@@ -15,6 +15,7 @@
 package injection
 
 import (
+	"fmt"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
@@ -25,6 +26,7 @@ import (
 
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
@@ -77,7 +79,9 @@ func (a *Analyzer) Analyze(c analysis.Context) {
 			// TODO: if Istio is installed with sidecarInjectorWebhook.enableNamespacesByDefault=true
 			// (in the istio-sidecar-injector configmap), we need to reverse this logic and treat this as an injected namespace
 
-			c.Report(collections.K8SCoreV1Namespaces.Name(), msg.NewNamespaceNotInjected(r, r.Metadata.FullName.String(), r.Metadata.FullName.String()))
+			m := msg.NewNamespaceNotInjected(r, r.Metadata.FullName.String(), r.Metadata.FullName.String())
+			m.Fix = injectionLabelFix(r)
+			c.Report(collections.K8SCoreV1Namespaces.Name(), m)
 			return true
 		}
 
@@ -126,3 +130,20 @@ func (a *Analyzer) Analyze(c analysis.Context) {
 		return true
 	})
 }
+
+// injectionLabelFix suggests enabling injection in the namespace with the istio-injection label.
+func injectionLabelFix(r *resource.Instance) *diag.Fix {
+	op := diag.PatchOperation{
+		Op:    "add",
+		Path:  diag.JSONPointer("metadata", "labels", InjectionLabelName),
+		Value: InjectionLabelEnableValue,
+	}
+	if len(r.Metadata.Labels) == 0 {
+		op.Path = diag.JSONPointer("metadata", "labels")
+		op.Value = map[string]string{InjectionLabelName: InjectionLabelEnableValue}
+	}
+	return &diag.Fix{
+		Description: fmt.Sprintf("Label the namespace with %s=%s", InjectionLabelName, InjectionLabelEnableValue),
+		Patch:       []diag.PatchOperation{op},
+	}
+}
@@ -15,8 +15,11 @@
 package service
 
 import (
+	"fmt"
+
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	configKube "istio.io/istio/pkg/config/kube"
 	"istio.io/istio/pkg/config/resource"
@@ -29,6 +32,14 @@ import (
 // PortNameAnalyzer checks the port name of the service
 type PortNameAnalyzer struct{}
 
+// The protocols commonly used on some ports, used to suggest port names.
+var wellKnownPortProtocols = map[int32]string{
+	80:   "http",
+	443:  "https",
+	8080: "http",
+	8443: "https",
+}
+
 var _ analysis.Analyzer = &PortNameAnalyzer{}
 
 // Metadata implements Analyzer
@@ -64,10 +75,30 @@ func (s *PortNameAnalyzer) Analyze(c analysis.Context) {
 
 func (s *PortNameAnalyzer) analyzeService(r *resource.Instance, c analysis.Context) {
 	svc := r.Message.(*v1.ServiceSpec)
-	for _, port := range svc.Ports {
+	for i, port := range svc.Ports {
 		if instance := configKube.ConvertProtocol(port.Port, port.Name, port.Protocol, port.AppProtocol); instance.IsUnsupported() {
-			c.Report(collections.K8SCoreV1Services.Name(), msg.NewPortNameIsNotUnderNamingConvention(
-				r, port.Name, int(port.Port), port.TargetPort.String()))
+			m := msg.NewPortNameIsNotUnderNamingConvention(r, port.Name, int(port.Port), port.TargetPort.String())
+			m.Path = fmt.Sprintf("spec.ports[%d]", i)
+			m.Fix = portNameFix(i, port)
+			c.Report(collections.K8SCoreV1Services.Name(), m)
 		}
 	}
 }
+
+// portNameFix suggests a port name prefixed with the protocol commonly used on the port, if there is one.
+func portNameFix(i int, port v1.ServicePort) *diag.Fix {
+	proto, ok := wellKnownPortProtocols[port.Port]
+	if !ok || port.AppProtocol != nil {
+		return nil
+	}
+	name := proto
+	if port.Name != "" {
+		name = proto + "-" + port.Name
+	}
+	return &diag.Fix{
+		Description: fmt.Sprintf("Rename the port to %q", name),
+		Patch: []diag.PatchOperation{
+			{Op: "add", Path: diag.JSONPointer("spec", "ports", i, "name"), Value: name},
+		},
+	}
+}
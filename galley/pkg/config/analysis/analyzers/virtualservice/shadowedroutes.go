@@ -0,0 +1,218 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package virtualservice
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// ShadowedRouteAnalyzer checks for HTTP matches that can never be used because an earlier route in the same
+// virtual service matches all of their requests.
+type ShadowedRouteAnalyzer struct{}
+
+var _ analysis.Analyzer = &ShadowedRouteAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *ShadowedRouteAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "virtualservice.ShadowedRouteAnalyzer",
+		Description: "Checks for HTTP routes that are shadowed by earlier routes",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (a *ShadowedRouteAnalyzer) Analyze(ctx analysis.Context) {
+	ctx.ForEach(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), func(r *resource.Instance) bool {
+		a.analyzeVirtualService(r, ctx)
+		return true
+	})
+}
+
+func (a *ShadowedRouteAnalyzer) analyzeVirtualService(r *resource.Instance, ctx analysis.Context) {
+	vs := r.Message.(*v1alpha3.VirtualService)
+
+	routes := vs.GetHttp()
+	for j, route := range routes {
+		matches := route.GetMatch()
+		if len(matches) == 0 {
+			// A route without matches is a catch-all.
+			matches = []*v1alpha3.HTTPMatchRequest{{}}
+		}
+
+		for k, m := range matches {
+			for i := 0; i < j; i++ {
+				if !routeCovers(routes[i], m) {
+					continue
+				}
+
+				where := fmt.Sprintf("http[%d]", j)
+				if len(route.GetMatch()) > 0 {
+					where = fmt.Sprintf("%s.match[%d]", where, k)
+				}
+				message := msg.NewVirtualServiceShadowedRoute(r, describeRoute(where, route), describeRoute(fmt.Sprintf("http[%d]", i), routes[i]))
+				message.Path = "spec." + where
+				ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), message)
+				break
+			}
+		}
+	}
+}
+
+func describeRoute(where string, route *v1alpha3.HTTPRoute) string {
+	if route.GetName() == "" {
+		return where
+	}
+	return fmt.Sprintf("%s (%s)", where, route.GetName())
+}
+
+// routeCovers returns true if the route matches every request matched by m.
+func routeCovers(route *v1alpha3.HTTPRoute, m *v1alpha3.HTTPMatchRequest) bool {
+	if len(route.GetMatch()) == 0 {
+		return true
+	}
+	for _, rm := range route.GetMatch() {
+		if matchCovers(rm, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCovers returns true if a matches every request matched by b. It errs on the side of returning false for
+// combinations it can't decide, such as overlapping regexes.
+func matchCovers(a, b *v1alpha3.HTTPMatchRequest) bool {
+	if a.GetUri() != nil {
+		// A case sensitive uri match can't cover a case insensitive one.
+		if b.GetIgnoreUriCase() && !a.GetIgnoreUriCase() {
+			return false
+		}
+		if !stringMatchCovers(a.GetUri(), b.GetUri(), a.GetIgnoreUriCase()) {
+			return false
+		}
+	}
+	if !stringMatchCovers(a.GetScheme(), b.GetScheme(), false) ||
+		!stringMatchCovers(a.GetMethod(), b.GetMethod(), false) ||
+		!stringMatchCovers(a.GetAuthority(), b.GetAuthority(), false) {
+		return false
+	}
+	if !stringMatchesCover(a.GetHeaders(), b.GetHeaders()) || !stringMatchesCover(a.GetQueryParams(), b.GetQueryParams()) {
+		return false
+	}
+	// b must exclude at least every request a excludes.
+	for name, av := range a.GetWithoutHeaders() {
+		bv, ok := b.GetWithoutHeaders()[name]
+		if !ok || !stringMatchCovers(bv, av, false) {
+			return false
+		}
+	}
+
+	if a.GetPort() != 0 && a.GetPort() != b.GetPort() {
+		return false
+	}
+	for k, v := range a.GetSourceLabels() {
+		if bv, ok := b.GetSourceLabels()[k]; !ok || bv != v {
+			return false
+		}
+	}
+	if a.GetSourceNamespace() != "" && a.GetSourceNamespace() != b.GetSourceNamespace() {
+		return false
+	}
+	if len(a.GetGateways()) > 0 {
+		if len(b.GetGateways()) == 0 {
+			return false
+		}
+		for _, gw := range b.GetGateways() {
+			if !containsString(a.GetGateways(), gw) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// stringMatchesCover returns true if, for every key in a, b has the key and a's match covers b's.
+func stringMatchesCover(a, b map[string]*v1alpha3.StringMatch) bool {
+	for name, av := range a {
+		bv, ok := b[name]
+		if !ok || !stringMatchCovers(av, bv, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// stringMatchCovers returns true if a matches every string matched by b. A nil match matches everything.
+func stringMatchCovers(a, b *v1alpha3.StringMatch, ignoreCase bool) bool {
+	if a == nil {
+		return true
+	}
+	if b == nil {
+		return false
+	}
+
+	normalize := func(s string) string {
+		if ignoreCase {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+
+	switch am := a.GetMatchType().(type) {
+	case *v1alpha3.StringMatch_Exact:
+		if bm, ok := b.GetMatchType().(*v1alpha3.StringMatch_Exact); ok {
+			return normalize(bm.Exact) == normalize(am.Exact)
+		}
+	case *v1alpha3.StringMatch_Prefix:
+		switch bm := b.GetMatchType().(type) {
+		case *v1alpha3.StringMatch_Exact:
+			return strings.HasPrefix(normalize(bm.Exact), normalize(am.Prefix))
+		case *v1alpha3.StringMatch_Prefix:
+			return strings.HasPrefix(normalize(bm.Prefix), normalize(am.Prefix))
+		}
+	case *v1alpha3.StringMatch_Regex:
+		switch bm := b.GetMatchType().(type) {
+		case *v1alpha3.StringMatch_Exact:
+			// Regexes must match the whole string.
+			re, err := regexp.Compile("^(?:" + am.Regex + ")$")
+			return err == nil && !ignoreCase && re.MatchString(bm.Exact)
+		case *v1alpha3.StringMatch_Regex:
+			return bm.Regex == am.Regex
+		}
+	}
+	return false
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Fix is a suggested fix for the problem reported by a message.
+type Fix struct {
+	// Description of the fix, for humans.
+	Description string `json:"description"`
+
+	// Patch is a JSON patch (RFC 6902) against the resource, as it is defined in Kubernetes, that fixes the problem.
+	Patch []PatchOperation `json:"patch"`
+}
+
+// PatchOperation is a single JSON patch operation.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// JSONPointer returns the JSON pointer (RFC 6901) made of the given reference tokens, e.g. "/spec/ports/0/name".
+func JSONPointer(tokens ...interface{}) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		token := fmt.Sprint(t)
+		token = strings.Replace(token, "~", "~0", -1)
+		token = strings.Replace(token, "/", "~1", -1)
+		sb.WriteString("/")
+		sb.WriteString(token)
+	}
+	return sb.String()
+}
+
+// Unstructured returns this fix as a JSON-style unstructured map
+func (f *Fix) Unstructured() map[string]interface{} {
+	patch := make([]interface{}, 0, len(f.Patch))
+	for _, op := range f.Patch {
+		o := map[string]interface{}{
+			"op":   op.Op,
+			"path": op.Path,
+		}
+		if op.Value != nil {
+			// Round trip the value so that it only contains JSON types.
+			var value interface{}
+			if b, err := json.Marshal(op.Value); err == nil && json.Unmarshal(b, &value) == nil {
+				o["value"] = value
+			}
+		}
+		patch = append(patch, o)
+	}
+	return map[string]interface{}{
+		"description": f.Description,
+		"patch":       patch,
+	}
+}
@@ -45,3 +45,18 @@ type testReference struct {
 func (r testReference) String() string {
 	return r.name
 }
+
+var _ resource.FieldReference = &testFieldReference{}
+
+type testFieldReference struct {
+	testReference
+	fields map[string]string
+}
+
+func (r testFieldReference) FieldReference(path string) (resource.Reference, bool) {
+	name, ok := r.fields[path]
+	if !ok {
+		return nil, false
+	}
+	return testReference{name}, true
+}
@@ -57,6 +57,12 @@ type Message struct {
 	// message, or nil if no resource is associated with it.
 	Resource *resource.Instance
 
+	// Path is an optional path to the field of the resource the message refers to, e.g. "spec.http[1].match[0]"
+	Path string
+
+	// Fix is an optional suggested fix for the problem
+	Fix *Fix
+
 	// DocRef is an optional reference tracker for the documentation URL
 	DocRef string
 }
@@ -69,11 +75,14 @@ func (m *Message) Unstructured(includeOrigin bool) map[string]interface{} {
 	result["level"] = m.Type.Level().String()
 	if includeOrigin && m.Resource != nil {
 		result["origin"] = m.Resource.Origin.FriendlyName()
-		if m.Resource.Origin.Reference() != nil {
-			result["reference"] = m.Resource.Origin.Reference().String()
+		if ref := m.Reference(); ref != nil {
+			result["reference"] = ref.String()
 		}
 	}
 	result["message"] = fmt.Sprintf(m.Type.Template(), m.Parameters...)
+	if m.Fix != nil {
+		result["fix"] = m.Fix.Unstructured()
+	}
 
 	docQueryString := ""
 	if m.DocRef != "" {
@@ -89,8 +98,8 @@ func (m *Message) String() string {
 	origin := ""
 	if m.Resource != nil {
 		loc := ""
-		if m.Resource.Origin.Reference() != nil {
-			loc = " " + m.Resource.Origin.Reference().String()
+		if ref := m.Reference(); ref != nil {
+			loc = " " + ref.String()
 		}
 		origin = " (" + m.Resource.Origin.FriendlyName() + loc + ")"
 	}
@@ -98,6 +107,23 @@ func (m *Message) String() string {
 		"%v [%v]%s %s", m.Type.Level(), m.Type.Code(), origin, fmt.Sprintf(m.Type.Template(), m.Parameters...))
 }
 
+// Reference returns the location of the message within its resource's source: the location of the field at Path if
+// it is known, otherwise the location of the resource itself. Returns nil if there is no location.
+func (m *Message) Reference() resource.Reference {
+	if m.Resource == nil || m.Resource.Origin == nil {
+		return nil
+	}
+	ref := m.Resource.Origin.Reference()
+	if m.Path != "" {
+		if fr, ok := ref.(resource.FieldReference); ok {
+			if fieldRef, ok := fr.FieldReference(m.Path); ok {
+				return fieldRef
+			}
+		}
+	}
+	return ref
+}
+
 // MarshalJSON satisfies the Marshaler interface
 func (m *Message) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m.Unstructured(true))
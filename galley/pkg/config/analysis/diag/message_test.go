@@ -70,3 +70,43 @@ func TestMessage_JSON(t *testing.T) {
 	g.Expect(string(j)).To(Equal(`{"code":"IST-0042","documentation_url":"https://istio.io/docs/reference/config/analysis/IST-0042"` +
 		`,"level":"Error","message":"Cheese type not found: \"Feta\"","origin":"toppings/cheese","reference":"path/to/file"}`))
 }
+
+func TestMessageWithPath_String(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mt := NewMessageType(Error, "IST-0042", "Cheese type not found: %q")
+	ref := testFieldReference{
+		testReference: testReference{"path/to/file:1"},
+		fields:        map[string]string{"spec.toppings[1]": "path/to/file:7"},
+	}
+	m := NewMessage(mt, &resource.Instance{Origin: testOrigin{name: "toppings/cheese", ref: ref}}, "Feta")
+
+	m.Path = "spec.toppings[1]"
+	g.Expect(m.String()).To(Equal(`Error [IST-0042] (toppings/cheese path/to/file:7) Cheese type not found: "Feta"`))
+
+	// Fall back to the location of the resource if the field can't be found.
+	m.Path = "spec.toppings[2]"
+	g.Expect(m.String()).To(Equal(`Error [IST-0042] (toppings/cheese path/to/file:1) Cheese type not found: "Feta"`))
+}
+
+func TestMessageWithFix_JSON(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mt := NewMessageType(Error, "IST-0042", "Cheese type not found: %q")
+	m := NewMessage(mt, nil, "Feta")
+	m.Fix = &Fix{
+		Description: "Use Gouda",
+		Patch:       []PatchOperation{{Op: "replace", Path: JSONPointer("spec", "cheeses", 0, "type"), Value: "Gouda"}},
+	}
+
+	j, _ := json.Marshal(&m)
+	g.Expect(string(j)).To(Equal(`{"code":"IST-0042","documentation_url":"https://istio.io/docs/reference/config/analysis/IST-0042",` +
+		`"fix":{"description":"Use Gouda","patch":[{"op":"replace","path":"/spec/cheeses/0/type","value":"Gouda"}]},` +
+		`"level":"Error","message":"Cheese type not found: \"Feta\""}`))
+}
+
+func TestJSONPointer(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	g.Expect(JSONPointer("metadata", "labels", "istio.io/rev")).To(Equal("/metadata/labels/istio.io~1rev"))
+	g.Expect(JSONPointer("spec", "ports", 0, "name")).To(Equal("/spec/ports/0/name"))
+	g.Expect(JSONPointer("a~b")).To(Equal("/a~0b"))
+}
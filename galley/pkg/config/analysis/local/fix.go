@@ -0,0 +1,99 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/galley/pkg/config/util/kubeyaml"
+)
+
+// ApplyFixes applies the fixes suggested by the messages to the resources of the named YAML content, as added with
+// AddReaderKubeSource. It returns the fixed content and the number of fixes applied. Fixed documents are serialized
+// again, so their comments and formatting are not preserved; other documents are kept as is.
+func ApplyFixes(name string, content []byte, messages diag.Messages) ([]byte, int, error) {
+	// Resources are identified by the line their document starts at.
+	fixes := make(map[int][]*diag.Fix)
+	for _, m := range messages {
+		if m.Fix == nil || m.Resource == nil || m.Resource.Origin == nil {
+			continue
+		}
+		pos, ok := m.Resource.Origin.Reference().(*rt.Position)
+		if !ok || pos.Filename != name {
+			continue
+		}
+		fixes[pos.Line] = append(fixes[pos.Line], m.Fix)
+	}
+	if len(fixes) == 0 {
+		return content, 0, nil
+	}
+
+	var docs [][]byte
+	var errs error
+	applied := 0
+	reader := kubeyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		doc, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		for _, fix := range fixes[line] {
+			fixed, err := applyFix(doc, fix)
+			if err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("%s:%d: failed to %s: %v", name, line, fix.Description, err))
+				continue
+			}
+			doc = fixed
+			applied++
+		}
+		docs = append(docs, doc)
+	}
+
+	return kubeyaml.Join(docs...), applied, errs
+}
+
+func applyFix(doc []byte, fix *diag.Fix) ([]byte, error) {
+	js, err := yaml.YAMLToJSON(doc)
+	if err != nil {
+		return nil, err
+	}
+	by, err := json.Marshal(fix.Patch)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := jsonpatch.DecodePatch(by)
+	if err != nil {
+		return nil, err
+	}
+	fixed, err := patch.Apply(js)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.JSONToYAML(fixed)
+}
@@ -0,0 +1,93 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/resource"
+)
+
+const fixContent = `# Unchanged
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: unchanged
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: details
+spec:
+  ports:
+  - port: 80 # Not named
+`
+
+func messageWithFix(filename string, line int, fix *diag.Fix) diag.Message {
+	m := diag.NewMessage(diag.NewMessageType(diag.Warning, "IST-0042", "Problem"),
+		&resource.Instance{Origin: &rt.Origin{Ref: &rt.Position{Filename: filename, Line: line}}})
+	m.Fix = fix
+	return m
+}
+
+func TestApplyFixes(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	messages := diag.Messages{
+		messageWithFix("a.yaml", 7, &diag.Fix{
+			Description: "name the port",
+			Patch:       []diag.PatchOperation{{Op: "add", Path: diag.JSONPointer("spec", "ports", 0, "name"), Value: "http"}},
+		}),
+		messageWithFix("a.yaml", 7, &diag.Fix{
+			Description: "remove a missing field",
+			Patch:       []diag.PatchOperation{{Op: "remove", Path: "/spec/bogus"}},
+		}),
+		// Fixes for other files, or without a fix, are ignored.
+		messageWithFix("b.yaml", 7, &diag.Fix{
+			Description: "remove the ports",
+			Patch:       []diag.PatchOperation{{Op: "remove", Path: "/spec/ports"}},
+		}),
+		messageWithFix("a.yaml", 1, nil),
+	}
+
+	fixed, applied, err := ApplyFixes("a.yaml", []byte(fixContent), messages)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("a.yaml:7: failed to remove a missing field"))
+	g.Expect(applied).To(Equal(1))
+	g.Expect(string(fixed)).To(Equal(`# Unchanged
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: unchanged
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: details
+spec:
+  ports:
+  - name: http
+    port: 80
+`))
+
+	unchanged, applied, err := ApplyFixes("c.yaml", []byte(fixContent), messages)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(applied).To(Equal(0))
+	g.Expect(string(unchanged)).To(Equal(fixContent))
+}
@@ -116,6 +116,26 @@ var (
 	// UnknownMeshNetworksServiceRegistry defines a diag.MessageType for message "UnknownMeshNetworksServiceRegistry".
 	// Description: A service registry in Mesh Networks is unknown
 	UnknownMeshNetworksServiceRegistry = diag.NewMessageType(diag.Error, "IST0126", "Unknown service registry %s in network %s")
+
+	// VirtualServiceShadowedRoute defines a diag.MessageType for message "VirtualServiceShadowedRoute".
+	// Description: A VirtualService match can never be used because an earlier route matches a superset of its requests
+	VirtualServiceShadowedRoute = diag.NewMessageType(diag.Warning, "IST0127", "The match %s can never be used because the earlier route %s matches all of its requests")
+
+	// NoMatchingWorkloadsFound defines a diag.MessageType for message "NoMatchingWorkloadsFound".
+	// Description: There aren't workloads matching the resource labels
+	NoMatchingWorkloadsFound = diag.NewMessageType(diag.Warning, "IST0128", "No matching workloads for this resource with the following labels: %s")
+
+	// AuthorizationPolicyNamespaceNotFound defines a diag.MessageType for message "AuthorizationPolicyNamespaceNotFound".
+	// Description: An AuthorizationPolicy refers to a source namespace that does not exist
+	AuthorizationPolicyNamespaceNotFound = diag.NewMessageType(diag.Warning, "IST0129", "The source namespace %q does not exist")
+
+	// AuthorizationPolicyUnknownTrustDomain defines a diag.MessageType for message "AuthorizationPolicyUnknownTrustDomain".
+	// Description: An AuthorizationPolicy refers to a principal that is not in the mesh trust domain or its aliases
+	AuthorizationPolicyUnknownTrustDomain = diag.NewMessageType(diag.Warning, "IST0130", "The trust domain of principal %q is not the mesh trust domain %q or one of its aliases")
+
+	// AuthorizationPolicyPortNotExposed defines a diag.MessageType for message "AuthorizationPolicyPortNotExposed".
+	// Description: An AuthorizationPolicy refers to a port that no workload it applies to exposes
+	AuthorizationPolicyPortNotExposed = diag.NewMessageType(diag.Warning, "IST0131", "The port %s is not exposed by any workload the policy applies to")
 )
 
 // All returns a list of all known message types.
@@ -148,6 +168,11 @@ func All() []*diag.MessageType {
 		NamespaceMultipleInjectionLabels,
 		InvalidAnnotation,
 		UnknownMeshNetworksServiceRegistry,
+		VirtualServiceShadowedRoute,
+		NoMatchingWorkloadsFound,
+		AuthorizationPolicyNamespaceNotFound,
+		AuthorizationPolicyUnknownTrustDomain,
+		AuthorizationPolicyPortNotExposed,
 	}
 }
 
@@ -417,3 +442,50 @@ func NewUnknownMeshNetworksServiceRegistry(r *resource.Instance, serviceregistry
 		network,
 	)
 }
+
+// NewVirtualServiceShadowedRoute returns a new diag.Message based on VirtualServiceShadowedRoute.
+func NewVirtualServiceShadowedRoute(r *resource.Instance, match string, shadowedBy string) diag.Message {
+	return diag.NewMessage(
+		VirtualServiceShadowedRoute,
+		r,
+		match,
+		shadowedBy,
+	)
+}
+
+// NewNoMatchingWorkloadsFound returns a new diag.Message based on NoMatchingWorkloadsFound.
+func NewNoMatchingWorkloadsFound(r *resource.Instance, labels string) diag.Message {
+	return diag.NewMessage(
+		NoMatchingWorkloadsFound,
+		r,
+		labels,
+	)
+}
+
+// NewAuthorizationPolicyNamespaceNotFound returns a new diag.Message based on AuthorizationPolicyNamespaceNotFound.
+func NewAuthorizationPolicyNamespaceNotFound(r *resource.Instance, namespace string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyNamespaceNotFound,
+		r,
+		namespace,
+	)
+}
+
+// NewAuthorizationPolicyUnknownTrustDomain returns a new diag.Message based on AuthorizationPolicyUnknownTrustDomain.
+func NewAuthorizationPolicyUnknownTrustDomain(r *resource.Instance, principal string, trustDomain string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyUnknownTrustDomain,
+		r,
+		principal,
+		trustDomain,
+	)
+}
+
+// NewAuthorizationPolicyPortNotExposed returns a new diag.Message based on AuthorizationPolicyPortNotExposed.
+func NewAuthorizationPolicyPortNotExposed(r *resource.Instance, port string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyPortNotExposed,
+		r,
+		port,
+	)
+}
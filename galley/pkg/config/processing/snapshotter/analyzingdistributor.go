@@ -24,7 +24,10 @@ import (
 
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
 	coll "istio.io/istio/galley/pkg/config/collection"
+	"istio.io/istio/galley/pkg/config/monitoring"
+	"istio.io/istio/galley/pkg/config/processing/snapshotter/strategy"
 	"istio.io/istio/galley/pkg/config/scope"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
@@ -40,6 +43,7 @@ type AnalyzingDistributor struct {
 
 	analysisMu     sync.Mutex
 	cancelAnalysis chan struct{}
+	stopCh         chan struct{}
 
 	snapshotsMu   sync.RWMutex
 	lastSnapshots map[string]*Snapshot
@@ -66,6 +70,10 @@ type AnalyzingDistributorSettings struct {
 	//  and a matching debounce mechanism.
 	TriggerSnapshot string
 
+	// The strategy used to debounce the analysis. If set, the combined snapshot is analyzed whenever the strategy
+	// fires after a change to any of the AnalysisSnapshots, and TriggerSnapshot is ignored.
+	AnalysisStrategy strategy.Instance
+
 	// An optional hook that will be called whenever a collection is accessed. Useful for testing.
 	CollectionReporter CollectionReporterFn
 
@@ -103,6 +111,39 @@ func NewAnalyzingDistributor(s AnalyzingDistributorSettings) *AnalyzingDistribut
 	}
 }
 
+// Start the analysis strategy, if any.
+func (d *AnalyzingDistributor) Start() {
+	if d.s.AnalysisStrategy == nil {
+		return
+	}
+
+	d.analysisMu.Lock()
+	defer d.analysisMu.Unlock()
+	if d.stopCh != nil {
+		return
+	}
+	stopCh := make(chan struct{})
+	d.stopCh = stopCh
+
+	d.s.AnalysisStrategy.Start(func() {
+		d.analyze(stopCh, d.analysisNamespaces())
+	})
+}
+
+// Stop the analysis strategy, if any. An ongoing analysis is canceled.
+func (d *AnalyzingDistributor) Stop() {
+	d.analysisMu.Lock()
+	if d.stopCh == nil {
+		d.analysisMu.Unlock()
+		return
+	}
+	close(d.stopCh)
+	d.stopCh = nil
+	d.analysisMu.Unlock()
+
+	d.s.AnalysisStrategy.Stop()
+}
+
 // Distribute implements snapshotter.Distributor
 func (d *AnalyzingDistributor) Distribute(name string, s *Snapshot) {
 	// Keep the most recent snapshot for each snapshot group we care about for analysis so we can combine them
@@ -111,6 +152,16 @@ func (d *AnalyzingDistributor) Distribute(name string, s *Snapshot) {
 		d.snapshotsMu.Lock()
 		d.lastSnapshots[name] = s
 		d.snapshotsMu.Unlock()
+
+		if d.s.AnalysisStrategy != nil {
+			d.s.AnalysisStrategy.OnChange()
+		}
+	}
+
+	// With a strategy, the analysis doesn't hold back the distribution of any snapshot.
+	if d.s.AnalysisStrategy != nil {
+		d.s.Distributor.Distribute(name, s)
+		return
 	}
 
 	// If the trigger snapshot is not set, simply bypass.
@@ -129,15 +180,18 @@ func (d *AnalyzingDistributor) Distribute(name string, s *Snapshot) {
 		d.cancelAnalysis = nil
 	}
 
+	// start a new analysis session
+	cancelAnalysis := make(chan struct{})
+	d.cancelAnalysis = cancelAnalysis
+	go d.analyzeAndDistribute(cancelAnalysis, name, s, d.analysisNamespaces())
+}
+
+func (d *AnalyzingDistributor) analysisNamespaces() map[resource.Namespace]struct{} {
 	namespaces := make(map[resource.Namespace]struct{})
 	for _, ns := range d.s.AnalysisNamespaces {
 		namespaces[ns] = struct{}{}
 	}
-
-	// start a new analysis session
-	cancelAnalysis := make(chan struct{})
-	d.cancelAnalysis = cancelAnalysis
-	go d.analyzeAndDistribute(cancelAnalysis, name, s, namespaces)
+	return namespaces
 }
 
 func (d *AnalyzingDistributor) isAnalysisSnapshot(s string) bool {
@@ -151,6 +205,13 @@ func (d *AnalyzingDistributor) isAnalysisSnapshot(s string) bool {
 }
 
 func (d *AnalyzingDistributor) analyzeAndDistribute(cancelCh chan struct{}, name string, s *Snapshot, namespaces map[resource.Namespace]struct{}) {
+	d.analyze(cancelCh, namespaces)
+
+	// Execution only reaches this point for trigger snapshot group
+	d.s.Distributor.Distribute(name, s)
+}
+
+func (d *AnalyzingDistributor) analyze(cancelCh chan struct{}, namespaces map[resource.Namespace]struct{}) {
 	// For analysis, we use a combined snapshot
 	ctx := &context{
 		sn:                 d.getCombinedSnapshot(),
@@ -164,11 +225,25 @@ func (d *AnalyzingDistributor) analyzeAndDistribute(cancelCh chan struct{}, name
 
 	msgs := filterMessages(ctx.messages, namespaces, d.s.Suppressions)
 	if !ctx.Canceled() {
-		d.s.StatusUpdater.Update(msgs.SortedDedupedCopy())
+		msgs = msgs.SortedDedupedCopy()
+		d.s.StatusUpdater.Update(msgs)
+		recordMessageCounts(msgs)
 	}
+}
 
-	// Execution only reaches this point for trigger snapshot group
-	d.s.Distributor.Distribute(name, s)
+// recordMessageCounts records the number of messages for each known message code, including the codes without any
+// message so that their count is reset.
+func recordMessageCounts(messages diag.Messages) {
+	counts := make(map[string]int)
+	for _, t := range msg.All() {
+		counts[t.Code()] = 0
+	}
+	for _, m := range messages {
+		counts[m.Type.Code()]++
+	}
+	for code, count := range counts {
+		monitoring.RecordAnalysisMessages(code, count)
+	}
 }
 
 // getCombinedSnapshot creates a new snapshot from the last snapshots of each snapshot group
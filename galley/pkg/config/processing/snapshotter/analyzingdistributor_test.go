@@ -25,6 +25,7 @@ import (
 	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	coll "istio.io/istio/galley/pkg/config/collection"
+	"istio.io/istio/galley/pkg/config/processing/snapshotter/strategy"
 	"istio.io/istio/galley/pkg/config/source/kube/rt"
 	"istio.io/istio/galley/pkg/config/testing/basicmeta"
 	"istio.io/istio/pkg/config/resource"
@@ -186,6 +187,59 @@ func TestAnalyzeAndDistributeSnapshots(t *testing.T) {
 	}
 }
 
+func TestAnalyzeWithStrategy(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	u := &updaterMock{waitTimeout: 1 * time.Second}
+	a := &analyzerMock{
+		collectionToAccess: basicmeta.K8SCollection1.Name(),
+		resourcesToReport: []*resource.Instance{
+			{
+				Origin: &rt.Origin{
+					Collection: basicmeta.K8SCollection1.Name(),
+					FullName:   resource.NewFullName("includedNamespace", "r1"),
+				},
+			},
+		},
+	}
+	d := NewInMemoryDistributor()
+
+	settings := AnalyzingDistributorSettings{
+		StatusUpdater:     u,
+		Analyzer:          analysis.Combine("testCombined", a),
+		Distributor:       d,
+		AnalysisSnapshots: []string{snapshots.Default, snapshots.LocalAnalysis},
+		AnalysisStrategy:  strategy.NewImmediate(),
+	}
+	ad := NewAnalyzingDistributor(settings)
+	ad.Start()
+
+	schemaA := newSchema("a")
+	schemaB := newSchema("b")
+	schemaD := newSchema("d")
+
+	sDefault := getTestSnapshot(schemaA)
+	sLocalAnalysis := getTestSnapshot(schemaB)
+	sOther := getTestSnapshot(schemaD)
+
+	// Every change to an analysis snapshot triggers the analysis of the combined snapshot, and snapshots are
+	// distributed regardless of the analysis.
+	ad.Distribute(snapshots.Default, sDefault)
+	ad.Distribute(snapshots.LocalAnalysis, sLocalAnalysis)
+	ad.Distribute("other", sOther)
+
+	g.Expect(d.GetSnapshot(snapshots.Default)).To(Equal(sDefault))
+	g.Expect(d.GetSnapshot(snapshots.LocalAnalysis)).To(Equal(sLocalAnalysis))
+	g.Expect(d.GetSnapshot("other")).To(Equal(sOther))
+	g.Expect(a.getAnalyzeCalls()).To(ConsistOf(getTestSnapshot(schemaA), getTestSnapshot(schemaA, schemaB)))
+	g.Expect(u.getMessages()).To(HaveLen(1))
+
+	// Once stopped, changes are no longer analyzed.
+	ad.Stop()
+	ad.Distribute(snapshots.Default, sDefault)
+	g.Expect(a.getAnalyzeCalls()).To(HaveLen(2))
+}
+
 func TestAnalyzeNamespaceMessageHasNoResource(t *testing.T) {
 	g := NewGomegaWithT(t)
 
@@ -303,7 +303,10 @@ func (s *KubeSource) parseChunk(r *collection.Schemas, name string, lineNum int,
 		return kubeResource{}, err
 	}
 
-	pos := rt.Position{Filename: name, Line: lineNum}
+	pos := rt.Position{Filename: name, Line: lineNum, FieldLines: kubeyaml.FieldLines(yamlChunk)}
+	for path, line := range pos.FieldLines {
+		pos.FieldLines[path] = lineNum + line - 1
+	}
 	return kubeResource{
 		schema:   schema,
 		sha:      sha1.Sum(yamlChunk),
@@ -347,6 +347,25 @@ func TestKubeSource_CanHandleDocumentSeparatorInComments(t *testing.T) {
 	g.Expect(s.ContentNames()).To(Equal(map[string]struct{}{"foo": {}}))
 }
 
+func TestKubeSource_FieldReferences(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	s, _ := setupKubeSource()
+	s.Start()
+	defer s.Stop()
+
+	err := s.ApplyContent("foo", kubeyaml.JoinString(data.YamlN1I1V1, data.YamlN2I2V1))
+	g.Expect(err).To(BeNil())
+
+	actual := s.Get(basicmeta.K8SCollection1.Name()).AllSorted()
+	g.Expect(actual).To(HaveLen(2))
+
+	g.Expect(actual[1].Origin.Reference().String()).To(Equal("foo:11"))
+	ref, ok := actual[1].Origin.Reference().(resource.FieldReference).FieldReference("spec.n2_i2")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ref.String()).To(Equal("foo:17"))
+}
+
 func setupKubeSource() (*KubeSource, *fixtures.Accumulator) {
 	s := NewKubeSource(basicmeta.MustGet().KubeCollections())
 
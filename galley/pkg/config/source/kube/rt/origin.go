@@ -35,6 +35,7 @@ type Origin struct {
 
 var _ resource.Origin = &Origin{}
 var _ resource.Reference = &Position{}
+var _ resource.FieldReference = &Position{}
 
 // FriendlyName implements resource.Origin
 func (o *Origin) FriendlyName() string {
@@ -66,6 +67,9 @@ func (o *Origin) Reference() resource.Reference {
 type Position struct {
 	Filename string // filename, if any
 	Line     int    // line number, starting at 1
+
+	// FieldLines maps field paths of the resource to their line numbers, if known.
+	FieldLines map[string]int
 }
 
 // String outputs the string representation of the position.
@@ -81,6 +85,19 @@ func (p *Position) String() string {
 	return s
 }
 
+// FieldReference implements resource.FieldReference. If the field itself isn't known, e.g. because it is in a flow
+// style collection, the position of its closest known parent is returned.
+func (p *Position) FieldReference(path string) (resource.Reference, bool) {
+	for path != "" {
+		if line, ok := p.FieldLines[path]; ok {
+			return &Position{Filename: p.Filename, Line: line}, true
+		}
+		path = path[:strings.LastIndexAny(path, ".[")+1]
+		path = strings.TrimRight(path, ".[")
+	}
+	return nil, false
+}
+
 func (p *Position) isValid() bool {
 	return p.Line > 0 && p.Filename != ""
 }
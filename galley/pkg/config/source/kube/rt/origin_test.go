@@ -62,3 +62,21 @@ func TestPositionString(t *testing.T) {
 		})
 	}
 }
+
+func TestPositionFieldReference(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	p := Position{Filename: "test.yaml", Line: 3, FieldLines: map[string]int{"spec.http[1]": 12}}
+
+	ref, ok := p.FieldReference("spec.http[1]")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ref.String()).To(Equal("test.yaml:12"))
+
+	// Fields that aren't known resolve to their closest known parent.
+	ref, ok = p.FieldReference("spec.http[1].match[0].uri")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(ref.String()).To(Equal("test.yaml:12"))
+
+	_, ok = p.FieldReference("spec.http[2]")
+	g.Expect(ok).To(BeFalse())
+}
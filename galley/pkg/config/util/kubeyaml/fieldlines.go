@@ -0,0 +1,57 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeyaml
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FieldLines returns the line numbers (starting at 1) of the fields of a single YAML document, keyed by
+// field path such as "spec.http[1].match[0]". The document is parsed as a yaml.v3 node tree, so flow style
+// collections are supported as well. An invalid document has no field lines.
+func FieldLines(yamlDoc []byte) map[string]int {
+	lines := make(map[string]int)
+	var doc yaml.Node
+	if err := yaml.Unmarshal(yamlDoc, &doc); err != nil {
+		return lines
+	}
+	for _, n := range doc.Content {
+		addFieldLines(lines, "", n)
+	}
+	return lines
+}
+
+func addFieldLines(lines map[string]int, path string, n *yaml.Node) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			p := key.Value
+			if path != "" {
+				p = path + "." + key.Value
+			}
+			lines[p] = key.Line
+			addFieldLines(lines, p, value)
+		}
+	case yaml.SequenceNode:
+		for i, item := range n.Content {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			lines[p] = item.Line
+			addFieldLines(lines, p, item)
+		}
+	}
+}
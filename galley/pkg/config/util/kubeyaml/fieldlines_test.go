@@ -0,0 +1,86 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeyaml
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFieldLines(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	doc := `apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: reviews
+  annotations:
+    "example.com/note": |
+      - not: a field
+spec:
+  hosts:
+  - reviews
+  http:
+  # catch-all first
+  - route:
+    - destination:
+        host: reviews
+  - match:
+    - uri:
+        prefix: /foo
+    - uri: {exact: /bar}
+    route:
+      - destination:
+          host: "reviews"
+`
+	lines := FieldLines([]byte(doc))
+
+	expected := map[string]int{
+		"apiVersion":                             1,
+		"kind":                                   2,
+		"metadata":                               3,
+		"metadata.name":                          4,
+		"metadata.annotations":                   5,
+		"metadata.annotations.example.com/note":  6,
+		"spec":                                   8,
+		"spec.hosts":                             9,
+		"spec.hosts[0]":                          10,
+		"spec.http":                              11,
+		"spec.http[0]":                           13,
+		"spec.http[0].route":                     13,
+		"spec.http[0].route[0]":                  14,
+		"spec.http[0].route[0].destination":      14,
+		"spec.http[0].route[0].destination.host": 15,
+		"spec.http[1]":                           16,
+		"spec.http[1].match":                     16,
+		"spec.http[1].match[0]":                  17,
+		"spec.http[1].match[0].uri":              17,
+		"spec.http[1].match[0].uri.prefix":       18,
+		"spec.http[1].match[1]":                  19,
+		"spec.http[1].match[1].uri":              19,
+		"spec.http[1].match[1].uri.exact":        19,
+		"spec.http[1].route":                     20,
+		"spec.http[1].route[0]":                  21,
+		"spec.http[1].route[0].destination.host": 22,
+	}
+	for path, line := range expected {
+		g.Expect(lines).To(HaveKeyWithValue(path, line), path)
+	}
+	g.Expect(lines).NotTo(HaveKey("metadata.annotations.example.com/note[0]"))
+	g.Expect(lines).NotTo(HaveKey("spec.http[2]"))
+
+	g.Expect(FieldLines([]byte("spec: [unterminated"))).To(BeEmpty())
+}
@@ -20,6 +20,7 @@ import (
 	"istio.io/istio/galley/pkg/config/analysis/analyzers"
 	"istio.io/istio/galley/pkg/config/processing"
 	"istio.io/istio/galley/pkg/config/processing/snapshotter"
+	"istio.io/istio/galley/pkg/config/processing/snapshotter/strategy"
 	"istio.io/istio/galley/pkg/config/processor"
 	"istio.io/istio/galley/pkg/config/processor/groups"
 	"istio.io/istio/galley/pkg/config/processor/transforms"
@@ -45,6 +46,7 @@ type Processing struct {
 	k kube.Interfaces
 
 	runtime  *processing.Runtime
+	analyzer *snapshotter.AnalyzingDistributor
 	reporter monitoring.Reporter
 	stopCh   chan struct{}
 }
@@ -90,13 +92,18 @@ func (p *Processing) Start() (err error) {
 		combinedAnalyzer := analyzers.AllCombined()
 		combinedAnalyzer.RemoveSkipped(colsInSnapshots, kubeResources.DisabledCollectionNames(), transformProviders)
 
-		distributor = snapshotter.NewAnalyzingDistributor(snapshotter.AnalyzingDistributorSettings{
+		analyzerSettings := snapshotter.AnalyzingDistributorSettings{
 			StatusUpdater:     updater,
 			Analyzer:          combinedAnalyzer,
 			Distributor:       distributor,
 			AnalysisSnapshots: p.args.Snapshots,
 			TriggerSnapshot:   p.args.TriggerSnapshot,
-		})
+		}
+		if p.args.DebounceConfigAnalysis {
+			analyzerSettings.AnalysisStrategy = strategy.NewDebounceWithDefaults()
+		}
+		p.analyzer = snapshotter.NewAnalyzingDistributor(analyzerSettings)
+		distributor = p.analyzer
 	}
 
 	processorSettings := processor.Settings{
@@ -115,6 +122,9 @@ func (p *Processing) Start() (err error) {
 
 	p.reporter = mcpMetricReporter("galley")
 
+	if p.analyzer != nil {
+		p.analyzer.Start()
+	}
 	p.runtime.Start()
 
 	return nil
@@ -167,6 +177,11 @@ func (p *Processing) Stop() {
 		p.runtime = nil
 	}
 
+	if p.analyzer != nil {
+		p.analyzer.Stop()
+		p.analyzer = nil
+	}
+
 	if p.reporter != nil {
 		_ = p.reporter.Close()
 		p.reporter = nil
@@ -58,6 +58,10 @@ type Args struct { // nolint:maligned
 	// Enable Config Analysis service, that will analyze and update CRD status. UseOldProcessor must be set to false.
 	EnableConfigAnalysis bool
 
+	// Run config analysis, debounced, whenever any of the Snapshots changes instead of whenever the TriggerSnapshot is
+	// published. Analysis then doesn't delay the publication of the TriggerSnapshot.
+	DebounceConfigAnalysis bool
+
 	Snapshots       []string
 	TriggerSnapshot string
 }
@@ -87,6 +91,8 @@ func (a *Args) String() string {
 	_, _ = fmt.Fprintf(buf, "MeshConfigFile: %s\n", a.MeshConfigFile)
 	_, _ = fmt.Fprintf(buf, "DomainSuffix: %s\n", a.DomainSuffix)
 	_, _ = fmt.Fprintf(buf, "ExcludedResourceKinds: %v\n", a.ExcludedResourceKinds)
+	_, _ = fmt.Fprintf(buf, "EnableConfigAnalysis: %v\n", a.EnableConfigAnalysis)
+	_, _ = fmt.Fprintf(buf, "DebounceConfigAnalysis: %v\n", a.DebounceConfigAnalysis)
 
 	return buf.String()
 }
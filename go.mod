@@ -159,6 +159,7 @@ require (
 	gopkg.in/d4l3k/messagediff.v1 v1.2.1
 	gopkg.in/square/go-jose.v2 v2.3.1
 	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.0-20190905181640-827449938966
 	helm.sh/helm/v3 v3.2.0
 	istio.io/api v0.0.0-20200616120102-657e06ab77c4
 	istio.io/gogo-genproto v0.0.0-20200422223746-8166b73efbae
@@ -17,6 +17,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -28,6 +29,7 @@ import (
 	"istio.io/istio/galley/pkg/config/processing/snapshotter"
 
 	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 
@@ -35,10 +37,13 @@ import (
 
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/authz"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/local"
 	cfgKube "istio.io/istio/galley/pkg/config/source/kube"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
 	"istio.io/istio/istioctl/pkg/util/handlers"
+	analyzewriter "istio.io/istio/istioctl/pkg/writer/analyze"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema"
 	"istio.io/istio/pkg/kube"
@@ -55,6 +60,8 @@ const (
 	LogOutput       = "log"
 	JSONOutput      = "json"
 	YamlOutput      = "yaml"
+	SarifOutput     = "sarif"
+	JUnitOutput     = "junit"
 )
 
 func (f AnalyzerFoundIssuesError) Error() string {
@@ -81,6 +88,9 @@ var (
 	suppress          []string
 	analysisTimeout   time.Duration
 	recursive         bool
+	fix               bool
+
+	federatedTrustDomains []string
 
 	termEnvVar = env.RegisterStringVar("TERM", "", "Specifies terminal type.  Use 'dumb' to suppress color output")
 
@@ -96,7 +106,7 @@ var (
 // Analyze command
 func Analyze() *cobra.Command {
 	// Validate the output format before doing potentially expensive work to fail earlier
-	msgOutputFormats := map[string]bool{LogOutput: true, JSONOutput: true, YamlOutput: true}
+	msgOutputFormats := map[string]bool{LogOutput: true, JSONOutput: true, YamlOutput: true, SarifOutput: true, JUnitOutput: true}
 	var msgOutputFormatKeys []string
 
 	for k := range msgOutputFormats {
@@ -127,6 +137,12 @@ istioctl analyze -S "IST0103=Pod mypod.testing"
 # and suppress MisplacedAnnotation on deployment foobar in namespace default.
 istioctl analyze -S "IST0103=Pod *.testing" -S "IST0107=Deployment foobar.default"
 
+# Analyze yaml files without connecting to a live cluster, and apply the suggested fixes to them
+istioctl analyze --use-kube=false --fix a.yaml b.yaml
+
+# Analyze yaml files and write the results as SARIF, for code review tools to show them inline
+istioctl analyze --use-kube=false -o sarif a.yaml b.yaml > analysis.sarif
+
 # List available analyzers
 istioctl analyze -L
 `,
@@ -159,7 +175,7 @@ istioctl analyze -L
 				selectedNamespace = ""
 			}
 
-			sa := local.NewSourceAnalyzer(schema.MustGet(), analyzers.AllCombined(),
+			sa := local.NewSourceAnalyzer(schema.MustGet(), combinedAnalyzers(),
 				resource.Namespace(selectedNamespace), resource.Namespace(istioNamespace), nil, true, analysisTimeout)
 
 			// Check for suppressions and add them to our SourceAnalyzer
@@ -250,6 +266,16 @@ istioctl analyze -L
 				fmt.Fprintln(cmd.ErrOrStderr())
 			}
 
+			if fix {
+				fixed, err := applyFixes(cmd, readers, result.Messages)
+				if err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Error(s) applying fixes: %v\n", err)
+				}
+				if fixed > 0 {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Applied %d fixes.\n", fixed)
+				}
+			}
+
 			// Filter outputMessages by specified level, and append a ref arg to the doc URL
 			var outputMessages diag.Messages
 			for _, m := range result.Messages {
@@ -282,6 +308,9 @@ istioctl analyze -L
 					for _, m := range outputMessages {
 						fmt.Fprintln(cmd.OutOrStdout(), renderMessage(m))
 					}
+					if fixable := countFixable(outputMessages); fixable > 0 && !fix {
+						fmt.Fprintf(cmd.ErrOrStderr(), "%d of these issues can be fixed in the input files with --fix.\n", fixable)
+					}
 				}
 
 				// Return code is based on the unfiltered validation message list/parse errors
@@ -303,6 +332,18 @@ istioctl analyze -L
 					return err
 				}
 				fmt.Fprintln(cmd.OutOrStdout(), string(yamlOutput))
+			case SarifOutput, JUnitOutput:
+				// These formats are meant for CI, so the return code is based on the failure threshold as well
+				var err error
+				if msgOutputFormat == SarifOutput {
+					err = (&analyzewriter.SARIFWriter{Writer: cmd.OutOrStdout()}).Write(outputMessages)
+				} else {
+					err = (&analyzewriter.JUnitWriter{Writer: cmd.OutOrStdout(), FailureLevel: failureLevel.Level}).Write(outputMessages)
+				}
+				if err != nil {
+					return err
+				}
+				returnError = errorIfMessagesExceedThreshold(result.Messages)
 			default: // This should never happen since we validate this already
 				panic(fmt.Sprintf("%q not found in output format switch statement post validate?", msgOutputFormat))
 			}
@@ -337,6 +378,10 @@ istioctl analyze -L
 		"the duration to wait before failing")
 	analysisCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "R", false,
 		"Process directory arguments recursively. Useful when you want to analyze related manifests organized within the same directory.")
+	analysisCmd.PersistentFlags().BoolVar(&fix, "fix", false,
+		"Apply the fixes suggested by analyzers to the input files. Comments and formatting of the fixed resources are not preserved.")
+	analysisCmd.PersistentFlags().StringSliceVar(&federatedTrustDomains, "federated-trust-domains", nil,
+		"The trust domains federated with the mesh. Principals of these trust domains are accepted by authorization policies.")
 	return analysisCmd
 }
 
@@ -347,7 +392,7 @@ func gatherFiles(cmd *cobra.Command, args []string) ([]local.ReaderSource, error
 
 		// Handle "-" as stdin as a special case.
 		if f == "-" {
-			if isatty.IsTerminal(os.Stdin.Fd()) && !isJSONorYAMLOutputFormat() {
+			if isatty.IsTerminal(os.Stdin.Fd()) && !isMachineReadableOutputFormat() {
 				fmt.Fprint(cmd.OutOrStdout(), "Reading from stdin:\n")
 			}
 			r = os.Stdin
@@ -422,6 +467,57 @@ func gatherFilesInDirectory(cmd *cobra.Command, dir string) ([]local.ReaderSourc
 	return readers, err
 }
 
+// applyFixes rewrites the input files with the fixes suggested by the messages, and returns the number of fixes applied.
+func applyFixes(cmd *cobra.Command, readers []local.ReaderSource, messages diag.Messages) (int, error) {
+	var errs error
+	total := 0
+	for _, r := range readers {
+		// Resources read from stdin can't be fixed in place.
+		if r.Name == "-" {
+			continue
+		}
+		fi, err := os.Stat(r.Name)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		content, err := ioutil.ReadFile(r.Name)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+
+		fixed, n, err := local.ApplyFixes(r.Name, content, messages)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if n == 0 {
+			continue
+		}
+		if err = ioutil.WriteFile(r.Name, fixed, fi.Mode()); err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Fixed %d issues in %s\n", n, r.Name)
+		total += n
+	}
+	return total, errs
+}
+
+// countFixable returns the number of messages with a fix that can be applied to an input file.
+func countFixable(messages diag.Messages) int {
+	count := 0
+	for _, m := range messages {
+		if m.Fix == nil || m.Resource == nil || m.Resource.Origin == nil {
+			continue
+		}
+		if pos, ok := m.Resource.Origin.Reference().(*rt.Position); ok && pos.Filename != "" && pos.Filename != "-" {
+			count++
+		}
+	}
+	return count
+}
+
 func colorPrefix(m diag.Message) string {
 	if !colorize {
 		return ""
@@ -557,6 +653,17 @@ func analyzeTargetAsString() string {
 }
 
 // TODO: Refactor output writer so that it is smart enough to know when to output what.
-func isJSONorYAMLOutputFormat() bool {
-	return msgOutputFormat == JSONOutput || msgOutputFormat == YamlOutput
+func isMachineReadableOutputFormat() bool {
+	return msgOutputFormat == JSONOutput || msgOutputFormat == YamlOutput || msgOutputFormat == SarifOutput || msgOutputFormat == JUnitOutput
+}
+
+// combinedAnalyzers returns all the analyzers, configured with the flags.
+func combinedAnalyzers() *analysis.CombinedAnalyzer {
+	all := analyzers.All()
+	for _, a := range all {
+		if ap, ok := a.(*authz.AuthorizationPoliciesAnalyzer); ok {
+			ap.FederatedTrustDomains = federatedTrustDomains
+		}
+	}
+	return analysis.Combine("all", all...)
 }
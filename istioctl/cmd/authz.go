@@ -25,6 +25,8 @@ import (
 	"istio.io/istio/istioctl/pkg/authz"
 	"istio.io/istio/istioctl/pkg/util/configdump"
 	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/kube"
 
 	"istio.io/pkg/log"
@@ -33,6 +35,12 @@ import (
 var (
 	printAll       bool
 	configDumpFile string
+
+	simulateFiles       []string
+	simulateLabels      []string
+	simulateHeaders     []string
+	simulateTrustDomain string
+	simulateRequest     authz.Request
 )
 
 var (
@@ -88,6 +96,61 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 	}
 )
 
+var (
+	simulateCmd = &cobra.Command{
+		Use:   "simulate",
+		Short: "Simulate the authorization of a request against policy files.",
+		Long: `Simulate reads AuthorizationPolicy and PeerAuthentication resources from files, and checks whether
+a described request to a workload would be allowed. The policies are converted to Envoy RBAC filters the
+same way as in Pilot, and the filters are evaluated without a cluster. The output is the decision and the
+matching policy and rule.
+
+Headers that are not described are absent from the request. If the decision depends on attributes that
+are not described or not supported, such as the service account of the source when only its namespace is
+given, the destination IP or JWT claims, the decision is UNKNOWN and the policies that can't be simulated
+are listed.
+
+THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+`,
+		Example: `  # Check a GET request from the sleep service account to httpbin in namespace foo:
+  istioctl x authz simulate -f policies.yaml -n foo -l app=httpbin --port 8000 \
+    --source-principal cluster.local/ns/foo/sa/sleep --method GET --path /headers`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(simulateFiles) == 0 {
+				return fmt.Errorf("at least one policy file is required")
+			}
+			var configs []model.Config
+			for _, filename := range simulateFiles {
+				data, err := ioutil.ReadFile(filename)
+				if err != nil {
+					return err
+				}
+				c, _, err := crd.ParseInputs(string(data))
+				if err != nil {
+					return fmt.Errorf("failed to parse %s: %v", filename, err)
+				}
+				configs = append(configs, c...)
+			}
+
+			simulator, err := authz.NewSimulator(configs, istioNamespace, simulateTrustDomain)
+			if err != nil {
+				return err
+			}
+			req := simulateRequest
+			req.Namespace = handlers.HandleNamespace(namespace, defaultNamespace)
+			req.Labels = convertToMap(simulateLabels)
+			req.Headers = convertToMap(simulateHeaders)
+			result, err := simulator.Simulate(req)
+			if err != nil {
+				return err
+			}
+			result.Print(cmd.OutOrStdout())
+			return nil
+		},
+	}
+)
+
 func getConfigDumpFromFile(filename string) (*configdump.Wrapper, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -145,6 +208,7 @@ func AuthZ() *cobra.Command {
 		Short: "Inspect and interact with authorization policies",
 		Long: `Commands to inspect and interact with the authorization policies
   check - check Envoy config dump for authorization configuration
+  simulate - simulate the authorization of a request against policy files
 `,
 		Example: `  # Check Envoy authorization configuration for pod httpbin-88ddbcfdd-nt5jb:
   istioctl x authz check httpbin-88ddbcfdd-nt5jb
@@ -152,6 +216,7 @@ func AuthZ() *cobra.Command {
 	}
 
 	cmd.AddCommand(checkCmd)
+	cmd.AddCommand(simulateCmd)
 	return cmd
 }
 
@@ -160,4 +225,31 @@ func init() {
 		"Show additional information (e.g. SNI and ALPN)")
 	checkCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
 		"The json file with Envoy config dump to be checked")
+
+	simulateCmd.PersistentFlags().StringSliceVarP(&simulateFiles, "filename", "f", nil,
+		"Files with the AuthorizationPolicy and PeerAuthentication resources")
+	simulateCmd.PersistentFlags().StringSliceVarP(&simulateLabels, "labels", "l", nil,
+		"Labels of the destination workload, e.g. -l app=httpbin,version=v1")
+	simulateCmd.PersistentFlags().StringVar(&simulateTrustDomain, "trust-domain", "cluster.local",
+		"Trust domain of the mesh")
+	simulateCmd.PersistentFlags().IntVar(&simulateRequest.Port, "port", 80,
+		"Destination port of the request")
+	simulateCmd.PersistentFlags().StringVar(&simulateRequest.SourcePrincipal, "source-principal", "",
+		"Peer identity of the source, e.g. cluster.local/ns/foo/sa/sleep")
+	simulateCmd.PersistentFlags().StringVar(&simulateRequest.SourceNamespace, "source-namespace", "",
+		"Namespace of the source if --source-principal is not set, its service account is unknown then")
+	simulateCmd.PersistentFlags().StringVar(&simulateRequest.SourceIP, "source-ip", "",
+		"IP address of the source")
+	simulateCmd.PersistentFlags().StringVar(&simulateRequest.RequestPrincipal, "request-principal", "",
+		"Principal (<iss>/<sub>) of the validated JWT of the request, if any")
+	simulateCmd.PersistentFlags().StringVar(&simulateRequest.Method, "method", "GET",
+		"Method of the request")
+	simulateCmd.PersistentFlags().StringVar(&simulateRequest.Host, "host", "",
+		"Host of the request")
+	simulateCmd.PersistentFlags().StringVar(&simulateRequest.Path, "path", "/",
+		"Path of the request")
+	simulateCmd.PersistentFlags().StringArrayVarP(&simulateHeaders, "header", "H", nil,
+		"Headers of the request, e.g. -H x-token=abc")
+	simulateCmd.PersistentFlags().BoolVar(&simulateRequest.Plaintext, "plaintext", false,
+		"Send the request without mutual TLS")
 }
@@ -0,0 +1,379 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+
+	authn_model "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// checkRequest holds the attributes of a request that Envoy uses to evaluate RBAC policies.
+type checkRequest struct {
+	headers  map[string]string
+	path     string
+	port     uint32
+	sourceIP net.IP
+	// principal is the peer principal, without the spiffe:// prefix. It's empty without mutual TLS.
+	principal string
+	// principalPrefix is <trust domain>/ns/<namespace>/sa/ when only the namespace of the peer is known.
+	// Matchers which depend on its service account can't be simulated then.
+	principalPrefix string
+	// requestPrincipal is the principal of the JWT, set in the metadata of the Istio authn filter.
+	requestPrincipal string
+
+	// reasons describe why the matchers that were reached can't be simulated.
+	reasons []string
+}
+
+func newCheckRequest(req Request, principal, principalPrefix string) *checkRequest {
+	r := &checkRequest{
+		headers:          map[string]string{},
+		path:             req.Path,
+		port:             uint32(req.Port),
+		sourceIP:         net.ParseIP(req.SourceIP),
+		principal:        principal,
+		principalPrefix:  principalPrefix,
+		requestPrincipal: req.RequestPrincipal,
+	}
+	for k, v := range req.Headers {
+		r.headers[strings.ToLower(k)] = v
+	}
+	for k, v := range map[string]string{":method": req.Method, ":path": req.Path, ":authority": req.Host} {
+		if v != "" {
+			r.headers[k] = v
+		}
+	}
+	if i := strings.IndexAny(r.path, "?#"); i >= 0 {
+		r.path = r.path[:i]
+	}
+	return r
+}
+
+// match is the result of a matcher. It's unknown when the matcher depends on attributes that are not
+// part of the simulated request, or that the simulator doesn't support.
+type match int
+
+const (
+	noMatch match = iota
+	matched
+	unknown
+)
+
+func toMatch(b bool) match {
+	if b {
+		return matched
+	}
+	return noMatch
+}
+
+// not negates a match. The negation of an unknown match is still unknown.
+func (m match) not() match {
+	switch m {
+	case matched:
+		return noMatch
+	case noMatch:
+		return matched
+	default:
+		return unknown
+	}
+}
+
+// and returns noMatch if any of a and b doesn't match, whatever the other is.
+func and(a, b match) match {
+	switch {
+	case a == noMatch || b == noMatch:
+		return noMatch
+	case a == unknown || b == unknown:
+		return unknown
+	default:
+		return matched
+	}
+}
+
+// or returns matched if any of a and b matches, whatever the other is.
+func or(a, b match) match {
+	switch {
+	case a == matched || b == matched:
+		return matched
+	case a == unknown || b == unknown:
+		return unknown
+	default:
+		return noMatch
+	}
+}
+
+// cannotSimulate records why a matcher can't be simulated, and returns unknown.
+func (r *checkRequest) cannotSimulate(reason string) match {
+	for _, existing := range r.reasons {
+		if existing == reason {
+			return unknown
+		}
+	}
+	r.reasons = append(r.reasons, reason)
+	return unknown
+}
+
+// authenticated returns true if the request has a peer identity.
+func (r *checkRequest) authenticated() bool {
+	return r.principal != "" || r.principalPrefix != ""
+}
+
+// matchPolicy matches if the request matches one of the permissions and one of the principals.
+func (r *checkRequest) matchPolicy(policy *rbacpb.Policy) match {
+	permission := noMatch
+	for _, p := range policy.Permissions {
+		if permission = or(permission, r.matchPermission(p)); permission == matched {
+			break
+		}
+	}
+	if permission == noMatch {
+		return noMatch
+	}
+	principal := noMatch
+	for _, p := range policy.Principals {
+		if principal = or(principal, r.matchPrincipal(p)); principal == matched {
+			break
+		}
+	}
+	return and(permission, principal)
+}
+
+func (r *checkRequest) matchPermission(p *rbacpb.Permission) match {
+	switch rule := p.Rule.(type) {
+	case *rbacpb.Permission_Any:
+		return toMatch(rule.Any)
+	case *rbacpb.Permission_AndRules:
+		m := matched
+		for _, p := range rule.AndRules.Rules {
+			if m = and(m, r.matchPermission(p)); m == noMatch {
+				break
+			}
+		}
+		return m
+	case *rbacpb.Permission_OrRules:
+		m := noMatch
+		for _, p := range rule.OrRules.Rules {
+			if m = or(m, r.matchPermission(p)); m == matched {
+				break
+			}
+		}
+		return m
+	case *rbacpb.Permission_NotRule:
+		return r.matchPermission(rule.NotRule).not()
+	case *rbacpb.Permission_Header:
+		return r.matchHeader(rule.Header)
+	case *rbacpb.Permission_UrlPath:
+		if r.path == "" {
+			return r.cannotSimulate("the path of the request is not set")
+		}
+		return toMatch(matchString(rule.UrlPath.GetPath(), r.path, true))
+	case *rbacpb.Permission_DestinationPort:
+		return toMatch(rule.DestinationPort == r.port)
+	case *rbacpb.Permission_DestinationIp:
+		return r.cannotSimulate("the destination IP is not simulated")
+	case *rbacpb.Permission_RequestedServerName:
+		return r.cannotSimulate("the SNI is not simulated")
+	case *rbacpb.Permission_Metadata:
+		return r.matchMetadata(rule.Metadata)
+	default:
+		return r.cannotSimulate(fmt.Sprintf("unsupported permission %T", p.Rule))
+	}
+}
+
+func (r *checkRequest) matchPrincipal(p *rbacpb.Principal) match {
+	switch id := p.Identifier.(type) {
+	case *rbacpb.Principal_Any:
+		return toMatch(id.Any)
+	case *rbacpb.Principal_AndIds:
+		m := matched
+		for _, p := range id.AndIds.Ids {
+			if m = and(m, r.matchPrincipal(p)); m == noMatch {
+				break
+			}
+		}
+		return m
+	case *rbacpb.Principal_OrIds:
+		m := noMatch
+		for _, p := range id.OrIds.Ids {
+			if m = or(m, r.matchPrincipal(p)); m == matched {
+				break
+			}
+		}
+		return m
+	case *rbacpb.Principal_NotId:
+		return r.matchPrincipal(id.NotId).not()
+	case *rbacpb.Principal_Authenticated_:
+		if id.Authenticated.PrincipalName == nil {
+			return toMatch(r.authenticated())
+		}
+		return r.matchPeerPrincipal(id.Authenticated.PrincipalName, spiffe.URIPrefix)
+	case *rbacpb.Principal_SourceIp:
+		if r.sourceIP == nil {
+			return r.cannotSimulate("the source IP is not set")
+		}
+		return toMatch(matchCidr(id.SourceIp, r.sourceIP))
+	case *rbacpb.Principal_Header:
+		return r.matchHeader(id.Header)
+	case *rbacpb.Principal_Metadata:
+		return r.matchMetadata(id.Metadata)
+	default:
+		return r.cannotSimulate(fmt.Sprintf("unsupported principal %T", p.Identifier))
+	}
+}
+
+// matchPeerPrincipal matches the peer principal, with the given URI prefix. When only the namespace of the
+// peer is known, the result is unknown if it depends on the service account.
+func (r *checkRequest) matchPeerPrincipal(m *matcher.StringMatcher, uriPrefix string) match {
+	if r.principalPrefix == "" {
+		return toMatch(matchString(m, uriPrefix+r.principal, r.principal != ""))
+	}
+	known := uriPrefix + r.principalPrefix
+	switch p := m.GetMatchPattern().(type) {
+	case *matcher.StringMatcher_Exact:
+		if !strings.HasPrefix(p.Exact, known) {
+			return noMatch
+		}
+	case *matcher.StringMatcher_Prefix:
+		if strings.HasPrefix(known, p.Prefix) {
+			return matched
+		}
+		if !strings.HasPrefix(p.Prefix, known) {
+			return noMatch
+		}
+	case *matcher.StringMatcher_SafeRegex:
+		// Istio only generates regexes from source namespaces, which don't depend on the service account.
+		// Two service accounts are tried to detect the other ones.
+		regex := p.SafeRegex.GetRegex()
+		if first := matchRegex(regex, known+"first"); first == matchRegex(regex, known+"second") {
+			return toMatch(first)
+		}
+	}
+	return r.cannotSimulate("the service account of the source is not set")
+}
+
+func (r *checkRequest) matchHeader(h *route.HeaderMatcher) match {
+	value, found := r.headers[strings.ToLower(h.Name)]
+	if !found && strings.HasPrefix(h.Name, ":") {
+		// Pseudo-headers are always set by Envoy.
+		return r.cannotSimulate(fmt.Sprintf("the %s header of the request is not set", h.Name))
+	}
+	var result bool
+	switch m := h.HeaderMatchSpecifier.(type) {
+	case *route.HeaderMatcher_ExactMatch:
+		result = found && value == m.ExactMatch
+	case *route.HeaderMatcher_PrefixMatch:
+		result = found && strings.HasPrefix(value, m.PrefixMatch)
+	case *route.HeaderMatcher_SuffixMatch:
+		result = found && strings.HasSuffix(value, m.SuffixMatch)
+	case *route.HeaderMatcher_SafeRegexMatch:
+		result = found && matchRegex(m.SafeRegexMatch.GetRegex(), value)
+	case *route.HeaderMatcher_PresentMatch:
+		result = found == m.PresentMatch
+	case nil:
+		result = found
+	default:
+		return r.cannotSimulate(fmt.Sprintf("unsupported header matcher %T", h.HeaderMatchSpecifier))
+	}
+	if h.InvertMatch {
+		return toMatch(!result)
+	}
+	return toMatch(result)
+}
+
+// matchMetadata matches the principals set by the Istio authn filter. Without a JWT, the request.auth
+// metadata are not set, so they never match. The JWT claims and the metadata of other filters can't be
+// simulated.
+func (r *checkRequest) matchMetadata(m *matcher.MetadataMatcher) match {
+	if m.Filter != authn_model.AuthnFilterName || len(m.Path) == 0 {
+		return r.cannotSimulate(fmt.Sprintf("the metadata of filter %s are not simulated", m.Filter))
+	}
+	key := m.Path[0].GetKey()
+	switch {
+	case key == "source.principal" && len(m.Path) == 1:
+		if s, ok := m.Value.GetMatchPattern().(*matcher.ValueMatcher_StringMatch); ok {
+			return r.matchPeerPrincipal(s.StringMatch, "")
+		}
+		return r.matchValue(m.Value, r.principal, r.authenticated())
+	case key == "request.auth.principal" && len(m.Path) == 1:
+		return r.matchValue(m.Value, r.requestPrincipal, r.requestPrincipal != "")
+	case strings.HasPrefix(key, "request.auth.") && r.requestPrincipal == "":
+		return r.matchValue(m.Value, "", false)
+	default:
+		return r.cannotSimulate(fmt.Sprintf("the %s metadata are not simulated", key))
+	}
+}
+
+func (r *checkRequest) matchValue(v *matcher.ValueMatcher, value string, found bool) match {
+	switch p := v.GetMatchPattern().(type) {
+	case *matcher.ValueMatcher_StringMatch:
+		return toMatch(matchString(p.StringMatch, value, found))
+	case *matcher.ValueMatcher_PresentMatch:
+		return toMatch(found == p.PresentMatch)
+	default:
+		if !found {
+			return noMatch
+		}
+		return r.cannotSimulate(fmt.Sprintf("unsupported value matcher %T", v.GetMatchPattern()))
+	}
+}
+
+func matchString(m *matcher.StringMatcher, value string, found bool) bool {
+	if m == nil || !found {
+		return false
+	}
+	switch p := m.MatchPattern.(type) {
+	case *matcher.StringMatcher_Exact:
+		return value == p.Exact
+	case *matcher.StringMatcher_Prefix:
+		return strings.HasPrefix(value, p.Prefix)
+	case *matcher.StringMatcher_Suffix:
+		return strings.HasSuffix(value, p.Suffix)
+	case *matcher.StringMatcher_SafeRegex:
+		return matchRegex(p.SafeRegex.GetRegex(), value)
+	default:
+		return false
+	}
+}
+
+// matchRegex matches the whole value, as RE2 full matches do in Envoy.
+func matchRegex(regex, value string) bool {
+	re, err := regexp.Compile("^(?:" + regex + ")$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(value)
+}
+
+func matchCidr(cidr *core.CidrRange, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	_, network, err := net.ParseCIDR(cidr.GetAddressPrefix() + "/" + strconv.FormatUint(uint64(cidr.GetPrefixLen().GetValue()), 10))
+	if err != nil {
+		return false
+	}
+	return network.Contains(ip)
+}
@@ -0,0 +1,334 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	rbachttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/rbac/v3"
+	"github.com/golang/protobuf/ptypes"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/authn/v1beta1"
+	"istio.io/istio/pilot/pkg/security/authz/builder"
+	authzmodel "istio.io/istio/pilot/pkg/security/authz/model"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/collections"
+	authn_alpha "istio.io/istio/security/proto/authentication/v1alpha1"
+	authn_filter "istio.io/istio/security/proto/envoy/config/filter/http/authn/v2alpha1"
+)
+
+// Decision is the result of the authorization of a request.
+type Decision string
+
+const (
+	Allow Decision = "ALLOW"
+	Deny  Decision = "DENY"
+	// Custom means the decision is delegated to the external authorization provider of a CUSTOM policy.
+	Custom Decision = "CUSTOM"
+	// Unknown means the decision depends on attributes that are not part of the request, or that the
+	// simulator doesn't support.
+	Unknown Decision = "UNKNOWN"
+)
+
+var ruleNameRegex = regexp.MustCompile(`^ns\[(.*)\]-policy\[(.*)\]-rule\[(\d+)\]$`)
+
+// Request describes a request to a workload for the simulator.
+type Request struct {
+	// Namespace and Labels of the destination workload, and the destination Port.
+	Namespace string
+	Labels    map[string]string
+	Port      int
+
+	// SourcePrincipal is the peer identity, e.g. cluster.local/ns/foo/sa/bar. If it's empty and SourceNamespace
+	// is set, the service account of the peer is unknown.
+	SourcePrincipal string
+	SourceNamespace string
+	SourceIP        string
+	// RequestPrincipal is the <iss>/<sub> of a validated JWT, if any.
+	RequestPrincipal string
+
+	Method  string
+	Host    string
+	Path    string
+	Headers map[string]string
+
+	// Plaintext is true if the request doesn't use mutual TLS.
+	Plaintext bool
+}
+
+// Result is the decision for a request, and the policy which made it.
+type Result struct {
+	Decision Decision
+	// Policy is the <namespace>/<name> of the policy which made the decision, and Rule the index of its
+	// matching rule. Policy is empty if no policy matched.
+	Policy string
+	Rule   int
+	Reason string
+	// DryRun are the results of the dry-run policies.
+	DryRun []string
+}
+
+// Print writes the result in a human readable format.
+func (r *Result) Print(w io.Writer) {
+	_, _ = fmt.Fprintf(w, "%s: %s\n", r.Decision, r.Reason)
+	for _, dryRun := range r.DryRun {
+		_, _ = fmt.Fprintf(w, "  dry-run: %s\n", dryRun)
+	}
+}
+
+// Simulator evaluates requests against authorization and peer authentication policies, without a cluster.
+// The policies go through the same generation as in Pilot, and the resulting RBAC filters are evaluated
+// the way Envoy does.
+type Simulator struct {
+	trustDomain string
+	authz       *model.AuthorizationPolicies
+	authn       *model.AuthenticationPolicies
+}
+
+// NewSimulator returns a simulator for the AuthorizationPolicy and PeerAuthentication configs. Other
+// configs are ignored.
+func NewSimulator(configs []model.Config, rootNamespace, trustDomain string) (*Simulator, error) {
+	store := model.MakeIstioStore(memory.Make(collections.Pilot))
+	for _, c := range configs {
+		switch c.GroupVersionKind() {
+		case collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().GroupVersionKind(),
+			collections.IstioSecurityV1Beta1Peerauthentications.Resource().GroupVersionKind():
+			if _, err := store.Create(c); err != nil {
+				return nil, fmt.Errorf("failed to add %s %s/%s: %v", c.Type, c.Namespace, c.Name, err)
+			}
+		}
+	}
+
+	env := &model.Environment{
+		IstioConfigStore: store,
+		Watcher:          mesh.NewFixedWatcher(&meshconfig.MeshConfig{RootNamespace: rootNamespace}),
+	}
+	authz, err := model.GetAuthorizationPolicies(env)
+	if err != nil {
+		return nil, err
+	}
+	authn, err := model.GetAuthenticationPolicies(env)
+	if err != nil {
+		return nil, err
+	}
+	return &Simulator{trustDomain: trustDomain, authz: authz, authn: authn}, nil
+}
+
+// Simulate returns the decision for a request.
+func (s *Simulator) Simulate(req Request) (*Result, error) {
+	workload := labels.Collection{req.Labels}
+
+	principal, principalPrefix := req.SourcePrincipal, ""
+	if principal == "" && req.SourceNamespace != "" {
+		principalPrefix = fmt.Sprintf("%s/ns/%s/sa/", s.trustDomain, req.SourceNamespace)
+	}
+	mode, err := s.mutualTLSMode(req.Namespace, workload, uint32(req.Port))
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case mode == model.MTLSStrict && req.Plaintext:
+		return &Result{Decision: Deny, Reason: "plaintext request rejected, PeerAuthentication requires mutual TLS"}, nil
+	case mode == model.MTLSDisable || req.Plaintext:
+		// The peer identity is only known with mutual TLS.
+		principal, principalPrefix = "", ""
+	}
+
+	b := builder.New(trustdomain.NewBundle(s.trustDomain, nil), workload, req.Namespace, s.authz, nil, true)
+	if b == nil {
+		return &Result{Decision: Allow, Reason: "no authorization policy applies to the workload"}, nil
+	}
+
+	check := newCheckRequest(req, principal, principalPrefix)
+	var custom *Result
+	// undecided describes the rules which could change the decision, but can't be simulated.
+	var undecided, customUndecided []string
+	if rules := b.BuildCustomRules(); rules != nil {
+		e := check.evaluate(rules)
+		if e.matched {
+			policy, rule := parseRuleName(strings.TrimPrefix(e.name, builder.CustomPolicyPrefix))
+			custom = &Result{
+				Decision: Custom,
+				Policy:   policy,
+				Rule:     rule,
+				Reason:   fmt.Sprintf("delegated to the ext_authz provider of policy %s, rule %d", policy, rule),
+			}
+		}
+		customUndecided = e.unknown
+	}
+
+	result := &Result{Decision: Allow, Reason: "no DENY policy matched, and no ALLOW policy applies to the workload"}
+	for _, filter := range b.BuildHTTP() {
+		if filter.Name != authzmodel.RBACHTTPFilterName {
+			continue
+		}
+		config := &rbachttppb.RBAC{}
+		if err := ptypes.UnmarshalAny(filter.GetTypedConfig(), config); err != nil {
+			return nil, fmt.Errorf("failed to parse RBAC filter: %v", err)
+		}
+
+		if shadow := config.ShadowRules; shadow != nil {
+			result.DryRun = append(result.DryRun, describe(shadow.Action, check.evaluate(shadow), "would be"))
+		}
+
+		if rules := config.Rules; rules != nil {
+			e := check.evaluate(rules)
+			if !e.matched && len(e.unknown) > 0 {
+				// A later DENY policy may still decide.
+				undecided = append(undecided, e.unknown...)
+				continue
+			}
+			if rules.Action == rbacpb.RBAC_DENY && !e.matched {
+				continue
+			}
+			result.Decision = Allow
+			if rules.Action == rbacpb.RBAC_DENY || !e.matched {
+				result.Decision = Deny
+			}
+			result.Policy, result.Rule = parseRuleName(e.name)
+			result.Reason = describe(rules.Action, e, "")
+			if provider := s.provider(result.Policy); rules.Action == rbacpb.RBAC_DENY && provider != "" {
+				result.Reason = fmt.Sprintf("denied by CUSTOM policy %s, rule %d: its provider %s is not found, or is "+
+					"not the only provider of the workload", result.Policy, result.Rule, provider)
+			}
+			if result.Decision == Deny {
+				return result, nil
+			}
+		}
+	}
+
+	if custom == nil {
+		undecided = append(undecided, customUndecided...)
+	}
+	if len(undecided) > 0 {
+		return &Result{Decision: Unknown, Reason: "cannot simulate " + strings.Join(undecided, "; "), DryRun: result.DryRun}, nil
+	}
+
+	// The ext_authz provider is called before the DENY and ALLOW policies are evaluated, but only decides the
+	// requests they allow.
+	if custom != nil {
+		custom.DryRun = result.DryRun
+		return custom, nil
+	}
+	return result, nil
+}
+
+// provider returns the provider of a CUSTOM policy, given its <namespace>/<name>, or "" for other policies.
+func (s *Simulator) provider(policy string) string {
+	parts := strings.SplitN(policy, "/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	for _, p := range s.authz.NamespaceToPolicies[parts[0]] {
+		if p.Name == parts[1] {
+			return p.Provider
+		}
+	}
+	return ""
+}
+
+// mutualTLSMode returns the mutual TLS mode of the PeerAuthentication policies for a workload port.
+func (s *Simulator) mutualTLSMode(namespace string, workload labels.Collection, port uint32) (model.MutualTLSMode, error) {
+	policies := s.authn.GetPeerAuthenticationsForWorkload(namespace, workload)
+	filter := v1beta1.NewPolicyApplier(s.authn.GetRootNamespace(), nil, policies).AuthNFilter(model.SidecarProxy, port)
+	if filter == nil {
+		return model.MTLSPermissive, nil
+	}
+	config := &authn_filter.FilterConfig{}
+	if err := ptypes.UnmarshalAny(filter.GetTypedConfig(), config); err != nil {
+		return model.MTLSUnknown, fmt.Errorf("failed to parse authentication filter: %v", err)
+	}
+	peers := config.GetPolicy().GetPeers()
+	if len(peers) == 0 {
+		return model.MTLSDisable, nil
+	}
+	if peers[0].GetMtls().GetMode() == authn_alpha.MutualTls_STRICT {
+		return model.MTLSStrict, nil
+	}
+	return model.MTLSPermissive, nil
+}
+
+// describe returns a description of the result of RBAC rules.
+func describe(action rbacpb.RBAC_Action, e evaluation, verb string) string {
+	if !e.matched && len(e.unknown) > 0 {
+		return "cannot simulate " + strings.Join(e.unknown, "; ")
+	}
+	if verb != "" {
+		verb += " "
+	}
+	policy, rule := parseRuleName(e.name)
+	switch {
+	case action == rbacpb.RBAC_DENY && e.matched:
+		return fmt.Sprintf("%sdenied by policy %s, rule %d", verb, policy, rule)
+	case action == rbacpb.RBAC_DENY:
+		return fmt.Sprintf("%sallowed, no DENY policy matched", verb)
+	case e.matched:
+		return fmt.Sprintf("%sallowed by policy %s, rule %d", verb, policy, rule)
+	default:
+		return fmt.Sprintf("%sdenied, no ALLOW policy matched", verb)
+	}
+}
+
+// parseRuleName returns the <namespace>/<name> of the policy and the rule index from a generated RBAC policy name.
+func parseRuleName(name string) (string, int) {
+	parts := ruleNameRegex.FindStringSubmatch(name)
+	if parts == nil {
+		return name, 0
+	}
+	rule, _ := strconv.Atoi(parts[3])
+	return parts[1] + "/" + parts[2], rule
+}
+
+// evaluation is the result of RBAC rules for a request.
+type evaluation struct {
+	// name is the first matching policy, in name order as Envoy does, if matched is true.
+	name    string
+	matched bool
+	// unknown describes the policies that can't be simulated. They may match the request.
+	unknown []string
+}
+
+// evaluate returns the first policy matching the request, or the policies that can't be simulated.
+func (r *checkRequest) evaluate(rules *rbacpb.RBAC) evaluation {
+	names := make([]string, 0, len(rules.Policies))
+	for name := range rules.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var e evaluation
+	for _, name := range names {
+		r.reasons = nil
+		switch r.matchPolicy(rules.Policies[name]) {
+		case matched:
+			return evaluation{name: name, matched: true}
+		case unknown:
+			policy, rule := parseRuleName(strings.TrimPrefix(name, builder.CustomPolicyPrefix))
+			e.unknown = append(e.unknown, fmt.Sprintf("policy %s, rule %d: %s", policy, rule, strings.Join(r.reasons, ", ")))
+		}
+	}
+	return e
+}
@@ -0,0 +1,256 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+)
+
+const simulatorPolicies = `
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: deny-admin
+  namespace: foo
+spec:
+  action: DENY
+  rules:
+  - to:
+    - operation:
+        paths: ["/admin/*"]
+    when:
+    - key: request.headers[x-override]
+      notValues: ["yes"]
+---
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: allow-sleep
+  namespace: foo
+spec:
+  selector:
+    matchLabels:
+      app: httpbin
+  rules:
+  - from:
+    - source:
+        namespaces: ["foo"]
+    to:
+    - operation:
+        methods: ["GET"]
+  - from:
+    - source:
+        principals: ["cluster.local/ns/bar/sa/admin"]
+---
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: audit-post
+  namespace: foo
+  annotations:
+    istio.io/dry-run: "true"
+spec:
+  action: DENY
+  rules:
+  - to:
+    - operation:
+        methods: ["POST"]
+---
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: ext-authz
+  namespace: foo
+  annotations:
+    istio.io/ext-authz-provider: missing
+spec:
+  selector:
+    matchLabels:
+      app: ext
+  rules:
+  - to:
+    - operation:
+        paths: ["/check/*"]
+---
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: deny-ip
+  namespace: foo
+spec:
+  selector:
+    matchLabels:
+      app: ip
+  action: DENY
+  rules:
+  - when:
+    - key: destination.ip
+      notValues: ["10.0.0.1"]
+---
+apiVersion: security.istio.io/v1beta1
+kind: AuthorizationPolicy
+metadata:
+  name: allow-jwt
+  namespace: foo
+spec:
+  selector:
+    matchLabels:
+      app: jwt
+  rules:
+  - when:
+    - key: request.auth.claims[group]
+      values: ["admin"]
+---
+apiVersion: security.istio.io/v1beta1
+kind: PeerAuthentication
+metadata:
+  name: strict
+  namespace: foo
+spec:
+  selector:
+    matchLabels:
+      app: strict
+  mtls:
+    mode: STRICT
+`
+
+func TestSimulator(t *testing.T) {
+	configs, _, err := crd.ParseInputs(simulatorPolicies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSimulator(configs, "istio-system", "cluster.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpbin := map[string]string{"app": "httpbin"}
+	cases := []struct {
+		name     string
+		req      Request
+		decision Decision
+		policy   string
+		rule     int
+		dryRun   int
+		reason   string
+	}{
+		{
+			name:     "allowed by source namespace",
+			req:      Request{Namespace: "foo", Labels: httpbin, SourceNamespace: "foo", Method: "GET", Path: "/ip"},
+			decision: Allow,
+			policy:   "foo/allow-sleep",
+			dryRun:   1,
+		},
+		{
+			name:     "no allow policy matched",
+			req:      Request{Namespace: "foo", Labels: httpbin, SourceNamespace: "foo", Method: "PUT", Path: "/ip"},
+			decision: Deny,
+			dryRun:   1,
+		},
+		{
+			name: "allowed by principal",
+			req: Request{Namespace: "foo", Labels: httpbin, SourcePrincipal: "cluster.local/ns/bar/sa/admin",
+				Method: "POST", Path: "/ip"},
+			decision: Allow,
+			policy:   "foo/allow-sleep",
+			rule:     1,
+			dryRun:   1,
+		},
+		{
+			name:     "denied by path",
+			req:      Request{Namespace: "foo", Labels: httpbin, SourceNamespace: "foo", Method: "GET", Path: "/admin/users?all"},
+			decision: Deny,
+			policy:   "foo/deny-admin",
+			dryRun:   1,
+		},
+		{
+			name: "header excluded from deny",
+			req: Request{Namespace: "foo", Labels: httpbin, SourceNamespace: "foo", Method: "GET", Path: "/admin/users",
+				Headers: map[string]string{"X-Override": "yes"}},
+			decision: Allow,
+			policy:   "foo/allow-sleep",
+			dryRun:   1,
+		},
+		{
+			name:     "no peer identity without mutual TLS",
+			req:      Request{Namespace: "foo", Labels: httpbin, SourceNamespace: "foo", Method: "GET", Path: "/ip", Plaintext: true},
+			decision: Deny,
+			dryRun:   1,
+		},
+		{
+			name:     "plaintext rejected by strict mode",
+			req:      Request{Namespace: "foo", Labels: map[string]string{"app": "strict"}, Method: "GET", Path: "/", Plaintext: true},
+			decision: Deny,
+		},
+		{
+			name:     "custom policy with unknown provider",
+			req:      Request{Namespace: "foo", Labels: map[string]string{"app": "ext"}, Method: "GET", Path: "/check/ip"},
+			decision: Deny,
+			policy:   "foo/ext-authz",
+			reason: "denied by CUSTOM policy foo/ext-authz, rule 0: its provider missing is not found, or is not the " +
+				"only provider of the workload",
+		},
+		{
+			name:     "service account of the source not set",
+			req:      Request{Namespace: "foo", Labels: httpbin, SourceNamespace: "bar", Method: "POST", Path: "/ip"},
+			decision: Unknown,
+			dryRun:   1,
+			reason:   "cannot simulate policy foo/allow-sleep, rule 1: the service account of the source is not set",
+		},
+		{
+			name:     "destination IP not simulated",
+			req:      Request{Namespace: "foo", Labels: map[string]string{"app": "ip"}, Method: "GET", Path: "/"},
+			decision: Unknown,
+			dryRun:   1,
+			reason:   "cannot simulate policy foo/deny-ip, rule 0: the destination IP is not simulated",
+		},
+		{
+			name: "JWT claims not simulated",
+			req: Request{Namespace: "foo", Labels: map[string]string{"app": "jwt"}, RequestPrincipal: "iss/sub",
+				Method: "GET", Path: "/"},
+			decision: Unknown,
+			dryRun:   1,
+			reason:   "cannot simulate policy foo/allow-jwt, rule 0: the request.auth.claims metadata are not simulated",
+		},
+		{
+			name:     "no JWT claims without JWT",
+			req:      Request{Namespace: "foo", Labels: map[string]string{"app": "jwt"}, Method: "GET", Path: "/"},
+			decision: Deny,
+			dryRun:   1,
+		},
+		{
+			name:     "no policy for workload",
+			req:      Request{Namespace: "bar", Labels: httpbin, Method: "GET", Path: "/"},
+			decision: Allow,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.Simulate(tc.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Decision != tc.decision || got.Policy != tc.policy || got.Rule != tc.rule || len(got.DryRun) != tc.dryRun {
+				t.Errorf("got %+v, want %s by %s rule %d with %d dry-run results", got, tc.decision, tc.policy, tc.rule, tc.dryRun)
+			}
+			if tc.reason != "" && got.Reason != tc.reason {
+				t.Errorf("got reason %q, want %q", got.Reason, tc.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+)
+
+// JUnitWriter writes analysis messages as a JUnit XML report, with a test case for each message. Messages at
+// FailureLevel or worse are reported as failed test cases, so that CI systems fail the build on them.
+type JUnitWriter struct {
+	Writer       io.Writer
+	FailureLevel diag.Level
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Write writes the messages as a JUnit XML report with a single test suite.
+func (j *JUnitWriter) Write(messages diag.Messages) error {
+	suite := junitTestSuite{
+		Name:      "istioctl analyze",
+		TestCases: []junitTestCase{},
+	}
+	for _, m := range messages {
+		file, line := location(m)
+		tc := junitTestCase{
+			Name:      m.Type.Code(),
+			ClassName: file,
+			File:      file,
+			Line:      line,
+		}
+		if name := resourceName(m); name != "" {
+			tc.Name = fmt.Sprintf("%s %s", m.Type.Code(), name)
+		}
+		if tc.ClassName == "" {
+			tc.ClassName = "istioctl analyze"
+		}
+
+		details := fmt.Sprintf("%s\nSee %s", m.String(), documentationURL(m))
+		if m.Type.Level().IsWorseThanOrEqualTo(j.FailureLevel) {
+			tc.Failure = &junitFailure{
+				Message: messageText(m),
+				Type:    m.Type.Level().String(),
+				Text:    details,
+			}
+			suite.Failures++
+		} else {
+			tc.SystemOut = details
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+		suite.Tests++
+	}
+
+	out, err := xml.MarshalIndent(junitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(j.Writer, "%s%s\n", xml.Header, out)
+	return err
+}
@@ -0,0 +1,48 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+)
+
+// location returns the file and line a message refers to. The file is empty for resources that weren't read from a
+// file, e.g. those read from a live cluster, and the line is 0 when it isn't known.
+func location(m diag.Message) (string, int) {
+	pos, ok := m.Reference().(*rt.Position)
+	if !ok || pos == nil || pos.Filename == "" || pos.Filename == "-" {
+		return "", 0
+	}
+	return filepath.ToSlash(pos.Filename), pos.Line
+}
+
+func resourceName(m diag.Message) string {
+	if m.Resource == nil || m.Resource.Origin == nil {
+		return ""
+	}
+	return m.Resource.Origin.FriendlyName()
+}
+
+func messageText(m diag.Message) string {
+	return fmt.Sprintf(m.Type.Template(), m.Parameters...)
+}
+
+func documentationURL(m diag.Message) string {
+	return fmt.Sprintf("%s/%s", diag.DocPrefix, m.Type.Code())
+}
@@ -0,0 +1,85 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/resource"
+)
+
+func testMessages() diag.Messages {
+	fromFile := &resource.Instance{
+		Origin: &rt.Origin{
+			Kind:     "VirtualService",
+			FullName: resource.NewFullName("default", "reviews"),
+			Ref: &rt.Position{
+				Filename:   "config/reviews.yaml",
+				Line:       3,
+				FieldLines: map[string]int{"spec.http[1]": 12},
+			},
+		},
+	}
+	fromCluster := &resource.Instance{
+		Origin: &rt.Origin{
+			Kind:     "Service",
+			FullName: resource.NewFullName("default", "details"),
+		},
+	}
+
+	shadowed := diag.NewMessage(diag.NewMessageType(diag.Warning, "IST0127", "The match %s is shadowed"), fromFile, "http[1]")
+	shadowed.Path = "spec.http[1]"
+	return diag.Messages{
+		diag.NewMessage(diag.NewMessageType(diag.Error, "IST0101", "Referenced %s not found: %q"), fromFile, "host", "ratings"),
+		shadowed,
+		diag.NewMessage(diag.NewMessageType(diag.Info, "IST0118", "Port name %s doesn't follow the naming convention"), fromCluster, "foo"),
+	}
+}
+
+func TestSARIFWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &SARIFWriter{Writer: &out}
+	assert.NoError(t, w.Write(testMessages()))
+	assertGolden(t, "testdata/analysis.sarif", out.String())
+}
+
+func TestSARIFWriter_NoMessages(t *testing.T) {
+	var out bytes.Buffer
+	w := &SARIFWriter{Writer: &out}
+	assert.NoError(t, w.Write(nil))
+	assert.Contains(t, out.String(), `"results": []`)
+}
+
+func TestJUnitWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := &JUnitWriter{Writer: &out, FailureLevel: diag.Warning}
+	assert.NoError(t, w.Write(testMessages()))
+	assertGolden(t, "testdata/analysis.junit.xml", out.String())
+}
+
+func assertGolden(t *testing.T, file, got string) {
+	t.Helper()
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(want), got)
+}
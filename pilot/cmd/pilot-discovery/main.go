@@ -33,6 +33,7 @@ import (
 	"istio.io/istio/pilot/pkg/bootstrap"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pilot/pkg/serviceregistry/file"
 	"istio.io/istio/pkg/cmd"
 	"istio.io/istio/pkg/config/constants"
 	"istio.io/istio/pkg/spiffe"
@@ -116,8 +117,8 @@ func init() {
 	// Process commandline args.
 	discoveryCmd.PersistentFlags().StringSliceVar(&serverArgs.RegistryOptions.Registries, "registries",
 		[]string{string(serviceregistry.Kubernetes)},
-		fmt.Sprintf("Comma separated list of platform service registries to read from (choose one or more from {%s, %s, %s})",
-			serviceregistry.Kubernetes, serviceregistry.Consul, serviceregistry.Mock))
+		fmt.Sprintf("Comma separated list of platform service registries to read from (choose one or more from {%s, %s, %s, %s})",
+			serviceregistry.Kubernetes, serviceregistry.Consul, serviceregistry.File, serviceregistry.Mock))
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.ClusterRegistriesNamespace, "clusterRegistriesNamespace",
 		serverArgs.RegistryOptions.ClusterRegistriesNamespace, "Namespace for ConfigMap which stores clusters configs")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.KubeConfig, "kubeconfig", "",
@@ -154,6 +155,10 @@ func init() {
 		"The domain serves to identify the system with spiffe")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.ConsulServerAddr, "consulserverURL", "",
 		"URL for the Consul server")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.RegistryOptions.FileRegistryPath, "fileRegistryPath", "",
+		"Directory or file with the services of the File registry, or an http(s) URL serving them")
+	discoveryCmd.PersistentFlags().DurationVar(&serverArgs.RegistryOptions.FileRegistryPollInterval, "fileRegistryPollInterval",
+		file.DefaultPollInterval, "Interval at which the File registry services are read again")
 
 	// using address, so it can be configured as localhost:.. (possibly UDS in future)
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.ServerOptions.HTTPAddr, "httpAddr", ":8080",
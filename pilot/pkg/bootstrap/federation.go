@@ -0,0 +1,137 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"crypto"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// initFederatedTrustBundles loads the trust bundles of PILOT_FEDERATED_TRUST_BUNDLES_DIR and watches the directory.
+// When the bundles change, e.g. on an update of the mounted ConfigMap, the federated trust domains are updated
+// and a full push is triggered. The roots of the federated trust domains are cross-signed by the CA, and published
+// with its root cert: proxies don't trust the federated roots themselves, which could sign any identity.
+func (s *Server) initFederatedTrustBundles(trustDomain string) {
+	dir := features.FederatedTrustBundlesDir
+	if dir == "" {
+		return
+	}
+	if s.ca == nil {
+		log.Warnf("Istiod is not the CA, the roots of the federated trust domains are not published to the proxies")
+	}
+	s.loadFederatedTrustBundles(dir, trustDomain)
+
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return err
+		}
+		// Watch the directory, to see the bundles added to it and the symlink updates of a mounted ConfigMap.
+		if err := watcher.Add(dir); err != nil {
+			_ = watcher.Close()
+			return fmt.Errorf("could not watch %v: %v", dir, err)
+		}
+		go func() {
+			defer watcher.Close()
+			var timerC <-chan time.Time
+			for {
+				select {
+				case <-timerC:
+					timerC = nil
+					if s.loadFederatedTrustBundles(dir, trustDomain) {
+						s.EnvoyXdsServer.ConfigUpdate(&model.PushRequest{
+							Full:   true,
+							Reason: []model.TriggerReason{model.GlobalUpdate},
+						})
+					}
+				case <-watcher.Events:
+					if timerC == nil {
+						timerC = time.After(watchDebounceDelay)
+					}
+				case err := <-watcher.Errors:
+					log.Errorf("error watching %v: %v", dir, err)
+				case <-stop:
+					return
+				}
+			}
+		}()
+		return nil
+	})
+}
+
+// loadFederatedTrustBundles loads the federated trust bundles in a directory and returns true if the federated
+// trust domains changed. The bundles previously loaded are kept on error.
+func (s *Server) loadFederatedTrustBundles(dir, trustDomain string) bool {
+	bundles, err := spiffe.LoadTrustBundles(dir)
+	if err != nil {
+		log.Errorf("failed to load federated trust bundles: %v", err)
+		return false
+	}
+	roots, err := s.crossSignTrustBundles(bundles, trustDomain)
+	if err != nil {
+		log.Errorf("failed to cross-sign federated trust bundles: %v", err)
+		return false
+	}
+	s.federatedRootsMu.Lock()
+	s.federatedRoots = roots
+	s.federatedRootsMu.Unlock()
+
+	trustDomains := bundles.TrustDomains()
+	if reflect.DeepEqual(trustDomains, trustdomain.FederatedTrustDomains()) {
+		return false
+	}
+	log.Infof("loaded federated trust domains %v", trustDomains)
+	trustdomain.SetFederatedTrustDomains(trustDomains)
+	return true
+}
+
+// crossSignTrustBundles returns the roots of the federated trust bundles cross-signed by the CA, followed by the
+// certificate chain of the CA, so they chain to its root cert.
+func (s *Server) crossSignTrustBundles(bundles spiffe.TrustBundles, trustDomain string) ([]byte, error) {
+	if s.ca == nil {
+		return nil, nil
+	}
+	cert, key, certChain, _ := s.ca.GetCAKeyCertBundle().GetAll()
+	if cert == nil || key == nil {
+		return nil, fmt.Errorf("the CA has no signing certificate")
+	}
+	signer, ok := (*key).(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the CA key is not a signer")
+	}
+	roots, err := bundles.CrossSign(trustDomain, cert, signer)
+	if err != nil || len(roots) == 0 {
+		return nil, err
+	}
+	return append(roots, certChain...), nil
+}
+
+// getFederatedRoots returns the cross-signed roots of the federated trust domains.
+func (s *Server) getFederatedRoots() []byte {
+	s.federatedRootsMu.RLock()
+	defer s.federatedRootsMu.RUnlock()
+	return s.federatedRoots
+}
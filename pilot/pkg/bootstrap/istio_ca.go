@@ -31,6 +31,8 @@ import (
 	"istio.io/istio/pkg/jwt"
 
 	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	securityModel "istio.io/istio/pilot/pkg/security/model"
 
 	"google.golang.org/grpc"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -41,6 +43,9 @@ import (
 	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/security/pkg/cmd"
 	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/revocation"
+	"istio.io/istio/security/pkg/pki/signer"
+	"istio.io/istio/security/pkg/pki/util"
 	caserver "istio.io/istio/security/pkg/server/ca"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 )
@@ -55,6 +60,9 @@ import (
 //
 // Support for signing other root CA has been removed - too dangerous, no clear use case.
 //
+// If CA_SIGNER is set, the private key of the user-provided root CA is held by the signer instead of ca-key.pem,
+// so it never enters the memory of istiod.
+//
 // Default config, for backward compat with Citadel:
 // - if "cacerts" secret exists in istio-system, will be mounted. It may contain an optional "root-cert.pem",
 // with additional roots and optional {ca-key, ca-cert, cert-chain}.pem user-provided root CA.
@@ -72,6 +80,41 @@ var (
 	LocalCertDir = env.RegisterStringVar("ROOT_CA_DIR", "./etc/cacerts",
 		"Location of a local or mounted CA root")
 
+	// caSigner holds the private key of the CA certificate in LocalCertDir, instead of ca-key.pem.
+	caSigner = env.RegisterStringVar("CA_SIGNER", "",
+		"The signer holding the private key of the CA certificate ca-cert.pem in ROOT_CA_DIR, instead of ca-key.pem. "+
+			"One of file:///path/to/key.pem, unix:///path/to/socket or grpc://host:port, the two latter serving the "+
+			"istio.security.signer.v1alpha1.Signer gRPC service, e.g. in front of an HSM or a KMS. The unix socket "+
+			"is not authenticated, its file permissions must restrict it to istiod. grpc:// uses mutual TLS, "+
+			"configured by CA_SIGNER_ROOT_CERT, CA_SIGNER_CLIENT_CERT and CA_SIGNER_CLIENT_KEY.")
+
+	caSignerRootCert = env.RegisterStringVar("CA_SIGNER_ROOT_CERT", "",
+		"The PEM encoded CA bundle verifying a grpc:// CA_SIGNER. The system roots are used if it is empty.")
+
+	caSignerClientCert = env.RegisterStringVar("CA_SIGNER_CLIENT_CERT", "",
+		"The PEM encoded client certificate authenticating istiod to a grpc:// CA_SIGNER.")
+
+	caSignerClientKey = env.RegisterStringVar("CA_SIGNER_CLIENT_KEY", "",
+		"The PEM encoded private key of CA_SIGNER_CLIENT_CERT.")
+
+	caRevocationEnabled = env.RegisterBoolVar("CA_REVOCATION_ENABLED", false,
+		"If enabled, the CA rejects the CSRs of the certificates and identities listed in the "+
+			revocation.ConfigMapName+" ConfigMap, publishes its CRL to the "+revocation.CRLConfigMapName+
+			" ConfigMap, and the proxies check the CRL on ISTIO_MUTUAL connections. Unless the CA is self-signed, "+
+			"istiod fails to start without the CRLs of the upper CAs, and of the federated CAs, in crl-chain.pem in "+
+			"ROOT_CA_DIR.")
+
+	caCRLTTL = env.RegisterDurationVar("CA_CRL_TTL", 24*time.Hour,
+		"The TTL of the CRL published by the CA. The CRL is refreshed at half of its TTL.")
+
+	caKeyAlgorithm = env.RegisterStringVar("CA_KEY_ALGORITHM", string(util.RSA2048),
+		"The key algorithm of the self-signed root cert, one of RSA-2048, RSA-4096, ECDSA-P256 or ECDSA-P384. "+
+			"It applies when the root cert is generated: an existing istio-ca-secret keeps its key.")
+
+	caAllowedKeyAlgorithms = env.RegisterStringVar("CA_ALLOWED_KEY_ALGORITHMS", "",
+		"The comma separated key algorithms of the CSRs signed by the CA, among RSA-2048, RSA-4096, ECDSA-P256 "+
+			"and ECDSA-P384. If empty, all of them are allowed.")
+
 	workloadCertTTL = env.RegisterDurationVar("DEFAULT_WORKLOAD_CERT_TTL",
 		cmd.DefaultWorkloadCertTTL,
 		"The default TTL of issued workload certificates. Applied when the client sets a "+
@@ -134,9 +177,9 @@ func (s *Server) EnableCA() bool {
 	if s.kubeClient == nil {
 		// No k8s - no self-signed certs.
 		// TODO: implement it using a local directory, for non-k8s env.
-		signingKeyFile := path.Join(LocalCertDir.Get(), "ca-key.pem")
-		if _, err := os.Stat(signingKeyFile); err != nil {
-			log.Warnf("kubeclient is nil and %v not found; disable the K8S CA functionality", signingKeyFile)
+		if !hasPluggedCA() {
+			log.Warnf("kubeclient is nil and %v not found; disable the K8S CA functionality",
+				path.Join(LocalCertDir.Get(), "ca-key.pem"))
 			return false
 		}
 		log.Info("Using local CA, no K8S Secrets")
@@ -213,6 +256,8 @@ func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts
 		}
 	}
 
+	caServer.Revocations = s.caRevocations
+
 	// Allow authorization with a previously issued certificate, for VMs
 	// Will return a caller with identities extracted from the SAN, should be a SPIFFE identity.
 	caServer.Authenticators = append(caServer.Authenticators, &authenticate.ClientCertAuthenticator{})
@@ -226,6 +271,46 @@ func (s *Server) RunCA(grpc *grpc.Server, ca caserver.CertificateAuthority, opts
 	log.Info("Istiod CA has started")
 }
 
+// startCARevocation watches the revocation ConfigMap of the CA, and pushes the CRL of the CA to the proxies.
+// Certificates issued before istiod started can only be revoked by serial number.
+//
+// Envoy rejects the certificates of a CA without a CRL once a CRL is set, so revocation requires the CRLs of the CAs
+// above a user-provided CA, and of the federated CAs, in crl-chain.pem.
+func (s *Server) startCARevocation(opts *CAOptions, stop <-chan struct{}) error {
+	if s.kubeClient == nil {
+		log.Warn("CA revocation requires Kubernetes, ignoring CA_REVOCATION_ENABLED")
+		return nil
+	}
+	upperCRLs, err := ioutil.ReadFile(path.Join(LocalCertDir.Get(), "crl-chain.pem"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read the CRLs of the upper CAs: %v", err)
+		}
+		if !isSelfSignedCA(s.ca) || features.FederatedTrustBundlesDir != "" {
+			return fmt.Errorf("CA_REVOCATION_ENABLED requires the CRLs of the upper and federated CAs in %s",
+				path.Join(LocalCertDir.Get(), "crl-chain.pem"))
+		}
+	}
+	s.caRevocations = revocation.NewStore()
+	c := revocation.NewController(s.kubeClient.CoreV1(), opts.Namespace, s.caRevocations, s.ca, caCRLTTL.Get(),
+		upperCRLs, func(crls []byte) {
+			securityModel.SetCertificateRevocationList(crls)
+			s.EnvoyXdsServer.ConfigUpdate(&model.PushRequest{
+				Full:   true,
+				Reason: []model.TriggerReason{model.GlobalUpdate},
+			})
+		})
+	go c.Run(stop)
+	return nil
+}
+
+// isSelfSignedCA returns true if the signing certificate of the CA is its self-signed root certificate.
+func isSelfSignedCA(istioCA *ca.IstioCA) bool {
+	cert, _, certChain, _ := istioCA.GetCAKeyCertBundle().GetAll()
+	return cert != nil && len(bytes.TrimSpace(certChain)) == 0 && bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignatureFrom(cert) == nil
+}
+
 // detectAuthEnv will use the JWT token that is mounted in istiod to set the default audience
 // and trust domain for Istiod, if not explicitly defined.
 // K8S will use the same kind of tokens for the pods, and the value in istiod's own token is
@@ -261,8 +346,7 @@ func (s *Server) initPublicKey() error {
 	if features.PilotCertProvider.Get() == KubernetesCAProvider {
 		s.caBundlePath = defaultCACertPath
 	} else if features.PilotCertProvider.Get() == IstiodCAProvider {
-		signingKeyFile := path.Join(LocalCertDir.Get(), "ca-key.pem")
-		if _, err := os.Stat(signingKeyFile); err != nil {
+		if !hasPluggedCA() {
 			// When Citadel is configured to use self-signed certs, keep a local copy so other
 			// components can load it via file (e.g. webhook config controller).
 			if err := os.MkdirAll(dnsCertDir, 0700); err != nil {
@@ -308,6 +392,16 @@ func (s *Server) initPublicKey() error {
 	return nil
 }
 
+// hasPluggedCA returns true if the user-provided root CA is found, with its private key in ca-key.pem or held by
+// the CA_SIGNER.
+func hasPluggedCA() bool {
+	if caSigner.Get() != "" {
+		return true
+	}
+	_, err := os.Stat(path.Join(LocalCertDir.Get(), "ca-key.pem"))
+	return err == nil
+}
+
 func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (*ca.IstioCA, error) {
 	var caOpts *ca.IstioCAOptions
 	var err error
@@ -327,7 +421,7 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 		rootCertFile = ""
 	}
 
-	if _, err := os.Stat(signingKeyFile); err != nil {
+	if !hasPluggedCA() {
 		// The user-provided certs are missing - create a self-signed cert.
 		// If we are not in K8S - no CA
 		// TODO: generate self-signed files in the /etc/cacert for non-k8s
@@ -338,6 +432,10 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 		}
 
 		log.Info("Use self-signed certificate as the CA certificate")
+		keyAlgorithm, keyAlgorithmErr := util.ParseKeyAlgorithm(caKeyAlgorithm.Get())
+		if keyAlgorithmErr != nil {
+			return nil, fmt.Errorf("invalid CA_KEY_ALGORITHM: %v", keyAlgorithmErr)
+		}
 		spiffe.SetTrustDomain(opts.TrustDomain)
 		// Abort after 20 minutes.
 		ctx, cancel := context.WithTimeout(context.Background(), time.Minute*20)
@@ -354,7 +452,7 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 			selfSignedRootCertCheckInterval.Get(), workloadCertTTL.Get(),
 			maxCertTTL, opts.TrustDomain, true,
 			opts.Namespace, -1, client, rootCertFile,
-			enableJitterForRootCertRotator.Get())
+			enableJitterForRootCertRotator.Get(), keyAlgorithm)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create a self-signed istiod CA: %v", err)
 		}
@@ -368,13 +466,31 @@ func (s *Server) createIstioCA(client corev1.CoreV1Interface, opts *CAOptions) (
 		certChainFile := path.Join(LocalCertDir.Get(), "cert-chain.pem")
 		s.caBundlePath = certChainFile
 
-		caOpts, err = ca.NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile,
-			rootCertFile, workloadCertTTL.Get(), maxCertTTL, opts.Namespace, client)
+		if caSigner.Get() != "" {
+			log.Infof("Use CA signer %s", caSigner.Get())
+			keySigner, signerErr := signer.New(caSigner.Get(), signer.TLSOptions{
+				RootCertFile: caSignerRootCert.Get(),
+				CertFile:     caSignerClientCert.Get(),
+				KeyFile:      caSignerClientKey.Get(),
+			})
+			if signerErr != nil {
+				return nil, fmt.Errorf("failed to create the CA signer: %v", signerErr)
+			}
+			caOpts, err = ca.NewPluggedSignerIstioCAOptions(certChainFile, signingCertFile, rootCertFile, keySigner,
+				workloadCertTTL.Get(), maxCertTTL, opts.Namespace, client)
+		} else {
+			caOpts, err = ca.NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile,
+				rootCertFile, workloadCertTTL.Get(), maxCertTTL, opts.Namespace, client)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
 		}
 	}
 
+	if caOpts.AllowedKeyAlgorithms, err = util.ParseKeyAlgorithms(caAllowedKeyAlgorithms.Get()); err != nil {
+		return nil, fmt.Errorf("invalid CA_ALLOWED_KEY_ALGORITHMS: %v", err)
+	}
+
 	istioCA, err := ca.NewIstioCA(caOpts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create an istiod CA: %v", err)
@@ -44,6 +44,10 @@ type RegistryOptions struct {
 	// Consul options
 	ConsulServerAddr string
 
+	// File registry options
+	FileRegistryPath         string
+	FileRegistryPollInterval time.Duration
+
 	// DistributionTracking control
 	DistributionCacheRetention time.Duration
 
@@ -68,8 +68,10 @@ import (
 	istiokeepalive "istio.io/istio/pkg/keepalive"
 	kubelib "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/inject"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/istio/security/pkg/k8s/chiron"
 	"istio.io/istio/security/pkg/pki/ca"
+	"istio.io/istio/security/pkg/pki/revocation"
 )
 
 var (
@@ -149,12 +151,19 @@ type Server struct {
 
 	certController *chiron.WebhookController
 	ca             *ca.IstioCA
+	// caRevocations holds the certificates revoked by the CA, if CA_REVOCATION_ENABLED is set.
+	caRevocations *revocation.Store
 	// path to the caBundle that signs the DNS certs. This should be agnostic to provider.
 	caBundlePath string
 	certMu       sync.Mutex
 	istiodCert   *tls.Certificate
 	jwtPath      string
 
+	// federatedRoots are the roots of the federated trust domains cross-signed by the CA, if
+	// PILOT_FEDERATED_TRUST_BUNDLES_DIR is set.
+	federatedRoots   []byte
+	federatedRootsMu sync.RWMutex
+
 	// startFuncs keeps track of functions that need to be executed when Istiod starts.
 	startFuncs []startFunc
 	// requiredTerminations keeps track of components that should block server exit
@@ -231,6 +240,7 @@ func NewServer(args *PilotArgs) (*Server, error) {
 	if err := s.maybeCreateCA(caOpts); err != nil {
 		return nil, err
 	}
+	s.initFederatedTrustBundles(caOpts.TrustDomain)
 
 	// Create Istiod certs and setup watches.
 	if err := s.initIstiodCerts(args, string(istiodHost)); err != nil {
@@ -1020,6 +1030,11 @@ func (s *Server) startCA(caOpts *CAOptions) {
 	if s.ca != nil {
 		s.addStartFunc(func(stop <-chan struct{}) error {
 			log.Infof("staring CA")
+			if caRevocationEnabled.Get() {
+				if err := s.startCARevocation(caOpts, stop); err != nil {
+					return err
+				}
+			}
 			s.RunCA(s.secureGrpcServer, s.ca, caOpts)
 			return nil
 		})
@@ -1027,9 +1042,14 @@ func (s *Server) startCA(caOpts *CAOptions) {
 }
 
 func (s *Server) fetchCARoot() map[string]string {
-	return map[string]string{
+	data := map[string]string{
 		constants.CACertNamespaceConfigMapDataName: string(s.ca.GetCAKeyCertBundle().GetRootCertPem()),
 	}
+	if features.FederatedTrustBundlesDir != "" {
+		// Always set, so the roots are removed from the ConfigMaps with the last federated trust bundle.
+		data[spiffe.FederatedRootsFile] = string(s.getFederatedRoots())
+	}
+	return data
 }
 
 // initMeshHandlers initializes mesh and network handlers.
@@ -24,6 +24,7 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/consul"
+	"istio.io/istio/pilot/pkg/serviceregistry/file"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pilot/pkg/serviceregistry/mock"
 	"istio.io/istio/pilot/pkg/serviceregistry/serviceentry"
@@ -55,6 +56,10 @@ func (s *Server) initServiceControllers(args *PilotArgs) error {
 			if err := s.initConsulRegistry(serviceControllers, args); err != nil {
 				return err
 			}
+		case serviceregistry.File:
+			if err := s.initFileRegistry(serviceControllers, args); err != nil {
+				return err
+			}
 		case serviceregistry.Mock:
 			s.initMockRegistry(serviceControllers)
 		default:
@@ -112,6 +117,19 @@ func (s *Server) initConsulRegistry(serviceControllers *aggregate.Controller, ar
 	return nil
 }
 
+func (s *Server) initFileRegistry(serviceControllers *aggregate.Controller, args *PilotArgs) error {
+	if args.RegistryOptions.FileRegistryPath == "" {
+		return fmt.Errorf("the %s registry requires a path or URL", serviceregistry.File)
+	}
+	log.Infof("File registry path: %v", args.RegistryOptions.FileRegistryPath)
+	serviceControllers.AddRegistry(file.NewController(file.Options{
+		Path:         args.RegistryOptions.FileRegistryPath,
+		PollInterval: args.RegistryOptions.FileRegistryPollInterval,
+		ClusterID:    s.clusterID,
+	}))
+	return nil
+}
+
 func (s *Server) initMockRegistry(serviceControllers *aggregate.Controller) {
 	// MemServiceDiscovery implementation
 	discovery := mock.NewDiscovery(map[host.Name]*model.Service{}, 2)
@@ -15,6 +15,7 @@
 package features
 
 import (
+	"strings"
 	"time"
 
 	"istio.io/istio/pkg/jwt"
@@ -45,6 +46,51 @@ var (
 		"Limits the number of concurrent pushes allowed. On larger machines this can be increased for faster pushes",
 	).Get()
 
+	PushMinInterval = env.RegisterDurationVar(
+		"PILOT_PROXY_PUSH_MIN_INTERVAL",
+		0,
+		"Minimum time between the start of two pushes to the same proxy. Updates received in between are merged "+
+			"into the next push, so that proxies receiving many updates don't take all the push capacity. Disabled by default.",
+	).Get()
+
+	EnableConfigCache = env.RegisterBoolVar(
+		"PILOT_ENABLE_CONFIG_CACHE",
+		false,
+		"If enabled, Pilot will generate CDS and RDS once for proxies with the same configuration scope, "+
+			"and reuse the result across pushes until a config change affects it.",
+	).Get()
+
+	ExtensionProviders = env.RegisterStringVar(
+		"PILOT_EXTENSION_PROVIDERS",
+		"",
+		"Temporary: a JSON list of external authorization providers, referenced by name from the "+
+			"istio.io/ext-authz-provider annotation of an AuthorizationPolicy. See "+
+			"pilot/pkg/security/authz/builder/extauthz.go for the format. It stands in for the extension providers "+
+			"of MeshConfig, missing from the API version in use, and will be removed once they are available.",
+	).Get()
+
+	FederatedTrustBundlesDir = env.RegisterStringVar(
+		"PILOT_FEDERATED_TRUST_BUNDLES_DIR",
+		"",
+		"The directory of the federated trust bundles, usually a mounted ConfigMap with a <trust-domain>.pem key "+
+			"for each federated trust domain. When set, inbound mutual TLS only accepts the SPIFFE IDs of the local "+
+			"trust domain, its aliases and the federated trust domains. The federated roots are cross-signed by the "+
+			"CA for their trust domain only, and published with its root cert. The directory is reloaded on change.",
+	).Get()
+
+	JwksCacheDir = env.RegisterStringVar(
+		"PILOT_JWKS_CACHE_DIR",
+		"",
+		"If set, the JWT public keys and jwks_uri fetched from the issuers are persisted in this directory, and "+
+			"used when the issuers can't be reached, including at startup.",
+	).Get()
+
+	JwksStaleWindow = env.RegisterDurationVar(
+		"PILOT_JWKS_STALE_WINDOW",
+		24*7*time.Hour,
+		"How long a cached JWT public key is still used after it failed to be refreshed from its issuer.",
+	).Get()
+
 	// MaxRecvMsgSize The max receive buffer size of gRPC received channel of Pilot in bytes.
 	MaxRecvMsgSize = env.RegisterIntVar(
 		"ISTIO_GPRC_MAXRECVMSGSIZE",
@@ -57,6 +103,33 @@ var (
 	// For larger clusters it can increase memory use and GC - useful for small tests.
 	DebugConfigs = env.RegisterBoolVar("PILOT_DEBUG_ADSZ_CONFIG", false, "").Get()
 
+	localityFailoverLabelsVar = env.RegisterStringVar(
+		"PILOT_LOCALITY_FAILOVER_LABELS",
+		"",
+		"Comma separated, ordered list of workload label keys used to prioritize endpoints when locality "+
+			"failover is enabled. Endpoints are ordered by the first label they don't share with the proxy, then the "+
+			"next ones. DestinationRules can override it with the networking.istio.io/localityFailoverLabels annotation.",
+	)
+	// LocalityFailoverLabels is the ordered list of label keys used by locality failover, most significant first.
+	LocalityFailoverLabels = func() []string {
+		var out []string
+		for _, l := range strings.Split(localityFailoverLabelsVar.Get(), ",") {
+			if l = strings.TrimSpace(l); l != "" {
+				out = append(out, l)
+			}
+		}
+		return out
+	}()
+
+	// PushHistorySize controls how many full pushes are kept for /debug/pushz. It is opt-in, since
+	// each push keeps a hash of every CDS, LDS and RDS resource sent, per proxy type.
+	PushHistorySize = env.RegisterIntVar(
+		"PILOT_PUSH_HISTORY_SIZE",
+		0,
+		"Number of full pushes to keep for /debug/pushz. The push history is disabled by default, "+
+			"since it keeps a hash of every pushed resource.",
+	).Get()
+
 	// FilterGatewayClusterConfig controls if a subset of clusters(only those required) should be pushed to gateways
 	FilterGatewayClusterConfig = env.RegisterBoolVar("PILOT_FILTER_GATEWAY_CLUSTER_CONFIG", false, "").Get()
 
@@ -82,6 +155,14 @@ var (
 			" EDS pushes may be delayed, but there will be fewer pushes. By default this is enabled",
 	)
 
+	SendUnhealthyEndpoints = env.RegisterBoolVar(
+		"PILOT_SEND_UNHEALTHY_ENDPOINTS",
+		false,
+		"If enabled, Pilot will include endpoints that are not ready in EDS, marked as unhealthy. "+
+			"Unhealthy endpoints don't receive traffic: the panic mode of the clusters is disabled, "+
+			"unless a DestinationRule sets outlierDetection.minHealthPercent.",
+	).Get()
+
 	// HTTP10 will add "accept_http_10" to http outbound listeners. Can also be set only for specific sidecars via meta.
 	//
 	// Alpha in 1.1, may become the default or be turned into a Sidecar API or mesh setting. Only applies to namespaces
@@ -43,6 +43,12 @@ const (
 
 const (
 	ResourceSeparator = "~"
+
+	// JwtClaimHeadersAnnotation copies claims of the JWT validated by a RequestAuthentication to request headers.
+	// The value is a comma separated list of <header>=<claim>. Nested claims are separated by dots, e.g.
+	// "x-jwt-roles=realm_access.roles", or in brackets if the claim names have dots, e.g. "x-jwt-groups=[example.com/groups]".
+	// List indexes, e.g. "groups[0]", are not supported: a list claim is copied as a whole, as a JSON array.
+	JwtClaimHeadersAnnotation = "istio.io/jwt-claim-headers"
 )
 
 // String converts MutualTLSMode to human readable string for debugging.
@@ -107,6 +113,11 @@ func initAuthenticationPolicies(env *Environment) (*AuthenticationPolicies, erro
 	return policy, nil
 }
 
+// GetAuthenticationPolicies returns the AuthenticationPolicies for the given environment.
+func GetAuthenticationPolicies(env *Environment) (*AuthenticationPolicies, error) {
+	return initAuthenticationPolicies(env)
+}
+
 func (policy *AuthenticationPolicies) addRequestAuthentication(configs []Config) {
 	for _, config := range configs {
 		reqPolicy := config.Spec.(*v1beta1.RequestAuthentication)
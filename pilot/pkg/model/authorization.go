@@ -15,6 +15,8 @@
 package model
 
 import (
+	"strconv"
+
 	authpb "istio.io/api/security/v1beta1"
 
 	"istio.io/istio/pkg/config/labels"
@@ -26,10 +28,23 @@ var (
 	authzLog = istiolog.RegisterScope("authorization", "Istio Authorization Policy", 0)
 )
 
+const (
+	// DryRunAnnotation marks an AuthorizationPolicy as dry-run when set to "true". A dry-run policy is
+	// evaluated and its result is reported in the proxy stats and dynamic metadata, but not enforced.
+	DryRunAnnotation = "istio.io/dry-run"
+
+	// ExtAuthzProviderAnnotation gives an AuthorizationPolicy the CUSTOM action: requests matching its rules
+	// are checked by the named external authorization provider. The policy action must be ALLOW (the default).
+	ExtAuthzProviderAnnotation = "istio.io/ext-authz-provider"
+)
+
 type AuthorizationPolicy struct {
 	Name      string                      `json:"name"`
 	Namespace string                      `json:"namespace"`
 	Spec      *authpb.AuthorizationPolicy `json:"spec"`
+	DryRun    bool                        `json:"dry_run,omitempty"`
+	// Provider is the external authorization provider of a CUSTOM policy.
+	Provider string `json:"provider,omitempty"`
 }
 
 // AuthorizationPolicies organizes AuthorizationPolicy by namespace.
@@ -58,6 +73,8 @@ func GetAuthorizationPolicies(env *Environment) (*AuthorizationPolicies, error)
 			Name:      config.Name,
 			Namespace: config.Namespace,
 			Spec:      config.Spec.(*authpb.AuthorizationPolicy),
+			DryRun:    isDryRun(config.Annotations),
+			Provider:  config.Annotations[ExtAuthzProviderAnnotation],
 		}
 		policy.NamespaceToPolicies[config.Namespace] =
 			append(policy.NamespaceToPolicies[config.Namespace], authzConfig)
@@ -66,9 +83,17 @@ func GetAuthorizationPolicies(env *Environment) (*AuthorizationPolicies, error)
 	return policy, nil
 }
 
-// ListAuthorizationPolicies returns the deny and allow AuthorizationPolicy for the workload in the given namespace.
+func isDryRun(annotations map[string]string) bool {
+	dryRun, err := strconv.ParseBool(annotations[DryRunAnnotation])
+	if err != nil && annotations[DryRunAnnotation] != "" {
+		authzLog.Warnf("ignored invalid value %q for annotation %s", annotations[DryRunAnnotation], DryRunAnnotation)
+	}
+	return dryRun
+}
+
+// ListAuthorizationPolicies returns the deny, allow and custom AuthorizationPolicy for the workload in the given namespace.
 func (policy *AuthorizationPolicies) ListAuthorizationPolicies(namespace string, workload labels.Collection) (
-	denyPolicies []AuthorizationPolicy, allowPolicies []AuthorizationPolicy) {
+	denyPolicies []AuthorizationPolicy, allowPolicies []AuthorizationPolicy, customPolicies []AuthorizationPolicy) {
 	if policy == nil {
 		return
 	}
@@ -87,6 +112,15 @@ func (policy *AuthorizationPolicies) ListAuthorizationPolicies(namespace string,
 			spec := config.Spec
 			selector := labels.Instance(spec.GetSelector().GetMatchLabels())
 			if workload.IsSupersetOf(selector) {
+				if config.Provider != "" {
+					if config.Spec.GetAction() != authpb.AuthorizationPolicy_ALLOW {
+						authzLog.Errorf("ignored authorization policy %s.%s with provider %s and action %s, expected ALLOW",
+							config.Namespace, config.Name, config.Provider, config.Spec.GetAction())
+						continue
+					}
+					customPolicies = append(customPolicies, config)
+					continue
+				}
 				switch config.Spec.GetAction() {
 				case authpb.AuthorizationPolicy_ALLOW:
 					allowPolicies = append(allowPolicies, config)
@@ -70,6 +70,7 @@ func TestAuthorizationPolicies_ListAuthorizationPolicies(t *testing.T) {
 		configs        []Config
 		wantDeny       []AuthorizationPolicy
 		wantAllow      []AuthorizationPolicy
+		wantCustom     []AuthorizationPolicy
 	}{
 		{
 			name:      "no policies",
@@ -156,6 +157,38 @@ func TestAuthorizationPolicies_ListAuthorizationPolicies(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "custom policy",
+			ns:   "bar",
+			configs: []Config{
+				newConfig("authz-1", "bar", policy),
+				func() Config {
+					cfg := newConfig("authz-2", "bar", policy)
+					cfg.Annotations = map[string]string{ExtAuthzProviderAnnotation: "opa"}
+					return cfg
+				}(),
+				func() Config {
+					cfg := newConfig("authz-3", "bar", denyPolicy)
+					cfg.Annotations = map[string]string{ExtAuthzProviderAnnotation: "opa"}
+					return cfg
+				}(),
+			},
+			wantAllow: []AuthorizationPolicy{
+				{
+					Name:      "authz-1",
+					Namespace: "bar",
+					Spec:      policy,
+				},
+			},
+			wantCustom: []AuthorizationPolicy{
+				{
+					Name:      "authz-2",
+					Namespace: "bar",
+					Spec:      policy,
+					Provider:  "opa",
+				},
+			},
+		},
 		{
 			name: "selector exact match",
 			ns:   "bar",
@@ -271,7 +304,7 @@ func TestAuthorizationPolicies_ListAuthorizationPolicies(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			authzPolicies := createFakeAuthorizationPolicies(tc.configs, t)
 
-			gotDeny, gotAllow := authzPolicies.ListAuthorizationPolicies(
+			gotDeny, gotAllow, gotCustom := authzPolicies.ListAuthorizationPolicies(
 				tc.ns, []labels.Instance{tc.workloadLabels})
 			if !reflect.DeepEqual(tc.wantAllow, gotAllow) {
 				t.Errorf("wantAllow:%v\n but got: %v\n", tc.wantAllow, gotAllow)
@@ -279,6 +312,9 @@ func TestAuthorizationPolicies_ListAuthorizationPolicies(t *testing.T) {
 			if !reflect.DeepEqual(tc.wantDeny, gotDeny) {
 				t.Errorf("wantDeny:%v\n but got: %v\n", tc.wantDeny, gotDeny)
 			}
+			if !reflect.DeepEqual(tc.wantCustom, gotCustom) {
+				t.Errorf("wantCustom:%v\n but got: %v\n", tc.wantCustom, gotCustom)
+			}
 		})
 	}
 }
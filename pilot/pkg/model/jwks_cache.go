@@ -0,0 +1,104 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// jwksDiskCache persists the responses of the issuers, i.e. the OpenID discovery documents and the JWKS, so that
+// they survive a restart of Pilot. There is one file per URI, named after the hash of the URI.
+type jwksDiskCache struct {
+	dir string
+}
+
+// jwksDiskCacheEntry is the content of a file of the disk cache.
+type jwksDiskCacheEntry struct {
+	URI         string    `json:"uri"`
+	Content     string    `json:"content"`
+	FetchedTime time.Time `json:"fetched_time"`
+}
+
+// newJwksDiskCache returns the disk cache in dir, or nil if dir is empty or can't be created.
+func newJwksDiskCache(dir string) *jwksDiskCache {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Errorf("Failed to create the JWKS cache directory %s, the disk cache is disabled: %v", dir, err)
+		return nil
+	}
+	return &jwksDiskCache{dir: dir}
+}
+
+func (c *jwksDiskCache) path(uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load returns the cached response for the URI.
+func (c *jwksDiskCache) load(uri string) (*jwksDiskCacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	b, err := ioutil.ReadFile(c.path(uri))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warnf("Failed to read the cached response of %q: %v", uri, err)
+		}
+		return nil, false
+	}
+	e := &jwksDiskCacheEntry{}
+	if err := json.Unmarshal(b, e); err != nil || e.URI != uri {
+		log.Warnf("Ignored invalid cached response of %q: %v", uri, err)
+		return nil, false
+	}
+	return e, true
+}
+
+// store saves the response for the URI. The file is replaced atomically, so a concurrent load never sees a
+// partial response.
+func (c *jwksDiskCache) store(uri string, content []byte, fetchedTime time.Time) {
+	if c == nil {
+		return
+	}
+	b, err := json.Marshal(&jwksDiskCacheEntry{URI: uri, Content: string(content), FetchedTime: fetchedTime})
+	if err != nil {
+		log.Warnf("Failed to encode the response of %q: %v", uri, err)
+		return
+	}
+	f, err := ioutil.TempFile(c.dir, ".tmp-")
+	if err != nil {
+		log.Warnf("Failed to cache the response of %q: %v", uri, err)
+		return
+	}
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.path(uri))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		log.Warnf("Failed to cache the response of %q: %v", uri, err)
+	}
+}
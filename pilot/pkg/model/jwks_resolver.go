@@ -33,6 +33,8 @@ import (
 	"istio.io/api/security/v1beta1"
 	"istio.io/pkg/cache"
 	"istio.io/pkg/monitoring"
+
+	"istio.io/istio/pilot/pkg/features"
 )
 
 const (
@@ -93,14 +95,39 @@ var (
 		"Total number of failed network fetch by pilot jwks resolver",
 	)
 
+	issuerTag = monitoring.MustCreateLabel("issuer")
+	resultTag = monitoring.MustCreateLabel("result")
+	sourceTag = monitoring.MustCreateLabel("source")
+
+	issuerFetchCounter = monitoring.NewSum(
+		"pilot_jwks_resolver_issuer_fetch_total",
+		"Total number of network fetches by pilot jwks resolver, by issuer and result.",
+		monitoring.WithLabels(issuerTag, resultTag),
+	)
+	staleKeyCounter = monitoring.NewSum(
+		"pilot_jwks_resolver_stale_key_total",
+		"Total number of times pilot jwks resolver kept using a cached response after failing to fetch it, "+
+			"by issuer and cache (memory or disk).",
+		monitoring.WithLabels(issuerTag, sourceTag),
+	)
+
+	jwksCABundlePaths = []string{jwksPublicRootCABundlePath, jwksExtraRootCABundlePath}
+
 	// JwtKeyResolver resolves JWT public key and JwksURI.
-	JwtKeyResolver = NewJwksResolver(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval)
+	JwtKeyResolver = newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval,
+		features.JwksStaleWindow, features.JwksCacheDir, jwksCABundlePaths)
 )
 
 // jwtPubKeyEntry is a single cached entry for jwt public key.
 type jwtPubKeyEntry struct {
 	pubKey string
 
+	// The issuer of the key, for metrics and debugging. It's empty if unknown.
+	issuer string
+
+	// The error of the last failed refresh, cleared by a successful one.
+	lastRefreshError string
+
 	// The last success refreshed time of the pubKey.
 	lastRefreshedTime time.Time
 
@@ -130,6 +157,13 @@ type JwksResolver struct {
 	// Refresher job running interval.
 	refreshInterval time.Duration
 
+	// Cached key will be removed from cache if it hasn't been refreshed successfully for staleWindow. Until then, it's
+	// used while the issuer can't be reached. This also applies to the keys persisted in diskCache.
+	staleWindow time.Duration
+
+	// diskCache persists the fetched keys and jwks_uri, it's nil if disabled.
+	diskCache *jwksDiskCache
+
 	// How many times refresh job has detected JWT public key change happened, used in unit test.
 	refreshJobKeyChangedCount uint64
 
@@ -138,23 +172,22 @@ type JwksResolver struct {
 }
 
 func init() {
-	monitoring.MustRegister(networkFetchSuccessCounter, networkFetchFailCounter)
+	monitoring.MustRegister(networkFetchSuccessCounter, networkFetchFailCounter, issuerFetchCounter, staleKeyCounter)
 }
 
 // NewJwksResolver creates new instance of JwksResolver.
 func NewJwksResolver(evictionDuration, refreshInterval time.Duration) *JwksResolver {
-	return newJwksResolverWithCABundlePaths(
-		evictionDuration,
-		refreshInterval,
-		[]string{jwksPublicRootCABundlePath, jwksExtraRootCABundlePath},
-	)
+	return newJwksResolverWithCABundlePaths(evictionDuration, refreshInterval, evictionDuration, "", jwksCABundlePaths)
 }
 
-func newJwksResolverWithCABundlePaths(evictionDuration, refreshInterval time.Duration, caBundlePaths []string) *JwksResolver {
+func newJwksResolverWithCABundlePaths(evictionDuration, refreshInterval, staleWindow time.Duration, cacheDir string,
+	caBundlePaths []string) *JwksResolver {
 	ret := &JwksResolver{
 		JwksURICache:     cache.NewTTL(jwksURICacheExpiration, jwksURICacheEviction),
 		evictionDuration: evictionDuration,
 		refreshInterval:  refreshInterval,
+		staleWindow:      staleWindow,
+		diskCache:        newJwksDiskCache(cacheDir),
 		httpClient: &http.Client{
 			Timeout: jwksHTTPTimeOutInSec * time.Second,
 			Transport: &http.Transport{
@@ -241,20 +274,36 @@ func (r *JwksResolver) ResolveJwksURI(policy *v1beta1.RequestAuthentication) {
 	}
 }
 
-// GetPublicKey gets JWT public key and cache the key for future use.
-func (r *JwksResolver) GetPublicKey(jwksURI string) (string, error) {
+// GetPublicKey gets JWT public key and cache the key for future use. The issuer is only used for metrics and
+// debugging, and may be empty.
+func (r *JwksResolver) GetPublicKey(issuer, jwksURI string) (string, error) {
 	now := time.Now()
 	if val, found := r.keyEntries.Load(jwksURI); found {
 		e := val.(jwtPubKeyEntry)
 		// Update cached key's last used time.
 		e.lastUsedTime = now
+		if e.issuer == "" {
+			e.issuer = issuer
+		}
 		r.keyEntries.Store(jwksURI, e)
 		return e.pubKey, nil
 	}
 
 	// Fetch key if it's not cached.
-	resp, err := r.getRemoteContentWithRetry(jwksURI, networkFetchRetryCountOnMainFlow)
+	resp, err := r.getRemoteContentWithRetry(issuer, jwksURI, networkFetchRetryCountOnMainFlow)
 	if err != nil {
+		// Fall back to the key persisted by a previous run, e.g. if the issuer is down when Pilot starts.
+		if cached, found := r.loadFromDisk(issuer, jwksURI, now); found {
+			log.Warnf("Failed to fetch public key from %q, using the key cached at %s: %v", jwksURI, cached.FetchedTime, err)
+			r.keyEntries.Store(jwksURI, jwtPubKeyEntry{
+				pubKey:            cached.Content,
+				issuer:            issuer,
+				lastRefreshError:  err.Error(),
+				lastRefreshedTime: cached.FetchedTime,
+				lastUsedTime:      now,
+			})
+			return cached.Content, nil
+		}
 		log.Errorf("Failed to fetch public key from %q: %v", jwksURI, err)
 		return "", err
 	}
@@ -262,13 +311,37 @@ func (r *JwksResolver) GetPublicKey(jwksURI string) (string, error) {
 	pubKey := string(resp)
 	r.keyEntries.Store(jwksURI, jwtPubKeyEntry{
 		pubKey:            pubKey,
+		issuer:            issuer,
 		lastRefreshedTime: now,
 		lastUsedTime:      now,
 	})
+	r.diskCache.store(jwksURI, resp, now)
 
 	return pubKey, nil
 }
 
+// loadFromDisk returns the response of the URI persisted in the disk cache, if it's within the stale window.
+func (r *JwksResolver) loadFromDisk(issuer, uri string, now time.Time) (*jwksDiskCacheEntry, bool) {
+	cached, found := r.diskCache.load(uri)
+	if !found {
+		return nil, false
+	}
+	if now.Sub(cached.FetchedTime) >= r.staleWindow {
+		log.Warnf("Ignored the response of %q cached at %s, older than the stale window %s", uri, cached.FetchedTime, r.staleWindow)
+		return nil, false
+	}
+	staleKeyCounter.With(issuerTag.Value(issuerLabel(issuer, uri)), sourceTag.Value("disk")).Increment()
+	return cached, true
+}
+
+// issuerLabel returns the value of the issuer label of the metrics, the URI is used if the issuer is unknown.
+func issuerLabel(issuer, uri string) string {
+	if issuer != "" {
+		return issuer
+	}
+	return uri
+}
+
 // Resolve jwks_uri through openID discovery and cache the jwks_uri for future use.
 func (r *JwksResolver) resolveJwksURIUsingOpenID(issuer string) (string, error) {
 	// Set policyJwt.JwksUri if the JwksUri could be found in cache.
@@ -277,9 +350,15 @@ func (r *JwksResolver) resolveJwksURIUsingOpenID(issuer string) (string, error)
 	}
 
 	// Try to get jwks_uri through OpenID Discovery.
-	body, err := r.getRemoteContentWithRetry(issuer+openIDDiscoveryCfgURLSuffix, networkFetchRetryCountOnMainFlow)
-	if err != nil {
-		log.Errorf("Failed to fetch jwks_uri from %q: %v", issuer+openIDDiscoveryCfgURLSuffix, err)
+	discoveryURL := issuer + openIDDiscoveryCfgURLSuffix
+	body, err := r.getRemoteContentWithRetry(issuer, discoveryURL, networkFetchRetryCountOnMainFlow)
+	if err == nil {
+		r.diskCache.store(discoveryURL, body, time.Now())
+	} else if cached, found := r.loadFromDisk(issuer, discoveryURL, time.Now()); found {
+		log.Warnf("Failed to fetch jwks_uri from %q, using the response cached at %s: %v", discoveryURL, cached.FetchedTime, err)
+		body = []byte(cached.Content)
+	} else {
+		log.Errorf("Failed to fetch jwks_uri from %q: %v", discoveryURL, err)
 		return "", err
 	}
 	var data map[string]interface{}
@@ -298,7 +377,7 @@ func (r *JwksResolver) resolveJwksURIUsingOpenID(issuer string) (string, error)
 	return jwksURI, nil
 }
 
-func (r *JwksResolver) getRemoteContentWithRetry(uri string, retry int) ([]byte, error) {
+func (r *JwksResolver) getRemoteContentWithRetry(issuer, uri string, retry int) ([]byte, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		log.Errorf("Failed to parse %q", uri)
@@ -320,9 +399,11 @@ func (r *JwksResolver) getRemoteContentWithRetry(uri string, retry int) ([]byte,
 		defer func() {
 			if e != nil {
 				networkFetchFailCounter.Increment()
+				issuerFetchCounter.With(issuerTag.Value(issuerLabel(issuer, uri)), resultTag.Value("failure")).Increment()
 				return
 			}
 			networkFetchSuccessCounter.Increment()
+			issuerFetchCounter.With(issuerTag.Value(issuerLabel(issuer, uri)), resultTag.Value("success")).Increment()
 			_ = resp.Body.Close()
 		}()
 		if err != nil {
@@ -382,7 +463,7 @@ func (r *JwksResolver) refresh() {
 		// 2) it hasn't been refreshed successfully for a while
 		// This makes sure 2 things, we don't grow the cache infinitely and also we don't reuse a cached public key
 		// with no success refresh for too much time.
-		if now.Sub(e.lastUsedTime) >= r.evictionDuration || now.Sub(e.lastRefreshedTime) >= r.evictionDuration {
+		if now.Sub(e.lastUsedTime) >= r.evictionDuration || now.Sub(e.lastRefreshedTime) >= r.staleWindow {
 			log.Infof("Removed cached JWT public key (lastRefreshed: %s, lastUsed: %s) from %q",
 				e.lastRefreshedTime, e.lastUsedTime, jwksURI)
 			r.keyEntries.Delete(jwksURI)
@@ -398,18 +479,24 @@ func (r *JwksResolver) refresh() {
 			// Decrement the counter when the goroutine completes.
 			defer wg.Done()
 
-			resp, err := r.getRemoteContentWithRetry(jwksURI, networkFetchRetryCountOnRefreshFlow)
+			resp, err := r.getRemoteContentWithRetry(e.issuer, jwksURI, networkFetchRetryCountOnRefreshFlow)
 			if err != nil {
 				log.Errorf("Failed to refresh JWT public key from %q: %v", jwksURI, err)
 				atomic.AddUint64(&r.refreshJobFetchFailedCount, 1)
+				staleKeyCounter.With(issuerTag.Value(issuerLabel(e.issuer, jwksURI)), sourceTag.Value("memory")).Increment()
+				// Keep using the cached key until the stale window, and record the error for debugging.
+				e.lastRefreshError = err.Error()
+				r.keyEntries.Store(jwksURI, e)
 				return
 			}
 			newPubKey := string(resp)
 			r.keyEntries.Store(jwksURI, jwtPubKeyEntry{
 				pubKey:            newPubKey,
+				issuer:            e.issuer,
 				lastRefreshedTime: now,            // update the lastRefreshedTime if we get a success response from the network.
 				lastUsedTime:      e.lastUsedTime, // keep original lastUsedTime.
 			})
+			r.diskCache.store(jwksURI, resp, now)
 			isNewKey, err := compareJWKSResponse(oldPubKey, newPubKey)
 			if err != nil {
 				log.Errorf("Failed to refresh JWT public key from %q: %v", jwksURI, err)
@@ -436,6 +523,61 @@ func (r *JwksResolver) refresh() {
 	}
 }
 
+// JwtPubKeyDebug is the debug information of a cached JWT public key.
+type JwtPubKeyDebug struct {
+	Issuer  string `json:"issuer,omitempty"`
+	JwksURI string `json:"jwks_uri"`
+	// KeyIDs are the "kid" of the keys in the JWKS.
+	KeyIDs            []string  `json:"key_ids,omitempty"`
+	LastRefreshedTime time.Time `json:"last_refreshed_time"`
+	LastUsedTime      time.Time `json:"last_used_time"`
+	// Age is the time since the last successful refresh.
+	Age              string `json:"age"`
+	LastRefreshError string `json:"last_refresh_error,omitempty"`
+}
+
+// DebugKeys returns the cached JWT public keys, sorted by jwks_uri.
+func (r *JwksResolver) DebugKeys() []JwtPubKeyDebug {
+	now := time.Now()
+	out := []JwtPubKeyDebug{}
+	r.keyEntries.Range(func(key interface{}, value interface{}) bool {
+		e := value.(jwtPubKeyEntry)
+		out = append(out, JwtPubKeyDebug{
+			Issuer:            e.issuer,
+			JwksURI:           key.(string),
+			KeyIDs:            jwksKeyIDs(e.pubKey),
+			LastRefreshedTime: e.lastRefreshedTime,
+			LastUsedTime:      e.lastUsedTime,
+			Age:               now.Sub(e.lastRefreshedTime).Round(time.Second).String(),
+			LastRefreshError:  e.lastRefreshError,
+		})
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].JwksURI < out[j].JwksURI
+	})
+	return out
+}
+
+// jwksKeyIDs returns the "kid" of the keys in a JWKS, or nil if it can't be parsed.
+func jwksKeyIDs(jwks string) []string {
+	var keys struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal([]byte(jwks), &keys); err != nil {
+		return nil
+	}
+	var kids []string
+	for _, k := range keys.Keys {
+		if k.Kid != "" {
+			kids = append(kids, k.Kid)
+		}
+	}
+	return kids
+}
+
 // Shut down the refresher job.
 // TODO: may need to figure out the right place to call this function.
 // (right now calls it from initDiscoveryService in pkg/bootstrap/server.go).
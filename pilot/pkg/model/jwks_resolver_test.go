@@ -15,6 +15,8 @@
 package model
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
 	"sync/atomic"
 	"testing"
@@ -302,7 +304,7 @@ func TestGetPublicKey(t *testing.T) {
 		},
 	}
 	for _, c := range cases {
-		pk, err := r.GetPublicKey(c.in)
+		pk, err := r.GetPublicKey("", c.in)
 		if err != nil {
 			t.Errorf("GetPublicKey(%+v) fails: expected no error, got (%v)", c.in, err)
 		}
@@ -344,7 +346,7 @@ func TestGetPublicKeyReorderedKey(t *testing.T) {
 		},
 	}
 	for _, c := range cases {
-		pk, err := r.GetPublicKey(c.in)
+		pk, err := r.GetPublicKey("", c.in)
 		if err != nil {
 			t.Errorf("GetPublicKey(%+v) fails: expected no error, got (%v)", c.in, err)
 		}
@@ -361,7 +363,8 @@ func TestGetPublicKeyReorderedKey(t *testing.T) {
 }
 
 func TestGetPublicKeyUsingTLS(t *testing.T) {
-	r := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, []string{"./test/testcert/cert.pem"})
+	r := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, JwtPubKeyEvictionDuration, "",
+		[]string{"./test/testcert/cert.pem"})
 	defer r.Close()
 
 	ms, err := test.StartNewTLSServer("./test/testcert/cert.pem", "./test/testcert/key.pem")
@@ -371,7 +374,7 @@ func TestGetPublicKeyUsingTLS(t *testing.T) {
 	}
 
 	mockCertURL := ms.URL + "/oauth2/v3/certs"
-	pk, err := r.GetPublicKey(mockCertURL)
+	pk, err := r.GetPublicKey("", mockCertURL)
 	if err != nil {
 		t.Errorf("GetPublicKey(%+v) fails: expected no error, got (%v)", mockCertURL, err)
 	}
@@ -381,7 +384,8 @@ func TestGetPublicKeyUsingTLS(t *testing.T) {
 }
 
 func TestGetPublicKeyUsingTLSBadCert(t *testing.T) {
-	r := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, []string{"./test/testcert/cert2.pem"})
+	r := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, JwtPubKeyEvictionDuration, "",
+		[]string{"./test/testcert/cert2.pem"})
 	defer r.Close()
 
 	ms, err := test.StartNewTLSServer("./test/testcert/cert.pem", "./test/testcert/key.pem")
@@ -391,14 +395,14 @@ func TestGetPublicKeyUsingTLSBadCert(t *testing.T) {
 	}
 
 	mockCertURL := ms.URL + "/oauth2/v3/certs"
-	_, err = r.GetPublicKey(mockCertURL)
+	_, err = r.GetPublicKey("", mockCertURL)
 	if err == nil {
 		t.Errorf("GetPublicKey(%+v) did not fail: expected bad certificate error, got no error", mockCertURL)
 	}
 }
 
 func TestGetPublicKeyUsingTLSWithoutCABundles(t *testing.T) {
-	r := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, []string{})
+	r := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, JwtPubKeyEvictionDuration, "", []string{})
 	defer r.Close()
 
 	ms, err := test.StartNewTLSServer("./test/testcert/cert.pem", "./test/testcert/key.pem")
@@ -408,7 +412,7 @@ func TestGetPublicKeyUsingTLSWithoutCABundles(t *testing.T) {
 	}
 
 	mockCertURL := ms.URL + "/oauth2/v3/certs"
-	_, err = r.GetPublicKey(mockCertURL)
+	_, err = r.GetPublicKey("", mockCertURL)
 	if err == nil {
 		t.Errorf("GetPublicKey(%+v) did not fail: expected https unsupported error, got no error", mockCertURL)
 	}
@@ -463,7 +467,7 @@ func TestJwtPubKeyEvictionForNotRefreshed(t *testing.T) {
 			case <-done:
 				return
 			case <-c.C:
-				_, _ = r.GetPublicKey(mockCertURL)
+				_, _ = r.GetPublicKey("", mockCertURL)
 			}
 		}
 	}()
@@ -471,7 +475,7 @@ func TestJwtPubKeyEvictionForNotRefreshed(t *testing.T) {
 		done <- struct{}{}
 	}()
 
-	pk, err := r.GetPublicKey(mockCertURL)
+	pk, err := r.GetPublicKey("", mockCertURL)
 	if err != nil {
 		t.Fatalf("GetPublicKey(%+v) fails: expected no error, got (%v)", mockCertURL, err)
 	}
@@ -482,7 +486,7 @@ func TestJwtPubKeyEvictionForNotRefreshed(t *testing.T) {
 
 	// Verify the cached public key is removed after failed to refresh longer than the eviction duration.
 	time.Sleep(5 * time.Second)
-	_, err = r.GetPublicKey(mockCertURL)
+	_, err = r.GetPublicKey("", mockCertURL)
 	if err == nil {
 		t.Errorf("GetPublicKey(%+v) fails: expected error, got no error", mockCertURL)
 	}
@@ -561,7 +565,7 @@ func TestJwtPubKeyMetric(t *testing.T) {
 		},
 	}
 	for _, c := range cases {
-		pk, _ := r.GetPublicKey(c.in)
+		pk, _ := r.GetPublicKey("", c.in)
 		if c.expectedJwtPubkey != pk {
 			t.Errorf("GetPublicKey(%+v): expected (%s), got (%s)", c.in, c.expectedJwtPubkey, pk)
 		}
@@ -577,6 +581,57 @@ func TestJwtPubKeyMetric(t *testing.T) {
 	}
 }
 
+func TestJwksDiskCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwks-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ms := startMockServer(t)
+	issuer := ms.URL
+	mockCertURL := ms.URL + "/oauth2/v3/certs"
+
+	// The first resolver fetches the jwks_uri and the key from the issuer, and persists them.
+	r := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, time.Hour, dir, nil)
+	defer r.Close()
+	if uri, err := r.resolveJwksURIUsingOpenID(issuer); err != nil || uri != mockCertURL {
+		t.Fatalf("resolveJwksURIUsingOpenID(%s): got (%s, %v), want %s", issuer, uri, err, mockCertURL)
+	}
+	if pk, err := r.GetPublicKey(issuer, mockCertURL); err != nil || pk != test.JwtPubKey1 {
+		t.Fatalf("GetPublicKey(%s): got (%s, %v), want %s", mockCertURL, pk, err, test.JwtPubKey1)
+	}
+
+	// A new resolver, e.g. after a restart, falls back to the disk cache while the issuer is down.
+	_ = ms.Stop()
+	restarted := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, time.Hour, dir, nil)
+	defer restarted.Close()
+	if uri, err := restarted.resolveJwksURIUsingOpenID(issuer); err != nil || uri != mockCertURL {
+		t.Fatalf("resolveJwksURIUsingOpenID(%s) from disk: got (%s, %v), want %s", issuer, uri, err, mockCertURL)
+	}
+	if pk, err := restarted.GetPublicKey(issuer, mockCertURL); err != nil || pk != test.JwtPubKey1 {
+		t.Fatalf("GetPublicKey(%s) from disk: got (%s, %v), want %s", mockCertURL, pk, err, test.JwtPubKey1)
+	}
+	keys := restarted.DebugKeys()
+	if len(keys) != 1 {
+		t.Fatalf("DebugKeys(): got %v, want 1 key", keys)
+	}
+	if got := keys[0]; got.Issuer != issuer || got.JwksURI != mockCertURL || got.LastRefreshError == "" ||
+		!reflect.DeepEqual(got.KeyIDs, []string{"fakeKey1_1", "fakeKey1_2"}) {
+		t.Errorf("DebugKeys(): got %+v", got)
+	}
+
+	// The cached responses are not used after the stale window.
+	expired := newJwksResolverWithCABundlePaths(JwtPubKeyEvictionDuration, JwtPubKeyRefreshInterval, time.Nanosecond, dir, nil)
+	defer expired.Close()
+	if _, err := expired.resolveJwksURIUsingOpenID(issuer); err == nil {
+		t.Errorf("resolveJwksURIUsingOpenID(%s): expected error after the stale window", issuer)
+	}
+	if _, err := expired.GetPublicKey(issuer, mockCertURL); err == nil {
+		t.Errorf("GetPublicKey(%s): expected error after the stale window", mockCertURL)
+	}
+}
+
 func startMockServer(t *testing.T) *test.MockOpenIDDiscoveryServer {
 	t.Helper()
 
@@ -591,7 +646,7 @@ func verifyKeyRefresh(t *testing.T, r *JwksResolver, ms *test.MockOpenIDDiscover
 	t.Helper()
 	mockCertURL := ms.URL + "/oauth2/v3/certs"
 
-	pk, err := r.GetPublicKey(mockCertURL)
+	pk, err := r.GetPublicKey("", mockCertURL)
 	if err != nil {
 		t.Fatalf("GetPublicKey(%+v) fails: expected no error, got (%v)", mockCertURL, err)
 	}
@@ -613,7 +668,7 @@ func verifyKeyRefresh(t *testing.T, r *JwksResolver, ms *test.MockOpenIDDiscover
 		t.Fatalf("Refresher failed to run")
 	}
 
-	pk, err = r.GetPublicKey(mockCertURL)
+	pk, err = r.GetPublicKey("", mockCertURL)
 	if err != nil {
 		t.Fatalf("GetPublicKey(%+v) fails: expected no error, got (%v)", mockCertURL, err)
 	}
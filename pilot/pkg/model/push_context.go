@@ -141,6 +141,10 @@ type PushContext struct {
 
 	Version string
 
+	// PushVersion is the version assigned by the discovery server to the push using this context.
+	// It is used to correlate the resources sent to proxies with the push that triggered them.
+	PushVersion string `json:"-"`
+
 	// cache gateways addresses for each network
 	// this is mainly used for kubernetes multi-cluster scenario
 	networkGateways map[string][]*Gateway
@@ -341,6 +341,25 @@ type IstioEndpoint struct {
 
 	// TLSMode endpoint is injected with istio sidecar and ready to configure Istio mTLS
 	TLSMode string
+
+	// HealthStatus of the endpoint. Unset means the registry does not report health, and the
+	// endpoint is considered healthy.
+	HealthStatus HealthStatus
+}
+
+// HealthStatus of an endpoint. The values match the Envoy core.HealthStatus enum.
+type HealthStatus int32
+
+const (
+	// Healthy endpoints receive traffic.
+	Healthy HealthStatus = 1
+	// UnHealthy endpoints are sent to Envoy, but don't receive traffic.
+	UnHealthy HealthStatus = 2
+)
+
+// IsHealthy returns true unless the registry reported the endpoint as unhealthy.
+func (ep *IstioEndpoint) IsHealthy() bool {
+	return ep.HealthStatus != UnHealthy
 }
 
 // ServiceAttributes represents a group of custom attributes of the service.
@@ -796,6 +796,14 @@ func setKeepAliveSettings(cluster *cluster.Cluster, keepalive *networking.Connec
 // FIXME: there isn't a way to distinguish between unset values and zero values
 func applyOutlierDetection(c *cluster.Cluster, outlier *networking.OutlierDetection) {
 	if outlier == nil {
+		// Unhealthy endpoints are sent in EDS, and would receive traffic in panic mode, e.g. when most pods of a
+		// service are not ready. Disable it, as with outlier detection below.
+		if features.SendUnhealthyEndpoints && c.GetType() == cluster.Cluster_EDS {
+			if c.CommonLbConfig == nil {
+				c.CommonLbConfig = &cluster.Cluster_CommonLbConfig{}
+			}
+			c.CommonLbConfig.HealthyPanicThreshold = &xdstype.Percent{Value: 0}
+		}
 		return
 	}
 
@@ -897,6 +905,8 @@ func applyLoadBalancer(c *cluster.Cluster, lb *networking.LoadBalancerSettings,
 
 	consistentHash := lb.GetConsistentHash()
 	if consistentHash != nil {
+		// TODO bounded load (hash_balance_factor), Maglev with a table size and filter state hashing
+		// need new ConsistentHashLB fields in istio.io/api, and the Envoy v3 cluster API in go-control-plane.
 		// TODO MinimumRingSize is an int, and zero could potentially be a valid value
 		// unable to distinguish between set and unset case currently GregHanson
 		// 1024 is the default value for envoy
@@ -1029,6 +1039,10 @@ func applyUpstreamTLSSettings(opts *buildClusterOpts, tls *networking.ClientTLSS
 			}
 		}
 
+		if tls.Mode == networking.ClientTLSSettings_ISTIO_MUTUAL {
+			authn_model.ApplyCRLToCommonTLSContext(tlsContext.CommonTlsContext)
+		}
+
 		// Set default SNI of cluster name for istio_mutual if sni is not set.
 		if len(tls.Sni) == 0 && tls.Mode == networking.ClientTLSSettings_ISTIO_MUTUAL {
 			tlsContext.Sni = c.Name
@@ -1040,6 +1040,30 @@ func TestDisablePanicThresholdAsDefault(t *testing.T) {
 	}
 }
 
+func TestDisablePanicThresholdWithUnhealthyEndpoints(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	defaultValue := features.SendUnhealthyEndpoints
+	defer func() { features.SendUnhealthyEndpoints = defaultValue }()
+
+	for _, send := range []bool{true, false} {
+		features.SendUnhealthyEndpoints = send
+		clusters, err := buildTestClusters("*.example.org", model.ClientSideLB, model.SidecarProxy,
+			&core.Locality{}, testMesh,
+			&networking.DestinationRule{
+				Host: "*.example.org",
+			})
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(clusters[0].GetType()).To(Equal(cluster.Cluster_EDS))
+		if send {
+			g.Expect(clusters[0].CommonLbConfig.GetHealthyPanicThreshold()).To(Not(BeNil()))
+			g.Expect(clusters[0].CommonLbConfig.HealthyPanicThreshold.GetValue()).To(Equal(float64(0)))
+		} else {
+			g.Expect(clusters[0].CommonLbConfig.GetHealthyPanicThreshold()).To(BeNil())
+		}
+	}
+}
+
 func TestApplyOutlierDetection(t *testing.T) {
 	g := NewGomegaWithT(t)
 
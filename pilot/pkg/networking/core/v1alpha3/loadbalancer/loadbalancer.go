@@ -18,6 +18,7 @@ package loadbalancer
 import (
 	"math"
 	"sort"
+	"strings"
 
 	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	"github.com/golang/protobuf/ptypes/wrappers"
@@ -137,6 +138,48 @@ func applyLocalityWeight(
 	}
 }
 
+// localityPriorities is the number of priorities applyLocalityFailover derives from the locality alone.
+const localityPriorities = 5
+
+const (
+	// FailoverLabelsAnnotation overrides the failover labels of PILOT_LOCALITY_FAILOVER_LABELS for the hosts of
+	// a DestinationRule, as a comma separated, ordered list of label keys. An empty value disables them.
+	FailoverLabelsAnnotation = "networking.istio.io/localityFailoverLabels"
+
+	// MaxFailoverLabels is the number of failover labels used to prioritize endpoints. Each label doubles the
+	// number of priorities, the labels after it are ignored.
+	MaxFailoverLabels = 8
+)
+
+// ParseFailoverLabels parses a comma separated list of failover labels.
+func ParseFailoverLabels(labels string) []string {
+	out := make([]string, 0)
+	for _, l := range strings.Split(labels, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// FailoverLabelPriority returns the priority of an endpoint based on the failover labels it shares
+// with the proxy, 0 meaning all labels match. Labels are ordered most significant first: a label
+// that doesn't match lowers the priority more than all the labels after it, which still order the
+// endpoints that share the same leading labels. Labels missing from the proxy never match.
+func FailoverLabelPriority(proxyLabels, endpointLabels map[string]string, failoverLabels []string) int {
+	if len(failoverLabels) > MaxFailoverLabels {
+		failoverLabels = failoverLabels[:MaxFailoverLabels]
+	}
+	priority := 0
+	for _, key := range failoverLabels {
+		priority <<= 1
+		if value, f := proxyLabels[key]; !f || endpointLabels[key] != value {
+			priority |= 1
+		}
+	}
+	return priority
+}
+
 // set locality loadbalancing priority
 func applyLocalityFailover(
 	locality *core.Locality,
@@ -164,6 +207,9 @@ func applyLocalityFailover(
 				}
 			}
 		}
+		// Endpoints may already be prioritized by failover labels (see FailoverLabelPriority), which
+		// take precedence over the locality. The locality orders endpoints with the same labels.
+		priority += int(localityEndpoint.Priority) * localityPriorities
 		loadAssignment.Endpoints[i].Priority = uint32(priority)
 		priorityMap[priority] = append(priorityMap[priority], i)
 	}
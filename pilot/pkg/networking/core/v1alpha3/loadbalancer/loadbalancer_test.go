@@ -168,6 +168,61 @@ func TestApplyLocalitySetting(t *testing.T) {
 			g.Expect(localityEndpoint.Priority).To(Equal(uint32(0)))
 		}
 	})
+
+	t.Run("Failover: with failover label priorities", func(t *testing.T) {
+		g := NewGomegaWithT(t)
+		// Endpoints are prioritized by failover labels when built, see FailoverLabelPriority.
+		loadAssignment := &endpoint.ClusterLoadAssignment{
+			ClusterName: "outbound|8080||test.example.org",
+			Endpoints: []*endpoint.LocalityLbEndpoints{
+				{Locality: &core.Locality{Region: "region1", Zone: "zone1", SubZone: "subzone1"}, Priority: 1},
+				{Locality: &core.Locality{Region: "region1", Zone: "zone1", SubZone: "subzone2"}},
+				{Locality: &core.Locality{Region: "region2", Zone: "zone1", SubZone: "subzone1"}},
+				{Locality: &core.Locality{Region: "region1", Zone: "zone1", SubZone: "subzone1"}},
+			},
+		}
+		ApplyLocalityLBSetting(locality, loadAssignment, &networking.LocalityLoadBalancerSetting{}, true)
+		priorities := []uint32{}
+		for _, localityEndpoint := range loadAssignment.Endpoints {
+			priorities = append(priorities, localityEndpoint.Priority)
+		}
+		g.Expect(priorities).To(Equal([]uint32{3, 1, 2, 0}))
+	})
+}
+
+func TestFailoverLabelPriority(t *testing.T) {
+	failoverLabels := []string{"cloud", "network", "rack"}
+	proxyLabels := map[string]string{"cloud": "aws", "network": "n1", "rack": "r1"}
+	cases := []struct {
+		name     string
+		proxy    map[string]string
+		endpoint map[string]string
+		expected int
+	}{
+		{"all match", proxyLabels, map[string]string{"cloud": "aws", "network": "n1", "rack": "r1", "app": "a"}, 0},
+		{"last mismatch", proxyLabels, map[string]string{"cloud": "aws", "network": "n1", "rack": "r2"}, 1},
+		{"middle mismatch", proxyLabels, map[string]string{"cloud": "aws", "network": "n2", "rack": "r1"}, 2},
+		{"middle and last mismatch", proxyLabels, map[string]string{"cloud": "aws", "network": "n2", "rack": "r2"}, 3},
+		{"first mismatch", proxyLabels, map[string]string{"cloud": "gcp", "network": "n1", "rack": "r1"}, 4},
+		{"later labels after first mismatch", proxyLabels, map[string]string{"cloud": "gcp", "network": "n1", "rack": "r2"}, 5},
+		{"all mismatch", proxyLabels, map[string]string{"cloud": "gcp", "network": "n2", "rack": "r2"}, 7},
+		{"missing on endpoint", proxyLabels, map[string]string{"cloud": "aws"}, 3},
+		{"missing on proxy", map[string]string{"network": "n1"}, map[string]string{"network": "n1"}, 5},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FailoverLabelPriority(tt.proxy, tt.endpoint, failoverLabels); got != tt.expected {
+				t.Fatalf("expected priority %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseFailoverLabels(t *testing.T) {
+	g := NewGomegaWithT(t)
+	g.Expect(ParseFailoverLabels(" cloud, network,,rack ")).To(Equal([]string{"cloud", "network", "rack"}))
+	// An empty list disables the failover labels, unlike a missing one.
+	g.Expect(ParseFailoverLabels("")).To(Equal([]string{}))
 }
 
 func TestGetLocalityLbSetting(t *testing.T) {
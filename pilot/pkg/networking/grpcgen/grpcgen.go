@@ -147,16 +147,21 @@ func (g *GrpcConfigGenerator) BuildClusters(node *model.Proxy, push *model.PushC
 	// gRPC doesn't currently support any of the APIs - returning just the expected EDS result.
 	// Since the code is relatively strict - we'll add info as needed.
 	for _, n := range names {
-		hn, portn, err := net.SplitHostPort(n)
-		if err != nil {
-			log.Warna("Failed to parse ", n, " ", err)
-			continue
+		// Routes to subsets use the regular Istio cluster name, everything else uses host:port.
+		serviceName := n
+		if !strings.HasPrefix(n, string(model.TrafficDirectionOutbound)+"|") {
+			hn, portn, err := net.SplitHostPort(n)
+			if err != nil {
+				log.Warna("Failed to parse ", n, " ", err)
+				continue
+			}
+			serviceName = "outbound|" + portn + "||" + hn
 		}
 		rc := &xdsapi.Cluster{
 			Name:                 n,
 			ClusterDiscoveryType: &xdsapi.Cluster_Type{Type: xdsapi.Cluster_EDS},
 			EdsClusterConfig: &xdsapi.Cluster_EdsClusterConfig{
-				ServiceName: "outbound|" + portn + "||" + hn,
+				ServiceName: serviceName,
 				EdsConfig: &envoycore.ConfigSource{
 					ConfigSourceSpecifier: &envoycore.ConfigSource_Ads{
 						Ads: &envoycore.AggregatedConfigSource{},
@@ -175,11 +180,8 @@ func (g *GrpcConfigGenerator) BuildClusters(node *model.Proxy, push *model.PushC
 func (g *GrpcConfigGenerator) BuildHTTPRoutes(node *model.Proxy, push *model.PushContext, routeNames []string) []*any.Any {
 	resp := []*any.Any{}
 
-	// Currently this mode is only used by GRPC, to extract Cluster for the default
-	// route.
-	// Current GRPC is also expecting the default route to be prefix=="", while we generate "/"
+	// Current GRPC is expecting the default route to be prefix=="", while we generate "/"
 	// in normal response.
-	// TODO: add support for full route, make sure GRPC is fixed to support both
 	for _, n := range routeNames {
 		hn, portn, err := net.SplitHostPort(n)
 		if err != nil {
@@ -192,34 +194,22 @@ func (g *GrpcConfigGenerator) BuildHTTPRoutes(node *model.Proxy, push *model.Pus
 			continue
 		}
 		el := node.SidecarScope.GetEgressListenerForRDS(port, "")
-		// TODO: use VirtualServices instead !
-		// Currently gRPC doesn't support matching the path.
 		svc := el.Services()
+		services := make(map[host.Name]*model.Service, len(svc))
+		for _, s := range svc {
+			services[s.Hostname] = s
+		}
 		for _, s := range svc {
 			if s.Hostname.Matches(host.Name(hn)) {
-				// Only generate the required route for grpc. Will need to generate more
-				// as GRPC adds more features.
+				// Only the subset of the VirtualService supported by gRPC is generated, see rds.go.
+				vs := virtualServiceForHost(el, s.Hostname)
 				rc := &xdsapi.RouteConfiguration{
 					Name: n,
 					VirtualHosts: []*envoy_api_v2_route.VirtualHost{
 						{
 							Name:    hn,
 							Domains: []string{hn, n},
-
-							Routes: []*envoy_api_v2_route.Route{
-								{
-									Match: &envoy_api_v2_route.RouteMatch{
-										PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{Prefix: ""},
-									},
-									Action: &envoy_api_v2_route.Route_Route{
-										Route: &envoy_api_v2_route.RouteAction{
-											ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
-												Cluster: n,
-											},
-										},
-									},
-								},
-							},
+							Routes:  buildGrpcRoutes(node, vs, services, port, n),
 						},
 					},
 				}
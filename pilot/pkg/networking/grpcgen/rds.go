@@ -0,0 +1,399 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcgen
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	envoy_api_v2_route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
+	"github.com/golang/protobuf/ptypes/wrappers"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/util/gogo"
+)
+
+// gRPC-xDS only understands a subset of RDS. The translation below keeps the parts of a VirtualService
+// that gRPC supports - path and header matches, cluster and weighted cluster actions, timeouts and
+// retries - and drops the rest, since sending them would cause gRPC to NACK the route. The dropped parts are
+// logged at debug level: the routes are built for every gRPC client on every push. Matches with conditions
+// gRPC can't evaluate are dropped with a warning instead, since ignoring the condition would widen the route.
+
+// grpcRetryOn is the set of retry conditions gRPC clients understand. They map to gRPC status codes.
+var grpcRetryOn = map[string]bool{
+	"cancelled":          true,
+	"deadline-exceeded":  true,
+	"internal":           true,
+	"resource-exhausted": true,
+	"unavailable":        true,
+}
+
+// defaultGrpcRetryOn is used when the VirtualService does not set retryOn, or none of the conditions
+// it sets is supported by gRPC. It is the gRPC subset of the Istio default retry policy.
+const defaultGrpcRetryOn = "unavailable,cancelled"
+
+// defaultGrpcRetryAttempts mirrors the default number of retries used for Envoy routes.
+const defaultGrpcRetryAttempts = 2
+
+// clusterName returns the name of the gRPC cluster for a destination. Destinations without a subset use
+// the host:port format understood by BuildClusters, subsets use the regular Istio subset key.
+func clusterName(dst *networking.Destination, svc *model.Service, port int) string {
+	if dst.GetPort() != nil {
+		port = int(dst.GetPort().GetNumber())
+	} else if svc != nil && len(svc.Ports) == 1 {
+		port = svc.Ports[0].Port
+	}
+	if dst.Subset == "" {
+		return net.JoinHostPort(dst.Host, strconv.Itoa(port))
+	}
+	return model.BuildSubsetKey(model.TrafficDirectionOutbound, dst.Subset, host.Name(dst.Host), port)
+}
+
+// virtualServiceForHost returns the first VirtualService visible to the egress listener that applies to the host.
+func virtualServiceForHost(el *model.IstioEgressListenerWrapper, hostname host.Name) *model.Config {
+	for _, vs := range el.VirtualServices() {
+		for _, h := range vs.Spec.(*networking.VirtualService).Hosts {
+			if host.Name(h).Matches(hostname) {
+				vs := vs
+				return &vs
+			}
+		}
+	}
+	return nil
+}
+
+// buildGrpcRoutes translates the http routes of a VirtualService for a gRPC client. The default route to
+// defaultCluster is appended unless the VirtualService already ends with a catch all route.
+func buildGrpcRoutes(node *model.Proxy, vs *model.Config, services map[host.Name]*model.Service,
+	port int, defaultCluster string) []*envoy_api_v2_route.Route {
+	out := make([]*envoy_api_v2_route.Route, 0)
+	catchAll := false
+	if vs != nil {
+		for _, http := range vs.Spec.(*networking.VirtualService).Http {
+			logUnsupportedRoute(vs, http)
+			if http.Redirect != nil || !hasWeightedDestination(http) {
+				// The route can't be expressed without its action, so it is dropped entirely.
+				continue
+			}
+			if len(http.Match) == 0 {
+				out = append(out, translateGrpcRoute(vs, http, nil, services, port))
+				catchAll = true
+				break
+			}
+			for _, match := range http.Match {
+				if !sourceMatch(node, match) || !supportedMatch(vs, http, match) {
+					continue
+				}
+				if match.Port != 0 && match.Port != uint32(port) {
+					continue
+				}
+				r := translateGrpcRoute(vs, http, match, services, port)
+				out = append(out, r)
+				if isCatchAllMatch(r.Match) {
+					catchAll = true
+					break
+				}
+			}
+			if catchAll {
+				break
+			}
+		}
+	}
+	if !catchAll {
+		out = append(out, defaultRoute(defaultCluster))
+	}
+	return out
+}
+
+// defaultRoute sends everything to a single cluster. gRPC expects the default prefix to be "" rather than "/".
+func defaultRoute(cluster string) *envoy_api_v2_route.Route {
+	return &envoy_api_v2_route.Route{
+		Match: &envoy_api_v2_route.RouteMatch{
+			PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{Prefix: ""},
+		},
+		Action: &envoy_api_v2_route.Route_Route{
+			Route: &envoy_api_v2_route.RouteAction{
+				ClusterSpecifier: &envoy_api_v2_route.RouteAction_Cluster{
+					Cluster: cluster,
+				},
+			},
+		},
+	}
+}
+
+// hasWeightedDestination returns true if the route sends traffic to at least one destination. Routes with no
+// destination, or only destinations with a 0 weight, would be translated to a WeightedCluster without cluster.
+func hasWeightedDestination(in *networking.HTTPRoute) bool {
+	if len(in.Route) == 1 {
+		return true
+	}
+	for _, dst := range in.Route {
+		if dst.Weight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceMatch checks the gateways, source labels and namespace of a match against the gRPC client.
+func sourceMatch(node *model.Proxy, match *networking.HTTPMatchRequest) bool {
+	if len(match.Gateways) > 0 {
+		mesh := false
+		for _, g := range match.Gateways {
+			if g == constants.IstioMeshGateway {
+				mesh = true
+				break
+			}
+		}
+		if !mesh {
+			return false
+		}
+	}
+	proxyLabels := labels.Collection{node.Metadata.Labels}
+	if !proxyLabels.IsSupersetOf(match.GetSourceLabels()) {
+		return false
+	}
+	return match.SourceNamespace == "" || match.SourceNamespace == node.Metadata.Namespace
+}
+
+// supportedMatch returns false if the match has conditions gRPC clients can't evaluate. Such matches are
+// dropped rather than translated without the condition, which would send more requests to the route.
+func supportedMatch(vs *model.Config, in *networking.HTTPRoute, match *networking.HTTPMatchRequest) bool {
+	var unsupported []string
+	if match.Method != nil {
+		unsupported = append(unsupported, "method")
+	}
+	if match.Authority != nil {
+		unsupported = append(unsupported, "authority")
+	}
+	if match.Scheme != nil {
+		unsupported = append(unsupported, "scheme")
+	}
+	if len(match.QueryParams) > 0 {
+		unsupported = append(unsupported, "queryParams")
+	}
+	if len(unsupported) == 0 {
+		return true
+	}
+	log.Warnf("grpc: VirtualService %s/%s route %q match %q: %s not supported by gRPC, dropping the match",
+		vs.Namespace, vs.Name, in.Name, match.Name, strings.Join(unsupported, ", "))
+	return false
+}
+
+func translateGrpcRoute(vs *model.Config, in *networking.HTTPRoute, match *networking.HTTPMatchRequest,
+	services map[host.Name]*model.Service, port int) *envoy_api_v2_route.Route {
+	routeName := in.Name
+	if match != nil && match.Name != "" {
+		routeName = routeName + "." + match.Name
+	}
+
+	action := &envoy_api_v2_route.RouteAction{
+		RetryPolicy: translateGrpcRetryPolicy(vs, in.Retries),
+	}
+	if in.Timeout != nil {
+		action.Timeout = gogo.DurationToProtoDuration(in.Timeout)
+	} else {
+		action.Timeout = features.DefaultRequestTimeout
+	}
+
+	weighted := make([]*envoy_api_v2_route.WeightedCluster_ClusterWeight, 0, len(in.Route))
+	var total uint32
+	for _, dst := range in.Route {
+		weight := uint32(dst.Weight)
+		if weight == 0 {
+			// Same as Envoy routes: a single destination gets all traffic, other 0 weights are ignored.
+			if len(in.Route) != 1 {
+				continue
+			}
+			weight = 100
+		}
+		total += weight
+		weighted = append(weighted, &envoy_api_v2_route.WeightedCluster_ClusterWeight{
+			Name:   clusterName(dst.Destination, services[host.Name(dst.Destination.GetHost())], port),
+			Weight: &wrappers.UInt32Value{Value: weight},
+		})
+	}
+	if len(weighted) == 1 {
+		action.ClusterSpecifier = &envoy_api_v2_route.RouteAction_Cluster{Cluster: weighted[0].Name}
+	} else {
+		action.ClusterSpecifier = &envoy_api_v2_route.RouteAction_WeightedClusters{
+			WeightedClusters: &envoy_api_v2_route.WeightedCluster{
+				Clusters:    weighted,
+				TotalWeight: &wrappers.UInt32Value{Value: total},
+			},
+		}
+	}
+
+	return &envoy_api_v2_route.Route{
+		Name:   routeName,
+		Match:  translateGrpcRouteMatch(match),
+		Action: &envoy_api_v2_route.Route_Route{Route: action},
+	}
+}
+
+func translateGrpcRouteMatch(in *networking.HTTPMatchRequest) *envoy_api_v2_route.RouteMatch {
+	out := &envoy_api_v2_route.RouteMatch{PathSpecifier: &envoy_api_v2_route.RouteMatch_Prefix{Prefix: ""}}
+	if in == nil {
+		return out
+	}
+
+	for name, stringMatch := range in.Headers {
+		out.Headers = append(out.Headers, translateGrpcHeaderMatch(name, stringMatch))
+	}
+	for name, stringMatch := range in.WithoutHeaders {
+		m := translateGrpcHeaderMatch(name, stringMatch)
+		m.InvertMatch = true
+		out.Headers = append(out.Headers, m)
+	}
+	// guarantee ordering of headers
+	sort.Slice(out.Headers, func(i, j int) bool {
+		return out.Headers[i].Name < out.Headers[j].Name
+	})
+
+	if in.Uri != nil {
+		switch m := in.Uri.MatchType.(type) {
+		case *networking.StringMatch_Exact:
+			out.PathSpecifier = &envoy_api_v2_route.RouteMatch_Path{Path: m.Exact}
+		case *networking.StringMatch_Prefix:
+			out.PathSpecifier = &envoy_api_v2_route.RouteMatch_Prefix{Prefix: m.Prefix}
+		case *networking.StringMatch_Regex:
+			out.PathSpecifier = &envoy_api_v2_route.RouteMatch_SafeRegex{SafeRegex: regexMatcher(m.Regex)}
+		}
+	}
+	if in.IgnoreUriCase {
+		out.CaseSensitive = &wrappers.BoolValue{Value: false}
+	}
+	return out
+}
+
+func translateGrpcHeaderMatch(name string, in *networking.StringMatch) *envoy_api_v2_route.HeaderMatcher {
+	out := &envoy_api_v2_route.HeaderMatcher{Name: name}
+	switch m := in.GetMatchType().(type) {
+	case *networking.StringMatch_Exact:
+		out.HeaderMatchSpecifier = &envoy_api_v2_route.HeaderMatcher_ExactMatch{ExactMatch: m.Exact}
+	case *networking.StringMatch_Prefix:
+		out.HeaderMatchSpecifier = &envoy_api_v2_route.HeaderMatcher_PrefixMatch{PrefixMatch: m.Prefix}
+	case *networking.StringMatch_Regex:
+		out.HeaderMatchSpecifier = &envoy_api_v2_route.HeaderMatcher_SafeRegexMatch{SafeRegexMatch: regexMatcher(m.Regex)}
+	default:
+		out.HeaderMatchSpecifier = &envoy_api_v2_route.HeaderMatcher_PresentMatch{PresentMatch: true}
+	}
+	return out
+}
+
+func regexMatcher(regex string) *matcher.RegexMatcher {
+	return &matcher.RegexMatcher{
+		EngineType: &matcher.RegexMatcher_GoogleRe2{GoogleRe2: &matcher.RegexMatcher_GoogleRE2{}},
+		Regex:      regex,
+	}
+}
+
+// translateGrpcRetryPolicy keeps the retry conditions gRPC understands. A policy with no attempts disables retries.
+func translateGrpcRetryPolicy(vs *model.Config, in *networking.HTTPRetry) *envoy_api_v2_route.RetryPolicy {
+	if in == nil {
+		return &envoy_api_v2_route.RetryPolicy{
+			RetryOn:    defaultGrpcRetryOn,
+			NumRetries: &wrappers.UInt32Value{Value: defaultGrpcRetryAttempts},
+		}
+	}
+	if in.Attempts <= 0 {
+		return nil
+	}
+
+	retryOn := make([]string, 0)
+	for _, cond := range strings.Split(in.RetryOn, ",") {
+		cond = strings.TrimSpace(cond)
+		if cond == "" {
+			continue
+		}
+		if !grpcRetryOn[cond] {
+			log.Debugf("grpc: VirtualService %s/%s: retry condition %q is not supported by gRPC, ignoring", vs.Namespace, vs.Name, cond)
+			continue
+		}
+		retryOn = append(retryOn, cond)
+	}
+	out := &envoy_api_v2_route.RetryPolicy{
+		RetryOn:    strings.Join(retryOn, ","),
+		NumRetries: &wrappers.UInt32Value{Value: uint32(in.Attempts)},
+	}
+	if len(retryOn) == 0 {
+		out.RetryOn = defaultGrpcRetryOn
+	}
+	if in.PerTryTimeout != nil {
+		out.PerTryTimeout = gogo.DurationToProtoDuration(in.PerTryTimeout)
+	}
+	return out
+}
+
+// logUnsupportedRoute logs the parts of a route that can't be sent to gRPC clients.
+func logUnsupportedRoute(vs *model.Config, in *networking.HTTPRoute) {
+	if !log.DebugEnabled() {
+		return
+	}
+	var unsupported []string
+	if in.Redirect != nil {
+		unsupported = append(unsupported, "redirect")
+	}
+	if in.Rewrite != nil {
+		unsupported = append(unsupported, "rewrite")
+	}
+	if in.Fault != nil {
+		unsupported = append(unsupported, "fault")
+	}
+	if in.Mirror != nil {
+		unsupported = append(unsupported, "mirror")
+	}
+	if in.CorsPolicy != nil {
+		unsupported = append(unsupported, "corsPolicy")
+	}
+	if in.Headers != nil {
+		unsupported = append(unsupported, "headers")
+	}
+	for _, dst := range in.Route {
+		if dst.Headers != nil {
+			unsupported = append(unsupported, "route.headers")
+			break
+		}
+	}
+	if in.Redirect == nil && !hasWeightedDestination(in) {
+		unsupported = append(unsupported, "route without weighted destination")
+	}
+	if len(unsupported) > 0 {
+		log.Debugf("grpc: VirtualService %s/%s route %q: %s not supported by gRPC, ignoring",
+			vs.Namespace, vs.Name, in.Name, strings.Join(unsupported, ", "))
+	}
+}
+
+// isCatchAllMatch returns true if the match selects all requests, so later routes would never be used.
+func isCatchAllMatch(m *envoy_api_v2_route.RouteMatch) bool {
+	if len(m.Headers) > 0 || len(m.QueryParameters) > 0 {
+		return false
+	}
+	if p, ok := m.PathSpecifier.(*envoy_api_v2_route.RouteMatch_Prefix); ok {
+		return p.Prefix == "" || p.Prefix == "/"
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcgen_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/grpcgen"
+	"istio.io/istio/pilot/pkg/proxy/envoy/xds"
+	"istio.io/istio/pilot/test/util"
+)
+
+func TestBuildHTTPRoutes(t *testing.T) {
+	cases := []string{"default", "weighted", "match"}
+	for _, tt := range cases {
+		t.Run(tt, func(t *testing.T) {
+			ds := xds.NewXDS()
+			sd := ds.DiscoveryServer.MemRegistry
+			sd.AddHTTPService("echo.test.svc.cluster.local", "10.10.10.1", 8080)
+			sd.AddHTTPService("echo-canary.test.svc.cluster.local", "10.10.10.2", 8080)
+
+			data, err := ioutil.ReadFile(fmt.Sprintf("testdata/%s.yaml", tt))
+			if err != nil {
+				t.Fatal(err)
+			}
+			configs, _, err := crd.ParseInputs(string(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range configs {
+				if _, err := ds.MemoryConfigStore.Create(c); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			push := model.NewPushContext()
+			if err := push.InitContext(ds.DiscoveryServer.Env, nil, nil); err != nil {
+				t.Fatal(err)
+			}
+			proxy := &model.Proxy{
+				Type:            model.SidecarProxy,
+				ConfigNamespace: "test",
+				Metadata:        &model.NodeMetadata{Namespace: "test"},
+			}
+			proxy.SidecarScope = model.DefaultSidecarScopeForNamespace(push, "test")
+
+			g := &grpcgen.GrpcConfigGenerator{}
+			res := g.BuildHTTPRoutes(proxy, push, []string{"echo.test.svc.cluster.local:8080"})
+			if len(res) != 1 {
+				t.Fatalf("expected one route configuration, got %d", len(res))
+			}
+			rc := &xdsapi.RouteConfiguration{}
+			if err := ptypes.UnmarshalAny(res[0], rc); err != nil {
+				t.Fatal(err)
+			}
+			out := &bytes.Buffer{}
+			if err := (&jsonpb.Marshaler{Indent: "  "}).Marshal(out, rc); err != nil {
+				t.Fatal(err)
+			}
+			util.CompareContent(out.Bytes(), fmt.Sprintf("testdata/%s.json.golden", tt), t)
+		})
+	}
+}
@@ -24,7 +24,10 @@ import (
 	"istio.io/istio/pilot/pkg/networking"
 	"istio.io/istio/pilot/pkg/networking/plugin"
 	"istio.io/istio/pilot/pkg/security/authn/factory"
+	authn_model "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
 	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/spiffe"
 )
 
 // Plugin implements Istio mTLS auth
@@ -37,9 +40,22 @@ func NewPlugin() plugin.Plugin {
 
 // OnInboundFilterChains setups filter chains based on the authentication policy.
 func (Plugin) OnInboundFilterChains(in *plugin.InputParams) []networking.FilterChain {
-	return factory.NewPolicyApplier(in.Push,
+	return applyTrustDomains(in, factory.NewPolicyApplier(in.Push,
 		in.ServiceInstance, in.Node.Metadata.Namespace, labels.Collection{in.Node.Metadata.Labels}).InboundFilterChain(
-		in.ServiceInstance.Endpoint.EndpointPort, in.Push.Mesh.SdsUdsPath, in.Node, in.ListenerProtocol)
+		in.ServiceInstance.Endpoint.EndpointPort, in.Push.Mesh.SdsUdsPath, in.Node, in.ListenerProtocol))
+}
+
+// applyTrustDomains restricts the mutual TLS peers of the filter chains to the local, alias and federated
+// trust domains when trust domain federation is enabled.
+func applyTrustDomains(in *plugin.InputParams, chains []networking.FilterChain) []networking.FilterChain {
+	trustDomains := trustdomain.NewBundle(spiffe.GetTrustDomain(), in.Push.Mesh.TrustDomainAliases).
+		PeerTrustDomains(trustdomain.FederatedTrustDomains())
+	for _, chain := range chains {
+		if chain.TLSContext != nil {
+			authn_model.ApplyTrustDomainsToCommonTLSContext(chain.TLSContext.CommonTlsContext, trustDomains)
+		}
+	}
+	return chains
 }
 
 // OnOutboundListener is called whenever a new outbound listener is added to the LDS output for a given service
@@ -96,10 +112,35 @@ func (Plugin) OnInboundCluster(in *plugin.InputParams, cluster *cluster.Cluster)
 
 // OnOutboundRouteConfiguration implements the Plugin interface method.
 func (Plugin) OnOutboundRouteConfiguration(in *plugin.InputParams, route *route.RouteConfiguration) {
+	if in.Node.Type != model.Router {
+		return
+	}
+	applyClaimHeaders(in, route)
 }
 
 // OnInboundRouteConfiguration implements the Plugin interface method.
 func (Plugin) OnInboundRouteConfiguration(in *plugin.InputParams, route *route.RouteConfiguration) {
+	if in.Node.Type != model.SidecarProxy {
+		return
+	}
+	applyClaimHeaders(in, route)
+}
+
+// applyClaimHeaders copies the claims of the validated JWT to the request headers on all the virtual hosts.
+// The headers are removed on the routes, which Envoy evaluates before the virtual host, so a client can't forge
+// them, whatever the order of the removal and the addition within a level.
+func applyClaimHeaders(in *plugin.InputParams, route *route.RouteConfiguration) {
+	applier := factory.NewPolicyApplier(in.Push, in.ServiceInstance, in.Node.Metadata.Namespace, labels.Collection{in.Node.Metadata.Labels})
+	headers, names := applier.ClaimHeaders()
+	if len(headers) == 0 {
+		return
+	}
+	for _, vh := range route.VirtualHosts {
+		for _, r := range vh.Routes {
+			r.RequestHeadersToRemove = append(r.RequestHeadersToRemove, names...)
+		}
+		vh.RequestHeadersToAdd = append(vh.RequestHeadersToAdd, headers...)
+	}
 }
 
 // OnOutboundCluster implements the Plugin interface method.
@@ -116,5 +157,5 @@ func (Plugin) OnInboundPassthroughFilterChains(in *plugin.InputParams) []network
 	// Pass nil for ServiceInstance so that we never consider any alpha policy for the pass through filter chain.
 	applier := factory.NewPolicyApplier(in.Push, nil /* ServiceInstance */, in.Node.Metadata.Namespace, labels.Collection{in.Node.Metadata.Labels})
 	// Pass 0 for endpointPort so that it never matches any port-level policy.
-	return applier.InboundFilterChain(0, in.Push.Mesh.SdsUdsPath, in.Node, in.ListenerProtocol)
+	return applyTrustDomains(in, applier.InboundFilterChain(0, in.Push.Mesh.SdsUdsPath, in.Node, in.ListenerProtocol))
 }
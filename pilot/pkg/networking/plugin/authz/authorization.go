@@ -18,6 +18,7 @@ import (
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 
+	"istio.io/api/security/v1beta1"
 	"istio.io/pkg/log"
 
 	"istio.io/istio/pilot/pkg/model"
@@ -72,10 +73,10 @@ func (Plugin) OnInboundListener(in *plugin.InputParams, mutable *networking.Muta
 	return nil
 }
 
-func buildFilter(in *plugin.InputParams, mutable *networking.MutableObjects) {
+func newBuilder(in *plugin.InputParams) *builder.Builder {
 	if in.Push == nil || in.Push.AuthzPolicies == nil {
 		authzLog.Debugf("no authorization policy in push context")
-		return
+		return nil
 	}
 
 	// TODO: Get trust domain from MeshConfig instead.
@@ -83,9 +84,31 @@ func buildFilter(in *plugin.InputParams, mutable *networking.MutableObjects) {
 	tdBundle := trustdomain.NewBundle(spiffe.GetTrustDomain(), in.Push.Mesh.TrustDomainAliases)
 	namespace := in.Node.ConfigNamespace
 	workload := labels.Collection{in.Node.Metadata.Labels}
-	b := builder.New(tdBundle, workload, namespace, in.Push.AuthzPolicies, util.IsIstioVersionGE15(in.Node))
+	b := builder.New(tdBundle, workload, namespace, in.Push.AuthzPolicies, jwtIssuers(in.Push, namespace, workload),
+		util.IsIstioVersionGE15(in.Node))
 	if b == nil {
 		authzLog.Debugf("no authorization policy for workload %v in %s", workload, namespace)
+	}
+	return b
+}
+
+// jwtIssuers returns the issuers of the RequestAuthentication policies of a workload.
+func jwtIssuers(push *model.PushContext, namespace string, workload labels.Collection) []string {
+	if push.AuthnBetaPolicies == nil {
+		return nil
+	}
+	var issuers []string
+	for _, policy := range push.AuthnBetaPolicies.GetJwtPoliciesForWorkload(namespace, workload) {
+		for _, rule := range policy.Spec.(*v1beta1.RequestAuthentication).JwtRules {
+			issuers = append(issuers, rule.Issuer)
+		}
+	}
+	return issuers
+}
+
+func buildFilter(in *plugin.InputParams, mutable *networking.MutableObjects) {
+	b := newBuilder(in)
+	if b == nil {
 		return
 	}
 
@@ -159,12 +182,33 @@ func (Plugin) OnVirtualListener(in *plugin.InputParams, mutable *networking.Muta
 func (Plugin) OnInboundCluster(in *plugin.InputParams, cluster *cluster.Cluster) {
 }
 
-// OnOutboundRouteConfiguration implements the Plugin interface method.
+// OnOutboundRouteConfiguration scopes the ext_authz filter of the CUSTOM policies to their rules on gateways.
 func (Plugin) OnOutboundRouteConfiguration(in *plugin.InputParams, route *route.RouteConfiguration) {
+	if in.Node.Type != model.Router {
+		// Only care about router.
+		return
+	}
+
+	if b := newBuilder(in); b != nil {
+		b.ScopeRoutes(route, 0)
+	}
 }
 
-// OnInboundRouteConfiguration implements the Plugin interface method.
+// OnInboundRouteConfiguration scopes the ext_authz filter of the CUSTOM policies to their rules on sidecars.
 func (Plugin) OnInboundRouteConfiguration(in *plugin.InputParams, route *route.RouteConfiguration) {
+	if in.Node.Type != model.SidecarProxy {
+		// Only care about sidecar.
+		return
+	}
+
+	if b := newBuilder(in); b != nil {
+		port := 0
+		// The passthrough route config has no service, its port is unknown.
+		if in.ServiceInstance != nil && in.ServiceInstance.Service.Hostname != "" && in.ServiceInstance.Endpoint != nil {
+			port = int(in.ServiceInstance.Endpoint.EndpointPort)
+		}
+		b.ScopeRoutes(route, port)
+	}
 }
 
 // OnOutboundCluster implements the Plugin interface method.
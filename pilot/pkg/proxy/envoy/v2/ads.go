@@ -95,6 +95,16 @@ type XdsConnection struct {
 	// Both ADS and EDS streams implement this interface
 	stream DiscoveryStream
 
+	// deltaStream is set instead of stream for connections using the incremental (delta) protocol.
+	deltaStream DeltaDiscoveryStream
+
+	// deltaWatches tracks, per type URL, the resources a delta client subscribed to and the versions it has.
+	deltaWatches map[string]*deltaWatch
+
+	// deltaProxyKey is the config cache key of the proxy at the last full delta push. Pushes for the same
+	// key are limited to the clusters of the updated services.
+	deltaProxyKey string
+
 	// Routes is the list of watched Routes.
 	Routes []string
 
@@ -106,6 +116,13 @@ type XdsConnection struct {
 	// Original node metadata, to avoid unmarshall/marshall. This is included
 	// in internal events.
 	xdsNode *core.Node
+
+	// nackedTypes holds the type URLs for which the proxy rejected the last push.
+	nackedTypes map[string]struct{}
+
+	// lastPush is the time the last push to this connection was dequeued. It is guarded by the
+	// push queue lock.
+	lastPush time.Time
 }
 
 // XdsEvent represents a config or registry event that results in a push.
@@ -139,6 +156,27 @@ func newXdsConnection(peerAddr string, stream DiscoveryStream) *XdsConnection {
 	}
 }
 
+// recordAck tracks whether the proxy rejected the last push of a type.
+func (con *XdsConnection) recordAck(typeURL string, nack bool) {
+	con.mu.Lock()
+	defer con.mu.Unlock()
+	if !nack {
+		delete(con.nackedTypes, typeURL)
+		return
+	}
+	if con.nackedTypes == nil {
+		con.nackedTypes = map[string]struct{}{}
+	}
+	con.nackedTypes[typeURL] = struct{}{}
+}
+
+// hasNack returns true if the proxy rejected the last push of any type.
+func (con *XdsConnection) hasNack() bool {
+	con.mu.RLock()
+	defer con.mu.RUnlock()
+	return len(con.nackedTypes) > 0
+}
+
 // isExpectedGRPCError checks a gRPC error code and determines whether it is an expected error when
 // things are operating normally. This is basically capturing when the client disconnects.
 func isExpectedGRPCError(err error) bool {
@@ -291,6 +329,10 @@ func (s *DiscoveryServer) StreamAggregatedResources(stream discovery.AggregatedD
 			if s.StatusReporter != nil {
 				s.StatusReporter.RegisterEvent(con.ConID, TypeURLToEventType(discReq.TypeUrl), discReq.ResponseNonce)
 			}
+			if discReq.ResponseNonce != "" {
+				// Proxies with a rejected config are pushed first, see pushPriorityOf.
+				con.recordAck(discReq.TypeUrl, discReq.ErrorDetail != nil)
+			}
 
 			// Based on node metadata a different generator was selected, use it instead of the default
 			// behavior.
@@ -633,21 +675,13 @@ func (s *DiscoveryServer) setProxyState(proxy *model.Proxy, push *model.PushCont
 	return nil
 }
 
-// DeltaAggregatedResources is not implemented.
-// Instead, Generators may send only updates/add, with Delete indicated by an empty spec.
-// This works if both ends follow this model. For example EDS and the API generator follow this
-// pattern.
-//
-// The delta protocol changes the request, adding unsubscribe/subscribe instead of sending full
-// list of resources. On the response it adds 'removed resources' and sends changes for everything.
-// TODO: we could implement this method if needed, the change is not very big.
-func (s *DiscoveryServer) DeltaAggregatedResources(stream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
-	return status.Errorf(codes.Unimplemented, "not implemented")
-}
-
 // Compute and send the new configuration for a connection. This is blocking and may be slow
 // for large configs. The method will hold a lock on con.pushMutex.
 func (s *DiscoveryServer) pushConnection(con *XdsConnection, pushEv *XdsEvent) error {
+	if con.deltaStream != nil {
+		return s.pushDeltaConnection(con, pushEv)
+	}
+
 	// TODO: update the service deps based on NetworkScope
 	if !pushEv.full {
 		if !ProxyNeedsPush(con.node, pushEv) {
@@ -863,6 +897,14 @@ func (s *DiscoveryServer) removeCon(conID string) {
 	}
 }
 
+// context returns the context of the underlying stream, which is done when the connection is closed.
+func (conn *XdsConnection) context() context.Context {
+	if conn.deltaStream != nil {
+		return conn.deltaStream.Context()
+	}
+	return conn.stream.Context()
+}
+
 // Send with timeout
 func (conn *XdsConnection) send(res *discovery.DiscoveryResponse) error {
 	done := make(chan error, 1)
@@ -19,6 +19,7 @@ import (
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/any"
 
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
@@ -26,7 +27,12 @@ import (
 
 // clusters aggregate a DiscoveryResponse for pushing.
 func cdsDiscoveryResponse(response []*cluster.Cluster, noncePrefix, typeURL string) *discovery.DiscoveryResponse {
-	out := &discovery.DiscoveryResponse{
+	return cdsResourcesDiscoveryResponse(clusterResources(response, typeURL), noncePrefix, typeURL)
+}
+
+// cdsResourcesDiscoveryResponse aggregates marshalled clusters in a DiscoveryResponse for pushing.
+func cdsResourcesDiscoveryResponse(resources []*any.Any, noncePrefix, typeURL string) *discovery.DiscoveryResponse {
+	return &discovery.DiscoveryResponse{
 		// All resources for CDS ought to be of the type Cluster
 		TypeUrl: typeURL,
 
@@ -36,26 +42,59 @@ func cdsDiscoveryResponse(response []*cluster.Cluster, noncePrefix, typeURL stri
 		// will begin seeing results it deems to be good.
 		VersionInfo: versionInfo(),
 		Nonce:       nonce(noncePrefix),
+		Resources:   resources,
 	}
+}
 
-	for _, c := range response {
+func clusterResources(clusters []*cluster.Cluster, typeURL string) []*any.Any {
+	out := make([]*any.Any, 0, len(clusters))
+	for _, c := range clusters {
 		cc := util.MessageToAny(c)
 		cc.TypeUrl = typeURL
-		out.Resources = append(out.Resources, cc)
+		out = append(out, cc)
 	}
-
 	return out
 }
 
+// buildClusters returns the clusters of a proxy, marshalled for the response. They are read from
+// the config cache when it is enabled.
+func (s *DiscoveryServer) buildClusters(con *XdsConnection, push *model.PushContext) ([]*cluster.Cluster, []*any.Any) {
+	key, cacheable := s.configCacheKey(con, CDS, con.node.RequestedTypes.CDS, nil)
+	return s.buildClustersForKey(con, push, key, cacheable)
+}
+
+// buildClustersForKey is buildClusters with the config cache key of the connection already computed.
+func (s *DiscoveryServer) buildClustersForKey(con *XdsConnection, push *model.PushContext,
+	key string, cacheable bool) ([]*cluster.Cluster, []*any.Any) {
+	typeURL := con.node.RequestedTypes.CDS
+	if cacheable {
+		if entry := s.configCache.get(push, CDS, key); entry != nil {
+			return entry.clusters, entry.resources
+		}
+	}
+
+	rawClusters := s.ConfigGenerator.BuildClusters(con.node, push)
+	resources := clusterResources(rawClusters, typeURL)
+	if cacheable {
+		s.configCache.add(push, key, &configCacheEntry{
+			xdsType:   CDS,
+			namespace: con.node.ConfigNamespace,
+			clusters:  rawClusters,
+			resources: resources,
+		})
+	}
+	return rawClusters, resources
+}
+
 func (s *DiscoveryServer) pushCds(con *XdsConnection, push *model.PushContext, version string) error {
 	// TODO: Modify interface to take services, and config instead of making library query registry
 	pushStart := time.Now()
-	rawClusters := s.ConfigGenerator.BuildClusters(con.node, push)
+	rawClusters, resources := s.buildClusters(con, push)
 
 	if s.DebugConfigs {
 		con.CDSClusters = rawClusters
 	}
-	response := cdsDiscoveryResponse(rawClusters, push.Version, con.node.RequestedTypes.CDS)
+	response := cdsResourcesDiscoveryResponse(resources, push.Version, con.node.RequestedTypes.CDS)
 	err := con.send(response)
 	cdsPushTime.Record(time.Since(pushStart).Seconds())
 	if err != nil {
@@ -63,6 +102,13 @@ func (s *DiscoveryServer) pushCds(con *XdsConnection, push *model.PushContext, v
 		return err
 	}
 	cdsPushes.Increment()
+	if s.pushHistory.enabled() {
+		names := make([]string, 0, len(rawClusters))
+		for _, c := range rawClusters {
+			names = append(names, c.Name)
+		}
+		s.recordPushedResources(con, push, CDS, names, response.Resources)
+	}
 
 	// The response can't be easily read due to 'any' marshaling.
 	adsLog.Infof("CDS: PUSH for node:%s clusters:%d services:%d version:%s",
@@ -0,0 +1,238 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"github.com/golang/protobuf/ptypes/any"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// configCacheEntry holds the resources generated for a proxy.
+type configCacheEntry struct {
+	// xdsType is CDS or RDS.
+	xdsType string
+	// namespace is the config namespace of the proxy the resources were built for.
+	namespace string
+
+	// clusters or routes hold the generated resources, and resources the marshalled ones.
+	clusters  []*cluster.Cluster
+	routes    []*route.RouteConfiguration
+	resources []*any.Any
+}
+
+// configCache stores the CDS and RDS resources generated for proxies, so that proxies with the same
+// scope share them instead of generating them again. Entries are keyed by the inputs of the
+// generation (see configCacheKey), and kept across pushes until a config change may affect them.
+type configCache struct {
+	mu      sync.RWMutex
+	entries map[string]*configCacheEntry
+	// push is the push context the entries are valid for. Entries generated with an older
+	// push context are not stored.
+	push *model.PushContext
+}
+
+func newConfigCache() *configCache {
+	return &configCache{
+		entries: map[string]*configCacheEntry{},
+	}
+}
+
+// get returns the entry for a key, if it is valid for the push context.
+func (c *configCache) get(push *model.PushContext, xdsType, key string) *configCacheEntry {
+	c.mu.RLock()
+	var entry *configCacheEntry
+	if push == c.push {
+		entry = c.entries[key]
+	}
+	c.mu.RUnlock()
+
+	if entry != nil {
+		configCacheHits.With(typeTag.Value(xdsType)).Increment()
+	} else {
+		configCacheMisses.With(typeTag.Value(xdsType)).Increment()
+	}
+	return entry
+}
+
+// add stores an entry, unless the push context is outdated.
+func (c *configCache) add(push *model.PushContext, key string, entry *configCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if push != c.push {
+		return
+	}
+	c.entries[key] = entry
+}
+
+// size returns the number of entries.
+func (c *configCache) size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// update makes the cache valid for a new push context, and removes the entries affected by the
+// configs updated. If the updated configs are unknown, all entries are removed.
+func (c *configCache) update(push *model.PushContext, configsUpdated map[model.ConfigKey]struct{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.push = push
+	if len(configsUpdated) == 0 {
+		c.entries = map[string]*configCacheEntry{}
+		return
+	}
+	for key, entry := range c.entries {
+		for config := range configsUpdated {
+			if affectsConfigCacheEntry(config, entry) {
+				delete(c.entries, key)
+				break
+			}
+		}
+	}
+}
+
+// affectsConfigCacheEntry returns true if a config change may change the resources of an entry.
+func affectsConfigCacheEntry(config model.ConfigKey, entry *configCacheEntry) bool {
+	switch config.Kind {
+	case collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().GroupVersionKind():
+		// Used for listeners, and for the ext_authz settings of routes.
+		return entry.xdsType == RDS
+	case collections.IstioSecurityV1Beta1Requestauthentications.Resource().GroupVersionKind():
+		// Used for listeners, and for the JWT claim headers of routes.
+		return entry.xdsType == RDS
+	case collections.IstioSecurityV1Beta1Peerauthentications.Resource().GroupVersionKind():
+		// Used for the TLS settings of clusters.
+		return entry.xdsType == CDS
+	case collections.IstioMixerV1ConfigClientQuotaspecbindings.Resource().GroupVersionKind(),
+		collections.IstioMixerV1ConfigClientQuotaspecs.Resource().GroupVersionKind():
+		// Used for the Mixer configuration of routes.
+		return entry.xdsType == RDS
+	case collections.IstioNetworkingV1Alpha3Sidecars.Resource().GroupVersionKind():
+		// A Sidecar only applies to proxies in its namespace.
+		return entry.namespace == config.Namespace
+	default:
+		return true
+	}
+}
+
+// configCacheKey lists the inputs of CDS and RDS generation for a proxy, apart from the push context.
+type configCacheKey struct {
+	XdsType string
+	TypeURL string
+
+	Type            model.NodeType
+	ConfigNamespace string
+	DNSDomain       string
+	IstioVersion    *model.IstioVersion
+	Locality        string
+	SupportsIPv4    bool
+	Instances       []configCacheInstance
+	// Metadata, without the fields which are specific to each instance of a workload.
+	Metadata model.NodeMetadata
+
+	// SidecarScope identifies the Sidecar resource used by the proxy, if any.
+	SidecarScope string
+
+	// RouteNames are the routes requested by the proxy, for RDS.
+	RouteNames []string `json:",omitempty"`
+}
+
+// configCacheInstance is the part of a proxy service instance used for generation. Addresses
+// are not included, inbound clusters use the proxy localhost.
+type configCacheInstance struct {
+	Service         string
+	ServicePort     int
+	ServicePortName string
+	EndpointPort    uint32
+	ServiceAccount  string
+	Labels          labels.Instance
+}
+
+// configCacheKey returns the cache key for the resources of a connection, or false if the cache is disabled.
+func (s *DiscoveryServer) configCacheKey(con *XdsConnection, xdsType, typeURL string, routeNames []string) (string, bool) {
+	if s.configCache == nil {
+		return "", false
+	}
+	return configCacheKeyFor(con.node, xdsType, typeURL, routeNames)
+}
+
+// configCacheKeyFor returns the cache key for the resources of a proxy, or false if they can't be cached.
+func configCacheKeyFor(proxy *model.Proxy, xdsType, typeURL string, routeNames []string) (string, bool) {
+	if proxy.SidecarScope == nil || proxy.Metadata == nil {
+		return "", false
+	}
+
+	key := configCacheKey{
+		XdsType:         xdsType,
+		TypeURL:         typeURL,
+		Type:            proxy.Type,
+		ConfigNamespace: proxy.ConfigNamespace,
+		DNSDomain:       proxy.DNSDomain,
+		IstioVersion:    proxy.IstioVersion,
+		SupportsIPv4:    proxy.SupportsIPv4(),
+		Metadata:        *proxy.Metadata,
+	}
+	if len(routeNames) > 0 {
+		key.RouteNames = append([]string{}, routeNames...)
+		sort.Strings(key.RouteNames)
+	}
+	if proxy.Locality != nil {
+		key.Locality = util.LocalityToString(proxy.Locality)
+	}
+	for _, si := range proxy.ServiceInstances {
+		key.Instances = append(key.Instances, configCacheInstance{
+			Service:         string(si.Service.Hostname),
+			ServicePort:     si.ServicePort.Port,
+			ServicePortName: si.ServicePort.Name,
+			EndpointPort:    si.Endpoint.EndpointPort,
+			ServiceAccount:  si.Endpoint.ServiceAccount,
+			Labels:          si.Endpoint.Labels,
+		})
+	}
+	sort.Slice(key.Instances, func(i, j int) bool {
+		a, b := key.Instances[i], key.Instances[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.ServicePort < b.ServicePort
+	})
+	if cfg := proxy.SidecarScope.Config; cfg != nil {
+		key.SidecarScope = cfg.Namespace + "/" + cfg.Name + "/" + cfg.ResourceVersion
+	}
+
+	// These vary between instances of a workload, and are not used for clusters and routes.
+	key.Metadata.InstanceIPs = nil
+	key.Metadata.InstanceName = ""
+	key.Metadata.PlatformMetadata = nil
+
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), true
+}
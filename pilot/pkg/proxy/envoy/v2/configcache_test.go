@@ -0,0 +1,124 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+func cacheTestProxy(ip, name, namespace string) *model.Proxy {
+	return &model.Proxy{
+		Type:            model.SidecarProxy,
+		IPAddresses:     []string{ip},
+		ID:              name + "." + namespace,
+		ConfigNamespace: namespace,
+		SidecarScope:    &model.SidecarScope{},
+		Metadata: &model.NodeMetadata{
+			InstanceIPs:  []string{ip},
+			InstanceName: name,
+			Labels:       map[string]string{"app": "reviews"},
+		},
+	}
+}
+
+func TestConfigCacheKey(t *testing.T) {
+	key := func(p *model.Proxy, xdsType string, routes []string) string {
+		t.Helper()
+		k, ok := configCacheKeyFor(p, xdsType, ClusterType, routes)
+		if !ok {
+			t.Fatalf("expected proxy to be cacheable")
+		}
+		return k
+	}
+
+	a := key(cacheTestProxy("10.0.0.1", "reviews-1", "ns"), CDS, nil)
+	if b := key(cacheTestProxy("10.0.0.2", "reviews-2", "ns"), CDS, nil); a != b {
+		t.Errorf("expected instances of the same workload to share the key")
+	}
+	if b := key(cacheTestProxy("10.0.0.1", "reviews-1", "other"), CDS, nil); a == b {
+		t.Errorf("expected proxies in different namespaces to have different keys")
+	}
+	if b := key(cacheTestProxy("10.0.0.1", "reviews-1", "ns"), RDS, nil); a == b {
+		t.Errorf("expected different types to have different keys")
+	}
+	labeled := cacheTestProxy("10.0.0.1", "reviews-1", "ns")
+	labeled.Metadata.Labels = map[string]string{"app": "ratings"}
+	if b := key(labeled, CDS, nil); a == b {
+		t.Errorf("expected proxies with different labels to have different keys")
+	}
+	if key(cacheTestProxy("10.0.0.1", "reviews-1", "ns"), RDS, []string{"80", "9080"}) !=
+		key(cacheTestProxy("10.0.0.1", "reviews-1", "ns"), RDS, []string{"9080", "80"}) {
+		t.Errorf("expected route order to be ignored")
+	}
+
+	if _, ok := configCacheKeyFor(&model.Proxy{Metadata: &model.NodeMetadata{}}, CDS, ClusterType, nil); ok {
+		t.Errorf("expected proxy without sidecar scope not to be cacheable")
+	}
+}
+
+func TestConfigCacheUpdate(t *testing.T) {
+	c := newConfigCache()
+	push := model.NewPushContext()
+	c.update(push, nil)
+	c.add(push, "cds-ns", &configCacheEntry{xdsType: CDS, namespace: "ns"})
+	c.add(push, "rds-ns", &configCacheEntry{xdsType: RDS, namespace: "ns"})
+	c.add(push, "cds-other", &configCacheEntry{xdsType: CDS, namespace: "other"})
+
+	if c.get(push, CDS, "cds-ns") == nil {
+		t.Fatalf("expected entry to be cached")
+	}
+	if c.get(model.NewPushContext(), CDS, "cds-ns") != nil {
+		t.Fatalf("expected entries to be invalid for another push context")
+	}
+
+	// Entries generated from an outdated push context are not stored.
+	next := model.NewPushContext()
+	c.update(next, map[model.ConfigKey]struct{}{{
+		Kind:      collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().GroupVersionKind(),
+		Name:      "policy",
+		Namespace: "ns",
+	}: {}})
+	c.add(push, "stale", &configCacheEntry{xdsType: CDS})
+	if c.size() != 2 || c.get(next, RDS, "rds-ns") != nil {
+		t.Fatalf("expected authorization policies to only remove the RDS entries, got %d", c.size())
+	}
+
+	c.add(next, "rds-ns", &configCacheEntry{xdsType: RDS, namespace: "ns"})
+	c.update(next, map[model.ConfigKey]struct{}{{
+		Kind:      collections.IstioSecurityV1Beta1Requestauthentications.Resource().GroupVersionKind(),
+		Name:      "jwt",
+		Namespace: "ns",
+	}: {}})
+	if c.size() != 2 || c.get(next, RDS, "rds-ns") != nil {
+		t.Fatalf("expected request authentications to only remove the RDS entries, got %d", c.size())
+	}
+
+	c.update(next, map[model.ConfigKey]struct{}{{
+		Kind:      collections.IstioNetworkingV1Alpha3Sidecars.Resource().GroupVersionKind(),
+		Name:      "default",
+		Namespace: "ns",
+	}: {}})
+	if c.size() != 1 || c.get(next, CDS, "cds-other") == nil {
+		t.Fatalf("expected only the entries of the sidecar namespace to be removed")
+	}
+
+	c.update(next, map[model.ConfigKey]struct{}{{Kind: model.VirtualServiceKind, Name: "vs", Namespace: "other"}: {}})
+	if c.size() != 0 {
+		t.Fatalf("expected virtual services to remove all entries, got %d", c.size())
+	}
+}
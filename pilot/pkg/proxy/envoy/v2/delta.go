@@ -0,0 +1,756 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+
+	"istio.io/pkg/monitoring"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/proxy/envoy/v3"
+)
+
+// DeltaDiscoveryStream is an interface for incremental (delta) ADS.
+type DeltaDiscoveryStream interface {
+	Send(*discovery.DeltaDiscoveryResponse) error
+	Recv() (*discovery.DeltaDiscoveryRequest, error)
+	grpc.ServerStream
+}
+
+// deltaWatch tracks, for a single type URL of a delta connection, the resources the client subscribed to
+// and the version of each resource the client has.
+// It is only accessed from the goroutine handling the connection, so it does not need locking.
+type deltaWatch struct {
+	typeURL string
+
+	// wildcard is set when the client did not subscribe to explicit names, meaning it wants every
+	// resource of the type. This is the case for CDS and LDS.
+	wildcard bool
+
+	// subscribed is the set of resource names the client explicitly subscribed to.
+	subscribed map[string]struct{}
+
+	// sent maps a resource name to the version last sent to the client. A resource is only resent
+	// when its generated version differs.
+	sent map[string]string
+
+	// acked maps a resource name to the version the client acknowledged.
+	acked map[string]string
+
+	// pending maps the nonce of each response in flight to the resource versions it carried. Removed
+	// resources are recorded with an empty version.
+	pending map[string]map[string]string
+
+	// forceFull is set when the client state is unknown (for example after a NACK), so the next push sends
+	// every resource instead of only the ones that changed.
+	forceFull bool
+
+	// source holds the config cache entry the last diff was computed from. A push reading the same entry
+	// has nothing to send.
+	source []*any.Any
+}
+
+func newDeltaWatch(typeURL string, wildcard bool) *deltaWatch {
+	return &deltaWatch{
+		typeURL:    typeURL,
+		wildcard:   wildcard,
+		subscribed: map[string]struct{}{},
+		sent:       map[string]string{},
+		acked:      map[string]string{},
+		pending:    map[string]map[string]string{},
+	}
+}
+
+// subscribe updates the subscription with the names from a request. It returns true if the client
+// subscribed to new resources that must be sent.
+func (w *deltaWatch) subscribe(subscribe, unsubscribe []string) bool {
+	added := false
+	for _, name := range subscribe {
+		if name == "*" {
+			added = added || !w.wildcard
+			w.wildcard = true
+			continue
+		}
+		if _, f := w.subscribed[name]; !f {
+			w.subscribed[name] = struct{}{}
+			added = true
+		}
+	}
+	for _, name := range unsubscribe {
+		if name == "*" {
+			w.wildcard = false
+			continue
+		}
+		delete(w.subscribed, name)
+		delete(w.sent, name)
+		delete(w.acked, name)
+	}
+	return added
+}
+
+// names returns the sorted list of explicitly subscribed resource names.
+func (w *deltaWatch) names() []string {
+	out := make([]string, 0, len(w.subscribed))
+	for name := range w.subscribed {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// wants returns true if the client is interested in the named resource.
+func (w *deltaWatch) wants(name string) bool {
+	if w.wildcard {
+		return true
+	}
+	_, f := w.subscribed[name]
+	return f
+}
+
+// ack marks the resources sent with the nonce as acknowledged by the client.
+func (w *deltaWatch) ack(nonce string) {
+	versions, f := w.pending[nonce]
+	if !f {
+		return
+	}
+	delete(w.pending, nonce)
+	for name, version := range versions {
+		if version == "" {
+			delete(w.acked, name)
+		} else {
+			w.acked[name] = version
+		}
+	}
+}
+
+// nack drops the resources sent with the nonce. The client state is reset to what it last acknowledged,
+// and the next push falls back to sending every resource.
+func (w *deltaWatch) nack(nonce string) {
+	delete(w.pending, nonce)
+	w.sent = make(map[string]string, len(w.acked))
+	for name, version := range w.acked {
+		w.sent[name] = version
+	}
+	w.forceFull = true
+}
+
+// diff compares the generated resources with the versions already sent to the client. It returns
+// the resources that must be sent, and the names of resources the client has that no longer exist.
+// Removals are only computed when complete is set, meaning resources holds every resource of the type
+// rather than a subset. If inScope is set, resources were only generated for the names it accepts, so
+// removals are limited to those names.
+func (w *deltaWatch) diff(resources []*discovery.Resource, complete bool,
+	inScope func(name string) bool) ([]*discovery.Resource, []string) {
+	changed := make([]*discovery.Resource, 0, len(resources))
+	generated := make(map[string]struct{}, len(resources))
+	for _, r := range resources {
+		if !w.wants(r.Name) {
+			continue
+		}
+		generated[r.Name] = struct{}{}
+		if !w.forceFull && w.sent[r.Name] == r.Version {
+			continue
+		}
+		changed = append(changed, r)
+	}
+
+	var removed []string
+	if complete {
+		for name := range w.sent {
+			if inScope != nil && !inScope(name) {
+				continue
+			}
+			if _, f := generated[name]; !f {
+				removed = append(removed, name)
+			}
+		}
+		sort.Strings(removed)
+	}
+	return changed, removed
+}
+
+// record stores the versions carried by a response that was sent to the client, keyed by its nonce.
+func (w *deltaWatch) record(nonce string, resources []*discovery.Resource, removed []string) {
+	versions := make(map[string]string, len(resources)+len(removed))
+	for _, r := range resources {
+		w.sent[r.Name] = r.Version
+		versions[r.Name] = r.Version
+	}
+	for _, name := range removed {
+		delete(w.sent, name)
+		versions[name] = ""
+	}
+	w.pending[nonce] = versions
+	w.forceFull = false
+}
+
+// forget drops the sent version of resources, so they are sent again on the next push even if
+// they did not change. Envoy needs the endpoints of a cluster again after the cluster is updated.
+func (w *deltaWatch) forget(names []string) {
+	for _, name := range names {
+		delete(w.sent, name)
+	}
+}
+
+// unchangedSource returns true if resources is the same config cache entry the last diff was computed
+// from. Cache entries are never modified, and are replaced when a config change may affect them.
+func (w *deltaWatch) unchangedSource(resources []*any.Any) bool {
+	if w.forceFull || len(resources) == 0 || len(w.source) != len(resources) {
+		return false
+	}
+	return &w.source[0] == &resources[0]
+}
+
+// resourceVersion returns a version for a marshalled resource, used to detect changes between pushes.
+func resourceVersion(a *any.Any) string {
+	h := fnv.New64a()
+	_, _ = h.Write(a.Value)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// deltaResource wraps a marshalled resource for a delta response. The resource must already have its
+// type URL set: resources read from the config cache are shared with other connections.
+func deltaResource(name string, a *any.Any) *discovery.Resource {
+	return &discovery.Resource{
+		Name:     name,
+		Version:  resourceVersion(a),
+		Resource: a,
+	}
+}
+
+// generatedResourceName returns the name of a resource built by a custom generator. Resources without
+// a name are identified by their content, so a change is sent as a new resource replacing the old one.
+func generatedResourceName(a *any.Any) string {
+	var msg ptypes.DynamicAny
+	if err := ptypes.UnmarshalAny(a, &msg); err == nil {
+		switch m := msg.Message.(type) {
+		case interface{ GetClusterName() string }:
+			return m.GetClusterName()
+		case interface{ GetName() string }:
+			return m.GetName()
+		}
+	}
+	return resourceVersion(a)
+}
+
+// deltaScope narrows a delta push to the resources that may have changed.
+type deltaScope struct {
+	// edsUpdatedServices limits EDS generation to the endpoints of these services.
+	edsUpdatedServices map[string]struct{}
+
+	// updatedHosts limits CDS and EDS to the clusters of these hosts. It is only set for pushes triggered
+	// by service updates alone, which can't change the clusters of other services unless the proxy changed.
+	updatedHosts map[string]struct{}
+
+	// updates are the configs updated, passed to custom generators.
+	updates model.XdsUpdates
+}
+
+// updatedHostsOf returns the hosts of the updated services, or nil if other configs were updated too.
+func updatedHostsOf(configsUpdated map[model.ConfigKey]struct{}) map[string]struct{} {
+	if len(configsUpdated) == 0 {
+		return nil
+	}
+	for config := range configsUpdated {
+		if config.Kind != model.ServiceEntryKind {
+			return nil
+		}
+	}
+	return model.ConfigNamesOfKind(configsUpdated, model.ServiceEntryKind)
+}
+
+// clusterInScope returns a filter accepting the clusters of the hosts.
+func clusterInScope(hosts map[string]struct{}) func(name string) bool {
+	return func(name string) bool {
+		_, _, hostname, _ := model.ParseSubsetKey(name)
+		_, f := hosts[string(hostname)]
+		return f
+	}
+}
+
+func newDeltaXdsConnection(peerAddr string, stream DeltaDiscoveryStream) *XdsConnection {
+	con := newXdsConnection(peerAddr, nil)
+	con.deltaStream = stream
+	con.deltaWatches = map[string]*deltaWatch{}
+	return con
+}
+
+func deltaReceiveThread(con *XdsConnection, reqChannel chan *discovery.DeltaDiscoveryRequest, errP *error) {
+	defer close(reqChannel) // indicates close of the remote side.
+	for {
+		req, err := con.deltaStream.Recv()
+		if err != nil {
+			if isExpectedGRPCError(err) {
+				con.mu.RLock()
+				adsLog.Infof("ADS:DELTA: %q %s terminated %v", con.PeerAddr, con.ConID, err)
+				con.mu.RUnlock()
+				return
+			}
+			*errP = err
+			adsLog.Errorf("ADS:DELTA: %q %s terminated with error: %v", con.PeerAddr, con.ConID, err)
+			totalXDSInternalErrors.Increment()
+			return
+		}
+		select {
+		case reqChannel <- req:
+		case <-con.deltaStream.Context().Done():
+			adsLog.Infof("ADS:DELTA: %q %s terminated with stream closed", con.PeerAddr, con.ConID)
+			return
+		}
+	}
+}
+
+// DeltaAggregatedResources implements the incremental variant of ADS. Clients subscribe and unsubscribe to
+// resources by name, and responses only carry the resources that changed since the client last received them,
+// plus the names of removed resources.
+//
+// Resources are still built by the ConfigGenerator, or the custom generator of the proxy. With the config cache
+// enabled, clusters and routes are only regenerated when ConfigsUpdated may affect them, and unchanged cache
+// entries are not compared again. Pushes triggered by service updates alone only compare and send the clusters
+// and endpoints of the updated services.
+func (s *DiscoveryServer) DeltaAggregatedResources(stream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	ctx := stream.Context()
+	peerInfo, ok := peer.FromContext(ctx)
+	peerAddr := "0.0.0.0"
+	if ok {
+		peerAddr = peerInfo.Addr.String()
+	}
+
+	ids, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	if ids != nil {
+		adsLog.Infof("Authenticated delta XDS: %v with identity %v", peerAddr, ids)
+	} else {
+		adsLog.Infoa("Unauthenticated delta XDS: ", peerAddr)
+	}
+
+	err = s.globalPushContext().InitContext(s.Env, nil, nil)
+	if err != nil {
+		adsLog.Warnf("Error reading config %v", err)
+		return err
+	}
+	con := newDeltaXdsConnection(peerAddr, stream)
+
+	var receiveError error
+	reqChannel := make(chan *discovery.DeltaDiscoveryRequest, 1)
+	go deltaReceiveThread(con, reqChannel, &receiveError)
+
+	for {
+		// Block until either a request is received or a push is triggered.
+		select {
+		case req, ok := <-reqChannel:
+			if !ok {
+				// Remote side closed connection.
+				return receiveError
+			}
+			// This should be only set for the first request. The node id may not be set - for example malicious clients.
+			if con.node == nil {
+				if req.Node == nil {
+					return errors.New("missing node ID")
+				}
+				if err := s.initConnection(req.Node, con); err != nil {
+					return err
+				}
+				defer func() {
+					s.removeCon(con.ConID)
+					if s.InternalGen != nil {
+						s.InternalGen.OnDisconnect(con)
+					}
+				}()
+			}
+			if s.StatusReporter != nil {
+				s.StatusReporter.RegisterEvent(con.ConID, TypeURLToEventType(req.TypeUrl), req.ResponseNonce)
+			}
+			if err := s.handleDeltaRequest(con, req); err != nil {
+				return err
+			}
+
+		case pushEv := <-con.pushChannel:
+			err := s.pushConnection(con, pushEv)
+			pushEv.done()
+			if err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func (s *DiscoveryServer) handleDeltaRequest(con *XdsConnection, req *discovery.DeltaDiscoveryRequest) error {
+	var reject monitoring.Metric
+	// Types served by a custom generator are opaque, so they are wildcard unless names are subscribed.
+	wildcard := con.node.XdsResourceGenerator != nil
+	if con.node.XdsResourceGenerator == nil {
+		var requestedType *string
+		switch req.TypeUrl {
+		case v3.ClusterType:
+			requestedType, reject, wildcard = &con.node.RequestedTypes.CDS, cdsReject, true
+		case v3.ListenerType:
+			requestedType, reject, wildcard = &con.node.RequestedTypes.LDS, ldsReject, true
+		case v3.RouteType:
+			requestedType, reject = &con.node.RequestedTypes.RDS, rdsReject
+		case v3.EndpointType:
+			requestedType, reject = &con.node.RequestedTypes.EDS, edsReject
+		default:
+			adsLog.Warnf("ADS:DELTA: Unknown watched resources %s", req.String())
+			return nil
+		}
+		if err := s.handleTypeURL(req.TypeUrl, requestedType); err != nil {
+			return err
+		}
+	}
+
+	w, existing := con.deltaWatches[req.TypeUrl]
+	if !existing {
+		// Wildcard subscription is implied for CDS/LDS when the first request names no resources.
+		w = newDeltaWatch(req.TypeUrl, wildcard && len(req.ResourceNamesSubscribe) == 0)
+		// On reconnect the client tells us what it already has, so unchanged resources are not resent.
+		for name, version := range req.InitialResourceVersions {
+			w.sent[name] = version
+			w.acked[name] = version
+		}
+		con.deltaWatches[req.TypeUrl] = w
+	}
+
+	if req.ErrorDetail != nil {
+		errCode := codes.Code(req.ErrorDetail.Code)
+		adsLog.Warnf("ADS:DELTA: ACK ERROR %s %s %s:%s", req.TypeUrl, con.ConID, errCode.String(), req.ErrorDetail.GetMessage())
+		if reject != nil {
+			incrementXDSRejects(reject, con.node.ID, errCode.String())
+		} else {
+			totalXDSRejects.Increment()
+		}
+		if s.InternalGen != nil {
+			s.InternalGen.OnNack(con.node, &discovery.DiscoveryRequest{
+				TypeUrl:       req.TypeUrl,
+				ResponseNonce: req.ResponseNonce,
+				ErrorDetail:   req.ErrorDetail,
+			})
+		}
+		w.nack(req.ResponseNonce)
+		// Proxies with a rejected config are pushed first, see pushPriorityOf.
+		con.recordAck(req.TypeUrl, true)
+		return nil
+	}
+	if req.ResponseNonce != "" {
+		adsLog.Debugf("ADS:DELTA: ACK %s %s %s", req.TypeUrl, con.ConID, req.ResponseNonce)
+		w.ack(req.ResponseNonce)
+		con.recordAck(req.TypeUrl, false)
+	}
+
+	added := w.subscribe(req.ResourceNamesSubscribe, req.ResourceNamesUnsubscribe)
+	if existing && !added {
+		return nil
+	}
+
+	adsLog.Debugf("ADS:DELTA: REQ %s %s subscribed:%d", req.TypeUrl, con.ConID, len(w.subscribed))
+	if req.TypeUrl == v3.EndpointType {
+		con.Clusters = w.names()
+	} else if req.TypeUrl == v3.RouteType {
+		con.Routes = w.names()
+	}
+	_, err := s.pushDelta(con, s.globalPushContext(), w, deltaScope{})
+	return err
+}
+
+// pushDeltaConnection is the delta counterpart of pushConnection.
+func (s *DiscoveryServer) pushDeltaConnection(con *XdsConnection, pushEv *XdsEvent) error {
+	if !pushEv.full {
+		if !ProxyNeedsPush(con.node, pushEv) {
+			adsLog.Debugf("Skipping delta EDS push to %v, no updates required", con.ConID)
+			return nil
+		}
+		edsUpdatedServices := model.ConfigNamesOfKind(pushEv.configsUpdated, model.ServiceEntryKind)
+		w := con.deltaWatches[v3.EndpointType]
+		if w != nil && con.node.XdsResourceGenerator == nil && len(edsUpdatedServices) > 0 {
+			_, err := s.pushDelta(con, pushEv.push, w, deltaScope{edsUpdatedServices: edsUpdatedServices})
+			return err
+		}
+		return nil
+	}
+
+	// Update Proxy with current information.
+	if err := s.updateProxy(con.node, pushEv.push); err != nil {
+		return nil
+	}
+	if !ProxyNeedsPush(con.node, pushEv) {
+		adsLog.Debugf("Skipping delta push to %v, no updates required", con.ConID)
+		if s.StatusReporter != nil {
+			// This version of the config will never be distributed to the proxy because it is not a relevant diff.
+			for _, distributionType := range AllEventTypes {
+				s.StatusReporter.RegisterEvent(con.ConID, distributionType, pushEv.noncePrefix)
+			}
+		}
+		return nil
+	}
+
+	adsLog.Infof("Pushing delta %v", con.ConID)
+	if con.node.XdsResourceGenerator != nil {
+		typeURLs := make([]string, 0, len(con.deltaWatches))
+		for typeURL := range con.deltaWatches {
+			typeURLs = append(typeURLs, typeURL)
+		}
+		sort.Strings(typeURLs)
+		for _, typeURL := range typeURLs {
+			if _, err := s.pushDelta(con, pushEv.push, con.deltaWatches[typeURL], deltaScope{updates: pushEv.configsUpdated}); err != nil {
+				return err
+			}
+		}
+		proxiesConvergeDelay.Record(time.Since(pushEv.start).Seconds())
+		return nil
+	}
+
+	pushTypes := PushTypeFor(con.node, pushEv)
+	scope := deltaScope{}
+	// The config cache key identifies everything about the proxy that is used to build its clusters.
+	key, ok := configCacheKeyFor(con.node, CDS, v3.ClusterType, nil)
+	if hosts := updatedHostsOf(pushEv.configsUpdated); hosts != nil && ok && key == con.deltaProxyKey {
+		scope.updatedHosts = hosts
+	}
+	con.deltaProxyKey = key
+	var changedClusters []string
+	// The order matches the state of the world push: clusters before endpoints, listeners before routes.
+	for _, t := range []struct {
+		typeURL string
+		xdsType XdsType
+	}{{v3.ClusterType, CDS}, {v3.EndpointType, EDS}, {v3.ListenerType, LDS}, {v3.RouteType, RDS}} {
+		w := con.deltaWatches[t.typeURL]
+		sent := false
+		if w != nil && pushTypes[t.xdsType] {
+			if t.typeURL == v3.EndpointType {
+				w.forget(changedClusters)
+			}
+			changed, err := s.pushDelta(con, pushEv.push, w, scope)
+			if err != nil {
+				return err
+			}
+			sent = changed != nil
+			if t.typeURL == v3.ClusterType {
+				changedClusters = changed
+			}
+		}
+		if !sent && s.StatusReporter != nil {
+			// The proxy already has this version of the resources.
+			s.StatusReporter.RegisterEvent(con.ConID, TypeURLToEventType(t.typeURL), pushEv.noncePrefix)
+		}
+	}
+	proxiesConvergeDelay.Record(time.Since(pushEv.start).Seconds())
+	return nil
+}
+
+// pushDelta generates the resources for a watched type and sends the ones that changed for the client.
+// It returns the names of the resources sent, or nil if nothing was sent.
+func (s *DiscoveryServer) pushDelta(con *XdsConnection, push *model.PushContext, w *deltaWatch, scope deltaScope) ([]string, error) {
+	pushStart := time.Now()
+	var resources []*discovery.Resource
+	var timeMetric, pushMetric, errMetric monitoring.Metric
+	var xdsType string
+	var historyType XdsType
+	recordHistory := false
+	// unchanged is set when the resources were read from the same config cache entry as the last push.
+	unchanged := false
+	complete := true
+	var inScope func(name string) bool
+
+	switch {
+	case con.node.XdsResourceGenerator != nil:
+		xdsType, pushMetric, errMetric = "ADS", apiPushes, apiSendErrPushes
+		// Endpoints are never removed: a cluster that disappears is removed through CDS.
+		complete = w.typeURL != EndpointType && w.typeURL != v3.EndpointType
+		g := con.node.XdsResourceGenerator
+		if cg, f := s.Generators[con.node.Metadata.Generator+"/"+w.typeURL]; f {
+			g = cg
+		}
+		watched := &model.WatchedResource{TypeUrl: w.typeURL}
+		if !w.wildcard {
+			watched.ResourceNames = w.names()
+		}
+		for _, a := range g.Generate(con.node, push, watched, scope.updates) {
+			resources = append(resources, deltaResource(generatedResourceName(a), a))
+		}
+	case w.typeURL == v3.ClusterType:
+		xdsType, timeMetric, pushMetric, errMetric = "CDS", cdsPushTime, cdsPushes, cdsSendErrPushes
+		historyType, recordHistory = CDS, true
+		key, cacheable := s.configCacheKey(con, CDS, w.typeURL, nil)
+		rawClusters, marshalled := s.buildClustersForKey(con, push, key, cacheable)
+		if s.DebugConfigs {
+			con.CDSClusters = rawClusters
+		}
+		unchanged = cacheable && w.unchangedSource(marshalled)
+		if unchanged && !s.pushHistory.enabled() {
+			adsLog.Debugf("CDS: DELTA PUSH for node:%s skipped, clusters not regenerated", con.node.ID)
+			return nil, nil
+		}
+		if scope.updatedHosts != nil && !w.forceFull {
+			inScope = clusterInScope(scope.updatedHosts)
+		}
+		w.source = nil
+		if cacheable {
+			w.source = marshalled
+		}
+		for i, c := range rawClusters {
+			if inScope == nil || inScope(c.Name) {
+				resources = append(resources, deltaResource(c.Name, marshalled[i]))
+			}
+		}
+	case w.typeURL == v3.ListenerType:
+		xdsType, timeMetric, pushMetric, errMetric = "LDS", ldsPushTime, ldsPushes, ldsSendErrPushes
+		historyType, recordHistory = LDS, true
+		rawListeners := s.ConfigGenerator.BuildListeners(con.node, push)
+		if s.DebugConfigs {
+			con.LDSListeners = rawListeners
+		}
+		for _, l := range rawListeners {
+			if l == nil {
+				continue
+			}
+			a := util.MessageToAny(l)
+			a.TypeUrl = w.typeURL
+			resources = append(resources, deltaResource(l.Name, a))
+		}
+	case w.typeURL == v3.RouteType:
+		xdsType, timeMetric, pushMetric, errMetric = "RDS", rdsPushTime, rdsPushes, rdsSendErrPushes
+		historyType, recordHistory = RDS, true
+		key, cacheable := s.configCacheKey(con, RDS, w.typeURL, con.Routes)
+		rawRoutes, marshalled := s.buildRoutesForKey(con, push, key, cacheable)
+		if s.DebugConfigs {
+			for _, r := range rawRoutes {
+				con.RouteConfigs[r.Name] = r
+			}
+		}
+		unchanged = cacheable && w.unchangedSource(marshalled)
+		if unchanged && !s.pushHistory.enabled() {
+			adsLog.Debugf("RDS: DELTA PUSH for node:%s skipped, routes not regenerated", con.node.ID)
+			return nil, nil
+		}
+		w.source = nil
+		if cacheable {
+			w.source = marshalled
+		}
+		for i, r := range rawRoutes {
+			resources = append(resources, deltaResource(r.Name, marshalled[i]))
+		}
+	case w.typeURL == v3.EndpointType:
+		xdsType, timeMetric, pushMetric, errMetric = "EDS", edsPushTime, edsPushes, edsSendErrPushes
+		// Endpoints are never removed: a cluster that disappears is removed through CDS.
+		complete = false
+		edsUpdatedServices := scope.edsUpdatedServices
+		if edsUpdatedServices == nil && scope.updatedHosts != nil && !w.forceFull {
+			edsUpdatedServices = scope.updatedHosts
+		}
+		for _, clusterName := range w.names() {
+			updated := edsUpdatedServices
+			if _, f := w.sent[clusterName]; !f {
+				// Endpoints that must be sent again, see forget, are generated even if the service was not updated.
+				updated = nil
+			}
+			l := s.generateEndpoints(clusterName, con.node, push, updated)
+			if l == nil {
+				continue
+			}
+			a := util.MessageToAny(l)
+			a.TypeUrl = w.typeURL
+			resources = append(resources, deltaResource(clusterName, a))
+		}
+	}
+	if recordHistory && s.pushHistory.enabled() {
+		hashes := make(map[string]string, len(resources))
+		for _, r := range resources {
+			hashes[r.Name] = r.Version
+		}
+		s.pushHistory.recordResources(push.PushVersion, con.node.Type, historyType, hashes)
+	}
+	if unchanged {
+		adsLog.Debugf("%s: DELTA PUSH for node:%s skipped, resources not regenerated", xdsType, con.node.ID)
+		return nil, nil
+	}
+
+	changed, removed := w.diff(resources, complete, inScope)
+	if len(changed) == 0 && len(removed) == 0 {
+		adsLog.Debugf("%s: DELTA PUSH for node:%s skipped, no changes", xdsType, con.node.ID)
+		return nil, nil
+	}
+
+	response := &discovery.DeltaDiscoveryResponse{
+		TypeUrl:           w.typeURL,
+		SystemVersionInfo: versionInfo(),
+		Resources:         changed,
+		RemovedResources:  removed,
+		Nonce:             nonce(push.Version),
+	}
+	err := con.sendDelta(response)
+	if timeMetric != nil {
+		timeMetric.Record(time.Since(pushStart).Seconds())
+	}
+	if err != nil {
+		recordSendError(xdsType, con.ConID, errMetric, err)
+		return nil, err
+	}
+	w.record(response.Nonce, changed, removed)
+	pushMetric.Increment()
+
+	adsLog.Infof("%s: DELTA PUSH %s for node:%s resources:%d changed:%d removed:%d",
+		xdsType, w.typeURL, con.node.ID, len(resources), len(changed), len(removed))
+	sent := make([]string, 0, len(changed)+len(removed))
+	for _, r := range changed {
+		sent = append(sent, r.Name)
+	}
+	return append(sent, removed...), nil
+}
+
+// sendDelta sends a delta response with timeout.
+func (conn *XdsConnection) sendDelta(res *discovery.DeltaDiscoveryResponse) error {
+	done := make(chan error, 1)
+	t := time.NewTimer(SendTimeout)
+	go func() {
+		err := conn.deltaStream.Send(res)
+		conn.mu.Lock()
+		switch res.TypeUrl {
+		case v3.ClusterType:
+			conn.ClusterNonceSent = res.Nonce
+		case v3.ListenerType:
+			conn.ListenerNonceSent = res.Nonce
+		case v3.RouteType:
+			conn.RouteNonceSent = res.Nonce
+		case v3.EndpointType:
+			conn.EndpointNonceSent = res.Nonce
+		}
+		conn.mu.Unlock()
+		done <- err
+	}()
+	select {
+	case <-t.C:
+		adsLog.Infof("Timeout writing %s", conn.ConID)
+		xdsResponseWriteTimeouts.Increment()
+		return errors.New("timeout sending")
+	case err := <-done:
+		t.Stop()
+		return err
+	}
+}
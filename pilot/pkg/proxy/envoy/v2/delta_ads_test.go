@@ -0,0 +1,217 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	v3 "istio.io/istio/pilot/pkg/proxy/envoy/v3"
+	"istio.io/istio/pilot/pkg/proxy/envoy/xds"
+)
+
+const (
+	deltaClusterA = "outbound|80||a.default.svc.cluster.local"
+	deltaClusterB = "outbound|80||b.default.svc.cluster.local"
+)
+
+type deltaClient struct {
+	t         *testing.T
+	stream    discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesClient
+	responses chan *discovery.DeltaDiscoveryResponse
+	cancel    context.CancelFunc
+}
+
+func connectDelta(t *testing.T, addr string) *deltaClient {
+	t.Helper()
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := discovery.NewAggregatedDiscoveryServiceClient(conn).DeltaAggregatedResources(ctx)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+	c := &deltaClient{t: t, stream: stream, responses: make(chan *discovery.DeltaDiscoveryResponse, 10), cancel: func() {
+		cancel()
+		_ = conn.Close()
+	}}
+	go func() {
+		defer close(c.responses)
+		for {
+			res, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			c.responses <- res
+		}
+	}()
+	return c
+}
+
+func (c *deltaClient) send(req *discovery.DeltaDiscoveryRequest) {
+	c.t.Helper()
+	req.Node = &corev3.Node{Id: sidecarID("10.10.10.10", "app3"), Metadata: nodeMetadata}
+	if err := c.stream.Send(req); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *deltaClient) recv(typeURL string) *discovery.DeltaDiscoveryResponse {
+	c.t.Helper()
+	select {
+	case res, ok := <-c.responses:
+		if !ok {
+			c.t.Fatalf("stream closed waiting for a %s response", typeURL)
+		}
+		if res.TypeUrl != typeURL {
+			c.t.Fatalf("expected a %s response, got %s", typeURL, res.TypeUrl)
+		}
+		return res
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timeout waiting for a %s response", typeURL)
+	}
+	return nil
+}
+
+// expectNone fails if a response is received within a short delay.
+func (c *deltaClient) expectNone() {
+	c.t.Helper()
+	select {
+	case res, ok := <-c.responses:
+		if ok {
+			c.t.Fatalf("unexpected %s response: %v", res.TypeUrl, resourceNames(res))
+		}
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func resourceNames(res *discovery.DeltaDiscoveryResponse) map[string]string {
+	out := map[string]string{}
+	for _, r := range res.Resources {
+		out[r.Name] = r.Version
+	}
+	return out
+}
+
+func TestDeltaAggregatedResources(t *testing.T) {
+	ds := xds.NewXDS()
+	sd := ds.DiscoveryServer.MemRegistry
+	sd.AddHTTPService("a.default.svc.cluster.local", "10.0.0.1", 80)
+	sd.AddHTTPService("b.default.svc.cluster.local", "10.0.0.2", 80)
+	sd.SetEndpoints("a.default.svc.cluster.local", "default", []*model.IstioEndpoint{
+		{Address: "10.1.0.1", EndpointPort: 8080, ServicePortName: "http-main"},
+	})
+
+	env := ds.DiscoveryServer.Env
+	if err := env.PushContext.InitContext(env, env.PushContext, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ds.DiscoveryServer.UpdateServiceShards(env.PushContext); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	ds.DiscoveryServer.Start(stop)
+	if err := ds.StartGRPC("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer ds.GRPCListener.Close()
+	addr := ds.GRPCListener.Addr().String()
+
+	c := connectDelta(t, addr)
+
+	// Subscribe: CDS is wildcard, EDS is for named clusters.
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType})
+	cds := c.recv(v3.ClusterType)
+	clusters := resourceNames(cds)
+	for _, name := range []string{deltaClusterA, deltaClusterB, util.BlackHoleCluster} {
+		if _, f := clusters[name]; !f {
+			t.Fatalf("missing cluster %s in %v", name, clusters)
+		}
+	}
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: cds.Nonce})
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResourceNamesSubscribe: []string{deltaClusterA}})
+	eds := c.recv(v3.EndpointType)
+	if got := resourceNames(eds); len(got) != 1 || got[deltaClusterA] == "" {
+		t.Fatalf("expected endpoints of %s, got %v", deltaClusterA, got)
+	}
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResponseNonce: eds.Nonce})
+
+	// A push without changes sends nothing.
+	ds.DiscoveryServer.Push(&model.PushRequest{Full: true})
+	c.expectNone()
+
+	// After a NACK, the next push sends every cluster again, and the endpoints of the clusters sent.
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: cds.Nonce,
+		ErrorDetail: &status.Status{Message: "rejected"}})
+	c.expectNone()
+	ds.DiscoveryServer.Push(&model.PushRequest{Full: true})
+	cds = c.recv(v3.ClusterType)
+	if got := resourceNames(cds); !reflect.DeepEqual(got, clusters) {
+		t.Fatalf("expected all clusters after a NACK, got %v want %v", got, clusters)
+	}
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: cds.Nonce})
+	eds = c.recv(v3.EndpointType)
+	if got := resourceNames(eds); len(got) != 1 || got[deltaClusterA] == "" {
+		t.Fatalf("expected endpoints of %s, got %v", deltaClusterA, got)
+	}
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.EndpointType, ResponseNonce: eds.Nonce})
+
+	// Removing a service removes its cluster, and only its cluster.
+	sd.RemoveService("b.default.svc.cluster.local")
+	ds.DiscoveryServer.Push(&model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{
+		{Kind: model.ServiceEntryKind, Name: "b.default.svc.cluster.local", Namespace: "default"}: {},
+	}})
+	cds = c.recv(v3.ClusterType)
+	if len(cds.Resources) != 0 || !reflect.DeepEqual(cds.RemovedResources, []string{deltaClusterB}) {
+		t.Fatalf("expected %s to be removed, got resources %v removed %v", deltaClusterB, resourceNames(cds), cds.RemovedResources)
+	}
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType, ResponseNonce: cds.Nonce})
+	c.expectNone()
+	c.cancel()
+
+	// On reconnect, only resources the client doesn't have at the current version are sent, and the ones
+	// that no longer exist are removed.
+	c = connectDelta(t, addr)
+	defer c.cancel()
+	c.send(&discovery.DeltaDiscoveryRequest{TypeUrl: v3.ClusterType, InitialResourceVersions: map[string]string{
+		deltaClusterA:         clusters[deltaClusterA],
+		deltaClusterB:         clusters[deltaClusterB],
+		util.BlackHoleCluster: "stale",
+	}})
+	cds = c.recv(v3.ClusterType)
+	got := resourceNames(cds)
+	if _, f := got[deltaClusterA]; f {
+		t.Fatalf("unchanged cluster %s sent again", deltaClusterA)
+	}
+	if got[util.BlackHoleCluster] != clusters[util.BlackHoleCluster] {
+		t.Fatalf("stale cluster %s not sent, got %v", util.BlackHoleCluster, got)
+	}
+	if !reflect.DeepEqual(cds.RemovedResources, []string{deltaClusterB}) {
+		t.Fatalf("expected %s to be removed, got %v", deltaClusterB, cds.RemovedResources)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"reflect"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+
+	v3 "istio.io/istio/pilot/pkg/proxy/envoy/v3"
+)
+
+func deltaResources(versions map[string]string) []*discovery.Resource {
+	out := []*discovery.Resource{}
+	for name, version := range versions {
+		out = append(out, &discovery.Resource{Name: name, Version: version})
+	}
+	return out
+}
+
+func resourceNames(resources []*discovery.Resource) map[string]struct{} {
+	out := map[string]struct{}{}
+	for _, r := range resources {
+		out[r.Name] = struct{}{}
+	}
+	return out
+}
+
+func expectNames(t *testing.T, got []*discovery.Resource, want ...string) {
+	t.Helper()
+	wantSet := map[string]struct{}{}
+	for _, w := range want {
+		wantSet[w] = struct{}{}
+	}
+	if !reflect.DeepEqual(resourceNames(got), wantSet) {
+		t.Fatalf("expected resources %v, got %v", want, resourceNames(got))
+	}
+}
+
+func TestDeltaWatchWildcard(t *testing.T) {
+	w := newDeltaWatch(v3.ClusterType, true)
+
+	changed, removed := w.diff(deltaResources(map[string]string{"a": "1", "b": "1"}), true, nil)
+	expectNames(t, changed, "a", "b")
+	if len(removed) != 0 {
+		t.Fatalf("unexpected removals %v", removed)
+	}
+	w.record("n1", changed, removed)
+	w.ack("n1")
+
+	// Only the modified resource and the deleted one are sent.
+	changed, removed = w.diff(deltaResources(map[string]string{"a": "2", "c": "1"}), true, nil)
+	expectNames(t, changed, "a", "c")
+	if !reflect.DeepEqual(removed, []string{"b"}) {
+		t.Fatalf("expected b to be removed, got %v", removed)
+	}
+	w.record("n2", changed, removed)
+	w.ack("n2")
+	if !reflect.DeepEqual(w.acked, map[string]string{"a": "2", "c": "1"}) {
+		t.Fatalf("unexpected acked state %v", w.acked)
+	}
+
+	// Nothing changed, nothing to send.
+	changed, removed = w.diff(deltaResources(map[string]string{"a": "2", "c": "1"}), true, nil)
+	if len(changed) != 0 || len(removed) != 0 {
+		t.Fatalf("expected no changes, got %v %v", changed, removed)
+	}
+}
+
+func TestDeltaWatchNackFallsBackToFull(t *testing.T) {
+	w := newDeltaWatch(v3.ClusterType, true)
+	changed, removed := w.diff(deltaResources(map[string]string{"a": "1", "b": "1"}), true, nil)
+	w.record("n1", changed, removed)
+	w.ack("n1")
+
+	changed, removed = w.diff(deltaResources(map[string]string{"a": "2", "b": "1"}), true, nil)
+	expectNames(t, changed, "a")
+	w.record("n2", changed, removed)
+	w.nack("n2")
+
+	if !reflect.DeepEqual(w.sent, map[string]string{"a": "1", "b": "1"}) {
+		t.Fatalf("expected sent state to be reset to acked, got %v", w.sent)
+	}
+	changed, _ = w.diff(deltaResources(map[string]string{"a": "2", "b": "1"}), true, nil)
+	expectNames(t, changed, "a", "b")
+	w.record("n3", changed, nil)
+	if w.forceFull {
+		t.Fatalf("expected full push fallback to be cleared after a send")
+	}
+}
+
+func TestDeltaWatchSubscriptions(t *testing.T) {
+	w := newDeltaWatch(v3.EndpointType, false)
+	if !w.subscribe([]string{"outbound|80||a.default"}, nil) {
+		t.Fatalf("expected new subscription")
+	}
+	if w.subscribe([]string{"outbound|80||a.default"}, nil) {
+		t.Fatalf("expected repeated subscription to be ignored")
+	}
+	w.subscribe([]string{"outbound|80||b.default"}, nil)
+
+	resources := deltaResources(map[string]string{"outbound|80||a.default": "1", "outbound|80||b.default": "1"})
+	changed, removed := w.diff(resources, false, nil)
+	expectNames(t, changed, "outbound|80||a.default", "outbound|80||b.default")
+	w.record("n1", changed, removed)
+
+	w.subscribe(nil, []string{"outbound|80||b.default"})
+	if !reflect.DeepEqual(w.names(), []string{"outbound|80||a.default"}) {
+		t.Fatalf("unexpected subscriptions %v", w.names())
+	}
+	if _, f := w.sent["outbound|80||b.default"]; f {
+		t.Fatalf("expected unsubscribed resource to be forgotten")
+	}
+	changed, removed = w.diff(resources, false, nil)
+	if len(changed) != 0 || len(removed) != 0 {
+		t.Fatalf("expected no changes, got %v %v", changed, removed)
+	}
+}
+
+func TestDeltaWatchScopedDiff(t *testing.T) {
+	w := newDeltaWatch(v3.ClusterType, true)
+	a, b := "outbound|80||a.default.svc.cluster.local", "outbound|80||b.default.svc.cluster.local"
+	changed, removed := w.diff(deltaResources(map[string]string{a: "1", b: "1", "BlackHoleCluster": "1"}), true, nil)
+	w.record("n1", changed, removed)
+
+	// Only the clusters of a are generated: b and the BlackHoleCluster are not removed.
+	inScope := clusterInScope(map[string]struct{}{"a.default.svc.cluster.local": {}})
+	changed, removed = w.diff(deltaResources(map[string]string{a: "2"}), true, inScope)
+	expectNames(t, changed, a)
+	if len(removed) != 0 {
+		t.Fatalf("unexpected removals %v", removed)
+	}
+	w.record("n2", changed, removed)
+
+	// a is deleted.
+	changed, removed = w.diff(deltaResources(map[string]string{}), true, inScope)
+	if len(changed) != 0 || !reflect.DeepEqual(removed, []string{a}) {
+		t.Fatalf("expected a to be removed, got %v %v", changed, removed)
+	}
+}
+
+func TestDeltaWatchForget(t *testing.T) {
+	w := newDeltaWatch(v3.EndpointType, false)
+	w.subscribe([]string{"a", "b"}, nil)
+	resources := deltaResources(map[string]string{"a": "1", "b": "1"})
+	changed, removed := w.diff(resources, false, nil)
+	w.record("n1", changed, removed)
+
+	// The cluster a was updated, its unchanged endpoints are sent again.
+	w.forget([]string{"a"})
+	changed, _ = w.diff(resources, false, nil)
+	expectNames(t, changed, "a")
+}
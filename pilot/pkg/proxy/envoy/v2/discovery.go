@@ -124,6 +124,13 @@ type DiscoveryServer struct {
 	Authenticators []authenticate.Authenticator
 
 	InternalGen *InternalGen
+
+	// pushHistory keeps the recent full pushes, and the resources they sent, for /debug/pushz.
+	pushHistory *pushHistory
+
+	// configCache stores the CDS and RDS resources shared by proxies with the same scope. It is nil
+	// unless PILOT_ENABLE_CONFIG_CACHE is set.
+	configCache *configCache
 }
 
 // EndpointShards holds the set of endpoint shards of a service. Registries update
@@ -159,6 +166,11 @@ func NewDiscoveryServer(env *model.Environment, plugins []string) *DiscoveryServ
 		DebugConfigs:            features.DebugConfigs,
 		debugHandlers:           map[string]string{},
 		adsClients:              map[string]*XdsConnection{},
+		pushHistory:             newPushHistory(features.PushHistorySize),
+	}
+
+	if features.EnableConfigCache {
+		out.configCache = newConfigCache()
 	}
 
 	if features.XDSAuth {
@@ -270,15 +282,24 @@ func (s *DiscoveryServer) Push(req *model.PushRequest) {
 		return
 	}
 
-	s.updateMutex.Lock()
-	s.Env.PushContext = push
-	s.updateMutex.Unlock()
-
 	versionLocal := time.Now().Format(time.RFC3339) + "/" + strconv.FormatUint(versionNum.Load(), 10)
 	versionNum.Inc()
 	initContextTime := time.Since(t0)
 	adsLog.Debugf("InitContext %v for push took %s", versionLocal, initContextTime)
 
+	// The version is assigned before the context is visible, so proxies connecting during the push
+	// are recorded under the push they actually receive.
+	push.PushVersion = versionLocal
+	s.pushHistory.startPush(versionLocal, req)
+
+	s.updateMutex.Lock()
+	s.Env.PushContext = push
+	s.updateMutex.Unlock()
+
+	if s.configCache != nil {
+		s.configCache.update(push, req.ConfigsUpdated)
+	}
+
 	versionMutex.Lock()
 	version = versionLocal
 	versionMutex.Unlock()
@@ -429,7 +450,7 @@ func doSendPushes(stopCh <-chan struct{}, semaphore chan struct{}, queue *PushQu
 				select {
 				case client.pushChannel <- pushEv:
 					return
-				case <-client.stream.Context().Done(): // grpc stream was closed
+				case <-client.context().Done(): // grpc stream was closed
 					doneFunc()
 					adsLog.Infof("Client closed connection %v", client.ConID)
 				}
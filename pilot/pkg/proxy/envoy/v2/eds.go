@@ -19,6 +19,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/golang/protobuf/ptypes/any"
@@ -26,6 +27,7 @@ import (
 
 	networkingapi "istio.io/api/networking/v1alpha3"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	networking "istio.io/istio/pilot/pkg/networking/core/v1alpha3"
 	"istio.io/istio/pilot/pkg/networking/core/v1alpha3/loadbalancer"
@@ -83,6 +85,7 @@ func buildEnvoyLbEndpoint(e *model.IstioEndpoint, push *model.PushContext) *endp
 				Address: addr,
 			},
 		},
+		HealthStatus: core.HealthStatus(e.HealthStatus),
 	}
 
 	// Istio telemetry depends on the metadata value being set for endpoints in the mesh.
@@ -288,7 +291,7 @@ func connectionID(node string) string {
 // Initial implementation is computing the endpoints on the flight - caching will be added as needed, based on
 // perf tests. The logic to compute is based on the current UpdateClusterInc
 func (s *DiscoveryServer) loadAssignmentsForClusterIsolated(proxy *model.Proxy, push *model.PushContext,
-	clusterName string) *endpoint.ClusterLoadAssignment {
+	clusterName string, failoverLabels []string) *endpoint.ClusterLoadAssignment {
 	_, subsetName, hostname, port := model.ParseSubsetKey(clusterName)
 
 	// TODO: BUG. this code is incorrect if 1.1 isolation is used. With destination rule scoping
@@ -338,7 +341,7 @@ func (s *DiscoveryServer) loadAssignmentsForClusterIsolated(proxy *model.Proxy,
 		return buildEmptyClusterLoadAssignment(clusterName)
 	}
 
-	locEps := buildLocalityLbEndpointsFromShards(proxy, se, svc, svcPort, subsetLabels, clusterName, push)
+	locEps := buildLocalityLbEndpointsFromShards(proxy, se, svc, svcPort, subsetLabels, clusterName, push, failoverLabels)
 
 	return &endpoint.ClusterLoadAssignment{
 		ClusterName: clusterName,
@@ -357,7 +360,13 @@ func (s *DiscoveryServer) generateEndpoints(clusterName string, proxy *model.Pro
 		}
 	}
 
-	l := s.loadAssignmentsForClusterIsolated(proxy, push, clusterName)
+	// If locality aware routing is enabled, prioritize endpoints or set their lb weight.
+	// Failover should only be enabled when there is an outlier detection, otherwise Envoy
+	// will never detect the hosts are unhealthy and redirect traffic.
+	enableFailover, lb, drFailoverLabels := getOutlierDetectionAndLoadBalancerSettings(push, proxy, clusterName)
+	lbSetting := loadbalancer.GetLocalityLbSetting(push.Mesh.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
+
+	l := s.loadAssignmentsForClusterIsolated(proxy, push, clusterName, failoverLabels(proxy, lbSetting, enableFailover, drFailoverLabels))
 	if l == nil {
 		return nil
 	}
@@ -374,11 +383,6 @@ func (s *DiscoveryServer) generateEndpoints(clusterName string, proxy *model.Pro
 		l = filteredCLA
 	}
 
-	// If locality aware routing is enabled, prioritize endpoints or set their lb weight.
-	// Failover should only be enabled when there is an outlier detection, otherwise Envoy
-	// will never detect the hosts are unhealthy and redirect traffic.
-	enableFailover, lb := getOutlierDetectionAndLoadBalancerSettings(push, proxy, clusterName)
-	lbSetting := loadbalancer.GetLocalityLbSetting(push.Mesh.GetLocalityLbSetting(), lb.GetLocalityLbSetting())
 	if lbSetting != nil {
 		// Make a shallow copy of the cla as we are mutating the endpoints with priorities/weights relative to the calling proxy
 		clonedCLA := util.CloneClusterLoadAssignment(l)
@@ -388,6 +392,24 @@ func (s *DiscoveryServer) generateEndpoints(clusterName string, proxy *model.Pro
 	return l
 }
 
+// failoverLabels returns the labels used to prioritize endpoints for the proxy, if locality failover
+// applies to the cluster. The labels of the DestinationRule, if set, override the mesh ones.
+func failoverLabels(proxy *model.Proxy, lbSetting *networkingapi.LocalityLoadBalancerSetting, enableFailover bool,
+	destinationRuleLabels []string) []string {
+	out := features.LocalityFailoverLabels
+	if destinationRuleLabels != nil {
+		out = destinationRuleLabels
+	}
+	if len(out) == 0 || lbSetting == nil || !enableFailover || proxy.Locality == nil {
+		return nil
+	}
+	// Distribute and failover are exclusive, see loadbalancer.ApplyLocalityLBSetting.
+	if lbSetting.GetDistribute() != nil || (lbSetting.Enabled != nil && !lbSetting.Enabled.Value) {
+		return nil
+	}
+	return out
+}
+
 // EdsGenerator implements the new Generate method for EDS, using the in-memory, optimized endpoint
 // storage in DiscoveryServer.
 type EdsGenerator struct {
@@ -464,7 +486,7 @@ func (s *DiscoveryServer) pushEds(push *model.PushContext, con *XdsConnection, v
 
 // getDestinationRule gets the DestinationRule for a given hostname. As an optimization, this also gets the service port,
 // which is needed to access the traffic policy from the destination rule.
-func getDestinationRule(push *model.PushContext, proxy *model.Proxy, hostname host.Name, clusterPort int) (*networkingapi.DestinationRule, *model.Port) {
+func getDestinationRule(push *model.PushContext, proxy *model.Proxy, hostname host.Name, clusterPort int) (*model.Config, *model.Port) {
 	for _, service := range push.Services(proxy) {
 		if service.Hostname == hostname {
 			cfg := push.DestinationRule(proxy, service)
@@ -473,7 +495,7 @@ func getDestinationRule(push *model.PushContext, proxy *model.Proxy, hostname ho
 			}
 			for _, p := range service.Ports {
 				if p.Port == clusterPort {
-					return cfg.Spec.(*networkingapi.DestinationRule), p
+					return cfg, p
 				}
 			}
 		}
@@ -481,14 +503,22 @@ func getDestinationRule(push *model.PushContext, proxy *model.Proxy, hostname ho
 	return nil, nil
 }
 
-func getOutlierDetectionAndLoadBalancerSettings(push *model.PushContext, proxy *model.Proxy, clusterName string) (bool, *networkingapi.LoadBalancerSettings) {
+// getOutlierDetectionAndLoadBalancerSettings returns whether outlier detection is enabled for the cluster, its
+// load balancer settings and the failover labels of its DestinationRule, nil if not set.
+func getOutlierDetectionAndLoadBalancerSettings(push *model.PushContext, proxy *model.Proxy,
+	clusterName string) (bool, *networkingapi.LoadBalancerSettings, []string) {
 	_, subsetName, hostname, portNumber := model.ParseSubsetKey(clusterName)
 	var outlierDetectionEnabled = false
 	var lbSettings *networkingapi.LoadBalancerSettings
 
-	destinationRule, port := getDestinationRule(push, proxy, hostname, portNumber)
-	if destinationRule == nil || port == nil {
-		return false, nil
+	cfg, port := getDestinationRule(push, proxy, hostname, portNumber)
+	if cfg == nil || port == nil {
+		return false, nil, nil
+	}
+	destinationRule := cfg.Spec.(*networkingapi.DestinationRule)
+	var failover []string
+	if value, f := cfg.Annotations[loadbalancer.FailoverLabelsAnnotation]; f {
+		failover = loadbalancer.ParseFailoverLabels(value)
 	}
 
 	_, outlierDetection, loadBalancerSettings, _ := networking.SelectTrafficPolicyComponents(destinationRule.TrafficPolicy, port)
@@ -507,7 +537,7 @@ func getOutlierDetectionAndLoadBalancerSettings(push *model.PushContext, proxy *
 			break
 		}
 	}
-	return outlierDetectionEnabled, lbSettings
+	return outlierDetectionEnabled, lbSettings, failover
 }
 
 func endpointDiscoveryResponse(loadAssignments []*endpoint.ClusterLoadAssignment, version, noncePrefix, typeURL string) *discovery.DiscoveryResponse {
@@ -537,7 +567,8 @@ func buildLocalityLbEndpointsFromShards(
 	svcPort *model.Port,
 	epLabels labels.Collection,
 	clusterName string,
-	push *model.PushContext) []*endpoint.LocalityLbEndpoints {
+	push *model.PushContext,
+	failoverLabels []string) []*endpoint.LocalityLbEndpoints {
 	localityEpMap := make(map[string]*endpoint.LocalityLbEndpoints)
 
 	// Determine whether or not the target service is considered local to the cluster
@@ -564,13 +595,22 @@ func buildLocalityLbEndpointsFromShards(
 				continue
 			}
 
-			locLbEps, found := localityEpMap[ep.Locality.Label]
+			// With failover labels, endpoints of a locality are further grouped by their priority,
+			// relative to the proxy. The locality priority is applied on top of it.
+			key := ep.Locality.Label
+			priority := 0
+			if len(failoverLabels) > 0 {
+				priority = loadbalancer.FailoverLabelPriority(proxy.Metadata.Labels, ep.Labels, failoverLabels)
+				key += "/" + strconv.Itoa(priority)
+			}
+			locLbEps, found := localityEpMap[key]
 			if !found {
 				locLbEps = &endpoint.LocalityLbEndpoints{
 					Locality:    util.ConvertLocality(ep.Locality.Label),
 					LbEndpoints: make([]*endpoint.LbEndpoint, 0, len(endpoints)),
+					Priority:    uint32(priority),
 				}
-				localityEpMap[ep.Locality.Label] = locLbEps
+				localityEpMap[key] = locLbEps
 			}
 			if ep.EnvoyEndpoint == nil {
 				ep.EnvoyEndpoint = buildEnvoyLbEndpoint(ep, push)
@@ -17,6 +17,7 @@ package v2
 import (
 	"net"
 
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
 	"github.com/golang/protobuf/ptypes/wrappers"
 
@@ -27,7 +28,8 @@ import (
 // EndpointsByNetworkFilter is a network filter function to support Split Horizon EDS - filter the endpoints based on the network
 // of the connected sidecar. The filter will filter out all endpoints which are not present within the
 // sidecar network and add a gateway endpoint to remote networks that have endpoints
-// (if gateway exists and its IP is an IP and not a dns name).
+// (if gateway exists and its IP is an IP and not a dns name). The weight of a gateway is the number of
+// healthy endpoints in its network, and gateways of networks without healthy endpoints are not added.
 // Information for the mesh networks is provided as a MeshNetwork config map.
 func EndpointsByNetworkFilter(push *model.PushContext, proxyNetwork string, endpoints []*endpoint.LocalityLbEndpoints) []*endpoint.LocalityLbEndpoints {
 	// calculate the multiples of weight.
@@ -65,9 +67,10 @@ func EndpointsByNetworkFilter(push *model.PushContext, proxyNetwork string, endp
 					Value: uint32(multiples),
 				}
 				lbEndpoints = append(lbEndpoints, clonedLbEp)
-			} else {
+			} else if isHealthy(lbEp) {
 				// Remote network endpoint which can not be accessed directly from local network.
-				// Increase the weight counter
+				// Increase the weight counter. Unhealthy endpoints are not counted, so that traffic
+				// shifts to the networks that can serve it.
 				remoteEps[epNetwork]++
 			}
 		}
@@ -118,6 +121,16 @@ func EndpointsByNetworkFilter(push *model.PushContext, proxyNetwork string, endp
 	return filtered
 }
 
+// isHealthy returns true if Envoy would send traffic to the endpoint outside of panic mode.
+func isHealthy(ep *endpoint.LbEndpoint) bool {
+	switch ep.HealthStatus {
+	case core.HealthStatus_UNKNOWN, core.HealthStatus_HEALTHY:
+		return true
+	default:
+		return false
+	}
+}
+
 // TODO: remove this, filtering should be done before generating the config, and
 // network metadata should not be included in output. A node only receives endpoints
 // in the same network as itself - so passing an network meta, with exactly
@@ -38,6 +38,8 @@ type LbEpInfo struct {
 	address string
 	// nolint: structcheck
 	weight uint32
+	// nolint: structcheck
+	healthStatus core.HealthStatus
 }
 
 type LocLbEpInfo struct {
@@ -566,6 +568,68 @@ func TestEndpointsByNetworkFilter_SkipLBWithHostname(t *testing.T) {
 	}
 }
 
+func TestEndpointsByNetworkFilter_UnhealthyEndpoints(t *testing.T) {
+	env := environment()
+	push := model.NewPushContext()
+	_ = push.InitContext(env, nil, nil)
+
+	// network1 has one unhealthy endpoint out of two, and network2 has no healthy endpoint.
+	lbEndpoints := createLbEndpoints(
+		[]*LbEpInfo{
+			{network: "network1", address: "10.0.0.1", healthStatus: core.HealthStatus_HEALTHY},
+			{network: "network1", address: "10.0.0.2", healthStatus: core.HealthStatus_UNHEALTHY},
+			{network: "network2", address: "20.0.0.1", healthStatus: core.HealthStatus_UNHEALTHY},
+			{network: "network4", address: "40.0.0.1"},
+		},
+	)
+	endpoints := []*endpoint.LocalityLbEndpoints{{LbEndpoints: lbEndpoints}}
+
+	tests := []struct {
+		network string
+		want    []LbEpInfo
+	}{
+		{
+			network: "network1",
+			want: []LbEpInfo{
+				// Local unhealthy endpoints are kept, Envoy doesn't send traffic to them
+				{address: "10.0.0.1", weight: 2, healthStatus: core.HealthStatus_HEALTHY},
+				{address: "10.0.0.2", weight: 2, healthStatus: core.HealthStatus_UNHEALTHY},
+				{address: "40.0.0.1", weight: 2},
+			},
+		},
+		{
+			network: "network3",
+			want: []LbEpInfo{
+				// Only the healthy endpoint of network1 is counted, and network2 is skipped
+				{address: "1.1.1.1", weight: 2},
+				{address: "40.0.0.1", weight: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			filtered := EndpointsByNetworkFilter(push, tt.network, endpoints)
+			if len(filtered) != 1 {
+				t.Fatalf("Unexpected number of filtered endpoints: got %v, want 1", len(filtered))
+			}
+			got := make([]LbEpInfo, 0, len(filtered[0].LbEndpoints))
+			for _, lbEp := range filtered[0].LbEndpoints {
+				got = append(got, LbEpInfo{
+					address:      lbEp.GetEndpoint().Address.GetSocketAddress().Address,
+					weight:       lbEp.GetLoadBalancingWeight().GetValue(),
+					healthStatus: lbEp.HealthStatus,
+				})
+			}
+			sort.Slice(got, func(i, j int) bool {
+				return got[i].address < got[j].address
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unexpected endpoints: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func xdsConnection(network string) *XdsConnection {
 	return &XdsConnection{
 		node: &model.Proxy{
@@ -667,6 +731,7 @@ func createLbEndpoints(lbEpsInfo []*LbEpInfo) []*endpoint.LbEndpoint {
 					},
 				},
 			},
+			HealthStatus: lbEpInfo.healthStatus,
 			Metadata: &core.Metadata{
 				FilterMetadata: map[string]*structpb.Struct{
 					"istio": {
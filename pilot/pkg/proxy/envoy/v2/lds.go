@@ -40,6 +40,16 @@ func (s *DiscoveryServer) pushLds(con *XdsConnection, push *model.PushContext, v
 		return err
 	}
 	ldsPushes.Increment()
+	if s.pushHistory.enabled() {
+		names := make([]string, 0, len(rawListeners))
+		for _, l := range rawListeners {
+			// Nil listeners are not sent, see ldsDiscoveryResponse.
+			if l != nil {
+				names = append(names, l.Name)
+			}
+		}
+		s.recordPushedResources(con, push, LDS, names, response.Resources)
+	}
 
 	adsLog.Infof("LDS: PUSH for node:%s listeners:%d", con.node.ID, len(rawListeners))
 	return nil
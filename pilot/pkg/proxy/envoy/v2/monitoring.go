@@ -28,6 +28,8 @@ var (
 	nodeTag    = monitoring.MustCreateLabel("node")
 	typeTag    = monitoring.MustCreateLabel("type")
 
+	priorityTag = monitoring.MustCreateLabel("priority")
+
 	cdsReject = monitoring.NewGauge(
 		"pilot_xds_cds_reject",
 		"Pilot rejected CDS configs.",
@@ -129,6 +131,25 @@ var (
 		[]float64{.1, 1, 3, 5, 10, 20, 30},
 	)
 
+	proxiesQueueWaitTime = monitoring.NewDistribution(
+		"pilot_proxy_queue_wait_time",
+		"Time in seconds a proxy waits in the push queue before being dequeued, labeled by priority class.",
+		[]float64{.01, .1, 1, 3, 5, 10, 20, 30},
+		monitoring.WithLabels(priorityTag),
+	)
+
+	configCacheHits = monitoring.NewSum(
+		"pilot_xds_config_cache_hits",
+		"Total number of CDS and RDS responses read from the config cache.",
+		monitoring.WithLabels(typeTag),
+	)
+
+	configCacheMisses = monitoring.NewSum(
+		"pilot_xds_config_cache_misses",
+		"Total number of CDS and RDS responses generated because they were not in the config cache.",
+		monitoring.WithLabels(typeTag),
+	)
+
 	pushTriggers = monitoring.NewSum(
 		"pilot_push_triggers",
 		"Total number of times a push was triggered, labeled by reason for the push.",
@@ -203,6 +224,9 @@ func init() {
 		pushTime,
 		proxiesConvergeDelay,
 		proxiesQueueTime,
+		proxiesQueueWaitTime,
+		configCacheHits,
+		configCacheMisses,
 		pushContextErrors,
 		totalXDSInternalErrors,
 		inboundUpdates,
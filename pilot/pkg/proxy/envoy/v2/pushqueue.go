@@ -15,11 +15,92 @@
 package v2
 
 import (
+	"container/heap"
 	"sync"
+	"time"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 )
 
+// pushPriority is the class of a proxy in the push queue. Lower values get a larger share of the pushes.
+type pushPriority int
+
+const (
+	// priorityGateway is used for gateways, which usually carry traffic for many services.
+	priorityGateway pushPriority = iota
+	// priorityNack is used for proxies which rejected their last push, so that they get the fix first.
+	priorityNack
+	// priorityDefault is used for all other proxies.
+	priorityDefault
+
+	numPushPriorities = iota
+)
+
+func (p pushPriority) String() string {
+	switch p {
+	case priorityGateway:
+		return "gateway"
+	case priorityNack:
+		return "nack"
+	default:
+		return "default"
+	}
+}
+
+// pushPriorityOf returns the class of a connection.
+func pushPriorityOf(con *XdsConnection) pushPriority {
+	if con.node != nil && con.node.Type == model.Router {
+		return priorityGateway
+	}
+	if con.hasNack() {
+		return priorityNack
+	}
+	return priorityDefault
+}
+
+// pushPriorityWeights is the share of the pushes of each class while the classes are backlogged. Classes are
+// served in weighted round robin rather than strictly by priority, so a steady stream of gateway or nack pushes
+// can't starve sidecars.
+var pushPriorityWeights = [numPushPriorities]int{
+	priorityGateway: 4,
+	priorityNack:    2,
+	priorityDefault: 1,
+}
+
+// pushSchedule is the round robin order of the classes, each class appearing as many times as its weight.
+var pushSchedule = func() []pushPriority {
+	var schedule []pushPriority
+	for priority, weight := range pushPriorityWeights {
+		for i := 0; i < weight; i++ {
+			schedule = append(schedule, pushPriority(priority))
+		}
+	}
+	return schedule
+}()
+
+// delayedPush is a connection held in the queue until it can be pushed again.
+type delayedPush struct {
+	con      *XdsConnection
+	priority pushPriority
+	readyAt  time.Time
+}
+
+// delayedPushes is a min-heap of delayed pushes by readyAt, implementing heap.Interface.
+type delayedPushes []delayedPush
+
+func (d delayedPushes) Len() int            { return len(d) }
+func (d delayedPushes) Less(i, j int) bool  { return d[i].readyAt.Before(d[j].readyAt) }
+func (d delayedPushes) Swap(i, j int)       { d[i], d[j] = d[j], d[i] }
+func (d *delayedPushes) Push(x interface{}) { *d = append(*d, x.(delayedPush)) }
+func (d *delayedPushes) Pop() interface{} {
+	old := *d
+	n := len(old)
+	x := old[n-1]
+	*d = old[:n-1]
+	return x
+}
+
 type PushQueue struct {
 	mu   *sync.RWMutex
 	cond *sync.Cond
@@ -28,22 +109,37 @@ type PushQueue struct {
 	// PushEvents will be merged.
 	eventsMap map[*XdsConnection]*model.PushRequest
 
-	// connections maintains ordering of the queue
-	connections []*XdsConnection
+	// ready maintains the ordering of the connections that can be pushed now, for each priority class.
+	ready [numPushPriorities][]*XdsConnection
+
+	// delayed holds the connections pushed less than minInterval ago, until they can be pushed again.
+	delayed delayedPushes
+
+	// next is the position in pushSchedule of the class to serve first on the next Dequeue.
+	next int
+
+	// enqueued stores the time each connection in the queue was first enqueued, to report the wait time.
+	enqueued map[*XdsConnection]time.Time
 
 	// inProgress stores all connections that have been Dequeue(), but not MarkDone().
 	// The value stored will be initially be nil, but may be populated if the connection is Enqueue().
 	// If model.PushRequest is not nil, it will be Enqueued again once MarkDone has been called.
 	inProgress map[*XdsConnection]*model.PushRequest
+
+	// minInterval is the minimum time between two pushes to the same connection. Pushes enqueued
+	// earlier are held in the queue, where further updates are merged into them.
+	minInterval time.Duration
 }
 
 func NewPushQueue() *PushQueue {
 	mu := &sync.RWMutex{}
 	return &PushQueue{
-		mu:         mu,
-		eventsMap:  make(map[*XdsConnection]*model.PushRequest),
-		inProgress: make(map[*XdsConnection]*model.PushRequest),
-		cond:       sync.NewCond(mu),
+		mu:          mu,
+		eventsMap:   make(map[*XdsConnection]*model.PushRequest),
+		enqueued:    make(map[*XdsConnection]time.Time),
+		inProgress:  make(map[*XdsConnection]*model.PushRequest),
+		cond:        sync.NewCond(mu),
+		minInterval: features.PushMinInterval,
 	}
 }
 
@@ -64,32 +160,75 @@ func (p *PushQueue) Enqueue(proxy *XdsConnection, pushInfo *model.PushRequest) {
 		return
 	}
 
+	now := time.Now()
 	p.eventsMap[proxy] = pushInfo
-	p.connections = append(p.connections, proxy)
+	p.enqueued[proxy] = now
+	priority := pushPriorityOf(proxy)
+	if readyAt := proxy.lastPush.Add(p.minInterval); readyAt.After(now) {
+		heap.Push(&p.delayed, delayedPush{con: proxy, priority: priority, readyAt: readyAt})
+	} else {
+		p.ready[priority] = append(p.ready[priority], proxy)
+	}
 	// Signal waiters on Dequeue that a new item is available
 	p.cond.Signal()
 }
 
-// Remove a proxy from the queue. If there are no proxies ready to be removed, this will block
+// Remove a proxy from the queue. If there are no proxies ready to be removed, this will block.
+// Priority classes are served in weighted round robin, see pushPriorityWeights, and proxies of a class in order.
+// Proxies pushed less than minInterval ago are held until they can be pushed again.
 func (p *PushQueue) Dequeue() (*XdsConnection, *model.PushRequest) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Block until there is one to remove. Enqueue will signal when one is added.
-	for len(p.connections) == 0 {
+	for {
+		now := time.Now()
+		for len(p.delayed) > 0 && !p.delayed[0].readyAt.After(now) {
+			d := heap.Pop(&p.delayed).(delayedPush)
+			p.ready[d.priority] = append(p.ready[d.priority], d.con)
+		}
+
+		for i := 0; i < len(pushSchedule); i++ {
+			priority := pushSchedule[(p.next+i)%len(pushSchedule)]
+			if len(p.ready[priority]) == 0 {
+				continue
+			}
+			p.next = (p.next + i + 1) % len(pushSchedule)
+			con := p.ready[priority][0]
+			p.ready[priority][0] = nil
+			p.ready[priority] = p.ready[priority][1:]
+			return con, p.remove(con, priority, now)
+		}
+
+		// Block until there is one to remove. Enqueue will signal when one is added, and
+		// the timer when a rate limited proxy can be pushed again.
+		var timer *time.Timer
+		if len(p.delayed) > 0 {
+			timer = time.AfterFunc(p.delayed[0].readyAt.Sub(now), func() {
+				p.mu.Lock()
+				p.cond.Broadcast()
+				p.mu.Unlock()
+			})
+		}
 		p.cond.Wait()
+		if timer != nil {
+			timer.Stop()
+		}
 	}
+}
 
-	head := p.connections[0]
-	p.connections = p.connections[1:]
+// remove marks a connection removed from the queue as in progress, and returns its push request.
+func (p *PushQueue) remove(con *XdsConnection, priority pushPriority, now time.Time) *model.PushRequest {
+	info := p.eventsMap[con]
+	delete(p.eventsMap, con)
 
-	info := p.eventsMap[head]
-	delete(p.eventsMap, head)
+	proxiesQueueWaitTime.With(priorityTag.Value(priority.String())).Record(now.Sub(p.enqueued[con]).Seconds())
+	delete(p.enqueued, con)
 
 	// Mark the connection as in progress
-	p.inProgress[head] = nil
+	p.inProgress[con] = nil
+	con.lastPush = now
 
-	return head, info
+	return info
 }
 
 func (p *PushQueue) MarkDone(con *XdsConnection) {
@@ -110,5 +249,5 @@ func (p *PushQueue) MarkDone(con *XdsConnection) {
 func (p *PushQueue) Pending() int {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	return len(p.connections)
+	return len(p.eventsMap)
 }
@@ -210,6 +210,79 @@ func TestProxyQueue(t *testing.T) {
 		}
 	})
 
+	t.Run("priority classes", func(t *testing.T) {
+		p := NewPushQueue()
+		sidecar := &XdsConnection{ConID: "sidecar", node: &model.Proxy{Type: model.SidecarProxy}}
+		nacked := &XdsConnection{ConID: "nacked", node: &model.Proxy{Type: model.SidecarProxy}}
+		nacked.recordAck(ClusterType, true)
+		gateway := &XdsConnection{ConID: "gateway", node: &model.Proxy{Type: model.Router}}
+
+		p.Enqueue(sidecar, &model.PushRequest{})
+		p.Enqueue(nacked, &model.PushRequest{})
+		p.Enqueue(gateway, &model.PushRequest{})
+		if p.Pending() != 3 {
+			t.Fatalf("Expected 3 pending proxies, got %v", p.Pending())
+		}
+
+		ExpectDequeue(t, p, gateway)
+		ExpectDequeue(t, p, nacked)
+		ExpectDequeue(t, p, sidecar)
+		if p.Pending() != 0 {
+			t.Fatalf("Expected no pending proxies, got %v", p.Pending())
+		}
+
+		p.MarkDone(gateway)
+		p.MarkDone(nacked)
+		p.MarkDone(sidecar)
+
+		// Once the proxy accepts a push, it is back to the default class.
+		nacked.recordAck(ClusterType, false)
+		p.Enqueue(nacked, &model.PushRequest{})
+		p.Enqueue(sidecar, &model.PushRequest{})
+		ExpectDequeue(t, p, nacked)
+	})
+
+	t.Run("weighted round robin", func(t *testing.T) {
+		p := NewPushQueue()
+		sidecar := &XdsConnection{ConID: "sidecar", node: &model.Proxy{Type: model.SidecarProxy}}
+		var gateways []*XdsConnection
+		for i := 0; i < 10; i++ {
+			gateway := &XdsConnection{ConID: fmt.Sprintf("gateway-%d", i), node: &model.Proxy{Type: model.Router}}
+			gateways = append(gateways, gateway)
+			p.Enqueue(gateway, &model.PushRequest{})
+		}
+		p.Enqueue(sidecar, &model.PushRequest{})
+
+		// Gateways go first, but can't starve sidecars: without nacked proxies, a sidecar gets a push after
+		// every round of gateway pushes.
+		for _, gateway := range gateways[:pushPriorityWeights[priorityGateway]] {
+			ExpectDequeue(t, p, gateway)
+		}
+		ExpectDequeue(t, p, sidecar)
+		ExpectDequeue(t, p, gateways[pushPriorityWeights[priorityGateway]])
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		p := NewPushQueue()
+		p.minInterval = time.Millisecond * 200
+		limited := &XdsConnection{ConID: "limited"}
+		other := &XdsConnection{ConID: "other"}
+
+		p.Enqueue(limited, &model.PushRequest{})
+		ExpectDequeue(t, p, limited)
+		p.MarkDone(limited)
+
+		// The proxy was just pushed, so the next one goes first.
+		start := time.Now()
+		p.Enqueue(limited, &model.PushRequest{})
+		p.Enqueue(other, &model.PushRequest{})
+		ExpectDequeue(t, p, other)
+		ExpectDequeue(t, p, limited)
+		if elapsed := time.Since(start); elapsed < time.Millisecond*100 {
+			t.Fatalf("Expected push to be rate limited, got it after %v", elapsed)
+		}
+	})
+
 	t.Run("concurrent", func(t *testing.T) {
 		p := NewPushQueue()
 		key := func(p *XdsConnection, eds string) string { return fmt.Sprintf("%s~%s", p.ConID, eds) }
@@ -0,0 +1,316 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/any"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+// xdsTypeNames is used to display XdsType in /debug/pushz.
+var xdsTypeNames = map[XdsType]string{
+	CDS: "cds",
+	EDS: "eds",
+	LDS: "lds",
+	RDS: "rds",
+}
+
+// pushRecord describes a single full push, and the resources it sent to proxies.
+type pushRecord struct {
+	Version        string                      `json:"version"`
+	Time           time.Time                   `json:"time"`
+	Reasons        map[model.TriggerReason]int `json:"reasons,omitempty"`
+	ConfigsUpdated []string                    `json:"configsUpdated,omitempty"`
+	// Scoped is set when the push only went to the proxies and xDS types affected by ConfigsUpdated.
+	// A scoped push only records part of the configuration, so resources it did not send can't be
+	// reported as removed.
+	Scoped bool `json:"scoped,omitempty"`
+
+	// resources holds the hashes of the resources pushed, by proxy type, xDS type and resource name.
+	// Proxies of the same type may receive different content for a resource with the same name (for
+	// example with different Sidecars), so every distinct hash is kept.
+	resources map[model.NodeType]map[XdsType]map[string]map[string]struct{}
+}
+
+// pushSummary is the /debug/pushz view of a push.
+type pushSummary struct {
+	*pushRecord
+	// Resources counts the resources pushed by proxy type and xDS type.
+	Resources map[model.NodeType]map[string]int `json:"resources,omitempty"`
+}
+
+// resourceDiff lists the resources that differ between two pushes, for a proxy and xDS type.
+type resourceDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+// pushDiff is the /debug/pushz view of the difference between two pushes.
+type pushDiff struct {
+	From *pushRecord                                 `json:"from"`
+	To   *pushRecord                                 `json:"to"`
+	Diff map[model.NodeType]map[string]*resourceDiff `json:"diff"`
+}
+
+// pushHistory keeps a bounded history of full pushes. Resources are recorded as they are sent, so
+// a push is only complete once it reached all proxies.
+type pushHistory struct {
+	mu      sync.RWMutex
+	size    int
+	records []*pushRecord
+}
+
+func newPushHistory(size int) *pushHistory {
+	return &pushHistory{size: size}
+}
+
+// enabled returns true if pushes should be recorded.
+func (h *pushHistory) enabled() bool {
+	return h != nil && h.size > 0
+}
+
+// startPush records a new push, evicting the oldest one if the history is full.
+func (h *pushHistory) startPush(version string, req *model.PushRequest) {
+	if !h.enabled() {
+		return
+	}
+	r := &pushRecord{
+		Version:   version,
+		Time:      time.Now(),
+		Reasons:   map[model.TriggerReason]int{},
+		resources: map[model.NodeType]map[XdsType]map[string]map[string]struct{}{},
+	}
+	for _, reason := range req.Reason {
+		r.Reasons[reason]++
+	}
+	for key := range req.ConfigsUpdated {
+		r.ConfigsUpdated = append(r.ConfigsUpdated, fmt.Sprintf("%s/%s/%s", key.Kind.Kind, key.Namespace, key.Name))
+	}
+	sort.Strings(r.ConfigsUpdated)
+	// Matches PushTypeFor: without ConfigsUpdated every proxy receives all xDS types.
+	r.Scoped = len(req.ConfigsUpdated) > 0
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	if len(h.records) > h.size {
+		h.records = h.records[len(h.records)-h.size:]
+	}
+}
+
+// recordResources adds the hashes of resources sent to a proxy to the push with the given version.
+// Pushes that already left the history are ignored.
+func (h *pushHistory) recordResources(version string, nodeType model.NodeType, xdsType XdsType, hashes map[string]string) {
+	if !h.enabled() || version == "" {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	r := h.find(version)
+	if r == nil {
+		return
+	}
+	byType, f := r.resources[nodeType]
+	if !f {
+		byType = map[XdsType]map[string]map[string]struct{}{}
+		r.resources[nodeType] = byType
+	}
+	byName, f := byType[xdsType]
+	if !f {
+		byName = map[string]map[string]struct{}{}
+		byType[xdsType] = byName
+	}
+	for name, hash := range hashes {
+		if byName[name] == nil {
+			byName[name] = map[string]struct{}{}
+		}
+		byName[name][hash] = struct{}{}
+	}
+}
+
+// find returns the push with the given version, or nil. The caller must hold the lock.
+func (h *pushHistory) find(version string) *pushRecord {
+	for i := len(h.records) - 1; i >= 0; i-- {
+		if h.records[i].Version == version {
+			return h.records[i]
+		}
+	}
+	return nil
+}
+
+func (h *pushHistory) summaries() []pushSummary {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]pushSummary, 0, len(h.records))
+	for _, r := range h.records {
+		s := pushSummary{pushRecord: r, Resources: map[model.NodeType]map[string]int{}}
+		for nodeType, byType := range r.resources {
+			s.Resources[nodeType] = map[string]int{}
+			for xdsType, byName := range byType {
+				s.Resources[nodeType][xdsTypeNames[xdsType]] = len(byName)
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// diff compares the resources sent by two pushes.
+func (h *pushHistory) diff(from, to string) (*pushDiff, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	fromRecord, toRecord := h.find(from), h.find(to)
+	if fromRecord == nil {
+		return nil, fmt.Errorf("push %q not found", from)
+	}
+	if toRecord == nil {
+		return nil, fmt.Errorf("push %q not found", to)
+	}
+
+	out := &pushDiff{From: fromRecord, To: toRecord, Diff: map[model.NodeType]map[string]*resourceDiff{}}
+	nodeTypes := map[model.NodeType]struct{}{}
+	for nodeType := range fromRecord.resources {
+		nodeTypes[nodeType] = struct{}{}
+	}
+	for nodeType := range toRecord.resources {
+		nodeTypes[nodeType] = struct{}{}
+	}
+	for nodeType := range nodeTypes {
+		for _, xdsType := range []XdsType{CDS, LDS, RDS} {
+			// A type that was not pushed at all by one side can't be compared.
+			before, fb := fromRecord.resources[nodeType][xdsType]
+			after, fa := toRecord.resources[nodeType][xdsType]
+			if !fb || !fa {
+				continue
+			}
+			d := diffResources(before, after, !fromRecord.Scoped, !toRecord.Scoped)
+			if d == nil {
+				continue
+			}
+			if out.Diff[nodeType] == nil {
+				out.Diff[nodeType] = map[string]*resourceDiff{}
+			}
+			out.Diff[nodeType][xdsTypeNames[xdsType]] = d
+		}
+	}
+	return out, nil
+}
+
+// diffResources compares the resources of two pushes. Added resources are only reported if before
+// is complete, and removed resources only if after is complete.
+func diffResources(before, after map[string]map[string]struct{}, beforeComplete, afterComplete bool) *resourceDiff {
+	d := &resourceDiff{}
+	for name, hashes := range after {
+		old, f := before[name]
+		if !f {
+			if beforeComplete {
+				d.Added = append(d.Added, name)
+			}
+		} else if !sameHashes(old, hashes) {
+			d.Changed = append(d.Changed, name)
+		}
+	}
+	if afterComplete {
+		for name := range before {
+			if _, f := after[name]; !f {
+				d.Removed = append(d.Removed, name)
+			}
+		}
+	}
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		return nil
+	}
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+func sameHashes(a, b map[string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for h := range a {
+		if _, f := b[h]; !f {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceHashes returns the hash of each marshalled resource, keyed by name. names and resources
+// must be aligned.
+func resourceHashes(names []string, resources []*any.Any) map[string]string {
+	out := make(map[string]string, len(names))
+	if len(names) != len(resources) {
+		return out
+	}
+	for i, name := range names {
+		out[name] = resourceVersion(resources[i])
+	}
+	return out
+}
+
+// recordPushedResources adds the resources sent to a connection to the push history.
+func (s *DiscoveryServer) recordPushedResources(con *XdsConnection, push *model.PushContext, xdsType XdsType,
+	names []string, resources []*any.Any) {
+	if !s.pushHistory.enabled() {
+		return
+	}
+	s.pushHistory.recordResources(push.PushVersion, con.node.Type, xdsType, resourceHashes(names, resources))
+}
+
+// pushz lists the recent full pushes. With from and to parameters, it shows the CDS, LDS and RDS
+// resources that were added, removed or changed between the two pushes, for each proxy type.
+func (s *DiscoveryServer) pushz(w http.ResponseWriter, req *http.Request) {
+	if !s.pushHistory.enabled() {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintln(w, "push history is disabled, set PILOT_PUSH_HISTORY_SIZE to enable it")
+		return
+	}
+
+	var out interface{}
+	from, to := strings.TrimSpace(req.URL.Query().Get("from")), strings.TrimSpace(req.URL.Query().Get("to"))
+	if from != "" || to != "" {
+		d, err := s.pushHistory.diff(from, to)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprintf(w, "unable to diff pushes: %v\n", err)
+			return
+		}
+		out = d
+	} else {
+		out = s.pushHistory.summaries()
+	}
+
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal push history: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(b)
+}
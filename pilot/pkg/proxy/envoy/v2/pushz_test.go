@@ -0,0 +1,118 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v2
+
+import (
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestPushHistoryEviction(t *testing.T) {
+	h := newPushHistory(2)
+	for _, v := range []string{"v1", "v2", "v3"} {
+		h.startPush(v, &model.PushRequest{Full: true, Reason: []model.TriggerReason{model.ConfigUpdate}})
+	}
+	got := []string{}
+	for _, s := range h.summaries() {
+		got = append(got, s.Version)
+	}
+	if !reflect.DeepEqual(got, []string{"v2", "v3"}) {
+		t.Fatalf("expected the oldest push to be evicted, got %v", got)
+	}
+
+	// Resources for an evicted push are dropped.
+	h.recordResources("v1", model.SidecarProxy, CDS, map[string]string{"a": "1"})
+	if _, err := h.diff("v1", "v3"); err == nil {
+		t.Fatalf("expected evicted push to be unknown")
+	}
+}
+
+func TestPushHistoryDisabled(t *testing.T) {
+	h := newPushHistory(0)
+	h.startPush("v1", &model.PushRequest{Full: true})
+	if len(h.summaries()) != 0 {
+		t.Fatalf("expected no pushes to be recorded")
+	}
+}
+
+func TestPushHistoryDiff(t *testing.T) {
+	h := newPushHistory(10)
+	h.startPush("v1", &model.PushRequest{Full: true})
+	h.recordResources("v1", model.SidecarProxy, CDS, map[string]string{"a": "1", "b": "1", "c": "1"})
+	h.recordResources("v1", model.SidecarProxy, LDS, map[string]string{"l": "1"})
+	h.recordResources("v1", model.Router, CDS, map[string]string{"a": "1"})
+
+	h.startPush("v2", &model.PushRequest{Full: true})
+	h.recordResources("v2", model.SidecarProxy, CDS, map[string]string{"a": "1", "b": "2", "d": "1"})
+	// Another sidecar received a different version of the same cluster.
+	h.recordResources("v2", model.SidecarProxy, CDS, map[string]string{"a": "2"})
+	h.recordResources("v2", model.SidecarProxy, LDS, map[string]string{"l": "1"})
+	h.recordResources("v2", model.Router, CDS, map[string]string{"a": "1"})
+
+	d, err := h.diff("v1", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[model.NodeType]map[string]*resourceDiff{
+		model.SidecarProxy: {
+			"cds": {
+				Added:   []string{"d"},
+				Removed: []string{"c"},
+				Changed: []string{"a", "b"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(d.Diff, want) {
+		t.Fatalf("unexpected diff %+v", d.Diff[model.SidecarProxy]["cds"])
+	}
+}
+
+func TestPushHistoryDiffScoped(t *testing.T) {
+	scoped := &model.PushRequest{Full: true, ConfigsUpdated: map[model.ConfigKey]struct{}{
+		{Kind: model.ServiceEntryKind, Name: "svc", Namespace: "ns"}: {},
+	}}
+	h := newPushHistory(10)
+	h.startPush("v1", &model.PushRequest{Full: true})
+	h.recordResources("v1", model.SidecarProxy, CDS, map[string]string{"a": "1", "b": "1"})
+	h.recordResources("v1", model.SidecarProxy, LDS, map[string]string{"l": "1"})
+
+	// Only a subset of the sidecars received the scoped push, and LDS was not pushed at all.
+	h.startPush("v2", scoped)
+	h.recordResources("v2", model.SidecarProxy, CDS, map[string]string{"a": "2", "c": "1"})
+
+	h.startPush("v3", &model.PushRequest{Full: true})
+	h.recordResources("v3", model.SidecarProxy, CDS, map[string]string{"a": "2", "c": "1"})
+
+	d, err := h.diff("v1", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[model.NodeType]map[string]*resourceDiff{
+		model.SidecarProxy: {"cds": {Added: []string{"c"}, Changed: []string{"a"}}},
+	}
+	if !reflect.DeepEqual(d.Diff, want) {
+		t.Fatalf("unexpected diff %+v", d.Diff[model.SidecarProxy]["cds"])
+	}
+
+	d, err = h.diff("v2", "v3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Diff) != 0 {
+		t.Fatalf("expected no diff from a scoped push, got %+v", d.Diff[model.SidecarProxy]["cds"])
+	}
+}
@@ -20,6 +20,7 @@ import (
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/golang/protobuf/ptypes/any"
 
 	"istio.io/istio/pkg/util/protomarshal"
 
@@ -29,7 +30,7 @@ import (
 
 func (s *DiscoveryServer) pushRoute(con *XdsConnection, push *model.PushContext, version string) error {
 	pushStart := time.Now()
-	rawRoutes := s.ConfigGenerator.BuildHTTPRoutes(con.node, push, con.Routes)
+	rawRoutes, resources := s.buildRoutes(con, push)
 	if s.DebugConfigs {
 		for _, r := range rawRoutes {
 			con.RouteConfigs[r.Name] = r
@@ -40,7 +41,7 @@ func (s *DiscoveryServer) pushRoute(con *XdsConnection, push *model.PushContext,
 		}
 	}
 
-	response := routeDiscoveryResponse(rawRoutes, version, push.Version, con.node.RequestedTypes.RDS)
+	response := routeResourcesDiscoveryResponse(resources, version, push.Version, con.node.RequestedTypes.RDS)
 	err := con.send(response)
 	rdsPushTime.Record(time.Since(pushStart).Seconds())
 	if err != nil {
@@ -48,22 +49,67 @@ func (s *DiscoveryServer) pushRoute(con *XdsConnection, push *model.PushContext,
 		return err
 	}
 	rdsPushes.Increment()
+	if s.pushHistory.enabled() {
+		names := make([]string, 0, len(rawRoutes))
+		for _, r := range rawRoutes {
+			names = append(names, r.Name)
+		}
+		s.recordPushedResources(con, push, RDS, names, response.Resources)
+	}
 
 	adsLog.Infof("RDS: PUSH for node:%s routes:%d", con.node.ID, len(rawRoutes))
 	return nil
 }
 
 func routeDiscoveryResponse(rs []*route.RouteConfiguration, version, noncePrefix, typeURL string) *discovery.DiscoveryResponse {
-	resp := &discovery.DiscoveryResponse{
+	return routeResourcesDiscoveryResponse(routeResources(rs, typeURL), version, noncePrefix, typeURL)
+}
+
+func routeResourcesDiscoveryResponse(resources []*any.Any, version, noncePrefix, typeURL string) *discovery.DiscoveryResponse {
+	return &discovery.DiscoveryResponse{
 		TypeUrl:     typeURL,
 		VersionInfo: version,
 		Nonce:       nonce(noncePrefix),
+		Resources:   resources,
 	}
+}
+
+func routeResources(rs []*route.RouteConfiguration, typeURL string) []*any.Any {
+	out := make([]*any.Any, 0, len(rs))
 	for _, rc := range rs {
 		rr := util.MessageToAny(rc)
 		rr.TypeUrl = typeURL
-		resp.Resources = append(resp.Resources, rr)
+		out = append(out, rr)
+	}
+	return out
+}
+
+// buildRoutes returns the routes requested by a proxy, marshalled for the response. They are read
+// from the config cache when it is enabled.
+func (s *DiscoveryServer) buildRoutes(con *XdsConnection, push *model.PushContext) ([]*route.RouteConfiguration, []*any.Any) {
+	key, cacheable := s.configCacheKey(con, RDS, con.node.RequestedTypes.RDS, con.Routes)
+	return s.buildRoutesForKey(con, push, key, cacheable)
+}
+
+// buildRoutesForKey is buildRoutes with the config cache key of the connection already computed.
+func (s *DiscoveryServer) buildRoutesForKey(con *XdsConnection, push *model.PushContext,
+	key string, cacheable bool) ([]*route.RouteConfiguration, []*any.Any) {
+	typeURL := con.node.RequestedTypes.RDS
+	if cacheable {
+		if entry := s.configCache.get(push, RDS, key); entry != nil {
+			return entry.routes, entry.resources
+		}
 	}
 
-	return resp
+	rawRoutes := s.ConfigGenerator.BuildHTTPRoutes(con.node, push, con.Routes)
+	resources := routeResources(rawRoutes, typeURL)
+	if cacheable {
+		s.configCache.add(push, key, &configCacheEntry{
+			xdsType:   RDS,
+			namespace: con.node.ConfigNamespace,
+			routes:    rawRoutes,
+			resources: resources,
+		})
+	}
+	return rawRoutes, resources
 }
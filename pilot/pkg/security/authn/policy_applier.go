@@ -15,6 +15,7 @@
 package authn
 
 import (
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
 	http_conn "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 
 	"istio.io/istio/pilot/pkg/model"
@@ -36,4 +37,8 @@ type PolicyApplier interface {
 	// AuthNFilter returns the (authn) HTTP filter to enforce the underlying authentication policy.
 	// It may return nil, if no authentication is needed.
 	AuthNFilter(proxyType model.NodeType, port uint32) *http_conn.HttpFilter
+
+	// ClaimHeaders returns the request headers to set from the claims of the validated JWT, and the names of
+	// these headers, to remove from the incoming request. It returns nil if no claim is copied.
+	ClaimHeaders() ([]*core.HeaderValueOption, []string)
 }
@@ -0,0 +1,141 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+
+	"istio.io/api/security/v1beta1"
+
+	"istio.io/istio/pilot/pkg/model"
+	authn_model "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pkg/proto"
+)
+
+// claimHeader copies a claim of the JWT validated for an issuer to a request header.
+type claimHeader struct {
+	header string
+	issuer string
+	claim  []string
+}
+
+// buildClaimHeaders returns the claim headers of the RequestAuthentication policies, sorted by header and issuer.
+// The claims of a policy are copied for each of its issuers, as Envoy sets the payload of the validated JWT in the
+// dynamic metadata of the JWT filter, under the issuer.
+func buildClaimHeaders(jwtPolicies []*model.Config) []claimHeader {
+	var out []claimHeader
+	for _, policy := range jwtPolicies {
+		value := policy.Annotations[model.JwtClaimHeadersAnnotation]
+		if value == "" {
+			continue
+		}
+		claims, err := parseClaimHeaders(value)
+		if err != nil {
+			authnLog.Errorf("ignored annotation %s of %s/%s: %v", model.JwtClaimHeadersAnnotation, policy.Namespace, policy.Name, err)
+			continue
+		}
+		for _, rule := range policy.Spec.(*v1beta1.RequestAuthentication).JwtRules {
+			for header, claim := range claims {
+				out = append(out, claimHeader{header: header, issuer: rule.Issuer, claim: claim})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].header != out[j].header {
+			return out[i].header < out[j].header
+		}
+		return out[i].issuer < out[j].issuer
+	})
+	return out
+}
+
+// parseClaimHeaders parses the value of the JwtClaimHeadersAnnotation into a map of header to claim path.
+func parseClaimHeaders(value string) (map[string][]string, error) {
+	out := map[string][]string{}
+	for _, item := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expecting <header>=<claim>, but found %q", item)
+		}
+		header := strings.ToLower(strings.TrimSpace(parts[0]))
+		if header == "" || strings.HasPrefix(header, ":") {
+			return nil, fmt.Errorf("invalid header %q", parts[0])
+		}
+		if _, found := out[header]; found {
+			return nil, fmt.Errorf("duplicate header %q", header)
+		}
+		claim, err := parseClaimPath(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, err
+		}
+		out[header] = claim
+	}
+	return out, nil
+}
+
+// parseClaimPath parses a nested claim, either separated by dots, e.g. "realm_access.roles", or in brackets,
+// e.g. "[realm_access][roles]". List indexes are not supported: Envoy serializes a list claim as a JSON array.
+func parseClaimPath(path string) ([]string, error) {
+	var claim []string
+	if strings.HasPrefix(path, "[") && strings.HasSuffix(path, "]") {
+		claim = strings.Split(strings.TrimSuffix(strings.TrimPrefix(path, "["), "]"), "][")
+	} else {
+		claim = strings.Split(path, ".")
+	}
+	for _, c := range claim {
+		if c == "" {
+			return nil, fmt.Errorf("invalid claim %q", path)
+		}
+		if _, err := strconv.Atoi(c); err == nil {
+			return nil, fmt.Errorf("invalid claim %q: list indexes are not supported, the whole list is copied", path)
+		}
+	}
+	return claim, nil
+}
+
+// ClaimHeaders returns the request headers set from the JWT claims. The returned header names must be removed
+// from the incoming request, so that they can't be set without a valid JWT.
+func (a *v1beta1PolicyApplier) ClaimHeaders() ([]*core.HeaderValueOption, []string) {
+	if len(a.claimHeaders) == 0 {
+		return nil, nil
+	}
+	var headers []*core.HeaderValueOption
+	var names []string
+	for _, h := range a.claimHeaders {
+		// The header is not added if the metadata is missing, e.g. the JWT is of another issuer.
+		path, err := json.Marshal(append([]string{authn_model.EnvoyJwtFilterName, h.issuer}, h.claim...))
+		if err != nil {
+			authnLog.Errorf("failed to copy claim %v to header %s: %v", h.claim, h.header, err)
+			continue
+		}
+		headers = append(headers, &core.HeaderValueOption{
+			Header: &core.HeaderValue{
+				Key:   h.header,
+				Value: fmt.Sprintf("%%DYNAMIC_METADATA(%s)%%", path),
+			},
+			Append: proto.BoolFalse,
+		})
+		if len(names) == 0 || names[len(names)-1] != h.header {
+			names = append(names, h.header)
+		}
+	}
+	return headers, names
+}
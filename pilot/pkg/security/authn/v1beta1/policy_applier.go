@@ -53,6 +53,9 @@ type v1beta1PolicyApplier struct {
 	processedJwtRules []*v1beta1.JWTRule
 
 	consolidatedPeerPolicy *v1beta1.PeerAuthentication
+
+	// claimHeaders is the claims copied to request headers, from the JwtClaimHeadersAnnotation of the jwtPolicies.
+	claimHeaders []claimHeader
 }
 
 func (a *v1beta1PolicyApplier) JwtFilter() *http_conn.HttpFilter {
@@ -199,6 +202,7 @@ func NewPolicyApplier(rootNamespace string,
 		peerPolices:            peerPolicies,
 		processedJwtRules:      processedJwtRules,
 		consolidatedPeerPolicy: composePeerAuthentication(rootNamespace, peerPolicies),
+		claimHeaders:           buildClaimHeaders(jwtPolicies),
 	}
 }
 
@@ -241,7 +245,7 @@ func convertToEnvoyJwtConfig(jwtRules []*v1beta1.JWTRule) *envoy_jwt.JwtAuthenti
 		jwtPubKey := jwtRule.Jwks
 		if jwtPubKey == "" {
 			var err error
-			jwtPubKey, err = model.JwtKeyResolver.GetPublicKey(jwtRule.JwksUri)
+			jwtPubKey, err = model.JwtKeyResolver.GetPublicKey(jwtRule.Issuer, jwtRule.JwksUri)
 			if err != nil {
 				log.Errorf("Failed to fetch jwt public key from %q: %s", jwtRule.JwksUri, err)
 			}
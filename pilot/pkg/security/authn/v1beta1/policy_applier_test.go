@@ -2005,3 +2005,98 @@ func TestGetMutualTLSMode(t *testing.T) {
 		})
 	}
 }
+
+func TestClaimHeaders(t *testing.T) {
+	jwtPolicy := func(name, annotation string, issuers ...string) *model.Config {
+		spec := &v1beta1.RequestAuthentication{}
+		for _, issuer := range issuers {
+			spec.JwtRules = append(spec.JwtRules, &v1beta1.JWTRule{Issuer: issuer})
+		}
+		return &model.Config{
+			ConfigMeta: model.ConfigMeta{
+				Name:        name,
+				Namespace:   "foo",
+				Annotations: map[string]string{model.JwtClaimHeadersAnnotation: annotation},
+			},
+			Spec: spec,
+		}
+	}
+	header := func(key, value string) *core.HeaderValueOption {
+		return &core.HeaderValueOption{
+			Header: &core.HeaderValue{Key: key, Value: value},
+			Append: protovalue.BoolFalse,
+		}
+	}
+	cases := []struct {
+		name        string
+		in          []*model.Config
+		wantHeaders []*core.HeaderValueOption
+		wantRemoved []string
+	}{
+		{
+			name: "no annotation",
+			in:   []*model.Config{jwtPolicy("a", "", "issuer-a")},
+		},
+		{
+			name: "invalid annotation",
+			in:   []*model.Config{jwtPolicy("a", "x-sub", "issuer-a")},
+		},
+		{
+			name: "list index",
+			in:   []*model.Config{jwtPolicy("a", "x-group=groups.0", "issuer-a")},
+		},
+		{
+			name: "claims",
+			in: []*model.Config{
+				jwtPolicy("a", "X-Sub=sub, x-roles=realm_access.roles", "issuer-a"),
+				jwtPolicy("b", "x-sub=[sub]", "issuer-b"),
+			},
+			wantHeaders: []*core.HeaderValueOption{
+				header("x-roles", `%DYNAMIC_METADATA(["envoy.filters.http.jwt_authn","issuer-a","realm_access","roles"])%`),
+				header("x-sub", `%DYNAMIC_METADATA(["envoy.filters.http.jwt_authn","issuer-a","sub"])%`),
+				header("x-sub", `%DYNAMIC_METADATA(["envoy.filters.http.jwt_authn","issuer-b","sub"])%`),
+			},
+			wantRemoved: []string{"x-roles", "x-sub"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			headers, removed := NewPolicyApplier("root-namespace", c.in, nil).ClaimHeaders()
+			if !reflect.DeepEqual(headers, c.wantHeaders) {
+				t.Errorf("got headers:\n%v\nwant:\n%v", spew.Sdump(headers), spew.Sdump(c.wantHeaders))
+			}
+			if !reflect.DeepEqual(removed, c.wantRemoved) {
+				t.Errorf("got removed headers %v, want %v", removed, c.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestParseClaimPath(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		{in: "sub", want: []string{"sub"}},
+		{in: "realm_access.roles", want: []string{"realm_access", "roles"}},
+		{in: "[realm_access][roles]", want: []string{"realm_access", "roles"}},
+		{in: "[a.b]", want: []string{"a.b"}},
+		{in: "", wantErr: true},
+		{in: "a..b", wantErr: true},
+		{in: "[a][]", wantErr: true},
+		{in: "groups.0", wantErr: true},
+		{in: "[groups][1]", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			got, err := parseClaimPath(c.in)
+			if c.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want error %v", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -39,36 +39,55 @@ var (
 // Builder builds Istio authorization policy to Envoy RBAC filter.
 type Builder struct {
 	trustDomainBundle  trustdomain.Bundle
+	jwtIssuers         []string
 	denyPolicies       []model.AuthorizationPolicy
 	allowPolicies      []model.AuthorizationPolicy
+	customPolicies     []model.AuthorizationPolicy
 	isIstioVersionGE15 bool
+
+	// extAuthz is the ext_authz config of the provider of the CUSTOM policies.
+	extAuthz *extAuthz
 }
 
 // New returns a new builder for the given workload with the authorization policy.
 // Returns nil if none of the authorization policies are enabled for the workload.
+// jwtIssuers are the issuers of the JWT validated for the workload, used to match the nested request claims.
 func New(trustDomainBundle trustdomain.Bundle, workload labels.Collection, namespace string,
-	policies *model.AuthorizationPolicies, isIstioVersionGE15 bool) *Builder {
-	denyPolicies, allowPolicies := policies.ListAuthorizationPolicies(namespace, workload)
-	if len(denyPolicies) == 0 && len(allowPolicies) == 0 {
+	policies *model.AuthorizationPolicies, jwtIssuers []string, isIstioVersionGE15 bool) *Builder {
+	denyPolicies, allowPolicies, customPolicies := policies.ListAuthorizationPolicies(namespace, workload)
+	if len(denyPolicies) == 0 && len(allowPolicies) == 0 && len(customPolicies) == 0 {
 		return nil
 	}
-	return &Builder{
+	b := &Builder{
 		trustDomainBundle:  trustDomainBundle,
+		jwtIssuers:         jwtIssuers,
 		denyPolicies:       denyPolicies,
 		allowPolicies:      allowPolicies,
 		isIstioVersionGE15: isIstioVersionGE15,
 	}
+	if len(customPolicies) > 0 {
+		provider, selected, rejected := selectProvider(customPolicies, extensionProviders)
+		b.customPolicies = selected
+		b.denyPolicies = append(b.denyPolicies, rejected...)
+		if provider != nil {
+			b.extAuthz = buildExtAuthz(provider)
+		}
+	}
+	return b
 }
 
 // BuilderHTTP returns the RBAC HTTP filters built from the authorization policy.
 func (b Builder) BuildHTTP() []*httppb.HttpFilter {
 	var filters []*httppb.HttpFilter
 
-	if denyConfig := build(b.denyPolicies, b.trustDomainBundle,
+	if len(b.customPolicies) > 0 {
+		filters = append(filters, b.buildCustomHTTP()...)
+	}
+	if denyConfig := build(b.denyPolicies, b.trustDomainBundle, b.jwtIssuers,
 		false /* forTCP */, true /* forDeny */, b.isIstioVersionGE15); denyConfig != nil {
 		filters = append(filters, createHTTPFilter(denyConfig))
 	}
-	if allowConfig := build(b.allowPolicies, b.trustDomainBundle,
+	if allowConfig := build(b.allowPolicies, b.trustDomainBundle, b.jwtIssuers,
 		false /* forTCP */, false /* forDeny */, b.isIstioVersionGE15); allowConfig != nil {
 		filters = append(filters, createHTTPFilter(allowConfig))
 	}
@@ -80,11 +99,14 @@ func (b Builder) BuildHTTP() []*httppb.HttpFilter {
 func (b Builder) BuildTCP() []*tcppb.Filter {
 	var filters []*tcppb.Filter
 
-	if denyConfig := build(b.denyPolicies, b.trustDomainBundle,
+	if len(b.customPolicies) > 0 {
+		filters = append(filters, b.buildCustomTCP()...)
+	}
+	if denyConfig := build(b.denyPolicies, b.trustDomainBundle, b.jwtIssuers,
 		true /* forTCP */, true /* forDeny */, b.isIstioVersionGE15); denyConfig != nil {
 		filters = append(filters, createTCPFilter(denyConfig))
 	}
-	if allowConfig := build(b.allowPolicies, b.trustDomainBundle,
+	if allowConfig := build(b.allowPolicies, b.trustDomainBundle, b.jwtIssuers,
 		true /* forTCP */, false /* forDeny */, b.isIstioVersionGE15); allowConfig != nil {
 		filters = append(filters, createTCPFilter(allowConfig))
 	}
@@ -92,7 +114,30 @@ func (b Builder) BuildTCP() []*tcppb.Filter {
 	return filters
 }
 
-func build(policies []model.AuthorizationPolicy, tdBundle trustdomain.Bundle, forTCP, forDeny, isIstioVersionGE15 bool) *rbachttppb.RBAC {
+// build returns the RBAC config for the policies of an action. Dry-run policies are built as shadow
+// rules, which Envoy evaluates and reports in its stats and dynamic metadata without enforcing them.
+func build(policies []model.AuthorizationPolicy, tdBundle trustdomain.Bundle, jwtIssuers []string,
+	forTCP, forDeny, isIstioVersionGE15 bool) *rbachttppb.RBAC {
+	var enforced, dryRun []model.AuthorizationPolicy
+	for _, policy := range policies {
+		if policy.DryRun {
+			dryRun = append(dryRun, policy)
+		} else {
+			enforced = append(enforced, policy)
+		}
+	}
+	if len(enforced) == 0 && len(dryRun) == 0 {
+		return nil
+	}
+
+	return &rbachttppb.RBAC{
+		Rules:       buildRules(enforced, tdBundle, jwtIssuers, forTCP, forDeny, isIstioVersionGE15),
+		ShadowRules: buildRules(dryRun, tdBundle, jwtIssuers, forTCP, forDeny, isIstioVersionGE15),
+	}
+}
+
+func buildRules(policies []model.AuthorizationPolicy, tdBundle trustdomain.Bundle, jwtIssuers []string,
+	forTCP, forDeny, isIstioVersionGE15 bool) *rbacpb.RBAC {
 	if len(policies) == 0 {
 		return nil
 	}
@@ -117,6 +162,7 @@ func build(policies []model.AuthorizationPolicy, tdBundle trustdomain.Bundle, fo
 				continue
 			}
 			m.MigrateTrustDomain(tdBundle)
+			m.SetJwtIssuers(jwtIssuers)
 			generated, err := m.Generate(forTCP, forDeny)
 			if err != nil {
 				authzLog.Errorf("skipped rule %s: %v", name, err)
@@ -129,7 +175,7 @@ func build(policies []model.AuthorizationPolicy, tdBundle trustdomain.Bundle, fo
 		}
 	}
 
-	return &rbachttppb.RBAC{Rules: rules}
+	return rules
 }
 
 // nolint: interfacer
@@ -148,8 +194,9 @@ func createTCPFilter(config *rbachttppb.RBAC) *tcppb.Filter {
 		return nil
 	}
 	rbacConfig := &rbactcppb.RBAC{
-		Rules:      config.Rules,
-		StatPrefix: authzmodel.RBACTCPFilterStatPrefix,
+		Rules:       config.Rules,
+		ShadowRules: config.ShadowRules,
+		StatPrefix:  authzmodel.RBACTCPFilterStatPrefix,
 	}
 	return &tcppb.Filter{
 		Name:       authzmodel.RBACTCPFilterName,
@@ -44,6 +44,9 @@ var (
 			"version": "v1",
 		},
 	}
+	testProviders = parseExtensionProviders(
+		`[{"name": "opa", "envoyExtAuthzGrpc": {"service": "opa.opa.svc.cluster.local", "port": 9191, "timeout": "1s"}},
+		{"name": "opa-http", "envoyExtAuthzHttp": {"service": "opa.opa.svc.cluster.local", "port": 8181}}]`)
 )
 
 func TestGenerator_GenerateHTTP(t *testing.T) {
@@ -72,6 +75,25 @@ func TestGenerator_GenerateHTTP(t *testing.T) {
 				"action-both-deny-out.yaml",
 				"action-both-allow-out.yaml"},
 		},
+		{
+			name:  "dry-run",
+			input: "dry-run-in.yaml",
+			want: []string{
+				"dry-run-deny-out.yaml",
+				"dry-run-allow-out.yaml"},
+		},
+		{
+			name:  "custom",
+			input: "custom-in.yaml",
+			want: []string{
+				"custom-ext-authz-out.yaml",
+				"custom-deny-out.yaml"},
+		},
+		{
+			name:  "custom-unknown-provider",
+			input: "custom-unknown-provider-in.yaml",
+			want:  []string{"custom-unknown-provider-out.yaml"},
+		},
 		{
 			name:  "all-fields",
 			input: "all-fields-in.yaml",
@@ -122,10 +144,12 @@ func TestGenerator_GenerateHTTP(t *testing.T) {
 			want:     []string{"td-aliases-source-principal-out.yaml"},
 		},
 	}
+	defer func(providers map[string]*ExtensionProvider) { extensionProviders = providers }(extensionProviders)
+	extensionProviders = testProviders
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			g := New(tc.tdBundle, httpbin, "foo", yamlPolicy(t, basePath+tc.input), !tc.isVersion14)
+			g := New(tc.tdBundle, httpbin, "foo", yamlPolicy(t, basePath+tc.input), nil, !tc.isVersion14)
 			if g == nil {
 				t.Fatalf("failed to create generator")
 			}
@@ -152,11 +176,25 @@ func TestGenerator_GenerateTCP(t *testing.T) {
 			input: "action-deny-HTTP-for-TCP-filter-in.yaml",
 			want:  []string{"action-deny-HTTP-for-TCP-filter-out.yaml"},
 		},
+		{
+			name:  "custom",
+			input: "custom-in.yaml",
+			want: []string{
+				"custom-tcp-ext-authz-out.yaml",
+				"custom-tcp-deny-out.yaml"},
+		},
+		{
+			name:  "custom-http-provider",
+			input: "custom-http-provider-in.yaml",
+			want:  []string{"custom-http-provider-tcp-out.yaml"},
+		},
 	}
+	defer func(providers map[string]*ExtensionProvider) { extensionProviders = providers }(extensionProviders)
+	extensionProviders = testProviders
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			g := New(tc.tdBundle, httpbin, "foo", yamlPolicy(t, basePath+tc.input), true)
+			g := New(tc.tdBundle, httpbin, "foo", yamlPolicy(t, basePath+tc.input), nil, true)
 			if g == nil {
 				t.Fatalf("failed to create generator")
 			}
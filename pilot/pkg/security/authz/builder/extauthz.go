@@ -0,0 +1,347 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tcppb "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v3"
+	extauthzhttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	extauthztcppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/ext_authz/v3"
+	httppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	rbactcppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/rbac/v3"
+	matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/pilot/pkg/features"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	authzmodel "istio.io/istio/pilot/pkg/security/authz/model"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pkg/config/host"
+)
+
+const (
+	extAuthzHTTPFilterName = "envoy.filters.http.ext_authz"
+	extAuthzTCPFilterName  = "envoy.filters.network.ext_authz"
+
+	// CustomPolicyPrefix is the prefix of the RBAC policy names of the CUSTOM rules, see BuildCustomRules.
+	CustomPolicyPrefix = "istio-ext-authz-"
+
+	// customTCPStatPrefix is the stat prefix of the TCP filters of the CUSTOM policies, to tell them apart from
+	// the RBAC filters of the ALLOW and DENY policies.
+	customTCPStatPrefix = "custom."
+
+	defaultExtAuthzTimeout = 600 * time.Second
+)
+
+// ExtensionProvider is an external authorization provider, referenced by the CUSTOM policies.
+// Exactly one of EnvoyExtAuthzGrpc and EnvoyExtAuthzHTTP must be set.
+//
+// The providers are configured with the PILOT_EXTENSION_PROVIDERS environment variable, for example:
+//
+//	[{"name": "opa", "envoyExtAuthzGrpc": {"service": "opa.opa.svc.cluster.local", "port": 9191, "timeout": "1s"}}]
+//
+// PILOT_EXTENSION_PROVIDERS is temporary: the format follows the extension providers of MeshConfig, which replace it
+// once the API in use has them.
+type ExtensionProvider struct {
+	Name              string                `json:"name"`
+	EnvoyExtAuthzGrpc *ExtAuthzGrpcProvider `json:"envoyExtAuthzGrpc,omitempty"`
+	EnvoyExtAuthzHTTP *ExtAuthzHTTPProvider `json:"envoyExtAuthzHttp,omitempty"`
+}
+
+// ExtAuthzGrpcProvider is an Envoy ext_authz service implementing the gRPC check API.
+type ExtAuthzGrpcProvider struct {
+	// Service is the fully qualified hostname of the service, and Port its port.
+	Service string `json:"service"`
+	Port    uint32 `json:"port"`
+	// Timeout of the check request, 600s by default.
+	Timeout string `json:"timeout,omitempty"`
+	// FailOpen allows the requests when the service can't be reached.
+	FailOpen bool `json:"failOpen,omitempty"`
+}
+
+// ExtAuthzHTTPProvider is an Envoy ext_authz service implementing the raw HTTP check API.
+type ExtAuthzHTTPProvider struct {
+	// Service is the fully qualified hostname of the service, and Port its port.
+	Service string `json:"service"`
+	Port    uint32 `json:"port"`
+	// PathPrefix is prepended to the path of the check request.
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Timeout of the check request, 600s by default.
+	Timeout string `json:"timeout,omitempty"`
+	// FailOpen allows the requests when the service can't be reached.
+	FailOpen bool `json:"failOpen,omitempty"`
+	// IncludeHeadersInCheck are the request headers sent in the check request, in addition to
+	// Host, Method, Path and Content-Length.
+	IncludeHeadersInCheck []string `json:"includeHeadersInCheck,omitempty"`
+}
+
+var extensionProviders = parseExtensionProviders(features.ExtensionProviders)
+
+// parseExtensionProviders returns the valid providers of a JSON list, by name.
+func parseExtensionProviders(in string) map[string]*ExtensionProvider {
+	if in == "" {
+		return nil
+	}
+	var providers []*ExtensionProvider
+	if err := json.Unmarshal([]byte(in), &providers); err != nil {
+		authzLog.Errorf("failed to parse extension providers: %v", err)
+		return nil
+	}
+	ret := map[string]*ExtensionProvider{}
+	for _, p := range providers {
+		if err := validateExtensionProvider(p); err != nil {
+			authzLog.Errorf("ignored extension provider %s: %v", p.Name, err)
+			continue
+		}
+		if _, f := ret[p.Name]; f {
+			authzLog.Errorf("ignored duplicate extension provider %s", p.Name)
+			continue
+		}
+		ret[p.Name] = p
+	}
+	return ret
+}
+
+func validateExtensionProvider(p *ExtensionProvider) error {
+	if p.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	var service, timeout string
+	var port uint32
+	switch {
+	case p.EnvoyExtAuthzGrpc != nil && p.EnvoyExtAuthzHTTP != nil:
+		return fmt.Errorf("only one of envoyExtAuthzGrpc and envoyExtAuthzHttp can be set")
+	case p.EnvoyExtAuthzGrpc != nil:
+		service, port, timeout = p.EnvoyExtAuthzGrpc.Service, p.EnvoyExtAuthzGrpc.Port, p.EnvoyExtAuthzGrpc.Timeout
+	case p.EnvoyExtAuthzHTTP != nil:
+		service, port, timeout = p.EnvoyExtAuthzHTTP.Service, p.EnvoyExtAuthzHTTP.Port, p.EnvoyExtAuthzHTTP.Timeout
+	default:
+		return fmt.Errorf("one of envoyExtAuthzGrpc and envoyExtAuthzHttp is required")
+	}
+	if service == "" {
+		return fmt.Errorf("service is required")
+	}
+	if port == 0 || port > 65535 {
+		return fmt.Errorf("invalid port %d", port)
+	}
+	if _, err := parseTimeout(timeout); err != nil {
+		return err
+	}
+	return nil
+}
+
+func parseTimeout(in string) (time.Duration, error) {
+	if in == "" {
+		return defaultExtAuthzTimeout, nil
+	}
+	d, err := time.ParseDuration(in)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid timeout %q", in)
+	}
+	return d, nil
+}
+
+// extAuthz holds the ext_authz filter configs of a provider. tcp is nil for HTTP providers.
+type extAuthz struct {
+	http *extauthzhttppb.ExtAuthz
+	tcp  *extauthztcppb.ExtAuthz
+}
+
+// selectProvider returns the provider of the CUSTOM policies of a workload, with the policies using it, and the
+// policies which can't be applied. A workload can only use a single provider, the first configured provider of
+// its policies. The rules of the rejected policies deny the requests they match, instead of delegating them.
+func selectProvider(policies []model.AuthorizationPolicy, providers map[string]*ExtensionProvider) (
+	provider *ExtensionProvider, selected []model.AuthorizationPolicy, rejected []model.AuthorizationPolicy) {
+	for _, policy := range policies {
+		if p, f := providers[policy.Provider]; f {
+			provider = p
+			break
+		}
+	}
+	for _, policy := range policies {
+		switch {
+		case provider == nil || providers[policy.Provider] == nil:
+			authzLog.Errorf("denying requests matching CUSTOM policy %s.%s, provider %s not found",
+				policy.Namespace, policy.Name, policy.Provider)
+			rejected = append(rejected, policy)
+		case policy.Provider != provider.Name:
+			authzLog.Errorf("denying requests matching CUSTOM policy %s.%s, only one provider can be used by a "+
+				"workload, found %s and %s", policy.Namespace, policy.Name, provider.Name, policy.Provider)
+			rejected = append(rejected, policy)
+		default:
+			selected = append(selected, policy)
+		}
+	}
+	return provider, selected, rejected
+}
+
+// buildExtAuthz returns the ext_authz configs of a provider.
+func buildExtAuthz(p *ExtensionProvider) *extAuthz {
+	if grpc := p.EnvoyExtAuthzGrpc; grpc != nil {
+		timeout, _ := parseTimeout(grpc.Timeout)
+		service := &core.GrpcService{
+			TargetSpecifier: &core.GrpcService_EnvoyGrpc_{
+				EnvoyGrpc: &core.GrpcService_EnvoyGrpc{
+					ClusterName: model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(grpc.Service), int(grpc.Port)),
+				},
+			},
+			Timeout: ptypes.DurationProto(timeout),
+		}
+		return &extAuthz{
+			http: &extauthzhttppb.ExtAuthz{
+				Services:         &extauthzhttppb.ExtAuthz_GrpcService{GrpcService: service},
+				FailureModeAllow: grpc.FailOpen,
+			},
+			tcp: &extauthztcppb.ExtAuthz{
+				StatPrefix:       customTCPStatPrefix,
+				GrpcService:      service,
+				FailureModeAllow: grpc.FailOpen,
+			},
+		}
+	}
+
+	h := p.EnvoyExtAuthzHTTP
+	timeout, _ := parseTimeout(h.Timeout)
+	var headers []*matcher.StringMatcher
+	for _, header := range h.IncludeHeadersInCheck {
+		headers = append(headers, &matcher.StringMatcher{
+			MatchPattern: &matcher.StringMatcher_Exact{Exact: header},
+		})
+	}
+	service := &extauthzhttppb.HttpService{
+		ServerUri: &core.HttpUri{
+			Uri: fmt.Sprintf("http://%s:%d", h.Service, h.Port),
+			HttpUpstreamType: &core.HttpUri_Cluster{
+				Cluster: model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(h.Service), int(h.Port)),
+			},
+			Timeout: ptypes.DurationProto(timeout),
+		},
+		PathPrefix: h.PathPrefix,
+	}
+	if len(headers) > 0 {
+		service.AuthorizationRequest = &extauthzhttppb.AuthorizationRequest{
+			AllowedHeaders: &matcher.ListStringMatcher{Patterns: headers},
+		}
+	}
+	return &extAuthz{
+		http: &extauthzhttppb.ExtAuthz{
+			Services:         &extauthzhttppb.ExtAuthz_HttpService{HttpService: service},
+			FailureModeAllow: h.FailOpen,
+		},
+	}
+}
+
+// BuildCustomRules returns the rules of the CUSTOM policies, as RBAC ALLOW rules named with CustomPolicyPrefix.
+// A request matching them is delegated to the provider. They aren't part of the generated filters, the ext_authz
+// filter is scoped to them by ScopeRoutes.
+func (b Builder) BuildCustomRules() *rbacpb.RBAC {
+	rules := buildRules(b.customPolicies, b.trustDomainBundle, b.jwtIssuers,
+		false /* forTCP */, false /* forDeny */, b.isIstioVersionGE15)
+	if rules == nil {
+		return nil
+	}
+	policies := map[string]*rbacpb.Policy{}
+	for name, policy := range rules.Policies {
+		policies[CustomPolicyPrefix+name] = policy
+	}
+	rules.Policies = policies
+	return rules
+}
+
+// buildCustomHTTP returns the ext_authz filter of the CUSTOM policies. The filter is enabled on all the routes,
+// ScopeRoutes disables it on the routes not matching the rules of the policies.
+func (b Builder) buildCustomHTTP() []*httppb.HttpFilter {
+	if b.extAuthz == nil {
+		return nil
+	}
+	return []*httppb.HttpFilter{
+		{
+			Name:       extAuthzHTTPFilterName,
+			ConfigType: &httppb.HttpFilter_TypedConfig{TypedConfig: util.MessageToAny(b.extAuthz.http)},
+		},
+	}
+}
+
+// buildCustomTCP returns the TCP filters of the CUSTOM policies, only when some of their rules can be evaluated
+// on TCP connections, i.e. rules without HTTP conditions. The network ext_authz filter can't be scoped to the
+// rules and checks every connection. It only supports gRPC providers: for HTTP providers, the connections
+// matching the rules are denied instead.
+func (b Builder) buildCustomTCP() []*tcppb.Filter {
+	if b.extAuthz == nil {
+		return nil
+	}
+	rules := buildCustomTCPRules(b.customPolicies, b.trustDomainBundle, b.jwtIssuers, b.isIstioVersionGE15)
+	if len(rules.Policies) == 0 {
+		return nil
+	}
+	if b.extAuthz.tcp != nil {
+		return []*tcppb.Filter{
+			{
+				Name:       extAuthzTCPFilterName,
+				ConfigType: &tcppb.Filter_TypedConfig{TypedConfig: util.MessageToAny(b.extAuthz.tcp)},
+			},
+		}
+	}
+	config := &rbactcppb.RBAC{
+		Rules:      rules,
+		StatPrefix: customTCPStatPrefix,
+	}
+	return []*tcppb.Filter{
+		{
+			Name:       authzmodel.RBACTCPFilterName,
+			ConfigType: &tcppb.Filter_TypedConfig{TypedConfig: util.MessageToAny(config)},
+		},
+	}
+}
+
+// buildCustomTCPRules returns the rules of the CUSTOM policies which can be evaluated on TCP connections, as
+// DENY rules. Unlike the DENY policies, rules with HTTP conditions are skipped rather than widened.
+func buildCustomTCPRules(policies []model.AuthorizationPolicy, tdBundle trustdomain.Bundle, jwtIssuers []string,
+	isIstioVersionGE15 bool) *rbacpb.RBAC {
+	rules := &rbacpb.RBAC{
+		Action:   rbacpb.RBAC_DENY,
+		Policies: map[string]*rbacpb.Policy{},
+	}
+	for _, policy := range policies {
+		for i, rule := range policy.Spec.Rules {
+			if rule == nil {
+				continue
+			}
+			name := fmt.Sprintf("ns[%s]-policy[%s]-rule[%d]", policy.Namespace, policy.Name, i)
+			m, err := authzmodel.New(rule, isIstioVersionGE15)
+			if err != nil {
+				authzLog.Errorf("skipped rule %s: %v", name, err)
+				continue
+			}
+			m.MigrateTrustDomain(tdBundle)
+			m.SetJwtIssuers(jwtIssuers)
+			generated, err := m.Generate(true /* forTCP */, false /* forDeny */)
+			if err != nil {
+				authzLog.Debugf("skipped rule %s for TCP: %v", name, err)
+				continue
+			}
+			if generated != nil {
+				rules.Policies[name] = generated
+			}
+		}
+	}
+	return rules
+}
@@ -0,0 +1,71 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+)
+
+func TestParseExtensionProviders(t *testing.T) {
+	providers := parseExtensionProviders(`[
+		{"name": "grpc", "envoyExtAuthzGrpc": {"service": "authz.foo.svc.cluster.local", "port": 9000}},
+		{"name": "http", "envoyExtAuthzHttp": {"service": "authz.foo.svc.cluster.local", "port": 8000, "timeout": "2s"}},
+		{"name": "grpc", "envoyExtAuthzHttp": {"service": "duplicate.foo.svc.cluster.local", "port": 8000}},
+		{"name": "both",
+			"envoyExtAuthzGrpc": {"service": "authz.foo.svc.cluster.local", "port": 9000},
+			"envoyExtAuthzHttp": {"service": "authz.foo.svc.cluster.local", "port": 8000}},
+		{"name": "none"},
+		{"name": "no-port", "envoyExtAuthzGrpc": {"service": "authz.foo.svc.cluster.local"}},
+		{"name": "bad-timeout", "envoyExtAuthzGrpc": {"service": "authz.foo.svc.cluster.local", "port": 9000, "timeout": "1"}}
+	]`)
+
+	if len(providers) != 2 || providers["grpc"].EnvoyExtAuthzGrpc == nil || providers["http"] == nil {
+		t.Fatalf("expected only the valid providers, got %v", providers)
+	}
+	if got := parseExtensionProviders("not json"); got != nil {
+		t.Errorf("expected no providers for invalid JSON, got %v", got)
+	}
+}
+
+func TestSelectProvider(t *testing.T) {
+	providers := parseExtensionProviders(`[
+		{"name": "grpc", "envoyExtAuthzGrpc": {"service": "authz.foo.svc.cluster.local", "port": 9000}},
+		{"name": "http", "envoyExtAuthzHttp": {"service": "authz.foo.svc.cluster.local", "port": 8000}}
+	]`)
+	policies := []model.AuthorizationPolicy{
+		{Name: "unknown", Provider: "unknown"},
+		{Name: "http-1", Provider: "http"},
+		{Name: "grpc", Provider: "grpc"},
+		{Name: "http-2", Provider: "http"},
+	}
+
+	provider, selected, rejected := selectProvider(policies, providers)
+	if provider == nil || provider.Name != "http" {
+		t.Fatalf("expected the first configured provider, got %v", provider)
+	}
+	if len(selected) != 2 || selected[0].Name != "http-1" || selected[1].Name != "http-2" {
+		t.Errorf("expected the policies of the provider to be selected, got %v", selected)
+	}
+	if len(rejected) != 2 || rejected[0].Name != "unknown" || rejected[1].Name != "grpc" {
+		t.Errorf("expected the policies of other providers to be rejected, got %v", rejected)
+	}
+
+	provider, selected, rejected = selectProvider(policies[:1], providers)
+	if provider != nil || len(selected) != 0 || len(rejected) != 1 {
+		t.Errorf("expected the policy of an unknown provider to be rejected, got %v %v %v", provider, selected, rejected)
+	}
+}
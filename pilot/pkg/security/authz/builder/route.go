@@ -0,0 +1,319 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	extauthzhttppb "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/http/ext_authz/v3"
+	matcherpb "github.com/envoyproxy/go-control-plane/envoy/type/matcher/v3"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/any"
+
+	authzpb "istio.io/api/security/v1beta1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/util"
+	"istio.io/istio/pilot/pkg/security/authz/matcher"
+)
+
+const (
+	attrRequestHeader = "request.headers"
+	attrDestPort      = "destination.port"
+
+	// maxCustomRouteMatches bounds the number of route copies made for the CUSTOM rules, above which the
+	// ext_authz filter is left enabled on all the routes.
+	maxCustomRouteMatches = 64
+)
+
+var extAuthzDisabled = util.MessageToAny(&extauthzhttppb.ExtAuthzPerRoute{
+	Override: &extauthzhttppb.ExtAuthzPerRoute_Disabled{Disabled: true},
+})
+
+// customMatch is the part of a CUSTOM rule that a route can match. path is a path of the rule, in the
+// authorization policy format, or empty for any path.
+type customMatch struct {
+	path    string
+	headers []*routepb.HeaderMatcher
+}
+
+// ScopeRoutes scopes the ext_authz filter of the CUSTOM policies to the requests matching their rules, so the
+// provider is only called for them. For each route of the config, the filter is disabled on the route and
+// enabled on copies of it, inserted before it, which only match the requests of a rule.
+//
+// Routes can only match the paths, methods, hosts and headers of the requests. The negative conditions on them are
+// matched with inverted header matchers, except notPaths suffixes for proxies matching the path without the query
+// string. The other conditions of the rules, e.g. the sources and the JWT claims, can't be matched by a route and are
+// ignored. The provider may be called for more requests than the rules match, but never less, and a route is left
+// enabled when it can't be intersected with a rule. The RBAC shadow rules in front of the ext_authz filter tell the
+// provider whether a rule actually matched, see BuildCustomRules.
+// port is the port of the workload receiving the requests, or 0 if unknown.
+func (b Builder) ScopeRoutes(config *routepb.RouteConfiguration, port int) {
+	if b.extAuthz == nil || config == nil {
+		return
+	}
+	matches, all := buildCustomMatches(b.customPolicies, port, b.isIstioVersionGE15)
+	if all {
+		return
+	}
+	for _, vhost := range config.VirtualHosts {
+		routes := make([]*routepb.Route, 0, len(vhost.Routes))
+		for _, r := range vhost.Routes {
+			routes = append(routes, scopeRoute(r, matches)...)
+		}
+		vhost.Routes = routes
+	}
+}
+
+// buildCustomMatches returns the route matches of the rules of the CUSTOM policies, or true if a rule matches
+// all the requests.
+func buildCustomMatches(policies []model.AuthorizationPolicy, port int, isIstioVersionGE15 bool) ([]customMatch, bool) {
+	var matches []customMatch
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Rules {
+			if rule == nil || !matchesPort(rule, port) {
+				continue
+			}
+			headers := [][]*routepb.HeaderMatcher{nil}
+			for _, when := range rule.When {
+				if strings.HasPrefix(when.Key, attrRequestHeader) {
+					name := strings.TrimSuffix(strings.TrimPrefix(when.Key, attrRequestHeader+"["), "]")
+					headers = appendHeader(headers, name, when.Values)
+					headers = appendNotHeader(headers, name, when.NotValues)
+				}
+			}
+
+			operations := []*authzpb.Operation{nil}
+			if len(rule.To) > 0 {
+				operations = operations[:0]
+				for _, to := range rule.To {
+					operations = append(operations, to.Operation)
+				}
+			}
+			for _, op := range operations {
+				opHeaders, paths := headers, []string{""}
+				if op != nil {
+					if port != 0 && (len(op.Ports) > 0 && !contains(op.Ports, strconv.Itoa(port)) ||
+						contains(op.NotPorts, strconv.Itoa(port))) {
+						continue
+					}
+					opHeaders = appendHeader(opHeaders, ":method", op.Methods)
+					opHeaders = appendHeader(opHeaders, ":authority", op.Hosts)
+					opHeaders = appendNotHeader(opHeaders, ":method", op.NotMethods)
+					opHeaders = appendNotHeader(opHeaders, ":authority", op.NotHosts)
+					opHeaders = appendNotHeader(opHeaders, ":path", notPathHeaders(op.NotPaths, isIstioVersionGE15))
+					if len(op.Paths) > 0 {
+						paths = op.Paths
+					}
+				}
+				for _, path := range paths {
+					if path == "*" {
+						path = ""
+					}
+					if !isIstioVersionGE15 && (strings.HasPrefix(path, "*") || strings.Contains(path, "?")) {
+						// Older proxies match the path with the query string.
+						path = ""
+					}
+					for _, h := range opHeaders {
+						if path == "" && len(h) == 0 {
+							return nil, true
+						}
+						matches = append(matches, customMatch{path: path, headers: h})
+					}
+				}
+			}
+		}
+	}
+	if len(matches) > maxCustomRouteMatches {
+		names := make([]string, 0, len(policies))
+		for _, policy := range policies {
+			names = append(names, policy.Namespace+"/"+policy.Name)
+		}
+		authzLog.Warnf("ext_authz filter enabled on all routes, the CUSTOM policies %v have %d route matches, "+
+			"more than %d: the provider is called for all the requests", names, len(matches), maxCustomRouteMatches)
+		return nil, true
+	}
+	return matches, false
+}
+
+// matchesPort returns false if the destination.port conditions of a rule exclude the port.
+func matchesPort(rule *authzpb.Rule, port int) bool {
+	if port == 0 {
+		return true
+	}
+	for _, when := range rule.When {
+		if when.Key != attrDestPort {
+			continue
+		}
+		if len(when.Values) > 0 && !contains(when.Values, strconv.Itoa(port)) || contains(when.NotValues, strconv.Itoa(port)) {
+			return false
+		}
+	}
+	return true
+}
+
+// notPathHeaders returns the notPaths of an operation that can be matched on the :path header. Proxies of Istio 1.5
+// and later match the path without the query string: a :path header excluded by an exact or prefix value has an
+// excluded path, but a suffix value may exclude requests whose query string ends like the path, so these are
+// ignored.
+func notPathHeaders(notPaths []string, isIstioVersionGE15 bool) []string {
+	if !isIstioVersionGE15 {
+		return notPaths
+	}
+	var ret []string
+	for _, path := range notPaths {
+		if path != "*" && (strings.HasPrefix(path, "*") || strings.Contains(path, "?")) {
+			continue
+		}
+		ret = append(ret, path)
+	}
+	return ret
+}
+
+// appendHeader returns the header matcher lists, each extended with a matcher for one of the values.
+func appendHeader(lists [][]*routepb.HeaderMatcher, name string, values []string) [][]*routepb.HeaderMatcher {
+	if len(values) == 0 {
+		return lists
+	}
+	ret := make([][]*routepb.HeaderMatcher, 0, len(lists)*len(values))
+	for _, list := range lists {
+		for _, v := range values {
+			extended := make([]*routepb.HeaderMatcher, 0, len(list)+1)
+			extended = append(extended, list...)
+			ret = append(ret, append(extended, matcher.HeaderMatcher(name, v)))
+		}
+	}
+	return ret
+}
+
+// appendNotHeader returns the header matcher lists, each extended with an inverted matcher for every value.
+func appendNotHeader(lists [][]*routepb.HeaderMatcher, name string, values []string) [][]*routepb.HeaderMatcher {
+	if len(values) == 0 {
+		return lists
+	}
+	ret := make([][]*routepb.HeaderMatcher, 0, len(lists))
+	for _, list := range lists {
+		extended := make([]*routepb.HeaderMatcher, 0, len(list)+len(values))
+		extended = append(extended, list...)
+		for _, v := range values {
+			m := matcher.HeaderMatcher(name, v)
+			m.InvertMatch = true
+			extended = append(extended, m)
+		}
+		ret = append(ret, extended)
+	}
+	return ret
+}
+
+// scopeRoute returns the copies of a route matching the CUSTOM rules, with the ext_authz filter enabled, followed
+// by the route with the filter disabled. The route is returned unchanged if it can't be intersected with a rule.
+func scopeRoute(r *routepb.Route, matches []customMatch) []*routepb.Route {
+	var ret []*routepb.Route
+	for _, m := range matches {
+		match, ok := intersect(r.Match, m)
+		if !ok {
+			return []*routepb.Route{r}
+		}
+		if match != nil {
+			scoped := proto.Clone(r).(*routepb.Route)
+			scoped.Match = match
+			ret = append(ret, scoped)
+		}
+	}
+
+	disabled := proto.Clone(r).(*routepb.Route)
+	if disabled.TypedPerFilterConfig == nil {
+		disabled.TypedPerFilterConfig = map[string]*any.Any{}
+	}
+	disabled.TypedPerFilterConfig[extAuthzHTTPFilterName] = extAuthzDisabled
+	return append(ret, disabled)
+}
+
+// intersect returns the route match of the requests matching both a route and a CUSTOM rule, nil if there are
+// none, or false if it can't be expressed as a route match.
+func intersect(in *routepb.RouteMatch, m customMatch) (*routepb.RouteMatch, bool) {
+	if in == nil {
+		return nil, false
+	}
+	out := proto.Clone(in).(*routepb.RouteMatch)
+	out.Headers = append(out.Headers, m.headers...)
+	if m.path == "" {
+		return out, true
+	}
+	if in.CaseSensitive != nil && !in.CaseSensitive.Value {
+		return nil, false
+	}
+
+	switch ps := in.PathSpecifier.(type) {
+	case *routepb.RouteMatch_Path:
+		if !matchesPath(m.path, ps.Path) {
+			return nil, true
+		}
+	case *routepb.RouteMatch_Prefix:
+		prefix := ps.Prefix
+		switch {
+		case strings.HasPrefix(m.path, "*"):
+			if prefix != "" && prefix != "/" {
+				return nil, false
+			}
+			out.PathSpecifier = &routepb.RouteMatch_SafeRegex{SafeRegex: &matcherpb.RegexMatcher{
+				EngineType: &matcherpb.RegexMatcher_GoogleRe2{GoogleRe2: &matcherpb.RegexMatcher_GoogleRE2{}},
+				Regex:      ".*" + regexp.QuoteMeta(strings.TrimPrefix(m.path, "*")),
+			}}
+		case strings.HasSuffix(m.path, "*"):
+			rulePrefix := strings.TrimSuffix(m.path, "*")
+			switch {
+			case strings.HasPrefix(rulePrefix, prefix):
+				out.PathSpecifier = &routepb.RouteMatch_Prefix{Prefix: rulePrefix}
+			case strings.HasPrefix(prefix, rulePrefix):
+				// All the paths of the route match the rule.
+			default:
+				return nil, true
+			}
+		default:
+			if !strings.HasPrefix(m.path, prefix) {
+				return nil, true
+			}
+			out.PathSpecifier = &routepb.RouteMatch_Path{Path: m.path}
+		}
+	default:
+		return nil, false
+	}
+	return out, true
+}
+
+// matchesPath returns true if a path matches a path of an authorization policy.
+func matchesPath(v, path string) bool {
+	switch {
+	case strings.HasPrefix(v, "*"):
+		return strings.HasSuffix(path, strings.TrimPrefix(v, "*"))
+	case strings.HasSuffix(v, "*"):
+		return strings.HasPrefix(path, strings.TrimSuffix(v, "*"))
+	default:
+		return v == path
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	routepb "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+
+	authzpb "istio.io/api/security/v1beta1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/util/protomarshal"
+)
+
+func TestScopeRoutes(t *testing.T) {
+	prefixRoute := &routepb.Route{Match: &routepb.RouteMatch{PathSpecifier: &routepb.RouteMatch_Prefix{Prefix: "/"}}}
+	apiRoute := &routepb.Route{Match: &routepb.RouteMatch{PathSpecifier: &routepb.RouteMatch_Prefix{Prefix: "/api/"}}}
+	exactRoute := &routepb.Route{Match: &routepb.RouteMatch{PathSpecifier: &routepb.RouteMatch_Path{Path: "/api/v1"}}}
+
+	testCases := []struct {
+		name  string
+		rules string
+		port  int
+		route *routepb.Route
+		want  []string
+	}{
+		{
+			name: "paths-and-methods",
+			rules: `
+rules:
+- to:
+  - operation:
+      paths: ["/admin/*"]
+      methods: ["GET", "POST"]`,
+			route: prefixRoute,
+			want: []string{
+				"prefix:/admin/ [:method=GET] enabled",
+				"prefix:/admin/ [:method=POST] enabled",
+				"prefix:/ [] disabled",
+			},
+		},
+		{
+			name: "request-headers",
+			rules: `
+rules:
+- to:
+  - operation:
+      paths: ["*.png", "/api/v1"]
+  when:
+  - key: request.headers[x-check]
+    values: ["yes"]`,
+			route: prefixRoute,
+			want: []string{
+				"regex:.*\\.png [x-check=yes] enabled",
+				"path:/api/v1 [x-check=yes] enabled",
+				"prefix:/ [] disabled",
+			},
+		},
+		{
+			name: "route-prefix",
+			rules: `
+rules:
+- to:
+  - operation:
+      paths: ["/api/v1/*", "/*", "/admin"]`,
+			route: apiRoute,
+			want: []string{
+				"prefix:/api/v1/ [] enabled",
+				"prefix:/api/ [] enabled",
+				"prefix:/api/ [] disabled",
+			},
+		},
+		{
+			name: "route-path",
+			rules: `
+rules:
+- to:
+  - operation:
+      paths: ["/api/*", "/admin/*"]`,
+			route: exactRoute,
+			want: []string{
+				"path:/api/v1 [] enabled",
+				"path:/api/v1 [] disabled",
+			},
+		},
+		{
+			name: "other-port",
+			rules: `
+rules:
+- to:
+  - operation:
+      ports: ["8080"]
+      paths: ["/admin/*"]`,
+			port:  9080,
+			route: prefixRoute,
+			want:  []string{"prefix:/ [] disabled"},
+		},
+		{
+			name: "source-only",
+			rules: `
+rules:
+- from:
+  - source:
+      namespaces: ["foo"]`,
+			route: prefixRoute,
+			want:  []string{"prefix:/ [] enabled"},
+		},
+		{
+			name: "negative-conditions",
+			rules: `
+rules:
+- to:
+  - operation:
+      paths: ["/admin/*"]
+      notMethods: ["GET"]
+      notPaths: ["/admin/public/*", "*.css"]
+  when:
+  - key: request.headers[x-skip]
+    notValues: ["yes"]`,
+			route: prefixRoute,
+			want: []string{
+				"prefix:/admin/ [!x-skip=yes !:method=GET !:path=/admin/public/*] enabled",
+				"prefix:/ [] disabled",
+			},
+		},
+		{
+			name: "not-ports",
+			rules: `
+rules:
+- to:
+  - operation:
+      notPorts: ["9080"]
+      paths: ["/admin/*"]
+- to:
+  - operation:
+      paths: ["/api/*"]
+  when:
+  - key: destination.port
+    notValues: ["9080"]`,
+			port:  9080,
+			route: prefixRoute,
+			want:  []string{"prefix:/ [] disabled"},
+		},
+		{
+			name: "suffix-in-route-prefix",
+			rules: `
+rules:
+- to:
+  - operation:
+      paths: ["*.png"]`,
+			route: apiRoute,
+			want:  []string{"prefix:/api/ [] enabled"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &authzpb.AuthorizationPolicy{}
+			if err := protomarshal.ApplyYAML(tc.rules, spec); err != nil {
+				t.Fatalf("failed to parse rules: %v", err)
+			}
+			b := Builder{
+				customPolicies:     []model.AuthorizationPolicy{{Name: "ext-authz", Namespace: "foo", Spec: spec}},
+				isIstioVersionGE15: true,
+				extAuthz:           &extAuthz{},
+			}
+			config := &routepb.RouteConfiguration{
+				VirtualHosts: []*routepb.VirtualHost{{Routes: []*routepb.Route{tc.route}}},
+			}
+			b.ScopeRoutes(config, tc.port)
+
+			var got []string
+			for _, r := range config.VirtualHosts[0].Routes {
+				got = append(got, describeRoute(r))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got routes %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func describeRoute(r *routepb.Route) string {
+	var path string
+	switch ps := r.Match.PathSpecifier.(type) {
+	case *routepb.RouteMatch_Prefix:
+		path = "prefix:" + ps.Prefix
+	case *routepb.RouteMatch_Path:
+		path = "path:" + ps.Path
+	case *routepb.RouteMatch_SafeRegex:
+		path = "regex:" + ps.SafeRegex.Regex
+	}
+	var headers []string
+	for _, h := range r.Match.Headers {
+		value := h.GetExactMatch()
+		switch {
+		case h.GetPrefixMatch() != "":
+			value = h.GetPrefixMatch() + "*"
+		case h.GetSuffixMatch() != "":
+			value = "*" + h.GetSuffixMatch()
+		}
+		name := h.Name
+		if h.InvertMatch {
+			name = "!" + name
+		}
+		headers = append(headers, name+"="+value)
+	}
+	state := "enabled"
+	if _, f := r.TypedPerFilterConfig[extAuthzHTTPFilterName]; f {
+		state = "disabled"
+	}
+	return fmt.Sprintf("%s %v %s", path, headers, state)
+}
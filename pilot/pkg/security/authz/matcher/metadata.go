@@ -38,6 +38,28 @@ func MetadataStringMatcher(filter, key string, m *matcherpb.StringMatcher) *matc
 	}
 }
 
+// MetadataNestedStringMatcher creates a metadata string matcher for the given path keys and string matcher.
+func MetadataNestedStringMatcher(filter string, keys []string, m *matcherpb.StringMatcher) *matcherpb.MetadataMatcher {
+	paths := make([]*matcherpb.MetadataMatcher_PathSegment, 0, len(keys))
+	for _, k := range keys {
+		paths = append(paths, &matcherpb.MetadataMatcher_PathSegment{
+			Segment: &matcherpb.MetadataMatcher_PathSegment_Key{
+				Key: k,
+			},
+		})
+	}
+
+	return &matcherpb.MetadataMatcher{
+		Filter: filter,
+		Path:   paths,
+		Value: &matcherpb.ValueMatcher{
+			MatchPattern: &matcherpb.ValueMatcher_StringMatch{
+				StringMatch: m,
+			},
+		},
+	}
+}
+
 // MetadataListMatcher creates a metadata list matcher for the given path keys and value.
 func MetadataListMatcher(filter string, keys []string, value string) *matcherpb.MetadataMatcher {
 	listMatcher := &matcherpb.ListMatcher{
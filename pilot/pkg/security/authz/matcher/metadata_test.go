@@ -49,6 +49,39 @@ func TestMetadataStringMatcher(t *testing.T) {
 	}
 }
 
+func TestMetadataNestedStringMatcher(t *testing.T) {
+	matcher := &matcherpb.StringMatcher{
+		MatchPattern: &matcherpb.StringMatcher_Exact{
+			Exact: "exact",
+		},
+	}
+	actual := MetadataNestedStringMatcher("envoy.filters.http.jwt_authn", []string{"issuer", "key"}, matcher)
+	expect := &matcherpb.MetadataMatcher{
+		Filter: "envoy.filters.http.jwt_authn",
+		Path: []*matcherpb.MetadataMatcher_PathSegment{
+			{
+				Segment: &matcherpb.MetadataMatcher_PathSegment_Key{
+					Key: "issuer",
+				},
+			},
+			{
+				Segment: &matcherpb.MetadataMatcher_PathSegment_Key{
+					Key: "key",
+				},
+			},
+		},
+		Value: &matcherpb.ValueMatcher{
+			MatchPattern: &matcherpb.ValueMatcher_StringMatch{
+				StringMatch: matcher,
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(*actual, *expect) {
+		t.Errorf("want %s, got %s", expect.String(), actual.String())
+	}
+}
+
 func TestMetadataListMatcher(t *testing.T) {
 	getWant := func(regex string) matcherpb.MetadataMatcher {
 		return matcherpb.MetadataMatcher{
@@ -16,6 +16,7 @@ package model
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"istio.io/istio/pilot/pkg/security/authz/matcher"
@@ -204,25 +205,51 @@ func (requestHeaderGenerator) principal(key, value string, forTCP bool) (*rbacpb
 }
 
 type requestClaimGenerator struct {
+	// issuers of the JWT validated for the workload, used to match the nested claims.
+	issuers []string
 }
 
 func (requestClaimGenerator) permission(_, _ string, _ bool) (*rbacpb.Permission, error) {
 	return nil, fmt.Errorf("unimplemented")
 }
 
-func (requestClaimGenerator) principal(key, value string, forTCP bool) (*rbacpb.Principal, error) {
+func (g requestClaimGenerator) principal(key, value string, forTCP bool) (*rbacpb.Principal, error) {
 	if forTCP {
 		return nil, fmt.Errorf("%s must not be used in TCP", key)
 	}
 
-	claim, err := extractNameInBrackets(strings.TrimPrefix(key, attrRequestClaims))
+	// Nested claims are in consecutive brackets, e.g. "request.auth.claims[realm_access][roles]".
+	claims, err := extractNameInNestedBrackets(strings.TrimPrefix(key, attrRequestClaims))
 	if err != nil {
 		return nil, err
 	}
-	// Generate a metadata list matcher for the given path keys and value.
-	// On proxy side, the value should be of list type.
-	m := matcher.MetadataListMatcher(sm.AuthnFilterName, []string{attrRequestClaims, claim}, value)
-	return principalMetadata(m), nil
+	if len(claims) == 1 {
+		// Generate a metadata list matcher for the given path keys and value.
+		// On proxy side, the value should be of list type.
+		m := matcher.MetadataListMatcher(sm.AuthnFilterName, []string{attrRequestClaims, claims[0]}, value)
+		return principalMetadata(m), nil
+	}
+
+	// The Istio authn filter only exports the top level claims. Nested claims are matched in the JWT payload,
+	// which the JWT filter sets in its dynamic metadata under the issuer. List indexes can't be matched, as the
+	// metadata path only supports keys.
+	for _, claim := range claims {
+		if _, err := strconv.Atoi(claim); err == nil {
+			return nil, fmt.Errorf("%s: list indexes are not supported", key)
+		}
+	}
+	if len(g.issuers) == 0 {
+		return nil, fmt.Errorf("%s: nested claims require a RequestAuthentication for the workload", key)
+	}
+	var principals []*rbacpb.Principal
+	for _, issuer := range g.issuers {
+		path := append([]string{issuer}, claims...)
+		// The claim is either a list or a string.
+		principals = append(principals,
+			principalMetadata(matcher.MetadataListMatcher(sm.EnvoyJwtFilterName, path, value)),
+			principalMetadata(matcher.MetadataNestedStringMatcher(sm.EnvoyJwtFilterName, path, matcher.StringMatcher(value))))
+	}
+	return principalOr(principals), nil
 }
 
 type hostGenerator struct {
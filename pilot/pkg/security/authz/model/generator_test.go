@@ -214,6 +214,35 @@ func TestGenerator(t *testing.T) {
           value:
             listMatch:
               oneOf:
+                stringMatch:
+                  exact: foo`),
+		},
+		{
+			name:  "requestNestedClaimGenerator",
+			g:     requestClaimGenerator{issuers: []string{"https://example.com"}},
+			key:   "request.auth.claims[bar][baz]",
+			value: "foo",
+			want: yamlPrincipal(t, `
+         orIds:
+          ids:
+          - metadata:
+              filter: envoy.filters.http.jwt_authn
+              path:
+              - key: https://example.com
+              - key: bar
+              - key: baz
+              value:
+                listMatch:
+                  oneOf:
+                    stringMatch:
+                      exact: foo
+          - metadata:
+              filter: envoy.filters.http.jwt_authn
+              path:
+              - key: https://example.com
+              - key: bar
+              - key: baz
+              value:
                 stringMatch:
                   exact: foo`),
 		},
@@ -285,6 +314,32 @@ func TestGenerator(t *testing.T) {
 	}
 }
 
+func TestRequestClaimGeneratorErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		g    requestClaimGenerator
+		key  string
+	}{
+		{
+			name: "nested-claim-without-issuer",
+			key:  "request.auth.claims[bar][baz]",
+		},
+		{
+			name: "list-index",
+			g:    requestClaimGenerator{issuers: []string{"https://example.com"}},
+			key:  "request.auth.claims[groups][0]",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := tc.g.principal(tc.key, "foo", false); err == nil {
+				t.Errorf("expected error, got %v", got)
+			}
+		})
+	}
+}
+
 func yamlPermission(t *testing.T, yaml string) *rbacpb.Permission {
 	t.Helper()
 	p := &rbacpb.Permission{}
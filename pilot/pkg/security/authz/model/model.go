@@ -157,6 +157,17 @@ func (m *Model) MigrateTrustDomain(tdBundle trustdomain.Bundle) {
 	}
 }
 
+// SetJwtIssuers sets the issuers of the JWT validated for the workload, used to match the nested request claims.
+func (m *Model) SetJwtIssuers(issuers []string) {
+	for _, p := range m.principals {
+		for _, r := range p.rules {
+			if _, ok := r.g.(requestClaimGenerator); ok {
+				r.g = requestClaimGenerator{issuers: issuers}
+			}
+		}
+	}
+}
+
 // Generate generates the Envoy RBAC config from the model.
 func (m Model) Generate(forTCP, forDeny bool) (*rbacpb.Policy, error) {
 	var permissions []*rbacpb.Permission
@@ -35,3 +35,20 @@ func extractNameInBrackets(s string) (string, error) {
 	}
 	return strings.TrimPrefix(strings.TrimSuffix(s, "]"), "["), nil
 }
+
+// extractNameInNestedBrackets returns the names of a nested key, e.g. ["a", "b"] for "[a][b]".
+func extractNameInNestedBrackets(s string) ([]string, error) {
+	name, err := extractNameInBrackets(s)
+	if err != nil {
+		return nil, err
+	}
+	names := strings.Split(name, "][")
+	if len(names) > 1 {
+		for _, n := range names {
+			if n == "" {
+				return nil, fmt.Errorf("expecting format [<NAME>][<NAME>], but found %s", s)
+			}
+		}
+	}
+	return names, nil
+}
@@ -15,6 +15,7 @@
 package model
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -86,3 +87,28 @@ func TestExtractNameInBrackets(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractNameInNestedBrackets(t *testing.T) {
+	cases := []struct {
+		s      string
+		expect []string
+		err    bool
+	}{
+		{s: "[good]", expect: []string{"good"}},
+		{s: "[good][better]", expect: []string{"good", "better"}},
+		{s: "[a][b][c]", expect: []string{"a", "b", "c"}},
+		{s: "[good][]", err: true},
+		{s: "[good][better", err: true},
+		{s: "bad", err: true},
+	}
+
+	for _, c := range cases {
+		s, err := extractNameInNestedBrackets(c.s)
+		if !reflect.DeepEqual(s, c.expect) {
+			t.Errorf("%s: expecting %v but found %v", c.s, c.expect, s)
+		}
+		if c.err != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", c.s, err)
+		}
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/spiffe"
 )
 
 const (
@@ -199,6 +200,32 @@ func ApplyToCommonTLSContext(tlsContext *tls.CommonTlsContext, metadata *model.N
 			},
 		}
 	}
+	ApplyCRLToCommonTLSContext(tlsContext)
+}
+
+// ApplyTrustDomainsToCommonTLSContext restricts the peer certificates accepted by an `ISTIO_MUTUAL` TLS context
+// to the SPIFFE IDs of the given trust domains. Envoy can't bind a trust domain to its own root certificates, so
+// this only makes sure that peers chaining to a federated root present an identity of a known trust domain.
+// Validation contexts which already match subject alt names are left as is.
+func ApplyTrustDomainsToCommonTLSContext(tlsContext *tls.CommonTlsContext, trustDomains []string) {
+	if len(trustDomains) == 0 {
+		return
+	}
+	var validationContext *tls.CertificateValidationContext
+	switch v := tlsContext.GetValidationContextType().(type) {
+	case *tls.CommonTlsContext_CombinedValidationContext:
+		validationContext = v.CombinedValidationContext.GetDefaultValidationContext()
+	case *tls.CommonTlsContext_ValidationContext:
+		validationContext = v.ValidationContext
+	}
+	if validationContext == nil || len(validationContext.MatchSubjectAltNames) > 0 {
+		return
+	}
+	prefixes := make([]string, 0, len(trustDomains))
+	for _, td := range trustDomains {
+		prefixes = append(prefixes, spiffe.URIPrefix+td+"/")
+	}
+	validationContext.MatchSubjectAltNames = util.StringToPrefixMatch(prefixes)
 }
 
 // ApplyCustomSDSToCommonTLSContext applies the customized sds to CommonTlsContext
@@ -364,3 +364,73 @@ func TestApplyToCommonTLSContext(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyTrustDomainsToCommonTLSContext(t *testing.T) {
+	sanPrefixes := util.StringToPrefixMatch([]string{"spiffe://cluster.local/", "spiffe://foo.com/"})
+	testCases := []struct {
+		name         string
+		tlsContext   *auth.CommonTlsContext
+		trustDomains []string
+		want         *auth.CertificateValidationContext
+	}{
+		{
+			name: "SDS",
+			tlsContext: &auth.CommonTlsContext{
+				ValidationContextType: &auth.CommonTlsContext_CombinedValidationContext{
+					CombinedValidationContext: &auth.CommonTlsContext_CombinedCertificateValidationContext{
+						DefaultValidationContext: &auth.CertificateValidationContext{},
+					},
+				},
+			},
+			trustDomains: []string{"cluster.local", "foo.com"},
+			want:         &auth.CertificateValidationContext{MatchSubjectAltNames: sanPrefixes},
+		},
+		{
+			name: "file mounted certs",
+			tlsContext: &auth.CommonTlsContext{
+				ValidationContextType: &auth.CommonTlsContext_ValidationContext{
+					ValidationContext: &auth.CertificateValidationContext{},
+				},
+			},
+			trustDomains: []string{"cluster.local", "foo.com"},
+			want:         &auth.CertificateValidationContext{MatchSubjectAltNames: sanPrefixes},
+		},
+		{
+			name: "no federation",
+			tlsContext: &auth.CommonTlsContext{
+				ValidationContextType: &auth.CommonTlsContext_ValidationContext{
+					ValidationContext: &auth.CertificateValidationContext{},
+				},
+			},
+			want: &auth.CertificateValidationContext{},
+		},
+		{
+			name: "subject alt names already set",
+			tlsContext: &auth.CommonTlsContext{
+				ValidationContextType: &auth.CommonTlsContext_ValidationContext{
+					ValidationContext: &auth.CertificateValidationContext{
+						MatchSubjectAltNames: util.StringToExactMatch([]string{"spiffe://cluster.local/ns/foo/sa/bar"}),
+					},
+				},
+			},
+			trustDomains: []string{"cluster.local", "foo.com"},
+			want: &auth.CertificateValidationContext{
+				MatchSubjectAltNames: util.StringToExactMatch([]string{"spiffe://cluster.local/ns/foo/sa/bar"}),
+			},
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			ApplyTrustDomainsToCommonTLSContext(test.tlsContext, test.trustDomains)
+
+			got := test.tlsContext.GetValidationContext()
+			if got == nil {
+				got = test.tlsContext.GetCombinedValidationContext().GetDefaultValidationContext()
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got() = %v, want %v", spew.Sdump(got), spew.Sdump(test.want))
+			}
+		})
+	}
+}
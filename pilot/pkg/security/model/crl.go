@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sync/atomic"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+)
+
+// crls holds the PEM encoded CRLs of the Istio CA.
+var crls atomic.Value
+
+// SetCertificateRevocationList sets the PEM encoded CRLs checked by the proxies on `ISTIO_MUTUAL` connections. Envoy
+// checks the whole peer certificate chain, so the list must hold a CRL of every CA of the chain. An empty list
+// disables the check.
+func SetCertificateRevocationList(pem []byte) {
+	crls.Store(pem)
+}
+
+// CertificateRevocationList returns the PEM encoded CRLs set by SetCertificateRevocationList.
+func CertificateRevocationList() []byte {
+	pem, _ := crls.Load().([]byte)
+	return pem
+}
+
+// ApplyCRLToCommonTLSContext sets the CRLs of the Istio CA in an `ISTIO_MUTUAL` TLS context. When the roots are
+// fetched over SDS, the CRLs are set in the default validation context, which Envoy merges with the SDS one.
+func ApplyCRLToCommonTLSContext(tlsContext *tls.CommonTlsContext) {
+	pem := CertificateRevocationList()
+	if len(pem) == 0 {
+		return
+	}
+	var validationContext *tls.CertificateValidationContext
+	switch v := tlsContext.GetValidationContextType().(type) {
+	case *tls.CommonTlsContext_CombinedValidationContext:
+		validationContext = v.CombinedValidationContext.GetDefaultValidationContext()
+	case *tls.CommonTlsContext_ValidationContext:
+		validationContext = v.ValidationContext
+	}
+	if validationContext == nil {
+		return
+	}
+	validationContext.Crl = &core.DataSource{
+		Specifier: &core.DataSource_InlineBytes{
+			InlineBytes: pem,
+		},
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"bytes"
+	"testing"
+
+	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+)
+
+func TestApplyCRLToCommonTLSContext(t *testing.T) {
+	crl := []byte("-----BEGIN X509 CRL-----\n-----END X509 CRL-----\n")
+	defer SetCertificateRevocationList(nil)
+
+	cases := []struct {
+		name    string
+		context func() *tls.CommonTlsContext
+		get     func(*tls.CommonTlsContext) *tls.CertificateValidationContext
+	}{
+		{
+			name: "validation context",
+			context: func() *tls.CommonTlsContext {
+				return &tls.CommonTlsContext{ValidationContextType: ConstructValidationContext("/etc/certs/root-cert.pem", nil)}
+			},
+			get: func(c *tls.CommonTlsContext) *tls.CertificateValidationContext {
+				return c.GetValidationContext()
+			},
+		},
+		{
+			name: "combined validation context",
+			context: func() *tls.CommonTlsContext {
+				return &tls.CommonTlsContext{
+					ValidationContextType: &tls.CommonTlsContext_CombinedValidationContext{
+						CombinedValidationContext: &tls.CommonTlsContext_CombinedCertificateValidationContext{
+							DefaultValidationContext:         &tls.CertificateValidationContext{},
+							ValidationContextSdsSecretConfig: ConstructSdsSecretConfig(SDSRootResourceName, "/etc/istio/proxy/SDS"),
+						},
+					},
+				}
+			},
+			get: func(c *tls.CommonTlsContext) *tls.CertificateValidationContext {
+				return c.GetCombinedValidationContext().GetDefaultValidationContext()
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			SetCertificateRevocationList(nil)
+			tlsContext := c.context()
+			ApplyCRLToCommonTLSContext(tlsContext)
+			if got := c.get(tlsContext).GetCrl(); got != nil {
+				t.Errorf("got CRL %v without a revocation list", got)
+			}
+
+			SetCertificateRevocationList(crl)
+			ApplyCRLToCommonTLSContext(tlsContext)
+			if got := c.get(tlsContext).GetCrl().GetInlineBytes(); !bytes.Equal(got, crl) {
+				t.Errorf("got CRL %q, want %q", got, crl)
+			}
+		})
+	}
+}
@@ -74,8 +74,10 @@ func (t Bundle) ReplaceTrustDomainAliases(principals []string) []string {
 			// Generate configuration for trust domain and trust domain aliases.
 			principalsIncludingAliases = append(principalsIncludingAliases, t.replaceTrustDomains(principal, trustDomainFromPrincipal)...)
 		} else {
-			authzLog.Warnf("Trust domain %s from principal %s does not match the current trust "+
-				"domain or its aliases", trustDomainFromPrincipal, principal)
+			if !isKeyInList(trustDomainFromPrincipal, FederatedTrustDomains()) {
+				authzLog.Warnf("Trust domain %s from principal %s does not match the current trust "+
+					"domain or its aliases", trustDomainFromPrincipal, principal)
+			}
 			// If the trust domain from the existing doesn't match with the new trust domain aliases or "cluster.local",
 			// keep the policy as it is.
 			principalsIncludingAliases = append(principalsIncludingAliases, principal)
@@ -84,6 +86,20 @@ func (t Bundle) ReplaceTrustDomainAliases(principals []string) []string {
 	return principalsIncludingAliases
 }
 
+// MatchesTrustDomain returns true if the principal can match an identity of the mesh: its trust domain is not
+// enforced, or is "cluster.local", the local trust domain, one of its aliases or a federated trust domain.
+func (t Bundle) MatchesTrustDomain(principal string) bool {
+	if !isTrustDomainBeingEnforced(principal) {
+		return true
+	}
+	trustDomain, err := getTrustDomainFromSpiffeIdentity(principal)
+	if err != nil {
+		return true
+	}
+	return trustDomain == constants.DefaultKubernetesDomain || stringMatch(trustDomain, t.TrustDomains) ||
+		isKeyInList(trustDomain, FederatedTrustDomains())
+}
+
 // replaceTrustDomains replace the given principal's trust domain with the trust domains from the
 // trustDomains list and return the new principals.
 func (t Bundle) replaceTrustDomains(principal, trustDomainFromPrincipal string) []string {
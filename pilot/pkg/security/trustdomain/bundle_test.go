@@ -176,3 +176,31 @@ func TestIsTrustDomainBeingEnforced(t *testing.T) {
 		}
 	}
 }
+
+func TestMatchesTrustDomain(t *testing.T) {
+	bundle := NewBundle("td1", []string{"td2", "*-td"})
+	cases := []struct {
+		principal string
+		want      bool
+	}{
+		{principal: "td1/ns/foo/sa/bar", want: true},
+		{principal: "td2/ns/foo/sa/bar", want: true},
+		{principal: "old-td/ns/foo/sa/bar", want: true},
+		{principal: "cluster.local/ns/foo/sa/bar", want: true},
+		{principal: "*/ns/foo/sa/bar", want: true},
+		{principal: "td*/ns/foo/sa/bar", want: true},
+		{principal: "sa/bar", want: true},
+		{principal: "td3/ns/foo/sa/bar", want: false},
+	}
+
+	for _, c := range cases {
+		if got := bundle.MatchesTrustDomain(c.principal); got != c.want {
+			t.Errorf("MatchesTrustDomain(%s): expect %v, but got %v", c.principal, c.want, got)
+		}
+	}
+	SetFederatedTrustDomains([]string{"td3"})
+	defer SetFederatedTrustDomains(nil)
+	if !bundle.MatchesTrustDomain("td3/ns/foo/sa/bar") {
+		t.Errorf("MatchesTrustDomain(td3/ns/foo/sa/bar): expect true for a federated trust domain")
+	}
+}
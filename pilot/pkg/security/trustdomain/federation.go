@@ -0,0 +1,65 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustdomain
+
+import (
+	"strings"
+	"sync"
+)
+
+var (
+	// federatedTrustDomains are the trust domains of the bundles in PILOT_FEDERATED_TRUST_BUNDLES_DIR. Unlike
+	// aliases, a federated trust domain has its own CA, and its identities are never rewritten to the local
+	// trust domain.
+	federatedTrustDomains      []string
+	federatedTrustDomainsMutex sync.RWMutex
+)
+
+// SetFederatedTrustDomains sets the federated trust domains of the mesh. It is called when the federated trust
+// bundles are loaded, and the configs using them must then be pushed again.
+func SetFederatedTrustDomains(trustDomains []string) {
+	federatedTrustDomainsMutex.Lock()
+	federatedTrustDomains = trustDomains
+	federatedTrustDomainsMutex.Unlock()
+}
+
+// FederatedTrustDomains returns the federated trust domains of the mesh.
+func FederatedTrustDomains() []string {
+	federatedTrustDomainsMutex.RLock()
+	defer federatedTrustDomainsMutex.RUnlock()
+	return federatedTrustDomains
+}
+
+// PeerTrustDomains returns the trust domains of the peers accepted with mutual TLS: the local trust domain,
+// its aliases and the federated trust domains. It returns nil, meaning any peer chaining to a trusted root is
+// accepted, if there is no federated trust domain or if an alias is a wildcard.
+func (t Bundle) PeerTrustDomains(federated []string) []string {
+	if len(federated) == 0 {
+		return nil
+	}
+	trustDomains := make([]string, 0, len(t.TrustDomains)+len(federated))
+	for _, list := range [][]string{t.TrustDomains, federated} {
+		for _, td := range list {
+			if strings.Contains(td, "*") {
+				authzLog.Warnf("trust domain %s is a wildcard, peers of any trust domain are accepted", td)
+				return nil
+			}
+			if td != "" && !isKeyInList(td, trustDomains) {
+				trustDomains = append(trustDomains, td)
+			}
+		}
+	}
+	return trustDomains
+}
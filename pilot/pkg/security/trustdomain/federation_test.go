@@ -0,0 +1,62 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustdomain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPeerTrustDomains(t *testing.T) {
+	testCases := []struct {
+		name      string
+		bundle    Bundle
+		federated []string
+		expect    []string
+	}{
+		{
+			name:   "No federation",
+			bundle: NewBundle("cluster.local", []string{"td1"}),
+			expect: nil,
+		},
+		{
+			name:      "Federated trust domains",
+			bundle:    NewBundle("cluster.local", []string{"td1"}),
+			federated: []string{"foo.com", "bar.com"},
+			expect:    []string{"cluster.local", "td1", "foo.com", "bar.com"},
+		},
+		{
+			name:      "Duplicated trust domains",
+			bundle:    NewBundle("cluster.local", []string{"foo.com"}),
+			federated: []string{"foo.com", "cluster.local"},
+			expect:    []string{"cluster.local", "foo.com"},
+		},
+		{
+			name:      "Wildcard alias",
+			bundle:    NewBundle("cluster.local", []string{"*-td"}),
+			federated: []string{"foo.com"},
+			expect:    nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.bundle.PeerTrustDomains(tc.federated)
+			if !reflect.DeepEqual(got, tc.expect) {
+				t.Errorf("got %v, want %v", got, tc.expect)
+			}
+		})
+	}
+}
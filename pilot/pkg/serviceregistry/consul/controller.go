@@ -22,6 +22,7 @@ import (
 
 	"istio.io/pkg/log"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pkg/config/host"
@@ -254,17 +255,35 @@ func (c *Controller) initCache() error {
 	}
 
 	for serviceName := range consulServices {
-		// get endpoints of a service from consul
-		endpoints, err := c.getCatalogService(serviceName, nil)
+		// get endpoints of a service, and their health, from consul
+		entries, err := c.getHealthService(serviceName, nil)
 		if err != nil {
 			return err
 		}
-		c.services[serviceName] = convertService(endpoints)
 
-		instances := make([]*model.ServiceInstance, len(endpoints))
-		for i, endpoint := range endpoints {
-			instances[i] = convertInstance(endpoint)
+		endpoints := make([]*api.CatalogService, 0, len(entries))
+		instances := make([]*model.ServiceInstance, 0, len(entries))
+		for _, entry := range entries {
+			if isConnectProxy(entry) {
+				continue
+			}
+			endpoint, healthy := convertHealthService(entry)
+			endpoints = append(endpoints, endpoint)
+			// The service is still defined by all its instances, but unhealthy ones don't receive traffic.
+			switch {
+			case healthy:
+				instances = append(instances, convertInstance(endpoint))
+			case features.SendUnhealthyEndpoints:
+				instance := convertInstance(endpoint)
+				instance.Endpoint.HealthStatus = model.UnHealthy
+				instances = append(instances, instance)
+			}
+		}
+		if len(endpoints) == 0 {
+			// Only Connect proxies are registered under this name
+			continue
 		}
+		c.services[serviceName] = convertService(endpoints)
 		c.serviceInstances[serviceName] = instances
 	}
 
@@ -288,14 +307,14 @@ func (c *Controller) getServices() (map[string][]string, error) {
 }
 
 // nolint: unparam
-func (c *Controller) getCatalogService(name string, q *api.QueryOptions) ([]*api.CatalogService, error) {
-	endpoints, _, err := c.client.Catalog().Service(name, "", q)
+func (c *Controller) getHealthService(name string, q *api.QueryOptions) ([]*api.ServiceEntry, error) {
+	entries, _, err := c.client.Health().Service(name, "", false, q)
 	if err != nil {
-		log.Warnf("Could not retrieve service catalog from consul: %v", err)
+		log.Warnf("Could not retrieve service health from consul: %v", err)
 		return nil, err
 	}
 
-	return endpoints, nil
+	return entries, nil
 }
 
 func (c *Controller) refreshCache() {
@@ -22,12 +22,14 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/consul/api"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/labels"
 )
@@ -42,8 +44,58 @@ type mockServer struct {
 	productpage []*api.CatalogService
 	reviews     []*api.CatalogService
 	rating      []*api.CatalogService
+	// health is the aggregated status of the health checks of an instance, keyed by service address.
+	// Instances are passing by default.
+	health map[string]string
+	// kinds is the kind of the service registration of an instance, keyed by service address.
+	kinds       map[string]api.ServiceKind
 	lock        sync.Mutex
 	consulIndex int
+	// healthIndex is the index of the health checks, which changes independently of the catalog.
+	healthIndex int
+}
+
+// healthEntries returns the result of the health API for a service. The caller must hold the lock.
+func (m *mockServer) healthEntries(name string) []*api.ServiceEntry {
+	var instances []*api.CatalogService
+	switch name {
+	case "productpage":
+		instances = m.productpage
+	case "reviews":
+		instances = m.reviews
+	case "rating":
+		instances = m.rating
+	}
+	out := make([]*api.ServiceEntry, 0, len(instances))
+	for _, instance := range instances {
+		status := api.HealthPassing
+		if s, f := m.health[instance.ServiceAddress]; f {
+			status = s
+		}
+		out = append(out, &api.ServiceEntry{
+			Node: &api.Node{
+				ID:         instance.ID,
+				Node:       instance.Node,
+				Address:    instance.Address,
+				Datacenter: instance.Datacenter,
+				Meta:       instance.NodeMeta,
+			},
+			Service: &api.AgentService{
+				Kind:    m.kinds[instance.ServiceAddress],
+				ID:      instance.ServiceID,
+				Service: instance.ServiceName,
+				Tags:    instance.ServiceTags,
+				Meta:    instance.ServiceMeta,
+				Port:    instance.ServicePort,
+				Address: instance.ServiceAddress,
+			},
+			Checks: api.HealthChecks{
+				{Node: instance.Node, CheckID: "serfHealth", Status: api.HealthPassing},
+				{Node: instance.Node, CheckID: "service:" + instance.ServiceID, Status: status},
+			},
+		})
+	}
+	return out
 }
 
 func newServer() *mockServer {
@@ -110,7 +162,10 @@ func newServer() *mockServer {
 			"reviews":     {"version|v1", "version|v2", "version|v3"},
 			"rating":      {"version|v1"},
 		},
+		health:      map[string]string{},
+		kinds:       map[string]api.ServiceKind{},
 		consulIndex: 1,
+		healthIndex: 1,
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -121,6 +176,19 @@ func newServer() *mockServer {
 			m.lock.Unlock()
 			w.Header().Set("Content-Type", "application/json")
 			_, _ = fmt.Fprintln(w, string(data))
+		} else if r.URL.Path == "/v1/health/state/any" {
+			m.lock.Lock()
+			w.Header().Set("X-Consul-Index", strconv.Itoa(m.healthIndex))
+			m.lock.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintln(w, "[]")
+		} else if strings.HasPrefix(r.URL.Path, "/v1/health/service/") {
+			m.lock.Lock()
+			data, _ := json.Marshal(m.healthEntries(strings.TrimPrefix(r.URL.Path, "/v1/health/service/")))
+			w.Header().Set("X-Consul-Index", strconv.Itoa(m.consulIndex))
+			m.lock.Unlock()
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = fmt.Fprintln(w, string(data))
 		} else if r.URL.Path == "/v1/catalog/service/reviews" {
 			m.lock.Lock()
 			data, _ := json.Marshal(&m.reviews)
@@ -226,6 +294,75 @@ func TestInstances(t *testing.T) {
 	}
 }
 
+func TestInstancesHealth(t *testing.T) {
+	ts := newServer()
+	defer ts.server.Close()
+
+	ts.lock.Lock()
+	ts.health["172.19.0.6"] = api.HealthCritical
+	ts.health["172.19.0.7"] = api.HealthWarning
+	ts.health["172.19.0.8"] = api.HealthMaint
+	ts.reviews = append(ts.reviews, &api.CatalogService{
+		Node:           "istio-node",
+		Address:        "172.19.0.5",
+		ID:             "istio-node-id",
+		ServiceID:      "reviews-sidecar-proxy",
+		ServiceName:    "reviews",
+		ServiceAddress: "172.19.0.9",
+		ServicePort:    21000,
+	})
+	ts.kinds["172.19.0.9"] = api.ServiceKindConnectProxy
+	ts.lock.Unlock()
+
+	controller, err := NewController(ts.server.URL, clusterID)
+	if err != nil {
+		t.Errorf("could not create Consul Controller: %v", err)
+	}
+
+	hostname := serviceHostname("reviews")
+	service, err := controller.GetService(hostname)
+	if err != nil {
+		t.Fatalf("client encountered error during GetService(): %v", err)
+	}
+	// Unhealthy instances still define the service ports, Connect proxies don't.
+	if len(service.Ports) != 2 {
+		t.Errorf("GetService() returned wrong # of ports => %v, want 2", service.Ports)
+	}
+
+	instances, err := controller.InstancesByPort(service, 0, labels.Collection{})
+	if err != nil {
+		t.Fatalf("client encountered error during Instances(): %v", err)
+	}
+	if len(instances) != 1 {
+		t.Fatalf("Instances() returned wrong # of service instances => %v, want 1", len(instances))
+	}
+	if instances[0].Endpoint.Address != "172.19.0.7" {
+		t.Errorf("Instances() returned wrong instance => %v, want the instance with warnings", instances[0].Endpoint.Address)
+	}
+
+	// With PILOT_SEND_UNHEALTHY_ENDPOINTS, unhealthy instances are sent as unhealthy endpoints.
+	defaultValue := features.SendUnhealthyEndpoints
+	features.SendUnhealthyEndpoints = true
+	defer func() { features.SendUnhealthyEndpoints = defaultValue }()
+	controller, err = NewController(ts.server.URL, clusterID)
+	if err != nil {
+		t.Fatalf("could not create Consul Controller: %v", err)
+	}
+	instances, err = controller.InstancesByPort(service, 0, labels.Collection{})
+	if err != nil {
+		t.Fatalf("client encountered error during Instances(): %v", err)
+	}
+	if len(instances) != 3 {
+		t.Fatalf("Instances() returned wrong # of service instances => %v, want 3", len(instances))
+	}
+	for _, instance := range instances {
+		if healthy := instance.Endpoint.Address == "172.19.0.7"; instance.Endpoint.IsHealthy() != healthy {
+			t.Errorf("Instances() returned instance %v with healthy %v, want %v",
+				instance.Endpoint.Address, instance.Endpoint.IsHealthy(), healthy)
+		}
+	}
+}
+
 func TestInstancesBadHostname(t *testing.T) {
 	ts := newServer()
 	defer ts.server.Close()
@@ -48,6 +48,51 @@ func convertLabels(labelsStr []string) labels.Instance {
 	return out
 }
 
+// convertInstanceLabels returns the labels of an instance. Service metadata is mapped to labels,
+// except for the keys reserved by Istio, and tags of the form "key|value" take precedence over it.
+func convertInstanceLabels(instance *api.CatalogService) labels.Instance {
+	out := make(labels.Instance, len(instance.ServiceMeta)+len(instance.ServiceTags))
+	for k, v := range instance.ServiceMeta {
+		if k == protocolTagName || k == externalTagName {
+			continue
+		}
+		out[k] = v
+	}
+	for k, v := range convertLabels(instance.ServiceTags) {
+		out[k] = v
+	}
+	return out
+}
+
+// convertHealthService returns the catalog view of an instance returned by the health API, and
+// whether the instance is healthy. Instances with a critical health check, or in maintenance, are
+// not healthy; warnings are tolerated like they are by Consul DNS.
+func convertHealthService(entry *api.ServiceEntry) (*api.CatalogService, bool) {
+	out := &api.CatalogService{
+		ServiceID:      entry.Service.ID,
+		ServiceName:    entry.Service.Service,
+		ServiceAddress: entry.Service.Address,
+		ServicePort:    entry.Service.Port,
+		ServiceTags:    entry.Service.Tags,
+		ServiceMeta:    entry.Service.Meta,
+	}
+	if entry.Node != nil {
+		out.ID = entry.Node.ID
+		out.Node = entry.Node.Node
+		out.Address = entry.Node.Address
+		out.Datacenter = entry.Node.Datacenter
+		out.NodeMeta = entry.Node.Meta
+	}
+	status := entry.Checks.AggregatedStatus()
+	return out, status != api.HealthCritical && status != api.HealthMaint
+}
+
+// isConnectProxy returns true for the sidecar proxies registered by Consul Connect. They are part of
+// the services they proxy, and are not converted to services on their own.
+func isConnectProxy(entry *api.ServiceEntry) bool {
+	return entry.Service.Kind == api.ServiceKindConnectProxy
+}
+
 func convertPort(port int, name string) *model.Port {
 	if name == "" {
 		name = "tcp"
@@ -110,7 +155,7 @@ func convertService(endpoints []*api.CatalogService) *model.Service {
 }
 
 func convertInstance(instance *api.CatalogService) *model.ServiceInstance {
-	svcLabels := convertLabels(instance.ServiceTags)
+	svcLabels := convertInstanceLabels(instance)
 	port := convertPort(instance.ServicePort, instance.ServiceMeta[protocolTagName])
 
 	addr := instance.ServiceAddress
@@ -133,6 +178,7 @@ func convertInstance(instance *api.CatalogService) *model.ServiceInstance {
 			Address:         addr,
 			EndpointPort:    uint32(instance.ServicePort),
 			ServicePortName: port.Name,
+			// Consul datacenters are mapped to the region of the locality.
 			Locality: model.Locality{
 				Label: instance.Datacenter,
 			},
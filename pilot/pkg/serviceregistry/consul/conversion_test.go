@@ -147,6 +147,64 @@ func TestConvertInstance(t *testing.T) {
 	}
 }
 
+func TestConvertInstanceLabels(t *testing.T) {
+	instance := &api.CatalogService{
+		ServiceName: "productpage",
+		ServiceTags: []string{"version|v2", "badtag"},
+		ServiceMeta: map[string]string{
+			protocolTagName: "http",
+			externalTagName: "",
+			"version":       "v1",
+			"team":          "bookinfo",
+		},
+	}
+
+	out := convertInstanceLabels(instance)
+	expected := map[string]string{"version": "v2", "team": "bookinfo"}
+	if len(out) != len(expected) {
+		t.Fatalf("convertInstanceLabels() => %v, want %v", out, expected)
+	}
+	for k, v := range expected {
+		if out[k] != v {
+			t.Errorf("convertInstanceLabels() => %v, want %v", out, expected)
+		}
+	}
+}
+
+func TestConvertHealthService(t *testing.T) {
+	entry := &api.ServiceEntry{
+		Node: &api.Node{Node: "istio-node", Address: "172.19.0.5", Datacenter: "dc1"},
+		Service: &api.AgentService{
+			ID:      "productpage-1",
+			Service: "productpage",
+			Tags:    []string{"version|v1"},
+			Port:    9080,
+			Address: "172.19.0.11",
+		},
+	}
+	for _, tt := range []struct {
+		status  string
+		healthy bool
+	}{
+		{api.HealthPassing, true},
+		{api.HealthWarning, true},
+		{api.HealthCritical, false},
+		{api.HealthMaint, false},
+	} {
+		entry.Checks = api.HealthChecks{{CheckID: "service:productpage-1", Status: tt.status}}
+		out, healthy := convertHealthService(entry)
+		if healthy != tt.healthy {
+			t.Errorf("convertHealthService() with status %v => healthy %v, want %v", tt.status, healthy, tt.healthy)
+		}
+		if out.ServiceName != "productpage" || out.ServiceAddress != "172.19.0.11" || out.ServicePort != 9080 {
+			t.Errorf("convertHealthService() bad service => %+v", out)
+		}
+		if out.Datacenter != "dc1" || out.Address != "172.19.0.5" {
+			t.Errorf("convertHealthService() bad node => %+v", out)
+		}
+	}
+}
+
 func TestServiceHostname(t *testing.T) {
 	out := serviceHostname("productpage")
 
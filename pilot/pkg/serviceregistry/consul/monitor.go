@@ -57,14 +57,35 @@ func NewConsulMonitor(client *api.Client) Monitor {
 	}
 }
 
+// Start watches Consul with two blocking queries, whatever the number of services: the index of the catalog
+// services changes when an instance is registered, updated or deregistered, and the index of the health checks
+// when a check changes status.
+//
+// Consul Enterprise namespaces are not supported, the client predates them: only the default namespace is
+// watched, and its services are all mapped to the Istio default config namespace.
 func (m *consulMonitor) Start(stop <-chan struct{}) {
 	change := make(chan struct{})
-	go m.watchConsul(change, stop)
+	go m.watchConsul("services", m.queryServices, true, change, stop)
+	go m.watchConsul("health checks", m.queryHealthChecks, false, change, stop)
 	go m.updateRecord(change, stop)
 }
 
-func (m *consulMonitor) watchConsul(change chan struct{}, stop <-chan struct{}) {
+func (m *consulMonitor) queryServices(q *api.QueryOptions) (*api.QueryMeta, error) {
+	_, queryMeta, err := m.discovery.Catalog().Services(q)
+	return queryMeta, err
+}
+
+func (m *consulMonitor) queryHealthChecks(q *api.QueryOptions) (*api.QueryMeta, error) {
+	_, queryMeta, err := m.discovery.Health().State(api.HealthAny, q)
+	return queryMeta, err
+}
+
+// watchConsul runs a blocking query until stop is closed, and notifies change when its index changes. If
+// notifyFirst is false, the first query only sets the index.
+func (m *consulMonitor) watchConsul(name string, query func(*api.QueryOptions) (*api.QueryMeta, error),
+	notifyFirst bool, change chan struct{}, stop <-chan struct{}) {
 	var consulWaitIndex uint64
+	first := true
 
 	for {
 		select {
@@ -75,19 +96,51 @@ func (m *consulMonitor) watchConsul(change chan struct{}, stop <-chan struct{})
 				WaitIndex: consulWaitIndex,
 				WaitTime:  blockQueryWaitTime,
 			}
-			// This Consul REST API will block until service changes or timeout
-			_, queryMeta, err := m.discovery.Catalog().Services(&queryOptions)
+			// This Consul REST API will block until a change or timeout
+			queryMeta, err := query(&queryOptions)
 			if err != nil {
-				log.Warnf("Could not fetch services: %v", err)
-			} else if consulWaitIndex != queryMeta.LastIndex {
-				consulWaitIndex = queryMeta.LastIndex
-				change <- struct{}{}
+				log.Warnf("Could not fetch %s: %v", name, err)
+			} else {
+				if consulWaitIndex != queryMeta.LastIndex && (notifyFirst || !first) {
+					notify(change, stop)
+				}
+				first = false
+				consulWaitIndex = nextWaitIndex(consulWaitIndex, queryMeta.LastIndex)
+			}
+			if !sleep(periodicCheckTime, stop) {
+				return
 			}
-			time.Sleep(periodicCheckTime)
 		}
 	}
 }
 
+// nextWaitIndex returns the index to use for the next blocking query. Consul indexes are not
+// guaranteed to increase (for example after a snapshot restore), in which case the watch restarts
+// from 0 rather than blocking until the old index is reached again.
+func nextWaitIndex(current, last uint64) uint64 {
+	if last < current {
+		return 0
+	}
+	return last
+}
+
+func notify(change chan<- struct{}, stop <-chan struct{}) {
+	select {
+	case change <- struct{}{}:
+	case <-stop:
+	}
+}
+
+// sleep waits for d, returning false if stop was closed first.
+func sleep(d time.Duration, stop <-chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-stop:
+		return false
+	}
+}
+
 func (m *consulMonitor) updateRecord(change <-chan struct{}, stop <-chan struct{}) {
 	lastChange := int64(0)
 	ticker := time.NewTicker(periodicCheckTime)
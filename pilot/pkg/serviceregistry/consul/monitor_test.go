@@ -76,4 +76,31 @@ func TestController(t *testing.T) {
 	ts.consulIndex++
 	ts.lock.Unlock()
 	expectNotify(t, 2)
+
+	//A health check changing status is seen even though the catalog doesn't change
+	ts.lock.Lock()
+	ts.healthIndex++
+	ts.lock.Unlock()
+	expectNotify(t, 2)
+}
+
+func TestNextWaitIndex(t *testing.T) {
+	cases := []struct {
+		name    string
+		current uint64
+		last    uint64
+		want    uint64
+	}{
+		{"first query", 0, 5, 5},
+		{"no change", 5, 5, 5},
+		{"change", 5, 7, 7},
+		{"index went backwards", 7, 3, 0},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextWaitIndex(tt.current, tt.last); got != tt.want {
+				t.Fatalf("nextWaitIndex(%d, %d) => %d, want %d", tt.current, tt.last, got, tt.want)
+			}
+		})
+	}
 }
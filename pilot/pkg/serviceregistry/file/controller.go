@@ -0,0 +1,433 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+)
+
+var _ serviceregistry.Instance = &Controller{}
+
+const (
+	// DefaultPollInterval is used when Options.PollInterval is not set.
+	DefaultPollInterval = 30 * time.Second
+
+	watchDebounceDelay = 100 * time.Millisecond
+	httpTimeout        = 10 * time.Second
+)
+
+// Options stores the configurable attributes of a Controller.
+type Options struct {
+	// Path is a directory or a file holding YAML or JSON documents, or an http(s) URL serving one document.
+	Path string
+	// PollInterval is how often the URL is fetched. Directories and files are watched for changes,
+	// and also read again at this interval in case an event was missed.
+	PollInterval time.Duration
+	// ClusterID is set on the locality of the endpoints.
+	ClusterID string
+}
+
+// Controller is a service registry reading services and endpoints from files or from an HTTP server.
+type Controller struct {
+	options Options
+	client  *http.Client
+
+	mutex sync.RWMutex
+	// services, instances and configs are keyed by hostname. configs holds the last read configuration
+	// of each service, used to detect changes.
+	services  map[host.Name]*model.Service
+	instances map[host.Name][]*model.ServiceInstance
+	configs   map[host.Name]Service
+	synced    bool
+
+	serviceHandlers  []func(*model.Service, model.Event)
+	instanceHandlers []func(*model.ServiceInstance, model.Event)
+}
+
+// NewController creates a new file registry.
+func NewController(options Options) *Controller {
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	return &Controller{
+		options:   options,
+		client:    &http.Client{Timeout: httpTimeout},
+		services:  map[host.Name]*model.Service{},
+		instances: map[host.Name][]*model.ServiceInstance{},
+		configs:   map[host.Name]Service{},
+	}
+}
+
+func (c *Controller) Provider() serviceregistry.ProviderID {
+	return serviceregistry.File
+}
+
+func (c *Controller) Cluster() string {
+	return c.options.ClusterID
+}
+
+func (c *Controller) isURL() bool {
+	return strings.HasPrefix(c.options.Path, "http://") || strings.HasPrefix(c.options.Path, "https://")
+}
+
+// Run reads the services, and reads them again on changes until a signal is received.
+func (c *Controller) Run(stop <-chan struct{}) {
+	c.reload()
+
+	var events <-chan struct{}
+	if !c.isURL() {
+		ch, err := c.watch(stop)
+		if err != nil {
+			log.Warnf("Unable to watch %s, falling back to polling: %v", c.options.Path, err)
+		}
+		events = ch
+	}
+
+	ticker := time.NewTicker(c.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.reload()
+		case <-events:
+			c.reload()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// watch notifies of file changes in the path, debounced.
+func (c *Controller) watch(stop <-chan struct{}) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err = watcher.Add(c.options.Path); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+		var debounceC <-chan time.Time
+		for {
+			select {
+			case <-debounceC:
+				debounceC = nil
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			case <-watcher.Events:
+				if debounceC == nil {
+					debounceC = time.After(watchDebounceDelay)
+				}
+			case err := <-watcher.Errors:
+				log.Warnf("Error watching %s: %v", c.options.Path, err)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// reload reads the services and notifies the handlers of the changes. On error, the previous
+// services are kept.
+func (c *Controller) reload() {
+	services, err := c.read()
+	if err != nil {
+		log.Warnf("Failed to read services from %s: %v", c.options.Path, err)
+		return
+	}
+	c.update(services)
+}
+
+func (c *Controller) read() ([]Service, error) {
+	if c.isURL() {
+		return c.fetch()
+	}
+	info, err := os.Stat(c.options.Path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{c.options.Path}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(c.options.Path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, e := range entries {
+			switch filepath.Ext(e.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !e.IsDir() {
+					files = append(files, filepath.Join(c.options.Path, e.Name()))
+				}
+			}
+		}
+		sort.Strings(files)
+	}
+
+	var out []Service
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		list, err := parseServiceList(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f, err)
+		}
+		out = append(out, list.Services...)
+	}
+	return out, nil
+}
+
+func (c *Controller) fetch() ([]Service, error) {
+	resp, err := c.client.Get(c.options.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	list, err := parseServiceList(data)
+	if err != nil {
+		return nil, err
+	}
+	return list.Services, nil
+}
+
+type serviceEvent struct {
+	service *model.Service
+	event   model.Event
+}
+
+type instanceEvent struct {
+	instance *model.ServiceInstance
+	event    model.Event
+}
+
+// update replaces the services, and notifies the handlers of the differences with the previous ones.
+// Invalid services are skipped; if a hostname is defined more than once, the first definition wins.
+func (c *Controller) update(services []Service) {
+	configs := make(map[host.Name]Service, len(services))
+	for _, svc := range services {
+		if err := validateService(&svc); err != nil {
+			log.Warnf("Skipping invalid service %s: %v", svc.Hostname, err)
+			continue
+		}
+		hostname := host.Name(svc.Hostname)
+		if _, f := configs[hostname]; f {
+			log.Warnf("Skipping duplicate service %s", svc.Hostname)
+			continue
+		}
+		configs[hostname] = svc
+	}
+
+	var serviceEvents []serviceEvent
+	var instanceEvents []instanceEvent
+
+	c.mutex.Lock()
+	for hostname, svc := range configs {
+		old, f := c.configs[hostname]
+		if f && reflect.DeepEqual(old, svc) {
+			continue
+		}
+		service, instances := convertService(&svc, c.options.ClusterID)
+		switch {
+		case !f:
+			serviceEvents = append(serviceEvents, serviceEvent{service, model.EventAdd})
+		case !sameService(&old, &svc):
+			serviceEvents = append(serviceEvents, serviceEvent{service, model.EventUpdate})
+		default:
+			// Only the endpoints changed.
+			instanceEvents = append(instanceEvents, endpointEvents(c.services[hostname], &old, service, &svc, c.options.ClusterID)...)
+		}
+		c.services[hostname] = service
+		c.instances[hostname] = instances
+	}
+	for hostname := range c.configs {
+		if _, f := configs[hostname]; !f {
+			serviceEvents = append(serviceEvents, serviceEvent{c.services[hostname], model.EventDelete})
+			delete(c.services, hostname)
+			delete(c.instances, hostname)
+		}
+	}
+	c.configs = configs
+	c.synced = true
+	c.mutex.Unlock()
+
+	for _, e := range serviceEvents {
+		for _, h := range c.serviceHandlers {
+			h(e.service, e.event)
+		}
+	}
+	for _, e := range instanceEvents {
+		for _, h := range c.instanceHandlers {
+			h(e.instance, e.event)
+		}
+	}
+}
+
+// sameService returns true if two versions of a service only differ by their endpoints.
+func sameService(a, b *Service) bool {
+	return a.Namespace == b.Namespace && a.Address == b.Address && reflect.DeepEqual(a.Ports, b.Ports)
+}
+
+// endpointEvents returns the instance events for the endpoints added, updated or removed between two
+// versions of a service. Endpoints are identified by their address.
+func endpointEvents(oldService *model.Service, old *Service, service *model.Service, svc *Service, clusterID string) []instanceEvent {
+	oldEndpoints := make(map[string]*Endpoint, len(old.Endpoints))
+	for i := range old.Endpoints {
+		oldEndpoints[old.Endpoints[i].Address] = &old.Endpoints[i]
+	}
+	var out []instanceEvent
+	seen := make(map[string]struct{}, len(svc.Endpoints))
+	for i := range svc.Endpoints {
+		ep := &svc.Endpoints[i]
+		seen[ep.Address] = struct{}{}
+		event := model.EventAdd
+		if prev, f := oldEndpoints[ep.Address]; f {
+			if reflect.DeepEqual(prev, ep) {
+				continue
+			}
+			event = model.EventUpdate
+		}
+		for _, instance := range convertEndpoint(service, ep, clusterID) {
+			out = append(out, instanceEvent{instance, event})
+		}
+	}
+	for address, ep := range oldEndpoints {
+		if _, f := seen[address]; !f {
+			for _, instance := range convertEndpoint(oldService, ep, clusterID) {
+				out = append(out, instanceEvent{instance, model.EventDelete})
+			}
+		}
+	}
+	return out
+}
+
+// HasSynced returns true once the services were read once.
+func (c *Controller) HasSynced() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.synced
+}
+
+// AppendServiceHandler implements a service catalog operation
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	c.serviceHandlers = append(c.serviceHandlers, f)
+	return nil
+}
+
+// AppendInstanceHandler implements a service catalog operation
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	c.instanceHandlers = append(c.instanceHandlers, f)
+	return nil
+}
+
+// Services list declarations of all services in the system
+func (c *Controller) Services() ([]*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	out := make([]*model.Service, 0, len(c.services))
+	for _, svc := range c.services {
+		out = append(out, svc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hostname < out[j].Hostname })
+	return out, nil
+}
+
+// GetService retrieves a service by host name if it exists
+func (c *Controller) GetService(hostname host.Name) (*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.services[hostname], nil
+}
+
+// InstancesByPort retrieves instances for a service on the given port that match any of the
+// supplied labels. All instances match an empty label list.
+func (c *Controller) InstancesByPort(svc *model.Service, port int, labels labels.Collection) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	var out []*model.ServiceInstance
+	for _, instance := range c.instances[svc.Hostname] {
+		if instance.ServicePort.Port == port && labels.HasSubsetOf(instance.Endpoint.Labels) {
+			out = append(out, instance)
+		}
+	}
+	return out, nil
+}
+
+// GetProxyServiceInstances lists service instances co-located with a given proxy
+func (c *Controller) GetProxyServiceInstances(node *model.Proxy) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	out := make([]*model.ServiceInstance, 0)
+	for _, instances := range c.instances {
+		for _, instance := range instances {
+			for _, ip := range node.IPAddresses {
+				if ip == instance.Endpoint.Address {
+					out = append(out, instance)
+					break
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) (labels.Collection, error) {
+	instances, err := c.GetProxyServiceInstances(proxy)
+	if err != nil {
+		return nil, err
+	}
+	out := make(labels.Collection, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, instance.Endpoint.Labels)
+	}
+	return out, nil
+}
+
+// GetIstioServiceAccounts implements model.ServiceAccounts operation
+func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
+	return model.GetServiceAccounts(svc, ports, c)
+}
@@ -0,0 +1,254 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const reviews = `
+services:
+- hostname: reviews.vm.local
+  namespace: bookinfo
+  ports:
+  - name: http
+    port: 9080
+  - name: tcp-admin
+    port: 9090
+  endpoints:
+  - address: 192.168.1.5
+    ports:
+      http: 19080
+    labels:
+      version: v1
+    locality: us-east/zone1
+  - address: 192.168.1.6
+    labels:
+      version: v2
+`
+
+const ratings = `{"services": [{"hostname": "ratings.vm.local", "ports": [{"name": "grpc", "port": 9080}]}]}`
+
+type events struct {
+	mu        sync.Mutex
+	services  map[string]model.Event
+	instances map[string]model.Event
+}
+
+func newEvents(c *Controller) *events {
+	e := &events{services: map[string]model.Event{}, instances: map[string]model.Event{}}
+	_ = c.AppendServiceHandler(func(svc *model.Service, event model.Event) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.services[string(svc.Hostname)] = event
+	})
+	_ = c.AppendInstanceHandler(func(si *model.ServiceInstance, event model.Event) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.instances[si.Endpoint.Address] = event
+	})
+	return e
+}
+
+func (e *events) reset() (map[string]model.Event, map[string]model.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	services, instances := e.services, e.instances
+	e.services, e.instances = map[string]model.Event{}, map[string]model.Event{}
+	return services, instances
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "reviews.yaml", reviews)
+	writeFile(t, dir, "ratings.json", ratings)
+	writeFile(t, dir, "README.md", "ignored")
+
+	c := NewController(Options{Path: dir, ClusterID: "vms"})
+	e := newEvents(c)
+	c.reload()
+
+	if !c.HasSynced() {
+		t.Fatalf("expected registry to be synced")
+	}
+	services, _ := e.reset()
+	if len(services) != 2 || services["reviews.vm.local"] != model.EventAdd || services["ratings.vm.local"] != model.EventAdd {
+		t.Fatalf("unexpected service events %v", services)
+	}
+
+	svc, _ := c.GetService("reviews.vm.local")
+	if svc == nil || svc.Attributes.Namespace != "bookinfo" || svc.Ports[1].Protocol != protocol.TCP {
+		t.Fatalf("unexpected service %+v", svc)
+	}
+	ratingsSvc, _ := c.GetService("ratings.vm.local")
+	if ratingsSvc.Attributes.Namespace != model.IstioDefaultConfigNamespace || ratingsSvc.Ports[0].Protocol != protocol.GRPC {
+		t.Fatalf("unexpected service %+v", ratingsSvc)
+	}
+
+	instances, _ := c.InstancesByPort(svc, 9080, labels.Collection{{"version": "v1"}})
+	if len(instances) != 1 {
+		t.Fatalf("expected one instance, got %v", instances)
+	}
+	ep := instances[0].Endpoint
+	if ep.Address != "192.168.1.5" || ep.EndpointPort != 19080 || ep.Locality.Label != "us-east/zone1" || ep.Locality.ClusterID != "vms" {
+		t.Fatalf("unexpected endpoint %+v", ep)
+	}
+	instances, _ = c.InstancesByPort(svc, 9090, nil)
+	if len(instances) != 2 {
+		t.Fatalf("expected two instances, got %v", instances)
+	}
+
+	proxyInstances, _ := c.GetProxyServiceInstances(&model.Proxy{IPAddresses: []string{"192.168.1.6"}})
+	if len(proxyInstances) != 2 {
+		t.Fatalf("expected an instance per port, got %v", proxyInstances)
+	}
+
+	// Endpoint changes are notified as instance events.
+	writeFile(t, dir, "reviews.yaml", `
+services:
+- hostname: reviews.vm.local
+  namespace: bookinfo
+  ports:
+  - name: http
+    port: 9080
+  - name: tcp-admin
+    port: 9090
+  endpoints:
+  - address: 192.168.1.5
+    ports:
+      http: 19080
+    labels:
+      version: v1
+    locality: us-east/zone1
+  - address: 192.168.1.7
+`)
+	c.reload()
+	services, instanceEvents := e.reset()
+	if len(services) != 0 {
+		t.Fatalf("unexpected service events %v", services)
+	}
+	expected := map[string]model.Event{"192.168.1.6": model.EventDelete, "192.168.1.7": model.EventAdd}
+	if len(instanceEvents) != len(expected) {
+		t.Fatalf("unexpected instance events %v", instanceEvents)
+	}
+	for k, v := range expected {
+		if instanceEvents[k] != v {
+			t.Fatalf("unexpected instance events %v", instanceEvents)
+		}
+	}
+
+	// Removing the file deletes the service, and invalid files keep the previous state.
+	if err := os.Remove(filepath.Join(dir, "ratings.json")); err != nil {
+		t.Fatal(err)
+	}
+	c.reload()
+	services, _ = e.reset()
+	if len(services) != 1 || services["ratings.vm.local"] != model.EventDelete {
+		t.Fatalf("unexpected service events %v", services)
+	}
+	writeFile(t, dir, "broken.yaml", "services: [")
+	c.reload()
+	if all, _ := c.Services(); len(all) != 1 {
+		t.Fatalf("expected previous services to be kept, got %v", all)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewController(Options{Path: dir, PollInterval: time.Hour})
+	updated := make(chan struct{}, 10)
+	_ = c.AppendServiceHandler(func(*model.Service, model.Event) {
+		updated <- struct{}{}
+	})
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.Run(stop)
+
+	// Wait for the initial read before changing the files.
+	for !c.HasSynced() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	writeFile(t, dir, "ratings.json", ratings)
+	select {
+	case <-updated:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a service event after a file change")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var mu sync.Mutex
+	content := ratings
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if content == "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	c := NewController(Options{Path: server.URL})
+	c.reload()
+	if all, _ := c.Services(); len(all) != 1 || all[0].Hostname != "ratings.vm.local" {
+		t.Fatalf("unexpected services %v", all)
+	}
+
+	// Errors keep the previous state.
+	mu.Lock()
+	content = ""
+	mu.Unlock()
+	c.reload()
+	if all, _ := c.Services(); len(all) != 1 {
+		t.Fatalf("expected previous services to be kept, got %v", all)
+	}
+
+	mu.Lock()
+	content = reviews
+	mu.Unlock()
+	c.reload()
+	if all, _ := c.Services(); len(all) != 1 || all[0].Hostname != "reviews.vm.local" {
+		t.Fatalf("unexpected services %v", all)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/validation"
+)
+
+// ServiceList is the content of a file, or of the document served over HTTP. See README.md for the format.
+type ServiceList struct {
+	Services []Service `json:"services"`
+}
+
+// Service is a service and its endpoints.
+type Service struct {
+	// Hostname is the fully qualified name of the service.
+	Hostname string `json:"hostname"`
+	// Namespace of the service, used for config scoping. Defaults to "default".
+	Namespace string `json:"namespace,omitempty"`
+	// Address is the virtual IP of the service, if any.
+	Address string `json:"address,omitempty"`
+	// Ports where the service is listening.
+	Ports []Port `json:"ports"`
+	// Endpoints of the service.
+	Endpoints []Endpoint `json:"endpoints,omitempty"`
+}
+
+// Port of a service.
+type Port struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+	// Protocol of the port. Defaults to the prefix of the name, or TCP.
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// Endpoint is an instance of a service.
+type Endpoint struct {
+	// Address is the IP of the endpoint.
+	Address string `json:"address"`
+	// Ports maps service port names to the port the endpoint listens on. Defaults to the service port.
+	Ports map[string]int `json:"ports,omitempty"`
+	// Labels of the workload.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Locality of the endpoint, as region/zone/subzone.
+	Locality string `json:"locality,omitempty"`
+	// Network of the endpoint, for multi-network meshes.
+	Network string `json:"network,omitempty"`
+	// ServiceAccount is the SPIFFE identity of the workload.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Weight is the load balancing weight of the endpoint.
+	Weight uint32 `json:"weight,omitempty"`
+}
+
+// parseServiceList parses a YAML or JSON document.
+func parseServiceList(data []byte) (*ServiceList, error) {
+	out := &ServiceList{}
+	if err := yaml.UnmarshalStrict(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// validateService checks that a service can be converted.
+func validateService(svc *Service) (errs error) {
+	if err := validation.ValidateFQDN(svc.Hostname); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid hostname %q: %v", svc.Hostname, err))
+	}
+	if svc.Address != "" && net.ParseIP(svc.Address) == nil {
+		errs = multierror.Append(errs, fmt.Errorf("invalid address %q", svc.Address))
+	}
+	if len(svc.Ports) == 0 {
+		errs = multierror.Append(errs, fmt.Errorf("service %s must have at least one port", svc.Hostname))
+	}
+	names := map[string]struct{}{}
+	for _, p := range svc.Ports {
+		if err := validation.ValidatePortName(p.Name); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if err := validation.ValidatePort(p.Port); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		if p.Protocol != "" && protocol.Parse(p.Protocol) == protocol.Unsupported {
+			errs = multierror.Append(errs, fmt.Errorf("unsupported protocol %q for port %s", p.Protocol, p.Name))
+		}
+		if _, f := names[p.Name]; f {
+			errs = multierror.Append(errs, fmt.Errorf("duplicate port name %s", p.Name))
+		}
+		names[p.Name] = struct{}{}
+	}
+	for _, ep := range svc.Endpoints {
+		if net.ParseIP(ep.Address) == nil {
+			errs = multierror.Append(errs, fmt.Errorf("invalid endpoint address %q", ep.Address))
+		}
+		for name, port := range ep.Ports {
+			if _, f := names[name]; !f {
+				errs = multierror.Append(errs, fmt.Errorf("endpoint %s uses unknown port %s", ep.Address, name))
+			}
+			if err := validation.ValidatePort(port); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+		if err := labels.Instance(ep.Labels).Validate(); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+	return
+}
+
+func convertProtocol(p Port) protocol.Instance {
+	if p.Protocol != "" {
+		return protocol.Parse(p.Protocol)
+	}
+	// Follow the Kubernetes naming convention, <protocol>[-<suffix>]
+	if i := protocol.Parse(strings.Split(p.Name, "-")[0]); i != protocol.Unsupported {
+		return i
+	}
+	return protocol.TCP
+}
+
+// convertService converts a validated service to the Istio model.
+func convertService(svc *Service, clusterID string) (*model.Service, []*model.ServiceInstance) {
+	namespace := svc.Namespace
+	if namespace == "" {
+		namespace = model.IstioDefaultConfigNamespace
+	}
+	address := svc.Address
+	if address == "" {
+		address = constants.UnspecifiedIP
+	}
+
+	ports := make(model.PortList, 0, len(svc.Ports))
+	for _, p := range svc.Ports {
+		ports = append(ports, &model.Port{
+			Name:     p.Name,
+			Port:     p.Port,
+			Protocol: convertProtocol(p),
+		})
+	}
+
+	out := &model.Service{
+		Hostname:   host.Name(svc.Hostname),
+		Address:    address,
+		Ports:      ports,
+		Resolution: model.ClientSideLB,
+		Attributes: model.ServiceAttributes{
+			ServiceRegistry: string(serviceregistry.File),
+			Name:            svc.Hostname,
+			Namespace:       namespace,
+		},
+	}
+
+	instances := make([]*model.ServiceInstance, 0, len(svc.Endpoints)*len(ports))
+	for _, ep := range svc.Endpoints {
+		instances = append(instances, convertEndpoint(out, &ep, clusterID)...)
+	}
+	return out, instances
+}
+
+// convertEndpoint returns an instance for each port of the service.
+func convertEndpoint(svc *model.Service, ep *Endpoint, clusterID string) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0, len(svc.Ports))
+	for _, port := range svc.Ports {
+		endpointPort := port.Port
+		if p, f := ep.Ports[port.Name]; f {
+			endpointPort = p
+		}
+		out = append(out, &model.ServiceInstance{
+			Service:     svc,
+			ServicePort: port,
+			Endpoint: &model.IstioEndpoint{
+				Address:         ep.Address,
+				EndpointPort:    uint32(endpointPort),
+				ServicePortName: port.Name,
+				Labels:          ep.Labels,
+				ServiceAccount:  ep.ServiceAccount,
+				Network:         ep.Network,
+				Locality: model.Locality{
+					Label:     ep.Locality,
+					ClusterID: clusterID,
+				},
+				LbWeight: ep.Weight,
+				TLSMode:  model.GetTLSModeFromEndpointLabels(ep.Labels),
+			},
+		})
+	}
+	return out
+}
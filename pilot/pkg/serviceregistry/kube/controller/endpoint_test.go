@@ -18,6 +18,8 @@ import (
 	"testing"
 
 	coreV1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pilot/pkg/features"
 )
 
 func TestEndpointsEqual(t *testing.T) {
@@ -121,3 +123,27 @@ func TestEndpointsEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestEndpointsEqualWithUnhealthyEndpoints(t *testing.T) {
+	defaultValue := features.SendUnhealthyEndpoints
+	defer func() { features.SendUnhealthyEndpoints = defaultValue }()
+
+	addressA := coreV1.EndpointAddress{IP: "1.2.3.4", Hostname: "a"}
+	addressB := coreV1.EndpointAddress{IP: "1.2.3.4", Hostname: "b"}
+	a := &coreV1.Endpoints{Subsets: []coreV1.EndpointSubset{
+		{
+			NotReadyAddresses: []coreV1.EndpointAddress{addressB},
+			Addresses:         []coreV1.EndpointAddress{addressA},
+		},
+	}}
+	b := &coreV1.Endpoints{Subsets: []coreV1.EndpointSubset{
+		{Addresses: []coreV1.EndpointAddress{addressA}},
+	}}
+	for _, send := range []bool{false, true} {
+		features.SendUnhealthyEndpoints = send
+		// Not ready addresses are only sent, as unhealthy endpoints, when the feature is enabled.
+		if got, want := endpointsEqual(a, b), !send; got != want {
+			t.Errorf("Compare endpoints with SendUnhealthyEndpoints %v got %v, want %v", send, got, want)
+		}
+	}
+}
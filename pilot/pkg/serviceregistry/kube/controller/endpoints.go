@@ -28,6 +28,7 @@ import (
 
 	"istio.io/pkg/log"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pkg/config/host"
@@ -204,7 +205,12 @@ func (e *endpointsController) buildIstioEndpoints(endpoint interface{}, host hos
 	endpoints := make([]*model.IstioEndpoint, 0)
 	ep := endpoint.(*v1.Endpoints)
 	for _, ss := range ep.Subsets {
-		for _, ea := range ss.Addresses {
+		// Not ready addresses are appended after the ready ones, and marked as unhealthy.
+		addresses := ss.Addresses
+		if features.SendUnhealthyEndpoints {
+			addresses = append(append([]v1.EndpointAddress{}, ss.Addresses...), ss.NotReadyAddresses...)
+		}
+		for i, ea := range addresses {
 			pod := e.c.pods.getPodByIP(ea.IP)
 			if pod == nil {
 				// This means, the endpoint event has arrived before pod event. This might happen because
@@ -229,6 +235,9 @@ func (e *endpointsController) buildIstioEndpoints(endpoint interface{}, host hos
 			// map to numbers.
 			for _, port := range ss.Ports {
 				istioEndpoint := builder.buildIstioEndpoint(ea.IP, port.Port, port.Name)
+				if i >= len(ss.Addresses) {
+					istioEndpoint.HealthStatus = model.UnHealthy
+				}
 				endpoints = append(endpoints, istioEndpoint)
 			}
 		}
@@ -242,7 +251,7 @@ func (e *endpointsController) getServiceInfo(ep interface{}) (host.Name, string,
 }
 
 // endpointsEqual returns true if the two endpoints are the same in aspects Pilot cares about
-// This currently means only looking at "Ready" endpoints
+// This currently means only looking at "Ready" endpoints, and "NotReady" endpoints if they are sent as unhealthy
 func endpointsEqual(first, second interface{}) bool {
 	a := first.(*v1.Endpoints)
 	b := second.(*v1.Endpoints)
@@ -256,6 +265,10 @@ func endpointsEqual(first, second interface{}) bool {
 		if !addressesEqual(a.Subsets[i].Addresses, b.Subsets[i].Addresses) {
 			return false
 		}
+		if features.SendUnhealthyEndpoints &&
+			!addressesEqual(a.Subsets[i].NotReadyAddresses, b.Subsets[i].NotReadyAddresses) {
+			return false
+		}
 	}
 	return true
 }
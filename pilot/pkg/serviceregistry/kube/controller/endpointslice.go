@@ -31,6 +31,7 @@ import (
 
 	"istio.io/pkg/log"
 
+	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/serviceregistry/kube"
 	"istio.io/istio/pkg/config/host"
@@ -168,9 +169,13 @@ func (esc *endpointSliceController) buildIstioEndpoints(es interface{}, host hos
 	slice := es.(*discoveryv1alpha1.EndpointSlice)
 	endpoints := make([]*model.IstioEndpoint, 0)
 	for _, e := range slice.Endpoints {
+		var healthStatus model.HealthStatus
 		if e.Conditions.Ready != nil && !*e.Conditions.Ready {
-			// Ignore not ready endpoints
-			continue
+			if !features.SendUnhealthyEndpoints {
+				// Ignore not ready endpoints
+				continue
+			}
+			healthStatus = model.UnHealthy
 		}
 		for _, a := range e.Addresses {
 			pod := esc.c.pods.getPodByIP(a)
@@ -205,6 +210,7 @@ func (esc *endpointSliceController) buildIstioEndpoints(es interface{}, host hos
 				}
 
 				istioEndpoint := builder.buildIstioEndpoint(a, portNum, portName)
+				istioEndpoint.HealthStatus = healthStatus
 				endpoints = append(endpoints, istioEndpoint)
 			}
 		}
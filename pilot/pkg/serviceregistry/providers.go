@@ -26,6 +26,8 @@ const (
 	Consul ProviderID = "Consul"
 	// MCP is a service registry backed by MCP ServiceEntries
 	MCP ProviderID = "MCP"
+	// File is a service registry backed by files, or by documents served over HTTP
+	File ProviderID = "File"
 	// External is a service registry for externally provided ServiceEntries
 	External = "External"
 )
@@ -27,3 +27,9 @@ type Origin interface {
 type Reference interface {
 	String() string
 }
+
+// FieldReference is optionally implemented by a Reference that can locate individual fields of the resource.
+type FieldReference interface {
+	// FieldReference returns the Reference for the field at the given path (e.g. "spec.http[1].match[0]"), if known.
+	FieldReference(path string) (Reference, bool)
+}
@@ -145,6 +145,10 @@ func TestValidateCondition(t *testing.T) {
 			key:    "request.auth.claims[id]",
 			values: []string{"123"},
 		},
+		{
+			key:    "request.auth.claims[realm_access][roles]",
+			values: []string{"admin"},
+		},
 		{
 			key:       "request.auth.claims[]",
 			values:    []string{"value"},
@@ -58,6 +58,9 @@ const (
 	drainTimeMax          = time.Hour
 	parentShutdownTimeMax = time.Hour
 
+	// maxRingHashSize is the largest ring Envoy accepts for the ring hash load balancer.
+	maxRingHashSize = 8 * 1024 * 1024
+
 	// UnixAddressPrefix is the prefix used to indicate an address is for a Unix Domain socket. It is used in
 	// ServiceEntry.Endpoint.Address message.
 	UnixAddressPrefix = "unix://"
@@ -953,6 +956,19 @@ func validateLoadBalancer(settings *networking.LoadBalancerSettings) (errs error
 				errs = appendErrors(errs, fmt.Errorf("ttl required for HttpCookie"))
 			}
 		}
+		switch h := consistentHash.GetHashKey().(type) {
+		case *networking.LoadBalancerSettings_ConsistentHashLB_HttpHeaderName:
+			if h.HttpHeaderName == "" {
+				errs = appendErrors(errs, fmt.Errorf("name required for HttpHeaderName"))
+			}
+		case *networking.LoadBalancerSettings_ConsistentHashLB_HttpQueryParameterName:
+			if h.HttpQueryParameterName == "" {
+				errs = appendErrors(errs, fmt.Errorf("name required for HttpQueryParameterName"))
+			}
+		}
+		if consistentHash.MinimumRingSize > maxRingHashSize {
+			errs = appendErrors(errs, fmt.Errorf("minimumRingSize must be at most %d", maxRingHashSize))
+		}
 	}
 	if err := validateLocalityLbSetting(settings.LocalityLbSetting); err != nil {
 		errs = multierror.Append(errs, err)
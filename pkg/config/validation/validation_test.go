@@ -2825,6 +2825,47 @@ func TestValidateLoadBalancer(t *testing.T) {
 			},
 		},
 			valid: false},
+
+		{name: "invalid load balancer with consistentHash load balancing, empty header name", in: networking.LoadBalancerSettings{
+			LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+				ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+					HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpHeaderName{},
+				},
+			},
+		},
+			valid: false},
+
+		{name: "valid load balancer with consistentHash load balancing on query parameter", in: networking.LoadBalancerSettings{
+			LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+				ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+					HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpQueryParameterName{
+						HttpQueryParameterName: "user",
+					},
+				},
+			},
+		},
+			valid: true},
+
+		{name: "invalid load balancer with consistentHash load balancing, empty query parameter", in: networking.LoadBalancerSettings{
+			LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+				ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+					HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_HttpQueryParameterName{},
+				},
+			},
+		},
+			valid: false},
+
+		{name: "invalid load balancer with consistentHash load balancing, ring too large", in: networking.LoadBalancerSettings{
+			LbPolicy: &networking.LoadBalancerSettings_ConsistentHash{
+				ConsistentHash: &networking.LoadBalancerSettings_ConsistentHashLB{
+					MinimumRingSize: 16 * 1024 * 1024,
+					HashKey: &networking.LoadBalancerSettings_ConsistentHashLB_UseSourceIp{
+						UseSourceIp: true,
+					},
+				},
+			},
+		},
+			valid: false},
 	}
 
 	for _, c := range cases {
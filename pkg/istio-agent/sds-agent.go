@@ -15,6 +15,7 @@
 package istioagent
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net"
 	"os"
@@ -26,6 +27,7 @@ import (
 
 	"istio.io/istio/pilot/pkg/security/model"
 	"istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/spiffe"
 	caClientInterface "istio.io/istio/security/pkg/nodeagent/caclient/interface"
 	citadel "istio.io/istio/security/pkg/nodeagent/caclient/providers/citadel"
 	gca "istio.io/istio/security/pkg/nodeagent/caclient/providers/google"
@@ -34,6 +36,7 @@ import (
 	"istio.io/istio/security/pkg/nodeagent/cache"
 	"istio.io/istio/security/pkg/nodeagent/sds"
 	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+	pkiutil "istio.io/istio/security/pkg/pki/util"
 	"istio.io/pkg/env"
 	"istio.io/pkg/log"
 )
@@ -76,6 +79,13 @@ var (
 	initialBackoffInMilliSecEnv = env.RegisterIntVar(initialBackoffInMilliSec, 0, "").Get()
 	pkcs8KeysEnv                = env.RegisterBoolVar(pkcs8Key, false, "Whether to generate PKCS#8 private keys").Get()
 	eccSigAlgEnv                = env.RegisterStringVar(eccSigAlg, "", "The type of ECC signature algorithm to use when generating private keys").Get()
+	credentialsDirEnv           = env.RegisterStringVar(credentialsDir, "",
+		"The directory of the file-backed credentials, e.g. mounted by a CSI driver or written by Vault agent. "+
+			"The credential foo is read from <dir>/foo/tls.crt, tls.key and ca.crt, or cert, key and cacert, "+
+			"served as the SDS resources foo and foo-cacert, and reloaded when the files change.").Get()
+	keyAlgorithmEnv = env.RegisterStringVar(keyAlgorithm, "",
+		"The algorithm of the generated private keys, one of RSA-2048, RSA-4096, ECDSA-P256 or ECDSA-P384. "+
+			"Takes precedence over "+eccSigAlg+".").Get()
 
 	// Location of K8S CA root.
 	k8sCAPath = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
@@ -87,6 +97,9 @@ var (
 	CitadelCACertPath = "./var/run/secrets/istio"
 
 	fileMountedCertsEnv = env.RegisterBoolVar(fileMountedCerts, false, "").Get()
+
+	federatedRootsFileEnv = env.RegisterStringVar(federatedRootsFile, path.Join(CitadelCACertPath, spiffe.FederatedRootsFile),
+		"The file of the cross-signed root certs of the federated trust domains, served with the local root cert").Get()
 )
 
 const (
@@ -135,8 +148,18 @@ const (
 	// when generating private keys. Currently only ECDSA is supported.
 	eccSigAlg = "ECC_SIGNATURE_ALGORITHM"
 
+	// The directory of the file-backed credentials served over SDS, e.g. mounted by a CSI driver.
+	credentialsDir = "CREDENTIALS_DIR"
+
+	// The algorithm and size of the generated private keys, e.g. ECDSA-P384. Takes precedence over eccSigAlg.
+	keyAlgorithm = "KEY_ALGORITHM"
+
 	// Indicates whether proxy uses file mounted certificates.
 	fileMountedCerts = "FILE_MOUNTED_CERTS"
+
+	// The file of the cross-signed roots of the federated trust domains, published by istiod in the
+	// istio-ca-root-cert ConfigMap.
+	federatedRootsFile = "FEDERATED_ROOTS_FILE"
 )
 
 var (
@@ -291,6 +314,12 @@ func NewSDSAgent(discAddr string, tlsRequired bool, pilotCertProvider, jwtPath,
 // 4. TODO: File watching, for backward compat/migration from mounted secrets.
 func (sa *SDSAgent) Start(isSidecar bool, podNamespace string) (*sds.Server, error) {
 	applyEnvVars()
+	if keyAlgorithmEnv != "" {
+		// Fail at startup rather than on every CSR.
+		if _, err := pkiutil.ParseKeyAlgorithm(keyAlgorithmEnv); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", keyAlgorithm, err)
+		}
+	}
 
 	gatewaySdsCacheOptions = workloadSdsCacheOptions
 
@@ -481,6 +510,7 @@ func (sa *SDSAgent) newSecretCache(serverOptions sds.Options) (workloadSecretCac
 	workloadSdsCacheOptions.TrustDomain = serverOptions.TrustDomain
 	workloadSdsCacheOptions.Pkcs8Keys = serverOptions.Pkcs8Keys
 	workloadSdsCacheOptions.ECCSigAlg = serverOptions.ECCSigAlg
+	workloadSdsCacheOptions.KeyAlgorithm = keyAlgorithmEnv
 	workloadSdsCacheOptions.OutputKeyCertToDir = serverOptions.OutputKeyCertToDir
 
 	return
@@ -531,6 +561,8 @@ func applyEnvVars() {
 		workloadSdsCacheOptions.AlwaysValidTokenFlag = true
 	}
 	workloadSdsCacheOptions.OutputKeyCertToDir = serverOptions.OutputKeyCertToDir
+	workloadSdsCacheOptions.FederatedRootsFile = federatedRootsFileEnv
+	workloadSdsCacheOptions.CredentialsDir = credentialsDirEnv
 }
 
 // shouldProvisionCertificates returns true if certs needs to be provisioned for the workload/gateway.
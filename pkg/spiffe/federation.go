@@ -0,0 +1,175 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TrustBundleSuffix is the suffix of the files, or ConfigMap keys, holding the root certificates of a
+// federated trust domain, e.g. "example.com.pem" for the trust domain "example.com".
+const TrustBundleSuffix = ".pem"
+
+// FederatedRootsFile is the name of the file, or key of the root cert ConfigMap, holding the cross-signed roots
+// of the federated trust domains.
+const FederatedRootsFile = "federated-roots.pem"
+
+// TrustBundles maps a federated trust domain to the PEM encoded root certificates of its CA.
+type TrustBundles map[string][]byte
+
+// LoadTrustBundles reads the trust bundles from the <trust-domain>.pem files in a directory. The directory
+// is usually a mounted ConfigMap, other files are ignored.
+func LoadTrustBundles(dir string) (TrustBundles, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trust bundles directory %s: %v", dir, err)
+	}
+	data := map[string]string{}
+	for _, f := range files {
+		// Skip the hidden entries of mounted ConfigMaps, e.g. "..data".
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read trust bundle %s: %v", f.Name(), err)
+		}
+		data[f.Name()] = string(b)
+	}
+	return ParseTrustBundles(data)
+}
+
+// ParseTrustBundles returns the trust bundles in the data of a ConfigMap, keyed by <trust-domain>.pem.
+// Other keys are ignored.
+func ParseTrustBundles(data map[string]string) (TrustBundles, error) {
+	bundles := TrustBundles{}
+	for key, value := range data {
+		if !strings.HasSuffix(key, TrustBundleSuffix) {
+			continue
+		}
+		trustDomain := strings.TrimSuffix(key, TrustBundleSuffix)
+		if trustDomain == "" || strings.Contains(trustDomain, "/") {
+			return nil, fmt.Errorf("invalid trust domain %q in trust bundle %s", trustDomain, key)
+		}
+		if err := verifyRootCerts([]byte(value)); err != nil {
+			return nil, fmt.Errorf("invalid trust bundle for trust domain %s: %v", trustDomain, err)
+		}
+		bundles[trustDomain] = []byte(value)
+	}
+	return bundles, nil
+}
+
+// TrustDomains returns the sorted trust domains of the bundles.
+func (b TrustBundles) TrustDomains() []string {
+	trustDomains := make([]string, 0, len(b))
+	for td := range b {
+		trustDomains = append(trustDomains, td)
+	}
+	sort.Strings(trustDomains)
+	return trustDomains
+}
+
+// CrossSign returns the PEM encoded cross-certificates of the federated roots, issued by the local CA. A
+// cross-certificate has the subject and public key of a federated root, and is name constrained to the SPIFFE IDs
+// of its trust domain: trusting the local roots and the cross-certificates binds each federated CA to its own
+// trust domain. A bundle for the local trust domain itself is ignored.
+func (b TrustBundles) CrossSign(localTrustDomain string, signerCert *x509.Certificate, signer crypto.Signer) ([]byte, error) {
+	var crossCerts bytes.Buffer
+	for _, td := range b.TrustDomains() {
+		if td == localTrustDomain {
+			continue
+		}
+		roots, err := parseCerts(b[td])
+		if err != nil {
+			return nil, fmt.Errorf("invalid trust bundle for trust domain %s: %v", td, err)
+		}
+		for _, root := range roots {
+			der, err := crossSign(td, root, signerCert, signer)
+			if err != nil {
+				return nil, fmt.Errorf("failed to cross-sign the root %q of trust domain %s: %v", root.Subject, td, err)
+			}
+			if err := pem.Encode(&crossCerts, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return crossCerts.Bytes(), nil
+}
+
+// crossSign issues a certificate for the key of a root, only valid for the URIs of a trust domain.
+func crossSign(trustDomain string, root, signerCert *x509.Certificate, signer crypto.Signer) ([]byte, error) {
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	notAfter := root.NotAfter
+	if signerCert.NotAfter.Before(notAfter) {
+		notAfter = signerCert.NotAfter
+	}
+	template := &x509.Certificate{
+		SerialNumber: serialNumber,
+		// The certificates issued by the federated CA must chain to the cross-certificate.
+		RawSubject:                  root.RawSubject,
+		SubjectKeyId:                root.SubjectKeyId,
+		NotBefore:                   root.NotBefore,
+		NotAfter:                    notAfter,
+		KeyUsage:                    x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid:       true,
+		IsCA:                        true,
+		PermittedURIDomains:         []string{trustDomain},
+		PermittedDNSDomainsCritical: true,
+	}
+	return x509.CreateCertificate(rand.Reader, template, signerCert, root.PublicKey, signer)
+}
+
+// verifyRootCerts returns an error if the PEM data doesn't contain certificates only.
+func verifyRootCerts(data []byte) error {
+	_, err := parseCerts(data)
+	return err
+}
+
+// parseCerts returns the certificates in PEM data, or an error if it contains anything else.
+func parseCerts(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for rest := bytes.TrimSpace(data); len(rest) > 0; rest = bytes.TrimSpace(rest) {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return nil, fmt.Errorf("failed to decode PEM block")
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block type %s", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,202 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"istio.io/istio/security/pkg/nodeagent/model"
+	"istio.io/istio/security/pkg/nodeagent/secretfetcher"
+	nodeagentutil "istio.io/istio/security/pkg/nodeagent/util"
+)
+
+// The file names of a credential in the credentials directory, following the layout of the Kubernetes TLS and
+// generic Secrets, as mounted by the Secrets Store CSI driver, cert-manager csi-driver or Vault agent templates.
+var (
+	credentialKeyCertFiles  = [][2]string{{"tls.crt", "tls.key"}, {"cert", "key"}}
+	credentialRootCertFiles = []string{"ca.crt", "cacert"}
+)
+
+// fileCredential returns the files of the credential of the SDS resource in the credentials directory: the
+// certificate chain and the private key, or the root certificate if the resource name ends with -cacert.
+// The credential foo is read from <credentials dir>/foo/.
+func (sc *SecretCache) fileCredential(resourceName string) (certChain, key, rootCert string, ok bool) {
+	if sc.configOptions.CredentialsDir == "" {
+		return "", "", "", false
+	}
+	name := strings.TrimSuffix(resourceName, secretfetcher.IngressGatewaySdsCaSuffix)
+	isRoot := name != resourceName
+	// The resource name must be a single path element, so that only the credentials directory is served.
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", "", "", false
+	}
+	// The workload certificate and root cert are never served from the credentials directory.
+	if name == WorkloadKeyCertResourceName || name == RootCertReqResourceName {
+		return "", "", "", false
+	}
+	dir := filepath.Join(sc.configOptions.CredentialsDir, name)
+	if isRoot {
+		for _, f := range credentialRootCertFiles {
+			if fileExists(filepath.Join(dir, f)) {
+				return "", "", filepath.Join(dir, f), true
+			}
+		}
+		return "", "", "", false
+	}
+	for _, files := range credentialKeyCertFiles {
+		if fileExists(filepath.Join(dir, files[0])) && fileExists(filepath.Join(dir, files[1])) {
+			return filepath.Join(dir, files[0]), filepath.Join(dir, files[1]), "", true
+		}
+	}
+	return "", "", "", false
+}
+
+// isFileCredential returns true if the SDS resource is a credential in the credentials directory.
+func (sc *SecretCache) isFileCredential(resourceName string) bool {
+	_, _, _, ok := sc.fileCredential(resourceName)
+	return ok
+}
+
+// generateFileCredential returns the secret of a credential in the credentials directory. Changes are pushed to the
+// proxy by watchCredentialsDir.
+func (sc *SecretCache) generateFileCredential(connKey ConnKey, token string) (*model.SecretItem, error) {
+	certChain, key, rootCert, _ := sc.fileCredential(connKey.ResourceName)
+	if rootCert != "" {
+		// Unlike the file-root resources, the root cert of a credential isn't the root cert of the workload.
+		cert, err := readFileWithTimeout(rootCert)
+		if err != nil {
+			return nil, err
+		}
+		expireTime, err := nodeagentutil.ParseCertAndGetExpiryTimestamp(cert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract expiration time in the root certificate loaded from file: %v", err)
+		}
+		now := time.Now()
+		return &model.SecretItem{
+			ResourceName: connKey.ResourceName,
+			RootCert:     cert,
+			ExpireTime:   expireTime,
+			Token:        token,
+			CreatedTime:  now,
+			Version:      now.String(),
+		}, nil
+	}
+	return sc.generateKeyCertFromExistingFiles(certChain, key, token, connKey)
+}
+
+// watchCredentialsDir watches the credentials directory and the directory of each credential, and pushes the
+// credentials to the proxies when their files change. The directories are watched rather than the files, so that
+// credentials created after they were requested, and files replaced through a symlink, are picked up as well.
+func (sc *SecretCache) watchCredentialsDir() {
+	dir := sc.configOptions.CredentialsDir
+	if dir == "" {
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		cacheLog.Errorf("failed to create a watcher for the credentials directory %s: %v", dir, err)
+		return
+	}
+	if err := watcher.Add(dir); err != nil {
+		cacheLog.Errorf("failed to watch the credentials directory %s, credentials won't be reloaded: %v", dir, err)
+		_ = watcher.Close()
+		return
+	}
+	if entries, err := ioutil.ReadDir(dir); err == nil {
+		for _, e := range entries {
+			if e.IsDir() {
+				sc.watchCredential(watcher, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sc.credentialsWatcher = watcher
+
+	go func() {
+		var timerC <-chan time.Time
+		changed := map[string]struct{}{}
+		for {
+			select {
+			case <-timerC:
+				timerC = nil
+				sc.pushFileCredentials(changed)
+				changed = map[string]struct{}{}
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				rel, err := filepath.Rel(dir, e.Name)
+				if err != nil || rel == "." {
+					continue
+				}
+				name := strings.SplitN(rel, string(filepath.Separator), 2)[0]
+				// A new credential: watch its directory.
+				if name == rel && e.Op&fsnotify.Create == fsnotify.Create {
+					sc.watchCredential(watcher, e.Name)
+				}
+				changed[name] = struct{}{}
+				// Use a timer to debounce watch updates
+				if timerC == nil {
+					timerC = time.After(100 * time.Millisecond)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				cacheLog.Errorf("error watching the credentials directory %s: %v", dir, err)
+			}
+		}
+	}()
+}
+
+func (sc *SecretCache) watchCredential(watcher *fsnotify.Watcher, dir string) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return
+	}
+	if err := watcher.Add(dir); err != nil {
+		cacheLog.Errorf("failed to watch the credential directory %s: %v", dir, err)
+	}
+}
+
+// pushFileCredentials regenerates the secrets of the given credentials, and pushes them to the proxies. This
+// includes the connections that were waiting for a credential that didn't exist yet.
+func (sc *SecretCache) pushFileCredentials(names map[string]struct{}) {
+	sc.secrets.Range(func(k interface{}, v interface{}) bool {
+		connKey := k.(ConnKey)
+		name := strings.TrimSuffix(connKey.ResourceName, secretfetcher.IngressGatewaySdsCaSuffix)
+		if _, f := names[name]; !f || !sc.isFileCredential(connKey.ResourceName) {
+			return true
+		}
+		secret := v.(model.SecretItem)
+		if _, ns, err := sc.generateFileSecret(connKey, secret.Token); err != nil {
+			cacheLog.Errorf("%v: error in generating secret after credential change %v", connKey, err)
+		} else {
+			cacheLog.Infof("%v: credential %s changed, triggering secret push to proxy", connKey, name)
+			sc.callbackWithTimeout(connKey, ns)
+		}
+		return true
+	})
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -40,6 +41,7 @@ import (
 	pkiutil "istio.io/istio/security/pkg/pki/util"
 	"istio.io/istio/security/pkg/util"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/google/uuid"
 )
 
@@ -130,6 +132,19 @@ type Options struct {
 	// The type of Elliptical Signature algorithm to use
 	// when generating private keys. Currently only ECDSA is supported.
 	ECCSigAlg string
+
+	// CredentialsDir is the directory of the file-backed credentials, e.g. mounted by a CSI driver or written by
+	// Vault agent. The credential foo, in <CredentialsDir>/foo/, is served as the SDS resources foo and foo-cacert,
+	// and takes precedence over the Kubernetes Secret foo.
+	CredentialsDir string
+
+	// KeyAlgorithm is the algorithm and size of the generated private keys, e.g. ECDSA-P384. If set, it takes
+	// precedence over ECCSigAlg.
+	KeyAlgorithm string
+
+	// FederatedRootsFile is the file of the roots of the federated trust domains, cross-signed by the CA for their
+	// trust domain only. They are served with the root cert of the local trust domain.
+	FederatedRootsFile string
 }
 
 // SecretManager defines secrets management interface which is used by SDS.
@@ -195,11 +210,18 @@ type SecretCache struct {
 	existingKeyFile       string
 	existingRootCertFile  string
 
+	// Read/Write to federatedRoots should use getFederatedRoots() and loadFederatedRoots().
+	federatedRootsMutex *sync.RWMutex
+	federatedRoots      []byte
+
 	// certWatcher watches the certificates for changes and triggers a notification to proxy.
 	certWatcher filewatcher.FileWatcher
 	// unique certs being watched with file watcher.
 	fileCerts map[string]map[ConnKey]struct{}
 	certMutex *sync.RWMutex
+
+	// credentialsWatcher watches the credentials directory, if set.
+	credentialsWatcher *fsnotify.Watcher
 }
 
 // NewSecretCache creates a new secret cache.
@@ -210,6 +232,7 @@ func NewSecretCache(fetcher *secretfetcher.SecretFetcher,
 		closing:               make(chan bool),
 		notifyCallback:        notifyCb,
 		rootCertMutex:         &sync.RWMutex{},
+		federatedRootsMutex:   &sync.RWMutex{},
 		configOptions:         options,
 		randMutex:             &sync.Mutex{},
 		existingCertChainFile: defaultCertChainFilePath,
@@ -228,6 +251,8 @@ func NewSecretCache(fetcher *secretfetcher.SecretFetcher,
 
 	atomic.StoreUint64(&ret.secretChangedCount, 0)
 	atomic.StoreUint64(&ret.rootCertChangedCount, 0)
+	ret.watchFederatedRoots()
+	ret.watchCredentialsDir()
 	go ret.keyCertRotationJob()
 	return ret
 }
@@ -316,7 +341,7 @@ func (sc *SecretCache) GenerateSecret(ctx context.Context, connectionID, resourc
 	t := time.Now()
 	ns = &model.SecretItem{
 		ResourceName: resourceName,
-		RootCert:     rootCert,
+		RootCert:     sc.trustBundle(rootCert),
 		ExpireTime:   rootCertExpr,
 		Token:        token,
 		CreatedTime:  t,
@@ -408,6 +433,10 @@ func (sc *SecretCache) ShouldWaitForIngressGatewaySecret(connectionID, resourceN
 	if sc.fetcher.UseCaClient || fileMountedCertsOnly {
 		return false
 	}
+	// A credential in the credentials directory is served from its files.
+	if sc.isFileCredential(resourceName) {
+		return false
+	}
 
 	connKey := ConnKey{
 		ConnectionID: connectionID,
@@ -476,6 +505,9 @@ func (sc *SecretCache) callbackWithTimeout(connKey ConnKey, secret *model.Secret
 // Close shuts down the secret cache.
 func (sc *SecretCache) Close() {
 	_ = sc.certWatcher.Close()
+	if sc.credentialsWatcher != nil {
+		_ = sc.credentialsWatcher.Close()
+	}
 	sc.closing <- true
 }
 
@@ -597,7 +629,7 @@ func (sc *SecretCache) rotate(updateRootFlag bool) {
 			rootCert, rootCertExpr := sc.getRootCert()
 			ns := &model.SecretItem{
 				ResourceName: connKey.ResourceName,
-				RootCert:     rootCert,
+				RootCert:     sc.trustBundle(rootCert),
 				ExpireTime:   rootCertExpr,
 				Token:        secret.Token,
 				CreatedTime:  now,
@@ -729,6 +761,110 @@ func (sc *SecretCache) keyCertificateExist(certPath, keyPath string) bool {
 	return true
 }
 
+// trustBundle returns the root cert of the local trust domain followed by the cross-signed roots of the federated
+// trust domains.
+func (sc *SecretCache) trustBundle(rootCert []byte) []byte {
+	federatedRoots := sc.getFederatedRoots()
+	if len(federatedRoots) == 0 || rootCert == nil {
+		return rootCert
+	}
+	bundle := make([]byte, 0, len(rootCert)+len(federatedRoots)+1)
+	bundle = append(bundle, rootCert...)
+	if !bytes.HasSuffix(bundle, []byte("\n")) {
+		bundle = append(bundle, '\n')
+	}
+	return append(bundle, federatedRoots...)
+}
+
+// getFederatedRoots returns the cached roots of the federated trust domains. This method is thread safe.
+func (sc *SecretCache) getFederatedRoots() []byte {
+	sc.federatedRootsMutex.RLock()
+	defer sc.federatedRootsMutex.RUnlock()
+	return sc.federatedRoots
+}
+
+// loadFederatedRoots reads the roots of the federated trust domains into the cache, and returns true if they
+// changed. A missing file means there is no federated trust domain.
+func (sc *SecretCache) loadFederatedRoots() bool {
+	federatedRoots, err := ioutil.ReadFile(sc.configOptions.FederatedRootsFile)
+	if err != nil && !os.IsNotExist(err) {
+		cacheLog.Errorf("failed to read the federated roots, keeping the previous ones: %v", err)
+		return false
+	}
+	sc.federatedRootsMutex.Lock()
+	defer sc.federatedRootsMutex.Unlock()
+	if bytes.Equal(sc.federatedRoots, federatedRoots) {
+		return false
+	}
+	sc.federatedRoots = federatedRoots
+	return true
+}
+
+// watchFederatedRoots loads the roots of the federated trust domains, and pushes the root cert to the proxies
+// when they change.
+func (sc *SecretCache) watchFederatedRoots() {
+	file := sc.configOptions.FederatedRootsFile
+	if file == "" {
+		return
+	}
+	sc.loadFederatedRoots()
+	if err := sc.certWatcher.Add(file); err != nil {
+		cacheLog.Errorf("error adding watcher for the federated roots %s, they won't be reloaded: %v", file, err)
+		return
+	}
+	go func() {
+		var timerC <-chan time.Time
+		for {
+			select {
+			case <-timerC:
+				timerC = nil
+				if sc.loadFederatedRoots() {
+					cacheLog.Infof("federated roots changed, triggering root cert push to proxy [%s]", file)
+					sc.pushRootCert()
+				}
+			case e := <-sc.certWatcher.Events(file):
+				if len(e.Op.String()) > 0 { // To avoid spurious events, mainly coming from tests.
+					// Use a timer to debounce watch updates
+					if timerC == nil {
+						timerC = time.After(100 * time.Millisecond)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// pushRootCert regenerates the root cert of all the connections and pushes it to the proxies.
+func (sc *SecretCache) pushRootCert() {
+	sc.secrets.Range(func(k interface{}, v interface{}) bool {
+		connKey := k.(ConnKey)
+		if connKey.ResourceName != RootCertReqResourceName {
+			return true
+		}
+		secret := v.(model.SecretItem)
+		sdsFromFile, ns, err := sc.generateFileSecret(connKey, secret.Token)
+		if err != nil {
+			cacheLog.Errorf("%v: error in generating root cert after federated roots change %v", connKey, err)
+			return true
+		}
+		if !sdsFromFile {
+			now := time.Now()
+			rootCert, rootCertExpr := sc.getRootCert()
+			ns = &model.SecretItem{
+				ResourceName: connKey.ResourceName,
+				RootCert:     sc.trustBundle(rootCert),
+				ExpireTime:   rootCertExpr,
+				Token:        secret.Token,
+				CreatedTime:  now,
+				Version:      now.String(),
+			}
+			sc.secrets.Store(connKey, *ns)
+		}
+		sc.callbackWithTimeout(connKey, ns)
+		return true
+	})
+}
+
 // Generate a root certificate item from the passed in rootCertPath
 func (sc *SecretCache) generateRootCertFromExistingFile(rootCertPath, token string, connKey ConnKey) (*model.SecretItem, error) {
 	rootCert, err := readFileWithTimeout(rootCertPath)
@@ -817,6 +953,7 @@ func (sc *SecretCache) generateFileSecret(connKey ConnKey, token string) (bool,
 	case connKey.ResourceName == RootCertReqResourceName && sc.rootCertificateExist(sc.existingRootCertFile):
 		sdsFromFile = true
 		if sitem, err = sc.generateRootCertFromExistingFile(sc.existingRootCertFile, token, connKey); err == nil {
+			sitem.RootCert = sc.trustBundle(sitem.RootCert)
 			sc.addFileWatcher(sc.existingRootCertFile, token, connKey)
 		}
 	// Default workload certificate.
@@ -826,6 +963,10 @@ func (sc *SecretCache) generateFileSecret(connKey ConnKey, token string) (bool,
 			// Adding cert is sufficient here as key can't change without changing the cert.
 			sc.addFileWatcher(sc.existingCertChainFile, token, connKey)
 		}
+	// Credential in the credentials directory.
+	case sc.isFileCredential(connKey.ResourceName):
+		sdsFromFile = true
+		sitem, err = sc.generateFileCredential(connKey, token)
 	default:
 		// Check if the resource name refers to a file mounted certificate.
 		// Currently used in destination rules and server certs (via metadata).
@@ -891,6 +1032,14 @@ func (sc *SecretCache) generateSecret(ctx context.Context, token string, connKey
 		PKCS8Key:   sc.configOptions.Pkcs8Keys,
 		ECSigAlg:   pkiutil.SupportedECSignatureAlgorithms(sc.configOptions.ECCSigAlg),
 	}
+	if sc.configOptions.KeyAlgorithm != "" {
+		keyAlgorithm, err := pkiutil.ParseKeyAlgorithm(sc.configOptions.KeyAlgorithm)
+		if err != nil {
+			cacheLog.Errorf("%s failed to generate key and certificate for CSR: %v", logPrefix, err)
+			return nil, err
+		}
+		keyAlgorithm.ApplyTo(&options)
+	}
 
 	// Generate the cert/key, send CSR to CA.
 	csrPEM, keyPEM, err := pkiutil.GenCSR(options)
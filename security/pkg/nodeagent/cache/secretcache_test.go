@@ -837,6 +837,92 @@ func TestRootCertificateExists(t *testing.T) {
 	}
 }
 
+func TestFederatedRoots(t *testing.T) {
+	rootCertPath := "./testdata/root-cert.pem"
+	rootCert, err := ioutil.ReadFile(rootCertPath)
+	if err != nil {
+		t.Fatalf("Error reading the root cert file: %v", err)
+	}
+	federatedRoots, err := ioutil.ReadFile("./testdata/cert-chain.pem")
+	if err != nil {
+		t.Fatalf("Error reading the cert chain file: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "federated-roots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	federatedRootsFile := filepath.Join(dir, "federated-roots.pem")
+	if err := ioutil.WriteFile(federatedRootsFile, federatedRoots, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := &secretfetcher.SecretFetcher{
+		UseCaClient: false,
+	}
+	opt := Options{
+		RotationInterval:   100 * time.Millisecond,
+		EvictionDuration:   0,
+		FederatedRootsFile: federatedRootsFile,
+	}
+	pushed := make(chan *model.SecretItem, 10)
+	notifyCallback := func(_ ConnKey, secret *model.SecretItem) error {
+		select {
+		case pushed <- secret:
+		default:
+		}
+		return nil
+	}
+
+	// Supply a fake watcher so that we can watch file events.
+	var fakeWatcher *filewatcher.FakeWatcher
+	newFileWatcher, fakeWatcher = filewatcher.NewFakeWatcher(func(_ string, _ bool) {})
+	sc := NewSecretCache(fetcher, notifyCallback, opt)
+	defer func() {
+		sc.Close()
+		newFileWatcher = filewatcher.NewWatcher
+	}()
+	sc.existingRootCertFile = rootCertPath
+
+	bundle := func(federated []byte) []byte {
+		return []byte(strings.TrimSuffix(string(rootCert), "\n") + "\n" + string(federated))
+	}
+	gotSecret, err := sc.GenerateSecret(context.Background(), "proxy1-id", RootCertReqResourceName, "jwtToken1")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	if !bytes.Equal(gotSecret.RootCert, bundle(federatedRoots)) {
+		t.Errorf("got root cert %s, want the root cert followed by the federated roots", gotSecret.RootCert)
+	}
+
+	// Updating the federated roots pushes the root cert again.
+	if err := ioutil.WriteFile(federatedRootsFile, rootCert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fakeWatcher.InjectEvent(federatedRootsFile, fsnotify.Event{
+		Name: federatedRootsFile,
+		Op:   fsnotify.Write,
+	})
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case secret := <-pushed:
+			if secret != nil && bytes.Equal(secret.RootCert, bundle(rootCert)) {
+				// Removing the federated roots serves the local root cert only.
+				if err := os.Remove(federatedRootsFile); err != nil {
+					t.Fatal(err)
+				}
+				if !sc.loadFederatedRoots() || !bytes.Equal(sc.trustBundle(rootCert), rootCert) {
+					t.Errorf("want the local root cert only without federated roots")
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatalf("root cert not pushed after the federated roots changed")
+		}
+	}
+}
+
 func TestKeyCertificateExist(t *testing.T) {
 	testCases := map[string]struct {
 		certPath     string
@@ -1131,6 +1217,126 @@ func TestWorkloadAgentGenerateSecretFromFileOverSds(t *testing.T) {
 	notifyEvent.Wait()
 }
 
+func TestGatewayAgentGenerateSecretFromCredentialsDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	credentialDir := filepath.Join(dir, "foo")
+	if err := os.Mkdir(credentialDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"tls.crt": "./testdata/cert-chain.pem",
+		"tls.key": "./testdata/key.pem",
+		"ca.crt":  "./testdata/root-cert.pem",
+	}
+	contents := map[string][]byte{}
+	for name, src := range files {
+		b, err := ioutil.ReadFile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[name] = b
+		if err := ioutil.WriteFile(filepath.Join(credentialDir, name), b, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notified := make(chan ConnKey, 10)
+	notifyCallback := func(connKey ConnKey, _ *model.SecretItem) error {
+		notified <- connKey
+		return nil
+	}
+	waitForNotify := func(resource string) {
+		t.Helper()
+		for {
+			select {
+			case connKey := <-notified:
+				if connKey.ResourceName == resource {
+					return
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for %s to be pushed", resource)
+			}
+		}
+	}
+
+	fetcher := &secretfetcher.SecretFetcher{UseCaClient: false}
+	sc := NewSecretCache(fetcher, notifyCallback, Options{RotationInterval: 100 * time.Millisecond, CredentialsDir: dir})
+	defer sc.Close()
+
+	for _, resource := range []string{"foo", "foo-cacert"} {
+		if sc.ShouldWaitForIngressGatewaySecret("proxy1-id", resource, "", false) {
+			t.Errorf("ShouldWaitForIngressGatewaySecret(%s) = true for a file-backed credential", resource)
+		}
+	}
+	// The workload certificate and root cert are not served from the credentials directory.
+	for _, name := range []string{WorkloadKeyCertResourceName, RootCertReqResourceName} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		for f, b := range contents {
+			if err := ioutil.WriteFile(filepath.Join(dir, name, f), b, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, resource := range []string{"bar", "..", "../credentials/foo", WorkloadKeyCertResourceName,
+		WorkloadKeyCertResourceName + secretfetcher.IngressGatewaySdsCaSuffix, RootCertReqResourceName} {
+		if sc.isFileCredential(resource) {
+			t.Errorf("isFileCredential(%s) = true, want false", resource)
+		}
+	}
+
+	ctx := context.Background()
+	gotSecret, err := sc.GenerateSecret(ctx, "proxy1-id", "foo", "")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	if err := verifySecret(gotSecret, &model.SecretItem{
+		ResourceName:     "foo",
+		CertificateChain: contents["tls.crt"],
+		PrivateKey:       contents["tls.key"],
+	}); err != nil {
+		t.Errorf("Secret verification failed: %v", err)
+	}
+
+	gotSecretRoot, err := sc.GenerateSecret(ctx, "proxy1-id", "foo-cacert", "")
+	if err != nil {
+		t.Fatalf("Failed to get secrets: %v", err)
+	}
+	if !bytes.Equal(gotSecretRoot.RootCert, contents["ca.crt"]) {
+		t.Errorf("got root cert %s, want %s", gotSecretRoot.RootCert, contents["ca.crt"])
+	}
+	// The root cert of a credential isn't the root cert of the workload.
+	if rootCert, _ := sc.getRootCert(); rootCert != nil {
+		t.Errorf("the root cert of the credential replaced the root cert of the workload")
+	}
+
+	// Rewrite the key and validate that the credential is pushed.
+	if err := ioutil.WriteFile(filepath.Join(credentialDir, "tls.key"), contents["tls.key"], 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitForNotify("foo")
+
+	// A credential created after it was requested is pushed once its files are written.
+	if !sc.ShouldWaitForIngressGatewaySecret("proxy1-id", "bar", "", false) {
+		t.Errorf("ShouldWaitForIngressGatewaySecret(bar) = false for a missing credential")
+	}
+	barDir := filepath.Join(dir, "bar")
+	if err := os.Mkdir(barDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{"tls.crt", "tls.key"} {
+		if err := ioutil.WriteFile(filepath.Join(barDir, f), contents[f], 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForNotify("bar")
+}
+
 func TestWorkloadAgentGenerateSecretFromFileOverSdsWithBogusFiles(t *testing.T) {
 	fetcher := &secretfetcher.SecretFetcher{}
 	originalTimeout := totalTimeout
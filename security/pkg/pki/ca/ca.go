@@ -16,11 +16,13 @@ package ca
 
 import (
 	"context"
+	"crypto"
+	"crypto/rand"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
-	"io/ioutil"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -90,6 +92,10 @@ type IstioCAOptions struct {
 
 	// Config for creating self-signed root cert rotator.
 	RotatorConfig *SelfSignedCARootCertRotatorConfig
+
+	// AllowedKeyAlgorithms are the key algorithms of the CSRs the CA signs. If empty, all the supported key
+	// algorithms are allowed.
+	AllowedKeyAlgorithms []util.KeyAlgorithm
 }
 
 // NewSelfSignedIstioCAOptions returns a new IstioCAOptions instance using self-signed certificate.
@@ -97,7 +103,7 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 	rootCertGracePeriodPercentile int, caCertTTL, rootCertCheckInverval, defaultCertTTL,
 	maxCertTTL time.Duration, org string, dualUse bool, namespace string,
 	readCertRetryInterval time.Duration, client corev1.CoreV1Interface,
-	rootCertFile string, enableJitter bool) (caOpts *IstioCAOptions, err error) {
+	rootCertFile string, enableJitter bool, keyAlgorithm util.KeyAlgorithm) (caOpts *IstioCAOptions, err error) {
 	// For the first time the CA is up, if readSigningCertOnly is unset,
 	// it generates a self-signed key/cert pair and write it to CASecret.
 	// For subsequent restart, CA will reads key/cert from CASecret.
@@ -133,6 +139,7 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 			rootCertFile:       rootCertFile,
 			enableJitter:       enableJitter,
 			client:             client,
+			keyAlgorithm:       keyAlgorithm,
 		},
 	}
 	if scrtErr != nil {
@@ -146,6 +153,7 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 			RSAKeySize:   caKeySize,
 			IsDualUse:    dualUse,
 		}
+		keyAlgorithm.ApplyTo(&options)
 		pemCert, pemKey, ckErr := util.GenCertKeyFromOptions(options)
 		if ckErr != nil {
 			return nil, fmt.Errorf("unable to generate CA cert and key for self-signed CA (%v)", ckErr)
@@ -191,31 +199,37 @@ func NewSelfSignedIstioCAOptions(ctx context.Context,
 // NewPluggedCertIstioCAOptions returns a new IstioCAOptions instance using given certificate.
 func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile, rootCertFile string,
 	defaultCertTTL, maxCertTTL time.Duration, namespace string, client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
-	caOpts = &IstioCAOptions{
+	bundle, err := util.NewVerifiedKeyCertBundleFromFile(signingCertFile, signingKeyFile, certChainFile, rootCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+	return newPluggedIstioCAOptions(bundle, defaultCertTTL, maxCertTTL, namespace, client)
+}
+
+// NewPluggedSignerIstioCAOptions returns a new IstioCAOptions instance using given certificate, whose private key
+// is held by the signer, e.g. in an HSM or a KMS.
+func NewPluggedSignerIstioCAOptions(certChainFile, signingCertFile, rootCertFile string, signer crypto.Signer,
+	defaultCertTTL, maxCertTTL time.Duration, namespace string, client corev1.CoreV1Interface) (caOpts *IstioCAOptions, err error) {
+	bundle, err := util.NewVerifiedKeyCertBundleWithSignerFromFile(signingCertFile, certChainFile, rootCertFile, signer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+	}
+	return newPluggedIstioCAOptions(bundle, defaultCertTTL, maxCertTTL, namespace, client)
+}
+
+func newPluggedIstioCAOptions(bundle util.KeyCertBundle, defaultCertTTL, maxCertTTL time.Duration,
+	namespace string, client corev1.CoreV1Interface) (*IstioCAOptions, error) {
+	caOpts := &IstioCAOptions{
 		CAType:         pluggedCertCA,
 		DefaultCertTTL: defaultCertTTL,
 		MaxCertTTL:     maxCertTTL,
-	}
-	if caOpts.KeyCertBundle, err = util.NewVerifiedKeyCertBundleFromFile(
-		signingCertFile, signingKeyFile, certChainFile, rootCertFile); err != nil {
-		return nil, fmt.Errorf("failed to create CA KeyCertBundle (%v)", err)
+		KeyCertBundle:  bundle,
 	}
 
 	// Validate that the passed in signing cert can be used as CA.
 	// The check can't be done inside `KeyCertBundle`, since bundle could also be used to
 	// validate workload certificates (i.e., where the leaf certificate is not a CA).
-	b, err := ioutil.ReadFile(signingCertFile)
-	if err != nil {
-		return nil, err
-	}
-	block, _ := pem.Decode(b)
-	if block == nil {
-		return nil, fmt.Errorf("invalid PEM encoded certificate")
-	}
-	cert, err := x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse X.509 certificate")
-	}
+	cert, _, _, _ := bundle.GetAll()
 	if !cert.IsCA {
 		return nil, fmt.Errorf("certificate is not authorized to sign other certificates")
 	}
@@ -224,7 +238,7 @@ func NewPluggedCertIstioCAOptions(certChainFile, signingCertFile, signingKeyFile
 	if len(crt) == 0 {
 		crt = caOpts.KeyCertBundle.GetRootCertPem()
 	}
-	if err = updateCertInConfigmap(namespace, client, crt); err != nil {
+	if err := updateCertInConfigmap(namespace, client, crt); err != nil {
 		pkiCaLog.Errorf("Failed to write Citadel cert to configmap (%v). Node agents will not be able to connect.", err)
 	}
 	return caOpts, nil
@@ -237,6 +251,8 @@ type IstioCA struct {
 
 	keyCertBundle util.KeyCertBundle
 
+	allowedKeyAlgorithms []util.KeyAlgorithm
+
 	livenessProbe *probe.Probe
 
 	// rootCertRotator periodically rotates self-signed root cert for CA. It is nil
@@ -251,6 +267,8 @@ func NewIstioCA(opts *IstioCAOptions) (*IstioCA, error) {
 		maxCertTTL:     opts.MaxCertTTL,
 		keyCertBundle:  opts.KeyCertBundle,
 		livenessProbe:  probe.NewProbe(),
+
+		allowedKeyAlgorithms: opts.AllowedKeyAlgorithms,
 	}
 
 	if opts.CAType == selfSignedCA && opts.RotatorConfig.CheckInterval > time.Duration(0) {
@@ -279,6 +297,9 @@ func (ca *IstioCA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 	if err != nil {
 		return nil, caerror.NewError(caerror.CSRError, err)
 	}
+	if err := ca.checkKeyAlgorithm(csr.PublicKey); err != nil {
+		return nil, caerror.NewError(caerror.CSRError, err)
+	}
 
 	lifetime := requestedLifetime
 	// If the requested requestedLifetime is non-positive, apply the default TTL.
@@ -305,6 +326,23 @@ func (ca *IstioCA) Sign(csrPEM []byte, subjectIDs []string, requestedLifetime ti
 	return cert, nil
 }
 
+// checkKeyAlgorithm returns an error if the key algorithm of the CSR isn't allowed.
+func (ca *IstioCA) checkKeyAlgorithm(pub interface{}) error {
+	if len(ca.allowedKeyAlgorithms) == 0 {
+		return nil
+	}
+	algorithm, err := util.KeyAlgorithmOf(pub)
+	if err != nil {
+		return err
+	}
+	for _, allowed := range ca.allowedKeyAlgorithms {
+		if algorithm == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("key algorithm %s is not allowed, expecting one of %v", algorithm, ca.allowedKeyAlgorithms)
+}
+
 // SignWithCertChain is similar to Sign but returns the leaf cert and the entire cert chain.
 func (ca *IstioCA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl time.Duration, forCA bool) ([]byte, error) {
 	cert, err := ca.Sign(csrPEM, subjectIDs, ttl, forCA)
@@ -318,6 +356,28 @@ func (ca *IstioCA) SignWithCertChain(csrPEM []byte, subjectIDs []string, ttl tim
 	return cert, nil
 }
 
+// GenCRL returns a PEM encoded certificate revocation list of the revoked certificates, signed by the CA and valid
+// for ttl.
+func (ca *IstioCA) GenCRL(revoked []pkix.RevokedCertificate, ttl time.Duration) ([]byte, error) {
+	signingCert, signingKey, _, _ := ca.keyCertBundle.GetAll()
+	if signingCert == nil {
+		return nil, caerror.NewError(caerror.CANotReady, fmt.Errorf("Istio CA is not ready")) // nolint
+	}
+	if signingCert.KeyUsage != 0 && signingCert.KeyUsage&x509.KeyUsageCRLSign == 0 {
+		return nil, fmt.Errorf("the CA certificate is not authorized to sign CRLs, its key usage misses cRLSign")
+	}
+	signer, ok := (*signingKey).(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the CA private key can't sign")
+	}
+	now := time.Now()
+	crl, err := signingCert.CreateCRL(rand.Reader, signer, revoked, now, now.Add(ttl))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the CRL: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), nil
+}
+
 // GetCAKeyCertBundle returns the KeyCertBundle for the CA.
 func (ca *IstioCA) GetCAKeyCertBundle() util.KeyCertBundle {
 	return ca.keyCertBundle
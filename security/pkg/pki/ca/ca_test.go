@@ -19,8 +19,10 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"io/ioutil"
+	"math/big"
 	"reflect"
 	"testing"
 	"time"
@@ -31,6 +33,7 @@ import (
 	"istio.io/istio/security/pkg/k8s/configmap"
 	k8ssecret "istio.io/istio/security/pkg/k8s/secret"
 	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/signer"
 	"istio.io/istio/security/pkg/pki/util"
 )
 
@@ -101,7 +104,7 @@ func TestCreateSelfSignedIstioCAWithoutSecret(t *testing.T) {
 	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
 		0, caCertTTL, rootCertCheckInverval, defaultCertTTL,
 		maxCertTTL, org, false, caNamespace, -1, client.CoreV1(),
-		rootCertFile, false)
+		rootCertFile, false, "")
 	if err != nil {
 		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
 	}
@@ -192,7 +195,7 @@ func TestCreateSelfSignedIstioCAWithSecret(t *testing.T) {
 	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
 		0, caCertTTL, rootCertCheckInverval, defaultCertTTL, maxCertTTL,
 		org, false, caNamespace, -1, client.CoreV1(),
-		rootCertFile, false)
+		rootCertFile, false, "")
 	if err != nil {
 		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
 	}
@@ -262,7 +265,7 @@ func TestCreateSelfSignedIstioCAReadSigningCertOnly(t *testing.T) {
 	defer cancel0()
 	_, err := NewSelfSignedIstioCAOptions(ctx0, 0,
 		caCertTTL, defaultCertTTL, rootCertCheckInverval, maxCertTTL, org, false,
-		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false)
+		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false, "")
 	if err == nil {
 		t.Errorf("Expected error, but succeeded.")
 	} else if err.Error() != expectedErr {
@@ -281,7 +284,7 @@ func TestCreateSelfSignedIstioCAReadSigningCertOnly(t *testing.T) {
 	defer cancel1()
 	caopts, err := NewSelfSignedIstioCAOptions(ctx1, 0,
 		caCertTTL, defaultCertTTL, rootCertCheckInverval, maxCertTTL, org, false,
-		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false)
+		caNamespace, time.Millisecond*10, client.CoreV1(), rootCertFile, false, "")
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -485,7 +488,7 @@ func TestSignCSRForCA(t *testing.T) {
 		}
 
 		fields := &util.VerifyFields{
-			KeyUsage: x509.KeyUsageCertSign,
+			KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 			IsCA:     true,
 			Host:     subjectID,
 		}
@@ -591,6 +594,163 @@ root-cert-3`
 	}
 }
 
+func TestCreatePluggedSignerCA(t *testing.T) {
+	rootCertFile := "../testdata/multilevelpki/root-cert.pem"
+	certChainFile := "../testdata/multilevelpki/int2-cert-chain.pem"
+	signingCertFile := "../testdata/multilevelpki/int2-cert.pem"
+	signingKeyFile := "../testdata/multilevelpki/int2-key.pem"
+	caNamespace := "default"
+
+	client := fake.NewSimpleClientset()
+
+	mismatchedSigner, err := signer.NewFileSigner("../testdata/multilevelpki/int-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPluggedSignerIstioCAOptions(certChainFile, signingCertFile, rootCertFile, mismatchedSigner,
+		30*time.Minute, time.Hour, caNamespace, client.CoreV1()); err == nil {
+		t.Errorf("Created a plugged-signer CA Options with a signer not matching the signing cert")
+	}
+
+	keySigner, err := signer.NewFileSigner(signingKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caopts, err := NewPluggedSignerIstioCAOptions(certChainFile, signingCertFile, rootCertFile, keySigner,
+		30*time.Minute, time.Hour, caNamespace, client.CoreV1())
+	if err != nil {
+		t.Fatalf("Failed to create a plugged-signer CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Got error while creating plugged-signer CA: %v", err)
+	}
+
+	_, signingKeyBytes, _, _ := ca.GetCAKeyCertBundle().GetAllPem()
+	if len(signingKeyBytes) != 0 {
+		t.Errorf("Plugged-signer CA has a signing key pem")
+	}
+
+	csrPEM, privPEM, err := util.GenCSR(util.CertOptions{RSAKeySize: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, err := ca.SignWithCertChain(csrPEM, []string{"spiffe://cluster.local/ns/foo/sa/bar"}, time.Hour, false)
+	if err != nil {
+		t.Fatalf("Failed to sign with the plugged-signer CA: %v", err)
+	}
+	rootCert, err := ioutil.ReadFile(rootCertFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := util.Verify(certPEM, privPEM, certPEM, rootCert); err != nil {
+		t.Errorf("Failed to verify the cert signed by the plugged-signer CA: %v", err)
+	}
+}
+
+func TestGenCRL(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
+		0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, "default", -1, client.CoreV1(),
+		"", false, "")
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA: %v", err)
+	}
+
+	revoked := []pkix.RevokedCertificate{{SerialNumber: big.NewInt(42), RevocationTime: time.Now().UTC()}}
+	crlPEM, err := ca.GenCRL(revoked, time.Hour)
+	if err != nil {
+		t.Fatalf("GenCRL() failed: %v", err)
+	}
+	crl, err := x509.ParseCRL(crlPEM)
+	if err != nil {
+		t.Fatalf("failed to parse the CRL: %v", err)
+	}
+	signingCert, _, _, _ := ca.GetCAKeyCertBundle().GetAll()
+	if err := signingCert.CheckCRLSignature(crl); err != nil {
+		t.Errorf("invalid CRL signature: %v", err)
+	}
+	if got := crl.TBSCertList.RevokedCertificates; len(got) != 1 || got[0].SerialNumber.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("got revoked certificates %v, want serial number 42", got)
+	}
+	if ttl := crl.TBSCertList.NextUpdate.Sub(crl.TBSCertList.ThisUpdate); ttl != time.Hour {
+		t.Errorf("got CRL TTL %v, want %v", ttl, time.Hour)
+	}
+}
+
+func TestSignKeyAlgorithmPolicy(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
+		0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, "default", -1, client.CoreV1(),
+		"", false, util.ECDSAP384)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	caopts.AllowedKeyAlgorithms = []util.KeyAlgorithm{util.ECDSAP256, util.ECDSAP384}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA: %v", err)
+	}
+	signingCert, _, _, _ := ca.GetCAKeyCertBundle().GetAll()
+	if algorithm, _ := util.KeyAlgorithmOf(signingCert.PublicKey); algorithm != util.ECDSAP384 {
+		t.Errorf("got root cert key algorithm %s, want %s", algorithm, util.ECDSAP384)
+	}
+
+	for _, c := range []struct {
+		algorithm util.KeyAlgorithm
+		allowed   bool
+	}{
+		{algorithm: util.ECDSAP256, allowed: true},
+		{algorithm: util.RSA2048, allowed: false},
+	} {
+		opts := util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/default", Org: "istio.io"}
+		c.algorithm.ApplyTo(&opts)
+		csrPEM, _, err := util.GenCSR(opts)
+		if err != nil {
+			t.Fatalf("GenCSR() failed: %v", err)
+		}
+		_, err = ca.Sign(csrPEM, []string{opts.Host}, time.Hour, false)
+		if c.allowed && err != nil {
+			t.Errorf("%s: Sign() failed: %v", c.algorithm, err)
+		}
+		if !c.allowed {
+			if err == nil {
+				t.Errorf("%s: Sign() succeeded with a key algorithm which isn't allowed", c.algorithm)
+			} else if err.(*caerror.Error).ErrorType() != caerror.CSRError {
+				t.Errorf("%s: got error %v, want a CSR error", c.algorithm, err)
+			}
+		}
+	}
+}
+
+func TestSignAnyKeyAlgorithm(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	caopts, err := NewSelfSignedIstioCAOptions(context.Background(),
+		0, time.Hour, time.Hour, 30*time.Minute, time.Hour, "test.ca.Org", false, "default", -1, client.CoreV1(),
+		"", false, util.ECDSAP256)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA Options: %v", err)
+	}
+	ca, err := NewIstioCA(caopts)
+	if err != nil {
+		t.Fatalf("Failed to create a self-signed CA: %v", err)
+	}
+
+	// Without allowed key algorithms, the CA signs keys of any size, even not listed in util.KeyAlgorithm.
+	opts := util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/default", Org: "istio.io", RSAKeySize: 3072}
+	csrPEM, _, err := util.GenCSR(opts)
+	if err != nil {
+		t.Fatalf("GenCSR() failed: %v", err)
+	}
+	if _, err := ca.Sign(csrPEM, []string{opts.Host}, time.Hour, false); err != nil {
+		t.Errorf("Sign() failed for an RSA-3072 key: %v", err)
+	}
+}
+
 func TestSignWithCertChain(t *testing.T) {
 	rootCertFile := "../testdata/multilevelpki/root-cert.pem"
 	certChainFile := "../testdata/multilevelpki/int-cert-chain.pem"
@@ -16,6 +16,7 @@ package ca
 
 import (
 	"bytes"
+	"crypto"
 	"encoding/base64"
 	"fmt"
 	"math/rand"
@@ -45,6 +46,8 @@ type SelfSignedCARootCertRotatorConfig struct {
 	retryInterval      time.Duration
 	dualUse            bool
 	enableJitter       bool
+	// keyAlgorithm is the key algorithm of the root cert, RSA-2048 if empty.
+	keyAlgorithm util.KeyAlgorithm
 }
 
 // SelfSignedCARootCertRotator automatically checks self-signed signing root
@@ -187,6 +190,7 @@ func (rotator *SelfSignedCARootCertRotator) checkAndRotateRootCertForSigningCert
 		RSAKeySize:    caKeySize,
 		IsDualUse:     rotator.config.dualUse,
 	}
+	rotator.checkKeyAlgorithm(caSecret.Data[caPrivateKeyID])
 	// options should be consistent with the one used in NewSelfSignedIstioCAOptions().
 	// This is to make sure when rotate the root cert, we don't make unnecessary changes
 	// to the certificate or add extra fields to the certificate.
@@ -263,3 +267,24 @@ func (rotator *SelfSignedCARootCertRotator) updateRootCertificate(caSecret *v1.S
 	rootCertRotatorLog.Info("Root certificate is updated into configmap.")
 	return false, nil
 }
+
+// checkKeyAlgorithm warns if the root cert key doesn't match the configured key algorithm. The root cert is rotated
+// with its existing key, so that the certificates it signed remain valid: changing the key algorithm requires
+// replacing istio-ca-secret.
+func (rotator *SelfSignedCARootCertRotator) checkKeyAlgorithm(pemKey []byte) {
+	if rotator.config.keyAlgorithm == "" {
+		return
+	}
+	key, err := util.ParsePemEncodedKey(pemKey)
+	if err != nil {
+		return
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return
+	}
+	if algorithm, err := util.KeyAlgorithmOf(signer.Public()); err != nil || algorithm != rotator.config.keyAlgorithm {
+		rootCertRotatorLog.Warnf("The root cert key is not %s, and is kept by the rotation. Replace the secret %s "+
+			"to change the key algorithm.", rotator.config.keyAlgorithm, CASecret)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"istio.io/pkg/log"
+)
+
+const (
+	// ConfigMapName is the name of the ConfigMap listing the revoked serial numbers and identities.
+	ConfigMapName = "istio-ca-revocations"
+	// CRLConfigMapName is the name of the ConfigMap the CRL is published to.
+	CRLConfigMapName = "istio-ca-crl"
+	// CRLKey is the key of the PEM encoded CRL in the CRL ConfigMap.
+	CRLKey = "ca-crl.pem"
+
+	resyncPeriod = 5 * time.Minute
+)
+
+var revocationLog = log.RegisterScope("revocation", "CA certificate revocation", 0)
+
+// CRLSigner signs the CRLs of the CA.
+type CRLSigner interface {
+	// GenCRL returns a PEM encoded CRL of the revoked certificates, valid for ttl.
+	GenCRL(revoked []pkix.RevokedCertificate, ttl time.Duration) ([]byte, error)
+}
+
+// Controller watches the revocation ConfigMap, and publishes the CRL of the CA whenever the revocation list changes,
+// and before the previous CRL expires. The revoked certificates are persisted in the revocation ConfigMap, so all the
+// instances of the CA publish the same CRL.
+type Controller struct {
+	core      corev1.CoreV1Interface
+	namespace string
+	store     *Store
+	signer    CRLSigner
+	crlTTL    time.Duration
+	// upperCRLs are the PEM encoded CRLs of the CAs above the CA, published with the CRL of the CA. Envoy requires
+	// a CRL for every CA of the chain once a CRL is set.
+	upperCRLs []byte
+	// onCRL is called with the published CRLs.
+	onCRL func(crls []byte)
+
+	informer cache.Controller
+	updates  chan struct{}
+
+	mutex sync.Mutex
+	// configMap is the last seen revocation ConfigMap, or nil if there is none.
+	configMap *v1.ConfigMap
+}
+
+// NewController returns a controller of the revocation ConfigMap in the namespace.
+func NewController(core corev1.CoreV1Interface, namespace string, store *Store, signer CRLSigner, crlTTL time.Duration,
+	upperCRLs []byte, onCRL func(crls []byte)) *Controller {
+	c := &Controller{
+		core:      core,
+		namespace: namespace,
+		store:     store,
+		signer:    signer,
+		crlTTL:    crlTTL,
+		upperCRLs: upperCRLs,
+		onCRL:     onCRL,
+		updates:   make(chan struct{}, 1),
+	}
+	selector := fields.OneTermEqualSelector("metadata.name", ConfigMapName).String()
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return core.ConfigMaps(namespace).List(context.TODO(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return core.ConfigMaps(namespace).Watch(context.TODO(), options)
+		},
+	}
+	_, c.informer = cache.NewInformer(lw, &v1.ConfigMap{}, resyncPeriod, cache.ResourceEventHandlerFuncs{
+		AddFunc: c.configMapUpdated,
+		UpdateFunc: func(_, obj interface{}) {
+			c.configMapUpdated(obj)
+		},
+		DeleteFunc: func(interface{}) {
+			c.mutex.Lock()
+			c.configMap = nil
+			c.mutex.Unlock()
+			c.store.SetList(NewList())
+			c.trigger()
+		},
+	})
+	return c
+}
+
+func (c *Controller) configMapUpdated(obj interface{}) {
+	cm, ok := obj.(*v1.ConfigMap)
+	if !ok {
+		return
+	}
+	l, err := ParseList(cm.Data)
+	if err != nil {
+		// Keep the previous list, so that an invalid update doesn't reinstate revoked certificates.
+		revocationLog.Errorf("ignored invalid ConfigMap %s/%s: %v", cm.Namespace, cm.Name, err)
+		return
+	}
+	revocationLog.Infof("revoked %d serial numbers and %d identities, %d certificates in the CRL",
+		len(l.Serials), len(l.Identities), len(l.Revoked))
+	c.mutex.Lock()
+	c.configMap = cm.DeepCopy()
+	c.mutex.Unlock()
+	c.store.SetList(l)
+	c.trigger()
+}
+
+func (c *Controller) trigger() {
+	select {
+	case c.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes the CRL until stop is closed.
+func (c *Controller) Run(stop <-chan struct{}) {
+	go c.informer.Run(stop)
+	if !cache.WaitForCacheSync(stop, c.informer.HasSynced) {
+		revocationLog.Errorf("failed to sync the revocation ConfigMap")
+		return
+	}
+	// Refresh the CRL at half of its lifetime, so that Envoy never sees an expired CRL.
+	ticker := time.NewTicker(c.crlTTL / 2)
+	defer ticker.Stop()
+	for {
+		if err := c.publish(); err != nil {
+			revocationLog.Errorf("failed to publish the CRL: %v", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-c.updates:
+		case <-ticker.C:
+		}
+	}
+}
+
+// publish signs the CRL and publishes it to the CRL ConfigMap. If the revoked certificates changed, they are
+// written to the revocation ConfigMap first, and the CRL is published on its update.
+func (c *Controller) publish() error {
+	now := time.Now()
+	if revoked, changed := c.store.Reconcile(now); changed {
+		err := c.writeRevoked(revoked)
+		if err == nil {
+			return nil
+		}
+		// Publish the CRL of the revoked certificates of the current list meanwhile.
+		revocationLog.Errorf("failed to persist the revoked certificates: %v", err)
+	}
+	crl, err := c.signer.GenCRL(c.store.Revoked(now), c.crlTTL)
+	if err != nil {
+		return err
+	}
+	crls := make([]byte, 0, len(crl)+len(c.upperCRLs))
+	crls = append(append(crls, crl...), c.upperCRLs...)
+	if c.onCRL != nil {
+		c.onCRL(crls)
+	}
+	// The instances of the CA publish CRLs of the same certificates, the last one wins.
+	return c.writeConfigMap(string(crls))
+}
+
+// writeRevoked writes the revoked certificates to the revocation ConfigMap. The update fails on a conflict with
+// another instance, and is retried with the revocation list updated by the other instance.
+func (c *Controller) writeRevoked(revoked map[string]Revocation) error {
+	c.mutex.Lock()
+	cm := c.configMap.DeepCopy()
+	c.mutex.Unlock()
+	if cm == nil {
+		return fmt.Errorf("the revocation ConfigMap %s/%s is missing", c.namespace, ConfigMapName)
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[RevokedKey] = FormatRevoked(revoked)
+	if _, err := c.core.ConfigMaps(c.namespace).Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the revocation ConfigMap: %v", err)
+	}
+	return nil
+}
+
+func (c *Controller) writeConfigMap(crls string) error {
+	cm, err := c.core.ConfigMaps(c.namespace).Get(context.TODO(), CRLConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to get the CRL ConfigMap: %v", err)
+		}
+		cm = &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      CRLConfigMapName,
+				Namespace: c.namespace,
+			},
+			Data: map[string]string{CRLKey: crls},
+		}
+		if _, err = c.core.ConfigMaps(c.namespace).Create(context.TODO(), cm, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("failed to create the CRL ConfigMap: %v", err)
+		}
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[CRLKey] = crls
+	if _, err = c.core.ConfigMaps(c.namespace).Update(context.TODO(), cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the CRL ConfigMap: %v", err)
+	}
+	return nil
+}
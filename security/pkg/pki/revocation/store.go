@@ -0,0 +1,261 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revocation revokes the workload certificates issued by the CA, by serial number or by identity, and
+// publishes the certificate revocation list (CRL) of the CA.
+package revocation
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SerialsKey is the key of the revoked serial numbers in the revocation ConfigMap, one hexadecimal serial number
+	// per line, optionally separated by colons.
+	SerialsKey = "serials"
+	// IdentitiesKey is the key of the revoked identities in the revocation ConfigMap, one SPIFFE ID per line.
+	IdentitiesKey = "identities"
+	// RevokedKey is the key of the certificates revoked by the CA in the revocation ConfigMap, written by istiod and
+	// shared by its instances: one "<serial> <revocation time>" line per revoked serial number, and one
+	// "<serial> <revocation time> <expiration time> <identity>" line per certificate revoked by identity.
+	RevokedKey = "revoked"
+)
+
+// Revocation is a certificate revoked by the CA.
+type Revocation struct {
+	// Time is the revocation time.
+	Time time.Time
+	// NotAfter and Identity are the expiration time and the revoked identity of a certificate revoked by identity.
+	NotAfter time.Time
+	Identity string
+}
+
+// List is the list of the revoked serial numbers and identities.
+type List struct {
+	// Serials are the revoked serial numbers, in lowercase hexadecimal.
+	Serials map[string]bool
+	// Identities are the revoked identities.
+	Identities map[string]bool
+	// Revoked are the certificates listed in the CRL, by serial number in lowercase hexadecimal.
+	Revoked map[string]Revocation
+}
+
+// NewList returns an empty list.
+func NewList() *List {
+	return &List{Serials: map[string]bool{}, Identities: map[string]bool{}, Revoked: map[string]Revocation{}}
+}
+
+// ParseList parses the data of the revocation ConfigMap. Empty lines and lines starting with # are ignored.
+func ParseList(data map[string]string) (*List, error) {
+	l := NewList()
+	for _, line := range lines(data[SerialsKey]) {
+		serial, err := parseSerial(line)
+		if err != nil {
+			return nil, err
+		}
+		l.Serials[serial] = true
+	}
+	for _, line := range lines(data[IdentitiesKey]) {
+		l.Identities[line] = true
+	}
+	for _, line := range lines(data[RevokedKey]) {
+		serial, r, err := parseRevocation(line)
+		if err != nil {
+			return nil, err
+		}
+		l.Revoked[serial] = r
+	}
+	return l, nil
+}
+
+func parseSerial(s string) (string, error) {
+	serial, ok := new(big.Int).SetString(strings.ReplaceAll(s, ":", ""), 16)
+	if !ok || serial.Sign() <= 0 {
+		return "", fmt.Errorf("invalid serial number %q", s)
+	}
+	return serial.Text(16), nil
+}
+
+func parseRevocation(line string) (string, Revocation, error) {
+	fields := strings.Fields(line)
+	if len(fields) != 2 && len(fields) != 4 {
+		return "", Revocation{}, fmt.Errorf("invalid revoked certificate %q", line)
+	}
+	serial, err := parseSerial(fields[0])
+	if err != nil {
+		return "", Revocation{}, err
+	}
+	var r Revocation
+	if r.Time, err = time.Parse(time.RFC3339, fields[1]); err != nil {
+		return "", Revocation{}, fmt.Errorf("invalid revoked certificate %q: %v", line, err)
+	}
+	if len(fields) == 4 {
+		if r.NotAfter, err = time.Parse(time.RFC3339, fields[2]); err != nil {
+			return "", Revocation{}, fmt.Errorf("invalid revoked certificate %q: %v", line, err)
+		}
+		r.Identity = fields[3]
+	}
+	return serial, r, nil
+}
+
+// FormatRevoked returns the revoked certificates in the format of RevokedKey, sorted by serial number.
+func FormatRevoked(revoked map[string]Revocation) string {
+	serials := make([]string, 0, len(revoked))
+	for serial := range revoked {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+	var sb strings.Builder
+	for _, serial := range serials {
+		r := revoked[serial]
+		sb.WriteString(serial + " " + r.Time.UTC().Format(time.RFC3339))
+		if r.Identity != "" {
+			sb.WriteString(" " + r.NotAfter.UTC().Format(time.RFC3339) + " " + r.Identity)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func lines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// issuedCert is a certificate issued by the CA, kept until it expires to revoke it by identity.
+type issuedCert struct {
+	identities []string
+	notAfter   time.Time
+}
+
+// Store holds the revocation list, and the certificates issued by this CA instance. The certificates revoked by
+// identity are only known by the instance which issued them: each instance adds the ones it issued to the revoked
+// certificates of the list, which are shared, and the CRL is built from them only. A certificate issued before a
+// restart can only be revoked by serial number.
+type Store struct {
+	mutex  sync.RWMutex
+	list   *List
+	issued map[string]issuedCert
+}
+
+// NewStore returns an empty store.
+func NewStore() *Store {
+	return &Store{
+		list:   NewList(),
+		issued: map[string]issuedCert{},
+	}
+}
+
+// SetList replaces the revocation list.
+func (s *Store) SetList(l *List) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.list = l
+}
+
+// IsRevoked returns true if the serial number or one of the identities is revoked. The serial number may be nil.
+func (s *Store) IsRevoked(serial *big.Int, identities []string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if serial != nil && s.list.Serials[serial.Text(16)] {
+		return true
+	}
+	for _, id := range identities {
+		if s.list.Identities[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordIssued records a certificate issued for the identities, so that revoking one of the identities revokes it.
+func (s *Store) RecordIssued(cert *x509.Certificate, identities []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.issued[cert.SerialNumber.Text(16)] = issuedCert{
+		identities: identities,
+		notAfter:   cert.NotAfter,
+	}
+}
+
+// Reconcile returns the revoked certificates of the list updated with the listed serial numbers and the unexpired
+// certificates issued by this instance for a revoked identity, and true if they changed. The certificates whose
+// serial number or identity is no longer listed, or which expired, are removed.
+func (s *Store) Reconcile(now time.Time) (map[string]Revocation, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	revoked := map[string]Revocation{}
+	for serial, r := range s.list.Revoked {
+		listed := s.list.Serials[serial]
+		if r.Identity != "" {
+			listed = s.list.Identities[r.Identity] && now.Before(r.NotAfter)
+		}
+		if listed {
+			revoked[serial] = r
+		}
+	}
+	for serial := range s.list.Serials {
+		if _, found := revoked[serial]; !found {
+			revoked[serial] = Revocation{Time: now}
+		}
+	}
+	for serial, cert := range s.issued {
+		if now.After(cert.notAfter) {
+			delete(s.issued, serial)
+			continue
+		}
+		if _, found := revoked[serial]; found {
+			continue
+		}
+		for _, id := range cert.identities {
+			if s.list.Identities[id] {
+				revoked[serial] = Revocation{Time: now, NotAfter: cert.notAfter, Identity: id}
+				break
+			}
+		}
+	}
+	return revoked, FormatRevoked(revoked) != FormatRevoked(s.list.Revoked)
+}
+
+// Revoked returns the revoked certificates of the list to include in the CRL, sorted by serial number. The
+// certificates revoked by identity are removed once expired.
+func (s *Store) Revoked(now time.Time) []pkix.RevokedCertificate {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	out := make([]pkix.RevokedCertificate, 0, len(s.list.Revoked))
+	for serial, r := range s.list.Revoked {
+		if r.Identity != "" && now.After(r.NotAfter) {
+			continue
+		}
+		n, _ := new(big.Int).SetString(serial, 16)
+		out = append(out, pkix.RevokedCertificate{SerialNumber: n, RevocationTime: r.Time})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].SerialNumber.Cmp(out[j].SerialNumber) < 0
+	})
+	return out
+}
@@ -0,0 +1,176 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revocation
+
+import (
+	"crypto/x509"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseList(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    map[string]string
+		want    *List
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: NewList(),
+		},
+		{
+			name: "serials and identities",
+			data: map[string]string{
+				SerialsKey:    "# compromised on 2020-06-01\n0A:1B:2C\n\n  ff  \n",
+				IdentitiesKey: "spiffe://cluster.local/ns/default/sa/foo\n# spiffe://cluster.local/ns/default/sa/bar\n",
+			},
+			want: &List{
+				Serials:    map[string]bool{"a1b2c": true, "ff": true},
+				Identities: map[string]bool{"spiffe://cluster.local/ns/default/sa/foo": true},
+				Revoked:    map[string]Revocation{},
+			},
+		},
+		{
+			name: "revoked certificates",
+			data: map[string]string{
+				RevokedKey: "ff 2020-06-01T10:00:00Z\n" +
+					"0a 2020-06-01T10:00:00Z 2020-06-02T10:00:00Z spiffe://cluster.local/ns/default/sa/foo\n",
+			},
+			want: &List{
+				Serials:    map[string]bool{},
+				Identities: map[string]bool{},
+				Revoked: map[string]Revocation{
+					"ff": {Time: time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)},
+					"a": {
+						Time:     time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC),
+						NotAfter: time.Date(2020, 6, 2, 10, 0, 0, 0, time.UTC),
+						Identity: "spiffe://cluster.local/ns/default/sa/foo",
+					},
+				},
+			},
+		},
+		{
+			name:    "invalid revoked certificate",
+			data:    map[string]string{RevokedKey: "ff 2020-06-01T10:00:00Z spiffe://cluster.local/ns/default/sa/foo"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid serial",
+			data:    map[string]string{SerialsKey: "not-hex"},
+			wantErr: true,
+		},
+		{
+			name:    "negative serial",
+			data:    map[string]string{SerialsKey: "-1"},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := ParseList(c.data)
+			if c.wantErr {
+				if err == nil {
+					t.Errorf("ParseList() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseList() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	const (
+		foo = "spiffe://cluster.local/ns/default/sa/foo"
+		bar = "spiffe://cluster.local/ns/default/sa/bar"
+	)
+	now := time.Now().UTC().Truncate(time.Second)
+	s := NewStore()
+	s.RecordIssued(&x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: now.Add(time.Hour)}, []string{foo})
+	s.RecordIssued(&x509.Certificate{SerialNumber: big.NewInt(2), NotAfter: now.Add(time.Hour)}, []string{bar})
+	s.RecordIssued(&x509.Certificate{SerialNumber: big.NewInt(3), NotAfter: now.Add(-time.Minute)}, []string{foo})
+
+	if s.IsRevoked(big.NewInt(1), []string{foo}) {
+		t.Errorf("IsRevoked() = true before any revocation")
+	}
+	if _, changed := s.Reconcile(now); changed {
+		t.Errorf("Reconcile() changed the revoked certificates before any revocation")
+	}
+
+	s.SetList(&List{Serials: map[string]bool{"10": true}, Identities: map[string]bool{foo: true}, Revoked: map[string]Revocation{}})
+	if !s.IsRevoked(nil, []string{bar, foo}) {
+		t.Errorf("IsRevoked() = false for the revoked identity %s", foo)
+	}
+	if !s.IsRevoked(big.NewInt(16), nil) {
+		t.Errorf("IsRevoked() = false for the revoked serial number 16")
+	}
+	if s.IsRevoked(big.NewInt(2), []string{bar}) {
+		t.Errorf("IsRevoked() = true for a certificate which isn't revoked")
+	}
+	// Only the persisted revoked certificates are in the CRL.
+	if got := s.Revoked(now); len(got) != 0 {
+		t.Errorf("got revoked certificates %v before they are persisted", got)
+	}
+
+	// The certificate 3 issued for foo is expired, and not revoked.
+	revoked, changed := s.Reconcile(now)
+	want := map[string]Revocation{
+		"1":  {Time: now, NotAfter: now.Add(time.Hour), Identity: foo},
+		"10": {Time: now},
+	}
+	if !changed || !reflect.DeepEqual(revoked, want) {
+		t.Fatalf("got revoked certificates %v, %v, want %v", revoked, changed, want)
+	}
+
+	// Another instance issued the certificate 4 for foo, and persists it with its own revocation time.
+	l, err := ParseList(map[string]string{
+		SerialsKey:    "10",
+		IdentitiesKey: foo,
+		RevokedKey:    FormatRevoked(revoked) + "4 2020-06-01T10:00:00Z " + now.Add(time.Hour).Format(time.RFC3339) + " " + foo,
+	})
+	if err != nil {
+		t.Fatalf("ParseList() failed: %v", err)
+	}
+	s.SetList(l)
+	if _, changed := s.Reconcile(now.Add(time.Minute)); changed {
+		t.Errorf("Reconcile() changed the persisted revoked certificates")
+	}
+	var serials []int64
+	for _, r := range s.Revoked(now) {
+		serials = append(serials, r.SerialNumber.Int64())
+	}
+	if want := []int64{1, 4, 16}; !reflect.DeepEqual(serials, want) {
+		t.Errorf("got revoked serial numbers %v, want %v", serials, want)
+	}
+
+	// The certificates of the identities and serial numbers removed from the list are removed.
+	l.Serials = map[string]bool{}
+	l.Identities = map[string]bool{bar: true}
+	s.SetList(l)
+	revoked, changed = s.Reconcile(now)
+	want = map[string]Revocation{"2": {Time: now, NotAfter: now.Add(time.Hour), Identity: bar}}
+	if !changed || !reflect.DeepEqual(revoked, want) {
+		t.Errorf("got revoked certificates %v, %v, want %v", revoked, changed, want)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+// NewFileSigner returns the signer of the PEM encoded private key in keyFile.
+func NewFileSigner(keyFile string) (crypto.Signer, error) {
+	b, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the private key: %v", err)
+	}
+	key, err := util.ParsePemEncodedKey(b)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the private key of %s can't sign", keyFile)
+	}
+	return s, nil
+}
@@ -0,0 +1,233 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ServiceName is the name of the gRPC service of a remote signer.
+	ServiceName = "istio.security.signer.v1alpha1.Signer"
+
+	publicKeyMethod = "/" + ServiceName + "/PublicKey"
+	signMethod      = "/" + ServiceName + "/Sign"
+
+	// codecName is the content subtype of the signer service. The messages are encoded in JSON, so a signer service
+	// can be implemented in any language without the Istio protos.
+	codecName = "istio-signer-json"
+
+	// signTimeout is the timeout of a call to a remote signer.
+	signTimeout = 10 * time.Second
+)
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// PublicKeyRequest is the request of the PublicKey method.
+type PublicKeyRequest struct{}
+
+// PublicKeyResponse is the response of the PublicKey method.
+type PublicKeyResponse struct {
+	// PublicKey is the DER encoded PKIX public key of the signer.
+	PublicKey []byte `json:"public_key"`
+}
+
+// SignRequest is the request of the Sign method, with the arguments of crypto.Signer.Sign.
+type SignRequest struct {
+	// Digest is the digest to sign.
+	Digest []byte `json:"digest"`
+	// Hash is the crypto.Hash of the digest.
+	Hash crypto.Hash `json:"hash"`
+	// PSSSaltLength is the salt length of a RSA-PSS signature, or nil for a PKCS #1 v1.5 or ECDSA signature.
+	PSSSaltLength *int `json:"pss_salt_length,omitempty"`
+}
+
+// SignResponse is the response of the Sign method.
+type SignResponse struct {
+	// Signature is the signature of the digest.
+	Signature []byte `json:"signature"`
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+// remoteSigner signs with a remote signer service.
+type remoteSigner struct {
+	conn      *grpc.ClientConn
+	publicKey crypto.PublicKey
+}
+
+// NewRemoteSigner returns the signer of the signer service at the other end of conn. The public key is fetched once.
+func NewRemoteSigner(conn *grpc.ClientConn) (crypto.Signer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	resp := &PublicKeyResponse{}
+	if err := conn.Invoke(ctx, publicKeyMethod, &PublicKeyRequest{}, resp, grpc.CallContentSubtype(codecName)); err != nil {
+		return nil, fmt.Errorf("failed to get the public key of the signer: %v", err)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the public key of the signer: %v", err)
+	}
+	return &remoteSigner{conn: conn, publicKey: publicKey}, nil
+}
+
+// Public implements crypto.Signer.
+func (s *remoteSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign implements crypto.Signer. The randomness is provided by the signer service.
+func (s *remoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	req := &SignRequest{
+		Digest: digest,
+		Hash:   opts.HashFunc(),
+	}
+	if pss, ok := opts.(*rsa.PSSOptions); ok {
+		saltLength := pss.SaltLength
+		req.PSSSaltLength = &saltLength
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	resp := &SignResponse{}
+	if err := s.conn.Invoke(ctx, signMethod, req, resp, grpc.CallContentSubtype(codecName)); err != nil {
+		signerLog.Errorf("remote signer failed to sign: %v", err)
+		return nil, fmt.Errorf("remote signer failed to sign: %v", err)
+	}
+	return resp.Signature, nil
+}
+
+// Server serves a signer over gRPC. It is the stand-in of an HSM or a KMS in tests, and the base of a signer
+// service fronting a backend that has no Go crypto.Signer in the CA.
+//
+// The server doesn't authenticate its clients: it must be served over mutual TLS, see ServerTLSConfig, or on a unix
+// domain socket only accessible to the CA.
+type Server struct {
+	signer crypto.Signer
+}
+
+// NewServer returns a server of the signer.
+func NewServer(signer crypto.Signer) *Server {
+	return &Server{signer: signer}
+}
+
+// Register registers the signer service on the gRPC server.
+func (s *Server) Register(grpcServer *grpc.Server) {
+	grpcServer.RegisterService(&serviceDesc, s)
+}
+
+// PublicKey returns the public key of the signer.
+func (s *Server) PublicKey(_ context.Context, _ *PublicKeyRequest) (*PublicKeyResponse, error) {
+	der, err := x509.MarshalPKIXPublicKey(s.signer.Public())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal the public key: %v", err)
+	}
+	return &PublicKeyResponse{PublicKey: der}, nil
+}
+
+// Sign signs the digest with the signer.
+func (s *Server) Sign(_ context.Context, req *SignRequest) (*SignResponse, error) {
+	if !req.Hash.Available() {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported hash %d", req.Hash)
+	}
+	if len(req.Digest) != req.Hash.Size() {
+		return nil, status.Errorf(codes.InvalidArgument, "digest of %d bytes, expecting %d", len(req.Digest), req.Hash.Size())
+	}
+	var opts crypto.SignerOpts = req.Hash
+	if req.PSSSaltLength != nil {
+		opts = &rsa.PSSOptions{SaltLength: *req.PSSSaltLength, Hash: req.Hash}
+	}
+	signature, err := s.signer.Sign(rand.Reader, req.Digest, opts)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to sign: %v", err)
+	}
+	return &SignResponse{Signature: signature}, nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PublicKey",
+			Handler:    publicKeyHandler,
+		},
+		{
+			MethodName: "Sign",
+			Handler:    signHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// nolint: golint
+func publicKeyHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &PublicKeyRequest{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).PublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: publicKeyMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).PublicKey(ctx, req.(*PublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// nolint: golint
+func signHandler(srv interface{}, ctx context.Context, dec func(interface{}) error,
+	interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := &SignRequest{}
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*Server).Sign(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: signMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*Server).Sign(ctx, req.(*SignRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
@@ -0,0 +1,149 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signer provides the backends signing with the CA private key. A backend implements crypto.Signer, so the
+// private key can stay out of the memory of the CA, e.g. in an HSM or a KMS.
+package signer
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/pkg/log"
+)
+
+const (
+	// FileScheme is the scheme of the software signer, reading the private key from a PEM file.
+	FileScheme = "file"
+	// UnixScheme is the scheme of the remote signer listening on a unix domain socket, e.g. a sidecar of the CA
+	// bridging to a PKCS#11 module or a KMS. The connection is not authenticated: anyone who can connect to the
+	// socket can sign with the CA key, so the file permissions of the socket must restrict it to the CA.
+	UnixScheme = "unix"
+	// GRPCScheme is the scheme of the remote signer listening on TCP. The connection uses mutual TLS.
+	GRPCScheme = "grpc"
+)
+
+// TLSOptions configures the mutual TLS connection to a grpc:// signer.
+type TLSOptions struct {
+	// RootCertFile is the PEM encoded CA bundle verifying the certificate of the signer service. The system roots
+	// are used if it is empty.
+	RootCertFile string
+	// CertFile is the PEM encoded client certificate authenticating the CA to the signer service.
+	CertFile string
+	// KeyFile is the PEM encoded private key of the client certificate.
+	KeyFile string
+}
+
+var signerLog = log.RegisterScope("signer", "CA signer debugging", 0)
+
+// New returns the signer of the URI:
+//   - file:///path/to/ca-key.pem signs with the private key of the file, loaded in memory. It is the reference
+//     implementation, which doesn't protect the key.
+//   - unix:///path/to/socket signs with the signer service listening on the unix domain socket. The socket must
+//     only be accessible to the CA, e.g. owned by its user with the mode 0600.
+//   - grpc://host:port signs with the signer service listening on host:port, over mutual TLS configured by opts.
+//
+// Other backends, e.g. a PKCS#11 module, are reached through a signer service implementing the Signer gRPC service.
+func New(uri string, opts TLSOptions) (crypto.Signer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signer %q: %v", uri, err)
+	}
+	switch u.Scheme {
+	case FileScheme:
+		return NewFileSigner(strings.TrimPrefix(uri, FileScheme+"://"))
+	case UnixScheme:
+		conn, err := grpc.Dial(uri, grpc.WithInsecure())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to signer %q: %v", uri, err)
+		}
+		return NewRemoteSigner(conn)
+	case GRPCScheme:
+		config, err := clientTLSConfig(opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS configuration of signer %q: %v", uri, err)
+		}
+		conn, err := grpc.Dial(u.Host, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to signer %q: %v", uri, err)
+		}
+		return NewRemoteSigner(conn)
+	default:
+		return nil, fmt.Errorf("unsupported signer %q, expecting one of the schemes %s, %s or %s",
+			uri, FileScheme, UnixScheme, GRPCScheme)
+	}
+}
+
+// clientTLSConfig returns the TLS configuration of the connection to a grpc:// signer. A client certificate is
+// required: the signer service must not sign for unauthenticated clients.
+func clientTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("a client certificate and key are required")
+	}
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the client certificate: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if opts.RootCertFile != "" {
+		if config.RootCAs, err = loadCertPool(opts.RootCertFile); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// ServerTLSConfig returns the TLS configuration of a signer service listening on TCP. The service presents the
+// certificate of certFile and keyFile, and only accepts clients with a certificate verified by the CA bundle of
+// clientCAFile.
+func ServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the server certificate: %v", err)
+	}
+	clientCAs, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the CA bundle: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificate found in the CA bundle %s", file)
+	}
+	return pool, nil
+}
@@ -0,0 +1,298 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signer
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+const (
+	rsaKeyFile = "../testdata/multilevelpki/int-key.pem"
+	ecKeyFile  = "../testdata/multilevelpki/ecc-int-key.pem"
+)
+
+// verify checks that the signer signs a digest verified by its public key.
+func verify(t *testing.T, s crypto.Signer, opts crypto.SignerOpts) {
+	t.Helper()
+	digest := sha256.Sum256([]byte("hello"))
+	signature, err := s.Sign(rand.Reader, digest[:], opts)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	switch pub := s.Public().(type) {
+	case *rsa.PublicKey:
+		if pss, ok := opts.(*rsa.PSSOptions); ok {
+			err = rsa.VerifyPSS(pub, crypto.SHA256, digest[:], signature, pss)
+		} else {
+			err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature)
+		}
+		if err != nil {
+			t.Errorf("invalid signature: %v", err)
+		}
+	case *ecdsa.PublicKey:
+		var sig struct {
+			R, S *big.Int
+		}
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			t.Fatalf("invalid signature: %v", err)
+		}
+		if !ecdsa.Verify(pub, digest[:], sig.R, sig.S) {
+			t.Errorf("invalid signature")
+		}
+	default:
+		t.Fatalf("unexpected public key %T", pub)
+	}
+}
+
+// startServer serves the signer of keyFile on a unix domain socket, and returns the URI of the socket.
+func startServer(t *testing.T, keyFile string) string {
+	t.Helper()
+	s, err := NewFileSigner(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "socket")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	NewServer(s).Register(grpcServer)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(func() {
+		grpcServer.Stop()
+		_ = os.RemoveAll(dir)
+	})
+	return "unix://" + socket
+}
+
+func TestFileSigner(t *testing.T) {
+	for _, keyFile := range []string{rsaKeyFile, ecKeyFile} {
+		t.Run(keyFile, func(t *testing.T) {
+			s, err := New("file://"+keyFile, TLSOptions{})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			verify(t, s, crypto.SHA256)
+		})
+	}
+
+	if _, err := NewFileSigner("missing-key.pem"); err == nil {
+		t.Errorf("NewFileSigner() succeeded with a missing file")
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	cases := []struct {
+		name    string
+		keyFile string
+		opts    crypto.SignerOpts
+	}{
+		{
+			name:    "RSA PKCS #1 v1.5",
+			keyFile: rsaKeyFile,
+			opts:    crypto.SHA256,
+		},
+		{
+			name:    "RSA PSS",
+			keyFile: rsaKeyFile,
+			opts:    &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256},
+		},
+		{
+			name:    "ECDSA",
+			keyFile: ecKeyFile,
+			opts:    crypto.SHA256,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := New(startServer(t, c.keyFile), TLSOptions{})
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			local, err := NewFileSigner(c.keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := x509.MarshalPKIXPublicKey(s.Public())
+			want, _ := x509.MarshalPKIXPublicKey(local.Public())
+			if !bytes.Equal(got, want) {
+				t.Errorf("got public key %v, want %v", s.Public(), local.Public())
+			}
+			verify(t, s, c.opts)
+		})
+	}
+}
+
+func TestRemoteSignerInvalidDigest(t *testing.T) {
+	s, err := New(startServer(t, rsaKeyFile), TLSOptions{})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if _, err := s.Sign(rand.Reader, []byte("short"), crypto.SHA256); err == nil {
+		t.Errorf("Sign() succeeded with an invalid digest")
+	}
+}
+
+// genCert writes a certificate and its key signed by the CA of caCert and caKey, or a self-signed CA if caCert is
+// nil, and returns their paths.
+func genCert(t *testing.T, dir, name string, opts util.CertOptions, caCert, caKey []byte) (string, string) {
+	t.Helper()
+	opts.TTL = time.Hour
+	opts.RSAKeySize = 2048
+	if caCert == nil {
+		opts.IsCA, opts.IsSelfSigned = true, true
+	} else {
+		signerCert, err := util.ParsePemEncodedCertificate(caCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signerKey, err := util.ParsePemEncodedKey(caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.SignerCert, opts.SignerPriv = signerCert, signerKey
+	}
+	cert, key, err := util.GenCertKeyFromOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+"-cert.pem"), filepath.Join(dir, name+"-key.pem")
+	if err := ioutil.WriteFile(certFile, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestRemoteSignerMutualTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	readFile := func(file string) []byte {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	rootCert, rootKey := genCert(t, dir, "root", util.CertOptions{Host: "root", Org: "signer"}, nil, nil)
+	otherCert, otherKey := genCert(t, dir, "other", util.CertOptions{Host: "other", Org: "other"}, nil, nil)
+	serverCert, serverKey := genCert(t, dir, "server", util.CertOptions{Host: "127.0.0.1", IsServer: true},
+		readFile(rootCert), readFile(rootKey))
+	clientCert, clientKey := genCert(t, dir, "client", util.CertOptions{Host: "istiod", IsClient: true},
+		readFile(rootCert), readFile(rootKey))
+	untrustedCert, untrustedKey := genCert(t, dir, "untrusted", util.CertOptions{Host: "istiod", IsClient: true},
+		readFile(otherCert), readFile(otherKey))
+
+	s, err := NewFileSigner(rsaKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := ServerTLSConfig(serverCert, serverKey, rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer(grpc.Creds(credentials.NewTLS(config)))
+	NewServer(s).Register(grpcServer)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	defer grpcServer.Stop()
+	uri := "grpc://" + lis.Addr().String()
+
+	cases := []struct {
+		name    string
+		opts    TLSOptions
+		wantErr string
+	}{
+		{
+			name: "trusted client",
+			opts: TLSOptions{RootCertFile: rootCert, CertFile: clientCert, KeyFile: clientKey},
+		},
+		{
+			name:    "no client certificate",
+			opts:    TLSOptions{RootCertFile: rootCert},
+			wantErr: "client certificate and key are required",
+		},
+		{
+			name:    "untrusted client",
+			opts:    TLSOptions{RootCertFile: rootCert, CertFile: untrustedCert, KeyFile: untrustedKey},
+			wantErr: "failed to get the public key",
+		},
+		{
+			name:    "untrusted server",
+			opts:    TLSOptions{RootCertFile: otherCert, CertFile: clientCert, KeyFile: clientKey},
+			wantErr: "failed to get the public key",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			remote, err := New(uri, c.opts)
+			if c.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+					t.Fatalf("got error %v, want %q", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			verify(t, remote, crypto.SHA256)
+		})
+	}
+}
+
+func TestNewUnsupportedScheme(t *testing.T) {
+	_, err := New("pkcs11:token=ca", TLSOptions{})
+	if err == nil || !strings.Contains(err.Error(), "unsupported signer") {
+		t.Errorf("got error %v, want unsupported signer", err)
+	}
+}
@@ -100,12 +100,16 @@ func GetRSAKeySize(privKey crypto.PrivateKey) (int, error) {
 	return pkey.N.BitLen(), nil
 }
 
-// IsSupportedECPrivateKey is a predicate returning true if the private key is EC based
+// IsSupportedECPrivateKey is a predicate returning true if the private key is EC based. The private key may be a
+// crypto.Signer holding the key, e.g. in an HSM.
 func IsSupportedECPrivateKey(privKey *crypto.PrivateKey) bool {
-	switch (*privKey).(type) {
+	switch k := (*privKey).(type) {
 	// this should agree with var SupportedECSignatureAlgorithms
 	case *ecdsa.PrivateKey:
 		return true
+	case crypto.Signer:
+		_, ok := k.Public().(*ecdsa.PublicKey)
+		return ok
 	default:
 		return false
 	}
@@ -97,6 +97,9 @@ type CertOptions struct {
 	// when generating private keys. Currently only ECDSA is supported.
 	// If empty, RSA is used, otherwise ECC is used.
 	ECSigAlg SupportedECSignatureAlgorithms
+
+	// The curve of the ECDSA private key to generate. If nil, P-256 is used.
+	ECCCurve elliptic.Curve
 }
 
 // GenCertKeyFromOptions generates a X.509 certificate and a private key with the given options.
@@ -111,7 +114,11 @@ func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte,
 
 		switch options.ECSigAlg {
 		case EcdsaSigAlg:
-			ecPriv, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			curve, curveErr := eccCurve(options)
+			if curveErr != nil {
+				return nil, nil, fmt.Errorf("cert generation fails at EC key generation (%v)", curveErr)
+			}
+			ecPriv, err = ecdsa.GenerateKey(curve, rand.Reader)
 			if err != nil {
 				return nil, nil, fmt.Errorf("cert generation fails at EC key generation (%v)", err)
 			}
@@ -131,6 +138,18 @@ func GenCertKeyFromOptions(options CertOptions) (pemCert []byte, pemKey []byte,
 	return genCert(options, rsaPriv, &rsaPriv.PublicKey)
 }
 
+// eccCurve returns the curve of the ECDSA private key to generate, P-256 by default.
+func eccCurve(options CertOptions) (elliptic.Curve, error) {
+	switch options.ECCCurve {
+	case nil:
+		return elliptic.P256(), nil
+	case elliptic.P256(), elliptic.P384():
+		return options.ECCCurve, nil
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", options.ECCCurve.Params().Name)
+	}
+}
+
 func genCert(options CertOptions, priv interface{}, key interface{}) ([]byte, []byte, error) {
 	template, err := genCertTemplateFromOptions(options)
 	if err != nil {
@@ -261,8 +280,8 @@ func genCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, t
 	var keyUsage x509.KeyUsage
 	extKeyUsages := []x509.ExtKeyUsage{}
 	if isCA {
-		// If the cert is a CA cert, the private key is allowed to sign other certificates.
-		keyUsage = x509.KeyUsageCertSign
+		// If the cert is a CA cert, the private key is allowed to sign other certificates and CRLs.
+		keyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	} else {
 		// Otherwise the private key is allowed for digital signature and key encipherment.
 		keyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
@@ -313,8 +332,8 @@ func genCertTemplateFromCSR(csr *x509.CertificateRequest, subjectIDs []string, t
 func genCertTemplateFromOptions(options CertOptions) (*x509.Certificate, error) {
 	var keyUsage x509.KeyUsage
 	if options.IsCA {
-		// If the cert is a CA cert, the private key is allowed to sign other certificates.
-		keyUsage = x509.KeyUsageCertSign
+		// If the cert is a CA cert, the private key is allowed to sign other certificates and CRLs.
+		keyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
 	} else {
 		// Otherwise the private key is allowed for digital signature and key encipherment.
 		keyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
@@ -0,0 +1,105 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+)
+
+// KeyAlgorithm is the algorithm and the size of a private key, e.g. RSA-2048 or ECDSA-P256.
+type KeyAlgorithm string
+
+const (
+	// RSA2048 is an RSA key of 2048 bits.
+	RSA2048 KeyAlgorithm = "RSA-2048"
+	// RSA4096 is an RSA key of 4096 bits.
+	RSA4096 KeyAlgorithm = "RSA-4096"
+	// ECDSAP256 is an ECDSA key on the NIST P-256 curve.
+	ECDSAP256 KeyAlgorithm = "ECDSA-P256"
+	// ECDSAP384 is an ECDSA key on the NIST P-384 curve.
+	ECDSAP384 KeyAlgorithm = "ECDSA-P384"
+)
+
+// SupportedKeyAlgorithms are the key algorithms of the generated private keys.
+var SupportedKeyAlgorithms = []KeyAlgorithm{RSA2048, RSA4096, ECDSAP256, ECDSAP384}
+
+// ParseKeyAlgorithm parses a key algorithm, ignoring the case.
+func ParseKeyAlgorithm(s string) (KeyAlgorithm, error) {
+	for _, a := range SupportedKeyAlgorithms {
+		if strings.EqualFold(s, string(a)) {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported key algorithm %q, expecting one of %v", s, SupportedKeyAlgorithms)
+}
+
+// ParseKeyAlgorithms parses a comma separated list of key algorithms. An empty string returns no algorithm.
+func ParseKeyAlgorithms(s string) ([]KeyAlgorithm, error) {
+	var algorithms []KeyAlgorithm
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		a, err := ParseKeyAlgorithm(entry)
+		if err != nil {
+			return nil, err
+		}
+		algorithms = append(algorithms, a)
+	}
+	return algorithms, nil
+}
+
+// ApplyTo sets the key options of the certificate options to generate a private key of the algorithm.
+func (a KeyAlgorithm) ApplyTo(options *CertOptions) {
+	switch a {
+	case RSA2048:
+		options.ECSigAlg, options.ECCCurve, options.RSAKeySize = "", nil, 2048
+	case RSA4096:
+		options.ECSigAlg, options.ECCCurve, options.RSAKeySize = "", nil, 4096
+	case ECDSAP256:
+		options.ECSigAlg, options.ECCCurve = EcdsaSigAlg, elliptic.P256()
+	case ECDSAP384:
+		options.ECSigAlg, options.ECCCurve = EcdsaSigAlg, elliptic.P384()
+	}
+}
+
+// KeyAlgorithmOf returns the key algorithm of a public key, or an error if the algorithm isn't supported.
+func KeyAlgorithmOf(pub crypto.PublicKey) (KeyAlgorithm, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return RSA2048, nil
+		case 4096:
+			return RSA4096, nil
+		}
+		return "", fmt.Errorf("unsupported RSA key size %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return ECDSAP256, nil
+		case elliptic.P384():
+			return ECDSAP384, nil
+		}
+		return "", fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+	default:
+		return "", fmt.Errorf("unsupported public key %T", pub)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKeyAlgorithms(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []KeyAlgorithm
+		wantErr bool
+	}{
+		{in: "", want: nil},
+		{in: "RSA-2048", want: []KeyAlgorithm{RSA2048}},
+		{in: "ecdsa-p256, ECDSA-P384 ,", want: []KeyAlgorithm{ECDSAP256, ECDSAP384}},
+		{in: "RSA-1024", wantErr: true},
+		{in: "ECDSA", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := ParseKeyAlgorithms(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("ParseKeyAlgorithms(%q) succeeded, want error", c.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseKeyAlgorithms(%q) failed: %v", c.in, err)
+		} else if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseKeyAlgorithms(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestKeyAlgorithmGenCSR(t *testing.T) {
+	for _, algorithm := range SupportedKeyAlgorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			options := CertOptions{Host: "spiffe://cluster.local/ns/default/sa/default", RSAKeySize: 2048}
+			algorithm.ApplyTo(&options)
+			csrPEM, _, err := GenCSR(options)
+			if err != nil {
+				t.Fatalf("GenCSR() failed: %v", err)
+			}
+			csr, err := ParsePemEncodedCSR(csrPEM)
+			if err != nil {
+				t.Fatalf("failed to parse the CSR: %v", err)
+			}
+			got, err := KeyAlgorithmOf(csr.PublicKey)
+			if err != nil {
+				t.Fatalf("KeyAlgorithmOf() failed: %v", err)
+			}
+			if got != algorithm {
+				t.Errorf("got key algorithm %s, want %s", got, algorithm)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@
 package util
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/rsa"
@@ -113,6 +114,50 @@ func NewVerifiedKeyCertBundleFromFile(certFile, privKeyFile, certChainFile, root
 	return NewVerifiedKeyCertBundleFromPem(certBytes, privKeyBytes, certChainBytes, rootCertBytes)
 }
 
+// NewVerifiedKeyCertBundleWithSigner returns a new KeyCertBundle whose private key is held by the signer, e.g. in
+// an HSM or a KMS, or error if the provided certs failed the verification or don't match the signer.
+// The bundle has no private key PEM, and GetAll returns the signer as the private key.
+func NewVerifiedKeyCertBundleWithSigner(certBytes, certChainBytes, rootCertBytes []byte, signer crypto.Signer) (
+	*KeyCertBundleImpl, error) {
+	cert, err := verifyCertChain(certBytes, certChainBytes, rootCertBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyPublicKey(cert, signer.Public()); err != nil {
+		return nil, err
+	}
+	privKey := crypto.PrivateKey(signer)
+	return &KeyCertBundleImpl{
+		certBytes:      copyBytes(certBytes),
+		cert:           cert,
+		privKeyBytes:   []byte{},
+		privKey:        &privKey,
+		certChainBytes: copyBytes(certChainBytes),
+		rootCertBytes:  copyBytes(rootCertBytes),
+	}, nil
+}
+
+// NewVerifiedKeyCertBundleWithSignerFromFile returns a new KeyCertBundle whose private key is held by the signer,
+// or error if the provided certs failed the verification or don't match the signer.
+func NewVerifiedKeyCertBundleWithSignerFromFile(certFile, certChainFile, rootCertFile string, signer crypto.Signer) (
+	*KeyCertBundleImpl, error) {
+	certBytes, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	certChainBytes := []byte{}
+	if len(certChainFile) != 0 {
+		if certChainBytes, err = ioutil.ReadFile(certChainFile); err != nil {
+			return nil, err
+		}
+	}
+	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
+	if err != nil {
+		return nil, err
+	}
+	return NewVerifiedKeyCertBundleWithSigner(certBytes, certChainBytes, rootCertBytes, signer)
+}
+
 // NewKeyCertBundleWithRootCertFromFile returns a new KeyCertBundle with the root cert without verification.
 func NewKeyCertBundleWithRootCertFromFile(rootCertFile string) (*KeyCertBundleImpl, error) {
 	rootCertBytes, err := ioutil.ReadFile(rootCertFile)
@@ -207,7 +252,7 @@ func (b *KeyCertBundleImpl) CertOptions() (*CertOptions, error) {
 		IsDualUse: ids[0] == b.cert.Subject.CommonName,
 	}
 
-	switch (*b.privKey).(type) {
+	switch k := (*b.privKey).(type) {
 	case *rsa.PrivateKey:
 		size, err := GetRSAKeySize(*b.privKey)
 		if err != nil {
@@ -216,6 +261,7 @@ func (b *KeyCertBundleImpl) CertOptions() (*CertOptions, error) {
 		opts.RSAKeySize = size
 	case *ecdsa.PrivateKey:
 		opts.ECSigAlg = EcdsaSigAlg
+		opts.ECCCurve = k.Curve
 	default:
 		return nil, errors.New("unknown private key type")
 	}
@@ -236,6 +282,25 @@ func (b *KeyCertBundleImpl) ExtractCACertExpiryTimestamp() (float64, error) {
 // Verify that the cert chain, root cert and key/cert match.
 func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error {
 	// Verify the cert can be verified from the root cert through the cert chain.
+	if _, err := verifyCertChain(certBytes, certChainBytes, rootCertBytes); err != nil {
+		return err
+	}
+
+	// Verify that the key can be correctly parsed.
+	if _, err := ParsePemEncodedKey(privKeyBytes); err != nil {
+		return fmt.Errorf("failed to parse private key PEM: %v", err)
+	}
+
+	// Verify the cert and key match.
+	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
+		return fmt.Errorf("the cert does not match the key")
+	}
+
+	return nil
+}
+
+// verifyCertChain verifies the cert from the root cert through the cert chain, and returns the parsed cert.
+func verifyCertChain(certBytes, certChainBytes, rootCertBytes []byte) (*x509.Certificate, error) {
 	rcp := x509.NewCertPool()
 	rcp.AppendCertsFromPEM(rootCertBytes)
 
@@ -248,26 +313,27 @@ func Verify(certBytes, privKeyBytes, certChainBytes, rootCertBytes []byte) error
 	}
 	cert, err := ParsePemEncodedCertificate(certBytes)
 	if err != nil {
-		return fmt.Errorf("failed to parse cert PEM: %v", err)
+		return nil, fmt.Errorf("failed to parse cert PEM: %v", err)
 	}
 	chains, err := cert.Verify(opts)
 
 	if len(chains) == 0 || err != nil {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"cannot verify the cert with the provided root chain and cert "+
 				"pool with error: %v", err)
 	}
+	return cert, nil
+}
 
-	// Verify that the key can be correctly parsed.
-	if _, err = ParsePemEncodedKey(privKeyBytes); err != nil {
-		return fmt.Errorf("failed to parse private key PEM: %v", err)
+// verifyPublicKey verifies that the public key is the public key of the cert.
+func verifyPublicKey(cert *x509.Certificate, pub crypto.PublicKey) error {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the public key: %v", err)
 	}
-
-	// Verify the cert and key match.
-	if _, err := tls.X509KeyPair(certBytes, privKeyBytes); err != nil {
+	if !bytes.Equal(der, cert.RawSubjectPublicKeyInfo) {
 		return fmt.Errorf("the cert does not match the key")
 	}
-
 	return nil
 }
 
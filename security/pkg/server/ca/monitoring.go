@@ -51,6 +51,11 @@ var (
 		monitoring.WithLabels(errorTag),
 	)
 
+	revokedErrorCounts = monitoring.NewSum(
+		"citadel_server_revoked_err_count",
+		"The number of CSRs rejected because the caller certificate or identity is revoked.",
+	)
+
 	successCounts = monitoring.NewSum(
 		"citadel_server_success_cert_issuance_count",
 		"The number of certificates issuances that have succeeded.",
@@ -75,6 +80,7 @@ func init() {
 		csrParsingErrorCounts,
 		idExtractionErrorCounts,
 		certSignErrorCounts,
+		revokedErrorCounts,
 		successCounts,
 		rootCertExpiryTimestamp,
 		certChainExpiryTimestamp,
@@ -88,6 +94,7 @@ type monitoringMetrics struct {
 	Success           monitoring.Metric
 	CSRError          monitoring.Metric
 	IDExtractionError monitoring.Metric
+	RevokedError      monitoring.Metric
 	certSignErrors    monitoring.Metric
 }
 
@@ -99,6 +106,7 @@ func newMonitoringMetrics() monitoringMetrics {
 		Success:           successCounts,
 		CSRError:          csrParsingErrorCounts,
 		IDExtractionError: idExtractionErrorCounts,
+		RevokedError:      revokedErrorCounts,
 		certSignErrors:    certSignErrorCounts,
 	}
 }
@@ -18,6 +18,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
+	"math/big"
 	"net"
 	"time"
 
@@ -33,6 +34,7 @@ import (
 	"istio.io/pkg/log"
 
 	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/revocation"
 	"istio.io/istio/security/pkg/pki/util"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	pb "istio.io/istio/security/proto"
@@ -70,6 +72,10 @@ type Server struct {
 	port           int
 	forCA          bool
 	grpcServer     *grpc.Server
+
+	// Revocations, if set, are checked before issuing a certificate, and record the issued certificates so that they
+	// can be revoked by identity.
+	Revocations *revocation.Store
 }
 
 func getConnectionAddress(ctx context.Context) string {
@@ -98,6 +104,12 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 
 	// TODO: Call authorizer.
 
+	if s.isRevoked(ctx, caller) {
+		serverCaLog.Warnf("rejected CSR of revoked identities %v", caller.Identities)
+		s.monitoring.RevokedError.Increment()
+		return nil, status.Error(codes.PermissionDenied, "the caller certificate or identity is revoked")
+	}
+
 	_, _, certChainBytes, rootCertBytes := s.ca.GetCAKeyCertBundle().GetAll()
 	cert, signErr := s.ca.Sign(
 		[]byte(request.Csr), caller.Identities, time.Duration(request.ValidityDuration)*time.Second, false)
@@ -106,6 +118,13 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 		s.monitoring.GetCertSignError(signErr.(*caerror.Error).ErrorType()).Increment()
 		return nil, status.Errorf(signErr.(*caerror.Error).HTTPErrorCode(), "CSR signing error (%v)", signErr.(*caerror.Error))
 	}
+	if s.Revocations != nil {
+		if issued, err := util.ParsePemEncodedCertificate(cert); err == nil {
+			s.Revocations.RecordIssued(issued, caller.Identities)
+		} else {
+			serverCaLog.Errorf("failed to record the issued certificate: %v", err)
+		}
+	}
 	respCertChain := []string{string(cert)}
 	if len(certChainBytes) != 0 {
 		respCertChain = append(respCertChain, string(certChainBytes))
@@ -120,6 +139,25 @@ func (s *Server) CreateCertificate(ctx context.Context, request *pb.IstioCertifi
 	return response, nil
 }
 
+// isRevoked returns true if the caller authenticated with a revoked client certificate, or if one of its identities
+// is revoked.
+func (s *Server) isRevoked(ctx context.Context, caller *authenticate.Caller) bool {
+	if s.Revocations == nil {
+		return false
+	}
+	var serial *big.Int
+	if caller.AuthSource == authenticate.AuthSourceClientCertificate {
+		if p, ok := peer.FromContext(ctx); ok {
+			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				if chains := tlsInfo.State.VerifiedChains; len(chains) > 0 && len(chains[0]) > 0 {
+					serial = chains[0][0].SerialNumber
+				}
+			}
+		}
+	}
+	return s.Revocations.IsRevoked(serial, caller.Identities)
+}
+
 func recordCertsExpiry(keyCertBundle util.KeyCertBundle) {
 	rootCertExpiry, err := keyCertBundle.ExtractRootCertExpiryTimestamp()
 	if err != nil {
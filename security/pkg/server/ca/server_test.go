@@ -31,6 +31,7 @@ import (
 	"istio.io/istio/security/pkg/pki/ca"
 	mockca "istio.io/istio/security/pkg/pki/ca/mock"
 	caerror "istio.io/istio/security/pkg/pki/error"
+	"istio.io/istio/security/pkg/pki/revocation"
 	mockutil "istio.io/istio/security/pkg/pki/util/mock"
 	"istio.io/istio/security/pkg/server/ca/authenticate"
 	pb "istio.io/istio/security/proto"
@@ -75,6 +76,7 @@ func TestCreateCertificate(t *testing.T) {
 		authenticators []authenticate.Authenticator
 		ca             CertificateAuthority
 		certChain      []string
+		revoked        []string
 		code           codes.Code
 	}{
 		"No authenticator": {
@@ -121,6 +123,14 @@ func TestCreateCertificate(t *testing.T) {
 			certChain: []string{"cert", "cert_chain", "root_cert"},
 			code:      codes.OK,
 		},
+		"Revoked identity": {
+			authenticators: []authenticate.Authenticator{&mockAuthenticator{
+				identities: []string{"spiffe://cluster.local/ns/default/sa/revoked"},
+			}},
+			ca:      &mockca.FakeCA{SignedCert: []byte("cert")},
+			revoked: []string{"spiffe://cluster.local/ns/default/sa/revoked"},
+			code:    codes.PermissionDenied,
+		},
 	}
 
 	for id, c := range testCases {
@@ -131,6 +141,10 @@ func TestCreateCertificate(t *testing.T) {
 			Authenticators: c.authenticators,
 			monitoring:     newMonitoringMetrics(),
 		}
+		if c.revoked != nil {
+			server.Revocations = revocation.NewStore()
+			server.Revocations.SetList(&revocation.List{Serials: map[string]bool{}, Identities: toSet(c.revoked)})
+		}
 		request := &pb.IstioCertificateRequest{Csr: "dumb CSR"}
 
 		response, err := server.CreateCertificate(context.Background(), request)
@@ -154,6 +168,14 @@ func TestCreateCertificate(t *testing.T) {
 	}
 }
 
+func toSet(entries []string) map[string]bool {
+	set := map[string]bool{}
+	for _, e := range entries {
+		set[e] = true
+	}
+	return set
+}
+
 func TestShouldRefresh(t *testing.T) {
 	now := time.Now()
 	testCases := map[string]struct {
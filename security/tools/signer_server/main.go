@@ -0,0 +1,88 @@
+// Copyright Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Provide a local signer service standing in for an HSM or a KMS, to test istiod with CA_SIGNER.
+// The private key is read from a file: it is not protected, do not use it in production.
+//
+// The service listens on a unix domain socket only accessible to its user, or on TCP with mutual TLS if -listen
+// is set.
+
+package main
+
+import (
+	"flag"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"istio.io/istio/security/pkg/pki/signer"
+	"istio.io/pkg/log"
+)
+
+var (
+	keyFile = flag.String("key", "ca-key.pem", "The PEM encoded private key to sign with.")
+	socket  = flag.String("socket", "./signer.sock", "The unix domain socket to listen on, e.g. CA_SIGNER=unix://<socket>.")
+	listen  = flag.String("listen", "",
+		"The TCP address to listen on with mutual TLS instead of the socket, e.g. CA_SIGNER=grpc://<address>.")
+	certFile     = flag.String("cert", "", "The PEM encoded server certificate, with -listen.")
+	certKeyFile  = flag.String("cert-key", "", "The PEM encoded private key of the server certificate, with -listen.")
+	clientCAFile = flag.String("client-ca", "", "The PEM encoded CA bundle verifying the clients, with -listen.")
+)
+
+func fatalf(template string, args ...interface{}) {
+	log.Errorf(template, args...)
+	os.Exit(-1)
+}
+
+func main() {
+	flag.Parse()
+
+	s, err := signer.NewFileSigner(*keyFile)
+	if err != nil {
+		fatalf("Failed to load the private key: %v", err)
+	}
+
+	var lis net.Listener
+	var grpcServer *grpc.Server
+	if *listen != "" {
+		config, err := signer.ServerTLSConfig(*certFile, *certKeyFile, *clientCAFile)
+		if err != nil {
+			fatalf("Invalid TLS configuration: %v", err)
+		}
+		if lis, err = net.Listen("tcp", *listen); err != nil {
+			fatalf("Failed to listen on %s: %v", *listen, err)
+		}
+		grpcServer = grpc.NewServer(grpc.Creds(credentials.NewTLS(config)))
+		log.Infof("Signing with %s on grpc://%s", *keyFile, lis.Addr())
+	} else {
+		if err := os.Remove(*socket); err != nil && !os.IsNotExist(err) {
+			fatalf("Failed to remove the socket %s: %v", *socket, err)
+		}
+		if lis, err = net.Listen("unix", *socket); err != nil {
+			fatalf("Failed to listen on %s: %v", *socket, err)
+		}
+		// The socket is not authenticated, only the user of the signer and the CA may connect.
+		if err := os.Chmod(*socket, 0600); err != nil {
+			fatalf("Failed to restrict the permissions of %s: %v", *socket, err)
+		}
+		grpcServer = grpc.NewServer()
+		log.Infof("Signing with %s on unix://%s", *keyFile, *socket)
+	}
+	signer.NewServer(s).Register(grpcServer)
+	if err := grpcServer.Serve(lis); err != nil {
+		fatalf("Failed to serve: %v", err)
+	}
+}